@@ -70,10 +70,30 @@ const (
  */
 const (
 	ATA_USING_LBA = (1 << 6)
+	ATA_STAT_DRDY = (1 << 6)
+	ATA_STAT_DF   = (1 << 5)
 	ATA_STAT_DRQ  = (1 << 3)
 	ATA_STAT_ERR  = (1 << 0)
 )
 
+/*
+ * ERROR register bits
+ */
+const (
+	ATA_ERR_UNC  = (1 << 6)
+	ATA_ERR_IDNF = (1 << 4)
+	ATA_ERR_ABRT = (1 << 2)
+)
+
+/*
+ * CHECK POWER MODE: COUNT field values
+ */
+const (
+	ATA_POWER_MODE_STANDBY = 0x00
+	ATA_POWER_MODE_IDLE    = 0x80
+	ATA_POWER_MODE_ACTIVE  = 0xff
+)
+
 /*
  * Useful parameters for initHdioTaskfile():
  */
@@ -96,6 +116,8 @@ const (
 	SMART_FEAT_EXECUTE_OFFLINE_IMMEDIATE = 0xd4
 	SMART_FEAT_READ_LOG                  = 0xd5
 	SMART_FEAT_WRITE_LOG                 = 0xd6
+	SMART_FEAT_ENABLE_OPERATIONS         = 0xd8
+	SMART_FEAT_DISABLE_OPERATIONS        = 0xd9
 	SMART_FEAT_RETURN_STATUS             = 0xda
 )
 
@@ -266,7 +288,48 @@ type IdentityWord127 struct {
 
 type IdentitySecurityStatus struct {
 	A uint16 `struc:"uint16"`
+	//SecuritySupported: 1
+	//SecurityEnabled: 1
+	//SecurityLocked: 1
+	//SecurityFrozen: 1
+	//SecurityCountExpired: 1
+	//EnhancedSecurityEraseSupported: 1
+	//Reserved0: 2
+	//SecurityLevel: 1
+	//Reserved1: 7
 }
+
+func (s *IdentitySecurityStatus) IsSupported() bool {
+	return s.A&0x0001 != 0
+}
+
+func (s *IdentitySecurityStatus) IsEnabled() bool {
+	return s.A&0x0002 != 0
+}
+
+func (s *IdentitySecurityStatus) IsLocked() bool {
+	return s.A&0x0004 != 0
+}
+
+func (s *IdentitySecurityStatus) IsFrozen() bool {
+	return s.A&0x0008 != 0
+}
+
+func (s *IdentitySecurityStatus) IsCountExpired() bool {
+	return s.A&0x0010 != 0
+}
+
+func (s *IdentitySecurityStatus) IsEnhancedEraseSupported() bool {
+	return s.A&0x0020 != 0
+}
+
+// IsLevelMaximum
+//   - true: Maximum, the master password can not unlock the device
+//   - false: High
+func (s *IdentitySecurityStatus) IsLevelMaximum() bool {
+	return s.A&0x0100 != 0
+}
+
 type IdentityCfgPowerMode1 struct {
 	A uint16 `struc:"uint16"`
 }
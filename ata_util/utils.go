@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"github.com/jc-lab/go-dparm/ata"
 	"github.com/jc-lab/go-dparm/common"
+	"github.com/jc-lab/go-dparm/internal"
 	"github.com/lunixbochs/struc"
 )
 
@@ -137,7 +138,7 @@ func ReadSmart(handle common.DriveHandle, timeoutSecs int) (*ata.SmartAttributeV
 	}, buffer[:], timeoutSecs); err != nil {
 		return nil, nil, err
 	}
-	if err := struc.Unpack(bytes.NewReader(buffer[:]), smartAttributesValues); err != nil {
+	if err := struc.UnpackWithOptions(bytes.NewReader(buffer[:]), smartAttributesValues, internal.GetStrucOptions()); err != nil {
 		return nil, nil, err
 	}
 
@@ -152,7 +153,7 @@ func ReadSmart(handle common.DriveHandle, timeoutSecs int) (*ata.SmartAttributeV
 	}, buffer[:], timeoutSecs); err != nil {
 		return nil, nil, err
 	}
-	if err := struc.Unpack(bytes.NewReader(buffer[:]), smartAttributeThresholds); err != nil {
+	if err := struc.UnpackWithOptions(bytes.NewReader(buffer[:]), smartAttributeThresholds, internal.GetStrucOptions()); err != nil {
 		return nil, nil, err
 	}
 
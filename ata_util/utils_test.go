@@ -2,6 +2,8 @@ package ata_util
 
 import (
 	"github.com/jc-lab/go-dparm/ata"
+	"github.com/jc-lab/go-dparm/common"
+	"github.com/jc-lab/go-dparm/test/ata_sim"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -63,3 +65,31 @@ func TestTfInit(t *testing.T) {
 		})
 	}
 }
+
+func TestReadSmart(t *testing.T) {
+	drive, err := ata_sim.NewDrive(ata_sim.DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle := &common.DriveHandleImpl{Dh: drive}
+	if err := handle.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer handle.Close()
+
+	drive.SetSmartAttribute(9, 99, 4321)
+
+	values, thresholds, err := ReadSmart(handle, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, uint16(0x0010), values.RevNumber)
+	assert.Equal(t, uint8(5), values.Attributes[0].Id)
+	assert.Equal(t, uint16(0x0033), values.Attributes[0].Flags)
+	assert.Equal(t, uint8(9), values.Attributes[1].Id)
+	assert.Equal(t, uint8(99), values.Attributes[1].Current)
+	assert.Equal(t, [6]uint8{0xe1, 0x10, 0, 0, 0, 0}, values.Attributes[1].Raw)
+	assert.Equal(t, uint8(5), thresholds.Attributes[0].Id)
+	assert.Equal(t, uint8(10), thresholds.Attributes[0].Threshold)
+}
@@ -2,7 +2,6 @@ package common
 
 import (
 	"errors"
-	"fmt"
 	"strings"
 
 	"github.com/jc-lab/go-dparm/ata"
@@ -69,7 +68,8 @@ func (p *DriveHandleImpl) Init() error {
 		p.Info.NvmeIdentityRaw = identityRaw
 
 		identity := &nvme.IdentifyController{}
-		if err := struc.Unpack(internal.NewWrappedBuffer(identityRaw), identity); err != nil {
+		strucOpts := internal.GetStrucOptions()
+		if err := struc.UnpackWithOptions(internal.NewWrappedBuffer(identityRaw), identity, strucOpts); err != nil {
 			return err
 		}
 		p.Info.NvmeIdentity = identity
@@ -186,55 +186,11 @@ func (p *DriveHandleImpl) TcgDiscovery0() error {
 
 	p.Info.TcgSupport = 1
 
-	alignedBuffer.ResetRead()
-	header := &tcg.Discovery0Header{}
-	headerSize, err := struc.Sizeof(header)
+	discovery, err := tcg.ParseLevel0Discovery(alignedBuffer.GetBuffer())
 	if err != nil {
 		return err
 	}
-	if err := struc.Unpack(alignedBuffer, header); err != nil {
-		return err
-	}
-	bufferRef := alignedBuffer.GetBuffer()
-
-	if len(bufferRef) < int(header.Length) {
-		return fmt.Errorf("invalid data: length overflow")
-	}
-
-	offset := headerSize
-	currentUnion := tcg.Discovery0FeatureUnion{}
-	for offset < int(header.Length) {
-		copy(currentUnion.Buffer[:], bufferRef[offset:])
-		basic, err := currentUnion.ToBasic()
-		if err != nil {
-			return err
-		}
-
-		itemBuffer := bufferRef[offset : offset+int(basic.Length)+4]
-		fc := basic.FeatureCode
-
-		switch fc {
-		case tcg.FcTPer:
-			p.Info.TcgTper = true
-		case tcg.FcLocking:
-			p.Info.TcgLocking = true
-		case tcg.FcGeometryReporting:
-			p.Info.TcgGeometryReporting = true
-		case tcg.FcOpalSscV100:
-			p.Info.TcgOpalSscV100 = true
-		case tcg.FcOpalSscV200:
-			p.Info.TcgOpalSscV200 = true
-		case tcg.FcEnterprise:
-			p.Info.TcgEnterprise = true
-		case tcg.FcSingleUser:
-			p.Info.TcgSingleUser = true
-		case tcg.FcDataStore:
-			p.Info.TcgDataStore = true
-		}
-
-		p.Info.TcgRawFeatures[uint16(fc)] = itemBuffer
-		offset += int(basic.Length) + 4
-	}
+	p.Info.TcgLevel0Info = discovery.Level0Info()
 
 	return nil
 }
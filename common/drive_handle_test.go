@@ -0,0 +1,438 @@
+package common_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/jc-lab/go-dparm/ata"
+	"github.com/jc-lab/go-dparm/common"
+	"github.com/jc-lab/go-dparm/nvme"
+	"github.com/jc-lab/go-dparm/tcg"
+	"github.com/jc-lab/go-dparm/test/ata_sim"
+	"github.com/jc-lab/go-dparm/test/nvme_sim"
+	"github.com/jc-lab/go-dparm/test/tcg_sim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testAtaSimHandle(t *testing.T, config ata_sim.Config) (*common.DriveHandleImpl, *ata_sim.Drive) {
+	config.Path = t.TempDir() + "/drive.img"
+	drive, err := ata_sim.NewDrive(config)
+	require.NoError(t, err)
+
+	handle := &common.DriveHandleImpl{Dh: drive}
+	require.NoError(t, handle.Init())
+	t.Cleanup(handle.Close)
+
+	return handle, drive
+}
+
+func lba48Tf(op ata.OpCode, lba uint64, count uint16) *ata.Tf {
+	return &ata.Tf{
+		Command: op,
+		Dev:     ata.ATA_USING_LBA,
+		IsLba48: 1,
+		Lob:     ata.LbaRegs{Nsect: uint8(count), Lbal: uint8(lba), Lbam: uint8(lba >> 8), Lbah: uint8(lba >> 16)},
+		Hob:     ata.LbaRegs{Nsect: uint8(count >> 8), Lbal: uint8(lba >> 24), Lbam: uint8(lba >> 32), Lbah: uint8(lba >> 40)},
+	}
+}
+
+func securityData(master bool, password string) []byte {
+	data := make([]byte, 512)
+	if master {
+		binary.LittleEndian.PutUint16(data[0:], 0x0001)
+	}
+	copy(data[2:34], password)
+	return data
+}
+
+func identity(t *testing.T, handle *common.DriveHandleImpl) *ata.IdentityDeviceData {
+	require.NoError(t, handle.Init())
+	return handle.GetDriveInfo().AtaIdentity
+}
+
+func TestAtaSimIdentify(t *testing.T) {
+	handle, _ := testAtaSimHandle(t, ata_sim.DefaultConfig())
+	info := handle.GetDriveInfo()
+
+	assert.Equal(t, "GO-DPARM ATA SIMULATOR", info.Model)
+	assert.Equal(t, "SIMATA0000000001", info.Serial)
+	assert.Equal(t, "1.0", info.FirmwareRevision)
+	assert.True(t, info.SmartEnabled)
+	assert.True(t, info.IsSsd)
+	assert.Equal(t, uint64(0x200000), info.AtaIdentity.Max48bitLba)
+	assert.True(t, info.AtaIdentity.SecurityStatus.IsSupported())
+	assert.False(t, info.AtaIdentity.SecurityStatus.IsEnabled())
+	assert.True(t, info.AtaIdentity.Word59.IsSanitizeFeatureSetSupported())
+	assert.Equal(t, uint8(0xa5), info.AtaIdentityRaw[510])
+}
+
+func TestAtaSimReadWriteAndHpa(t *testing.T) {
+	handle, drive := testAtaSimHandle(t, ata_sim.DefaultConfig())
+
+	data := make([]byte, 2*512)
+	copy(data, "hello")
+	tf := lba48Tf(ata.ATA_OP_WRITE_DMA_EXT, 100, 2)
+	require.NoError(t, handle.AtaDoTaskFileCmd(true, true, tf, data, 3))
+	assert.Zero(t, tf.Status&ata.ATA_STAT_ERR)
+
+	read := make([]byte, 2*512)
+	tf = lba48Tf(ata.ATA_OP_READ_DMA_EXT, 100, 2)
+	require.NoError(t, handle.AtaDoTaskFileCmd(false, true, tf, read, 3))
+	assert.Equal(t, data, read)
+
+	// SET MAX ADDRESS is aborted unless preceded by READ NATIVE MAX ADDRESS
+	tf = lba48Tf(ata.ATA_OP_SET_MAX_EXT, 0xfffff, 0)
+	require.NoError(t, handle.AtaDoTaskFileCmd(false, false, tf, nil, 3))
+	assert.Equal(t, uint8(ata.ATA_ERR_ABRT), tf.Error)
+
+	tf = lba48Tf(ata.ATA_OP_READ_NATIVE_MAX_EXT, 0, 0)
+	require.NoError(t, handle.AtaDoTaskFileCmd(false, false, tf, nil, 3))
+	assert.Equal(t, uint8(0xff), tf.Lob.Lbal)
+	assert.Equal(t, uint8(0xff), tf.Lob.Lbam)
+	assert.Equal(t, uint8(0x1f), tf.Lob.Lbah)
+
+	tf = lba48Tf(ata.ATA_OP_SET_MAX_EXT, 0xfffff, 0)
+	require.NoError(t, handle.AtaDoTaskFileCmd(false, false, tf, nil, 3))
+	assert.Zero(t, tf.Status&ata.ATA_STAT_ERR)
+	assert.Equal(t, uint64(0x100000), identity(t, handle).Max48bitLba)
+
+	tf = lba48Tf(ata.ATA_OP_READ_DMA_EXT, 0x100000, 1)
+	require.NoError(t, handle.AtaDoTaskFileCmd(false, true, tf, read, 3))
+	assert.NotZero(t, tf.Status&ata.ATA_STAT_ERR)
+	assert.Equal(t, uint8(ata.ATA_ERR_IDNF), tf.Error)
+
+	// the max address was volatile
+	drive.PowerCycle()
+	assert.Equal(t, uint64(0x200000), identity(t, handle).Max48bitLba)
+}
+
+func TestAtaSimSecurity(t *testing.T) {
+	config := ata_sim.DefaultConfig()
+	config.MasterPassword = "master"
+	handle, drive := testAtaSimHandle(t, config)
+
+	data := make([]byte, 512)
+	copy(data, "user data")
+	require.NoError(t, handle.AtaDoTaskFileCmd(true, true, lba48Tf(ata.ATA_OP_WRITE_DMA_EXT, 0, 1), data, 3))
+
+	tf := &ata.Tf{Command: ata.ATA_OP_SECURITY_SET_PASS}
+	require.NoError(t, handle.AtaDoTaskFileCmd(true, false, tf, securityData(false, "user"), 3))
+	assert.Zero(t, tf.Status&ata.ATA_STAT_ERR)
+	status := identity(t, handle).SecurityStatus
+	assert.True(t, status.IsEnabled())
+	assert.False(t, status.IsLocked())
+
+	drive.PowerCycle()
+	assert.True(t, identity(t, handle).SecurityStatus.IsLocked())
+
+	read := make([]byte, 512)
+	tf = lba48Tf(ata.ATA_OP_READ_DMA_EXT, 0, 1)
+	require.NoError(t, handle.AtaDoTaskFileCmd(false, true, tf, read, 3))
+	assert.Equal(t, uint8(ata.ATA_ERR_ABRT), tf.Error)
+
+	for i := 0; i < 5; i++ {
+		tf = &ata.Tf{Command: ata.ATA_OP_SECURITY_UNLOCK}
+		require.NoError(t, handle.AtaDoTaskFileCmd(true, false, tf, securityData(false, "wrong"), 3))
+		assert.Equal(t, uint8(ata.ATA_ERR_ABRT), tf.Error)
+	}
+	assert.True(t, identity(t, handle).SecurityStatus.IsCountExpired())
+
+	tf = &ata.Tf{Command: ata.ATA_OP_SECURITY_UNLOCK}
+	require.NoError(t, handle.AtaDoTaskFileCmd(true, false, tf, securityData(false, "user"), 3))
+	assert.Equal(t, uint8(ata.ATA_ERR_ABRT), tf.Error)
+
+	drive.PowerCycle()
+	tf = &ata.Tf{Command: ata.ATA_OP_SECURITY_UNLOCK}
+	require.NoError(t, handle.AtaDoTaskFileCmd(true, false, tf, securityData(true, "master"), 3))
+	assert.Zero(t, tf.Status&ata.ATA_STAT_ERR)
+	assert.False(t, identity(t, handle).SecurityStatus.IsLocked())
+
+	tf = &ata.Tf{Command: ata.ATA_OP_SECURITY_FREEZE_LOCK}
+	require.NoError(t, handle.AtaDoTaskFileCmd(false, false, tf, nil, 3))
+	assert.True(t, identity(t, handle).SecurityStatus.IsFrozen())
+	tf = &ata.Tf{Command: ata.ATA_OP_SECURITY_ERASE_PREPARE}
+	require.NoError(t, handle.AtaDoTaskFileCmd(false, false, tf, nil, 3))
+	assert.Equal(t, uint8(ata.ATA_ERR_ABRT), tf.Error)
+
+	drive.PowerCycle()
+	tf = &ata.Tf{Command: ata.ATA_OP_SECURITY_UNLOCK}
+	require.NoError(t, handle.AtaDoTaskFileCmd(true, false, tf, securityData(false, "user"), 3))
+	assert.Zero(t, tf.Status&ata.ATA_STAT_ERR)
+
+	// ERASE UNIT has to follow ERASE PREPARE immediately
+	tf = &ata.Tf{Command: ata.ATA_OP_SECURITY_ERASE_UNIT}
+	require.NoError(t, handle.AtaDoTaskFileCmd(true, false, tf, securityData(false, "user"), 3))
+	assert.Equal(t, uint8(ata.ATA_ERR_ABRT), tf.Error)
+
+	require.NoError(t, handle.AtaDoTaskFileCmd(false, false, &ata.Tf{Command: ata.ATA_OP_SECURITY_ERASE_PREPARE}, nil, 3))
+	tf = &ata.Tf{Command: ata.ATA_OP_SECURITY_ERASE_UNIT}
+	require.NoError(t, handle.AtaDoTaskFileCmd(true, false, tf, securityData(false, "user"), 3))
+	assert.Zero(t, tf.Status&ata.ATA_STAT_ERR)
+	assert.False(t, identity(t, handle).SecurityStatus.IsEnabled())
+
+	tf = lba48Tf(ata.ATA_OP_READ_DMA_EXT, 0, 1)
+	require.NoError(t, handle.AtaDoTaskFileCmd(false, true, tf, read, 3))
+	assert.Equal(t, make([]byte, 512), read)
+}
+
+func TestAtaSimSanitize(t *testing.T) {
+	handle, _ := testAtaSimHandle(t, ata_sim.DefaultConfig())
+
+	tf := lba48Tf(ata.ATA_OP_SANITIZE, 0x12345678, 0)
+	tf.Lob.Feat = uint8(ata.SANITIZE_BLOCK_ERASE_EXT)
+	require.NoError(t, handle.AtaDoTaskFileCmd(false, false, tf, nil, 3))
+	assert.Equal(t, uint8(ata.ATA_ERR_ABRT), tf.Error)
+
+	tf = lba48Tf(ata.ATA_OP_SANITIZE, uint64(ata.SANITIZE_BLOCK_ERASE_KEY), 0)
+	tf.Lob.Feat = uint8(ata.SANITIZE_BLOCK_ERASE_EXT)
+	require.NoError(t, handle.AtaDoTaskFileCmd(false, false, tf, nil, 3))
+	assert.Zero(t, tf.Status&ata.ATA_STAT_ERR)
+
+	tf = lba48Tf(ata.ATA_OP_SANITIZE, uint64(ata.SANITIZE_FREEZE_LOCK_KEY), 0)
+	tf.Lob.Feat = uint8(ata.SANITIZE_FREEZE_LOCK_EXT)
+	require.NoError(t, handle.AtaDoTaskFileCmd(false, false, tf, nil, 3))
+
+	tf = lba48Tf(ata.ATA_OP_SANITIZE, 0, 0)
+	tf.Lob.Feat = uint8(ata.SANITIZE_STATUS_EXT)
+	require.NoError(t, handle.AtaDoTaskFileCmd(false, false, tf, nil, 3))
+	assert.Equal(t, uint8(ata.SANITIZE_FLAG_OPERATION_SUCCEEDED|ata.SANITIZE_FLAG_DEVICE_IN_FROZEN), tf.Hob.Nsect)
+}
+
+func TestAtaSimPowerModeAndScript(t *testing.T) {
+	handle, drive := testAtaSimHandle(t, ata_sim.DefaultConfig())
+
+	require.NoError(t, handle.AtaDoTaskFileCmd(false, false, &ata.Tf{Command: ata.ATA_OP_STANDBYNOW1}, nil, 3))
+	tf := &ata.Tf{Command: ata.ATA_OP_CHECKPOWERMODE1}
+	require.NoError(t, handle.AtaDoTaskFileCmd(false, false, tf, nil, 3))
+	assert.Equal(t, uint8(ata.ATA_POWER_MODE_STANDBY), tf.Lob.Nsect)
+
+	drive.On(ata.ATA_OP_CHECKPOWERMODE1, func(rw bool, dma bool, tf *ata.Tf, data []byte) (bool, error) {
+		ata_sim.Fail(tf, ata.ATA_ERR_ABRT)
+		return true, nil
+	})
+	tf = &ata.Tf{Command: ata.ATA_OP_CHECKPOWERMODE1}
+	require.NoError(t, handle.AtaDoTaskFileCmd(false, false, tf, nil, 3))
+	assert.Equal(t, uint8(ata.ATA_ERR_ABRT), tf.Error)
+
+	drive.On(ata.ATA_OP_CHECKPOWERMODE1, nil)
+	require.NoError(t, handle.AtaDoTaskFileCmd(false, false, &ata.Tf{Command: ata.ATA_OP_SLEEPNOW1}, nil, 3))
+	tf = &ata.Tf{Command: ata.ATA_OP_CHECKPOWERMODE1}
+	require.NoError(t, handle.AtaDoTaskFileCmd(false, false, tf, nil, 3))
+	assert.Equal(t, uint8(ata.ATA_ERR_ABRT), tf.Error)
+
+	drive.PowerCycle()
+	tf = &ata.Tf{Command: ata.ATA_OP_CHECKPOWERMODE1}
+	require.NoError(t, handle.AtaDoTaskFileCmd(false, false, tf, nil, 3))
+	assert.Equal(t, uint8(ata.ATA_POWER_MODE_ACTIVE), tf.Lob.Nsect)
+}
+
+func testNvmeSimHandle(t *testing.T, config nvme_sim.Config) (*common.DriveHandleImpl, *nvme_sim.Controller) {
+	controller := nvme_sim.NewController(config)
+
+	handle := &common.DriveHandleImpl{Dh: controller}
+	require.NoError(t, handle.Init())
+	t.Cleanup(handle.Close)
+
+	return handle, controller
+}
+
+func nvmeAdmin(handle *common.DriveHandleImpl, cmd *nvme.NvmeAdminCmd) error {
+	return handle.GetDriverHandle().(common.NvmeDriverHandle).DoNvmeAdminPassthru(cmd)
+}
+
+func requireNvmeStatus(t *testing.T, expected nvme.StatusCode, err error) {
+	status, ok := nvme.StatusCodeOf(err)
+	require.True(t, ok, "expected status %#x, got %v", uint16(expected), err)
+	assert.Equal(t, expected, status)
+}
+
+func TestNvmeSimIdentify(t *testing.T) {
+	config := nvme_sim.DefaultConfig()
+	handle, _ := testNvmeSimHandle(t, config)
+	info := handle.GetDriveInfo()
+
+	assert.Equal(t, "GO-DPARM NVME SIMULATOR", info.Model)
+	assert.Equal(t, "SIMNVME000000001", info.Serial)
+	assert.Equal(t, "1.0", info.FirmwareRevision)
+	assert.True(t, info.IsSsd)
+	assert.Equal(t, config.VendorId, info.NvmeIdentity.Vid)
+	assert.Equal(t, uint32(1), info.NvmeIdentity.Nn)
+	assert.Equal(t, uint32(0x7), info.NvmeIdentity.Sanicap)
+	assert.Equal(t, 0, info.TcgSupport)
+
+	data := make([]byte, 4096)
+	require.NoError(t, nvmeAdmin(handle, &nvme.NvmeAdminCmd{
+		Opcode:     uint8(nvme.NVME_ADMIN_OP_IDENTIFY),
+		Nsid:       1,
+		DataBuffer: data,
+		DataLen:    uint32(len(data)),
+	}))
+	assert.Equal(t, uint64(0x200000), binary.LittleEndian.Uint64(data[0:]))
+	assert.Equal(t, uint8(1), data[25])
+	assert.Equal(t, uint8(12), data[128+4+2])
+
+	requireNvmeStatus(t, nvme.NVME_SC_INVALID_NS, nvmeAdmin(handle, &nvme.NvmeAdminCmd{
+		Opcode:     uint8(nvme.NVME_ADMIN_OP_IDENTIFY),
+		Nsid:       2,
+		DataBuffer: data,
+		DataLen:    uint32(len(data)),
+	}))
+}
+
+func TestNvmeSimFeatures(t *testing.T) {
+	handle, controller := testNvmeSimHandle(t, nvme_sim.DefaultConfig())
+
+	getFeature := func(fid uint32, sel uint32) uint32 {
+		cmd := &nvme.NvmeAdminCmd{Opcode: uint8(nvme.NVME_ADMIN_OP_GET_FEATURES), Cdw10: fid | sel<<8}
+		require.NoError(t, nvmeAdmin(handle, cmd))
+		return cmd.Result
+	}
+	setFeature := func(fid uint32, value uint32, save bool) error {
+		cmd := &nvme.NvmeAdminCmd{Opcode: uint8(nvme.NVME_ADMIN_OP_SET_FEATURES), Cdw10: fid, Cdw11: value}
+		if save {
+			cmd.Cdw10 |= 0x80000000
+		}
+		return nvmeAdmin(handle, cmd)
+	}
+
+	// volatile write cache
+	assert.Equal(t, uint32(1), getFeature(0x06, 0))
+	require.NoError(t, setFeature(0x06, 0, false))
+	assert.Equal(t, uint32(0), getFeature(0x06, 0))
+
+	// temperature threshold, saved across a power cycle
+	require.NoError(t, setFeature(0x04, 350, true))
+	assert.Equal(t, uint32(350), getFeature(0x04, 2))
+	assert.Equal(t, uint32(343), getFeature(0x04, 1))
+
+	controller.PowerCycle()
+	assert.Equal(t, uint32(1), getFeature(0x06, 0))
+	assert.Equal(t, uint32(350), getFeature(0x04, 0))
+
+	// number of queues is not saveable and may only be set once
+	requireNvmeStatus(t, nvme.NVME_SC_FEATURE_NOT_SAVEABLE, setFeature(0x07, 0x00030003, true))
+	require.NoError(t, setFeature(0x07, 0x00030003, false))
+	requireNvmeStatus(t, nvme.NVME_SC_CMD_SEQ_ERROR, setFeature(0x07, 0x00030003, false))
+}
+
+func TestNvmeSimFormatAndSanitize(t *testing.T) {
+	config := nvme_sim.DefaultConfig()
+	config.SanitizePolls = 2
+	handle, _ := testNvmeSimHandle(t, config)
+
+	requireNvmeStatus(t, nvme.NVME_SC_INVALID_FORMAT, nvmeAdmin(handle, &nvme.NvmeAdminCmd{
+		Opcode: uint8(nvme.NVME_ADMIN_OP_FORMAT_NVM),
+		Nsid:   1,
+		Cdw10:  5,
+	}))
+	require.NoError(t, nvmeAdmin(handle, &nvme.NvmeAdminCmd{
+		Opcode: uint8(nvme.NVME_ADMIN_OP_FORMAT_NVM),
+		Nsid:   1,
+		Cdw10:  1,
+	}))
+
+	data := make([]byte, 4096)
+	require.NoError(t, nvmeAdmin(handle, &nvme.NvmeAdminCmd{
+		Opcode:     uint8(nvme.NVME_ADMIN_OP_IDENTIFY),
+		Nsid:       1,
+		DataBuffer: data,
+		DataLen:    uint32(len(data)),
+	}))
+	assert.Equal(t, uint64(0x200000>>3), binary.LittleEndian.Uint64(data[0:]))
+	assert.Equal(t, uint8(1), data[26])
+
+	require.NoError(t, nvmeAdmin(handle, &nvme.NvmeAdminCmd{
+		Opcode: uint8(nvme.NVME_ADMIN_OP_SANITIZE_NVM),
+		Cdw10:  uint32(nvme.NVME_SANITIZE_ACT_CRYPTO_ERASE),
+	}))
+	requireNvmeStatus(t, nvme.NVME_SC_SANITIZE_IN_PROGRESS, nvmeAdmin(handle, &nvme.NvmeAdminCmd{
+		Opcode: uint8(nvme.NVME_ADMIN_OP_SANITIZE_NVM),
+		Cdw10:  uint32(nvme.NVME_SANITIZE_ACT_CRYPTO_ERASE),
+	}))
+
+	log, err := handle.NvmeGetLogPage(0, uint32(nvme.NVME_GET_LOG_PAGE_SANITIZE_STATUS), false, 512)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(nvme.NVME_SANITIZE_LOG_IN_PROGESS), binary.LittleEndian.Uint16(log[2:])&uint16(nvme.NVME_SANITIZE_LOG_STATUS_MASK))
+	assert.Equal(t, uint16(0x8000), binary.LittleEndian.Uint16(log[0:]))
+
+	log, err = handle.NvmeGetLogPage(0, uint32(nvme.NVME_GET_LOG_PAGE_SANITIZE_STATUS), false, 512)
+	require.NoError(t, err)
+	sstat := binary.LittleEndian.Uint16(log[2:])
+	assert.Equal(t, uint16(nvme.NVME_SANITIZE_LOG_COMPLETED_SUCCESS), sstat&uint16(nvme.NVME_SANITIZE_LOG_STATUS_MASK))
+	assert.NotZero(t, sstat&uint16(nvme.NVME_SANITIZE_LOG_GLOBAL_DATA_ERASED))
+	assert.Equal(t, uint16(0xffff), binary.LittleEndian.Uint16(log[0:]))
+}
+
+func TestNvmeSimFirmware(t *testing.T) {
+	handle, controller := testNvmeSimHandle(t, nvme_sim.DefaultConfig())
+
+	image := make([]byte, 4096)
+	copy(image, "2.0")
+	for offset := 0; offset < len(image); offset += 1024 {
+		require.NoError(t, nvmeAdmin(handle, &nvme.NvmeAdminCmd{
+			Opcode:     uint8(nvme.NVME_ADMIN_OP_DOWNLOAD_FW),
+			DataBuffer: image[offset : offset+1024],
+			DataLen:    1024,
+			Cdw10:      1024/4 - 1,
+			Cdw11:      uint32(offset / 4),
+		}))
+	}
+
+	commit := func(slot uint32, action uint32) error {
+		return nvmeAdmin(handle, &nvme.NvmeAdminCmd{
+			Opcode: uint8(nvme.NVME_ADMIN_OP_ACTIVATE_FW),
+			Cdw10:  slot | action<<3,
+		})
+	}
+	requireNvmeStatus(t, nvme.NVME_SC_FIRMWARE_SLOT, commit(1, 1))
+	require.NoError(t, commit(2, 1))
+	requireNvmeStatus(t, nvme.NVME_SC_FIRMWARE_IMAGE, commit(3, 0))
+
+	log, err := handle.NvmeGetLogPage(0, uint32(nvme.NVME_GET_LOG_PAGE_FIRMWARE_SLOT_INFO), false, 512)
+	require.NoError(t, err)
+	assert.Equal(t, uint8(0x21), log[0])
+	assert.Equal(t, "1.0     ", string(log[8:16]))
+	assert.Equal(t, "2.0     ", string(log[16:24]))
+
+	controller.PowerCycle()
+	require.NoError(t, handle.Init())
+	assert.Equal(t, "2.0", handle.GetDriveInfo().FirmwareRevision)
+
+	log, err = handle.NvmeGetLogPage(0, uint32(nvme.NVME_GET_LOG_PAGE_FIRMWARE_SLOT_INFO), false, 512)
+	require.NoError(t, err)
+	assert.Equal(t, uint8(0x02), log[0])
+
+	// immediate activation is not supported by default, it falls back to the next reset
+	image = []byte("3.0     ")
+	require.NoError(t, nvmeAdmin(handle, &nvme.NvmeAdminCmd{
+		Opcode:     uint8(nvme.NVME_ADMIN_OP_DOWNLOAD_FW),
+		DataBuffer: image,
+		DataLen:    uint32(len(image)),
+		Cdw10:      1,
+	}))
+	requireNvmeStatus(t, nvme.NVME_SC_FW_NEEDS_CONV_RESET, commit(3, 3))
+	require.NoError(t, handle.Init())
+	assert.Equal(t, "2.0", handle.GetDriveInfo().FirmwareRevision)
+
+	controller.PowerCycle()
+	require.NoError(t, handle.Init())
+	assert.Equal(t, "3.0", handle.GetDriveInfo().FirmwareRevision)
+}
+
+func TestNvmeSimSecurityCommand(t *testing.T) {
+	config := nvme_sim.DefaultConfig()
+	config.Security = tcg_sim.NewTPer(tcg_sim.DefaultConfig())
+	handle, _ := testNvmeSimHandle(t, config)
+	info := handle.GetDriveInfo()
+
+	assert.Equal(t, 1, info.TcgSupport)
+	assert.True(t, info.TcgOpalSscV200)
+	assert.True(t, info.TcgLocking)
+
+	device, err := tcg.NewTcgDevice(handle)
+	require.NoError(t, err)
+	msid, err := device.GetDefaultPassword()
+	require.NoError(t, err)
+	assert.Equal(t, tcg_sim.DefaultConfig().MSID, msid)
+}
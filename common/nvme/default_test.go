@@ -0,0 +1,57 @@
+package common_nvme_test
+
+import (
+	"testing"
+
+	common_nvme "github.com/jc-lab/go-dparm/common/nvme"
+	"github.com/jc-lab/go-dparm/internal"
+	"github.com/jc-lab/go-dparm/nvme"
+	"github.com/jc-lab/go-dparm/test/nvme_sim"
+	"github.com/lunixbochs/struc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNvmeGetLogPageByAdminPassthru(t *testing.T) {
+	controller := nvme_sim.NewController(nvme_sim.DefaultConfig())
+	controller.UpdateSmartLog(func(log *nvme.SmartLogPage) {
+		log.CompositeTemperature = 0x0141
+		log.PercentageUsed = 7
+	})
+
+	var sent *nvme.NvmeAdminCmd
+	controller.On(nvme.NVME_ADMIN_OP_GET_LOG_PAGE, func(cmd *nvme.NvmeAdminCmd) (bool, error) {
+		sent = cmd
+		return false, nil
+	})
+
+	data, err := common_nvme.NvmeGetLogPageByAdminPassthru(controller, 0xffffffff, uint32(nvme.NVME_GET_LOG_PAGE_SMART), false, 512)
+	require.NoError(t, err)
+	require.Len(t, data, 512)
+
+	require.NotNil(t, sent)
+	assert.Equal(t, uint32(0x007f0002), sent.Cdw10)
+	assert.Equal(t, uint32(0xffffffff), sent.Nsid)
+
+	smart := &nvme.SmartLogPage{}
+	require.NoError(t, struc.UnpackWithOptions(internal.NewWrappedBuffer(data), smart, internal.GetStrucOptions()))
+	assert.Equal(t, uint16(0x0141), smart.CompositeTemperature)
+	assert.Equal(t, uint8(7), smart.PercentageUsed)
+	assert.Equal(t, uint8(100), smart.AvailableSpare)
+}
+
+func TestNvmeGetLogPageByAdminPassthruStatus(t *testing.T) {
+	controller := nvme_sim.NewController(nvme_sim.DefaultConfig())
+
+	_, err := common_nvme.NvmeGetLogPageByAdminPassthru(controller, 0, 0x7f, false, 512)
+	require.Error(t, err)
+	status, ok := nvme.StatusCodeOf(err)
+	assert.True(t, ok)
+	assert.Equal(t, nvme.NVME_SC_INVALID_LOG_PAGE, status)
+
+	// the failed command is recorded in the error log
+	data, err := controller.NvmeGetLogPage(0, uint32(nvme.NVME_GET_LOG_PAGE_ERROR_INFO), false, 64)
+	require.NoError(t, err)
+	assert.Equal(t, uint8(1), data[0])
+	assert.Equal(t, uint16(nvme.NVME_SC_INVALID_LOG_PAGE)<<1, uint16(data[12])|uint16(data[13])<<8)
+}
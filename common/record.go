@@ -0,0 +1,284 @@
+package common
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/jc-lab/go-dparm/ata"
+	"github.com/jc-lab/go-dparm/nvme"
+)
+
+// Recorded operations
+const (
+	RecordOpOpen                = "Open"
+	RecordOpReopenWritable      = "ReopenWritable"
+	RecordOpSecurityCommand     = "SecurityCommand"
+	RecordOpDoTaskFileCmd       = "DoTaskFileCmd"
+	RecordOpDoNvmeAdminPassthru = "DoNvmeAdminPassthru"
+	RecordOpNvmeGetLogPage      = "NvmeGetLogPage"
+)
+
+// RecordEntry is one line of a recording.
+// The first entry of a recording is RecordOpOpen and describes the driver.
+type RecordEntry struct {
+	Op string `json:"op"`
+
+	// RecordOpOpen
+	DriverName  string      `json:"driverName,omitempty"`
+	DrivingType DrivingType `json:"drivingType,omitempty"`
+	Ata         bool        `json:"ata,omitempty"`
+	Nvme        bool        `json:"nvme,omitempty"`
+	Identity    []byte      `json:"identity,omitempty"`
+	// Redacted is set when the credentials were removed from the inputs
+	Redacted bool `json:"redacted,omitempty"`
+
+	Rw          bool   `json:"rw,omitempty"`
+	Dma         bool   `json:"dma,omitempty"`
+	Protocol    uint8  `json:"protocol,omitempty"`
+	ComId       uint16 `json:"comId,omitempty"`
+	TimeoutSecs int    `json:"timeoutSecs,omitempty"`
+
+	// Tf is the task file before the command, TfOut after it
+	Tf    *ata.Tf `json:"tf,omitempty"`
+	TfOut *ata.Tf `json:"tfOut,omitempty"`
+
+	// Cmd is the admin command without its data buffer
+	Cmd    *nvme.NvmeAdminCmd `json:"cmd,omitempty"`
+	Result uint32             `json:"result,omitempty"`
+
+	// RecordOpNvmeGetLogPage
+	Nsid  uint32 `json:"nsid,omitempty"`
+	LogId uint32 `json:"logId,omitempty"`
+	Rae   bool   `json:"rae,omitempty"`
+	Size  int    `json:"size,omitempty"`
+
+	// Input is the buffer sent to the drive, Output the buffer received from it
+	Input  []byte `json:"input,omitempty"`
+	Output []byte `json:"output,omitempty"`
+
+	Error string `json:"error,omitempty"`
+	// Status is set when Error was an nvme.StatusCode
+	Status nvme.StatusCode `json:"status,omitempty"`
+}
+
+func (e *RecordEntry) setError(err error) {
+	if err == nil {
+		return
+	}
+	e.Error = err.Error()
+	if status, ok := nvme.StatusCodeOf(err); ok {
+		e.Status = status
+	}
+}
+
+func cloneBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+// nvmeAdminDirection returns whether an admin opcode transfers data to and from the controller
+func nvmeAdminDirection(opcode uint8) (toController bool, fromController bool) {
+	return opcode&0x1 != 0, opcode&0x2 != 0
+}
+
+// RecordOptions configures NewRecordingDriverHandle
+type RecordOptions struct {
+	// IncludeCredentials keeps the passwords, PINs and challenges sent to the drive in the recording.
+	// Such a recording contains secrets and must be handled like the passwords themselves.
+	IncludeCredentials bool
+}
+
+func recordOptionsOf(options []RecordOptions) RecordOptions {
+	if len(options) == 0 {
+		return RecordOptions{}
+	}
+	return options[0]
+}
+
+type recorder struct {
+	dh     DriverHandle
+	redact bool
+
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+type ataRecorder struct {
+	*recorder
+	ata AtaDriverHandle
+}
+
+type nvmeRecorder struct {
+	*recorder
+	nvme NvmeDriverHandle
+}
+
+// NewRecordingDriverHandle wraps dh and writes every command passed through it to w as JSON lines.
+// The returned handle implements AtaDriverHandle or NvmeDriverHandle when dh does.
+// A command fails if its entry can not be written.
+//
+// A recording contains secrets. The ATA security passwords and the TCG challenges and PINs sent to the drive are
+// zeroed unless RecordOptions.IncludeCredentials is set, but the responses are kept as they are: the MSID, the
+// identity with its serial number and the content of the DataStore table can be read back from a recording.
+func NewRecordingDriverHandle(dh DriverHandle, w io.Writer, options ...RecordOptions) (DriverHandle, error) {
+	opts := recordOptionsOf(options)
+	r := &recorder{
+		dh:     dh,
+		redact: !opts.IncludeCredentials,
+		enc:    json.NewEncoder(w),
+	}
+
+	entry := &RecordEntry{
+		Op:          RecordOpOpen,
+		DriverName:  dh.GetDriverName(),
+		DrivingType: dh.GetDrivingType(),
+		Redacted:    r.redact,
+	}
+	var handle DriverHandle = r
+	if ataDrive, ok := dh.(AtaDriverHandle); ok {
+		entry.Ata = true
+		entry.Identity = cloneBytes(ataDrive.GetIdentity())
+		handle = &ataRecorder{recorder: r, ata: ataDrive}
+	} else if nvmeDrive, ok := dh.(NvmeDriverHandle); ok {
+		entry.Nvme = true
+		entry.Identity = cloneBytes(nvmeDrive.GetIdentity())
+		handle = &nvmeRecorder{recorder: r, nvme: nvmeDrive}
+	}
+
+	if err := r.write(entry, nil); err != nil {
+		return nil, err
+	}
+	return handle, nil
+}
+
+// write stores entry and returns the error of the recorded command, or of the recording itself
+func (r *recorder) write(entry *RecordEntry, err error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if writeErr := r.enc.Encode(entry); writeErr != nil {
+		return NewNestedError("record failed", writeErr)
+	}
+	return err
+}
+
+func (r *recorder) GetDriverName() string {
+	return r.dh.GetDriverName()
+}
+
+func (r *recorder) GetDrivingType() DrivingType {
+	return r.dh.GetDrivingType()
+}
+
+func (r *recorder) ReopenWritable() error {
+	err := r.dh.ReopenWritable()
+	entry := &RecordEntry{Op: RecordOpReopenWritable}
+	entry.setError(err)
+	return r.write(entry, err)
+}
+
+func (r *recorder) Close() {
+	r.dh.Close()
+}
+
+func (r *recorder) SecurityCommand(rw bool, dma bool, protocol uint8, comId uint16, buffer []byte, timeoutSecs int) error {
+	entry := &RecordEntry{
+		Op:          RecordOpSecurityCommand,
+		Rw:          rw,
+		Dma:         dma,
+		Protocol:    protocol,
+		ComId:       comId,
+		TimeoutSecs: timeoutSecs,
+		Size:        len(buffer),
+	}
+	if rw && r.redact {
+		entry.Input = redactSecurityData(protocol, buffer)
+	} else if rw {
+		entry.Input = cloneBytes(buffer)
+	}
+
+	err := r.dh.SecurityCommand(rw, dma, protocol, comId, buffer, timeoutSecs)
+	if !rw {
+		entry.Output = cloneBytes(buffer)
+	}
+	entry.setError(err)
+	return r.write(entry, err)
+}
+
+func (r *ataRecorder) GetIdentity() []byte {
+	return r.ata.GetIdentity()
+}
+
+func (r *ataRecorder) DoTaskFileCmd(rw bool, dma bool, tf *ata.Tf, data []byte, timeoutSecs int) error {
+	tfIn := *tf
+	entry := &RecordEntry{
+		Op:          RecordOpDoTaskFileCmd,
+		Rw:          rw,
+		Dma:         dma,
+		TimeoutSecs: timeoutSecs,
+		Tf:          &tfIn,
+		Size:        len(data),
+	}
+	if rw && r.redact {
+		entry.Input = redactTaskFileData(tf, data)
+	} else if rw {
+		entry.Input = cloneBytes(data)
+	}
+
+	err := r.ata.DoTaskFileCmd(rw, dma, tf, data, timeoutSecs)
+	tfOut := *tf
+	entry.TfOut = &tfOut
+	if !rw {
+		entry.Output = cloneBytes(data)
+	}
+	entry.setError(err)
+	return r.write(entry, err)
+}
+
+func (r *nvmeRecorder) GetIdentity() []byte {
+	return r.nvme.GetIdentity()
+}
+
+func (r *nvmeRecorder) DoNvmeAdminPassthru(cmd *nvme.NvmeAdminCmd) error {
+	cmdIn := *cmd
+	cmdIn.DataAddr = 0
+	cmdIn.DataBuffer = nil
+	cmdIn.Result = 0
+	entry := &RecordEntry{
+		Op:   RecordOpDoNvmeAdminPassthru,
+		Cmd:  &cmdIn,
+		Size: len(cmd.DataBuffer),
+	}
+	toController, fromController := nvmeAdminDirection(cmd.Opcode)
+	if toController && r.redact {
+		entry.Input = redactNvmeAdminData(cmd)
+	} else if toController {
+		entry.Input = cloneBytes(cmd.DataBuffer)
+	}
+
+	err := r.nvme.DoNvmeAdminPassthru(cmd)
+	if fromController {
+		entry.Output = cloneBytes(cmd.DataBuffer)
+	}
+	entry.Result = cmd.Result
+	entry.setError(err)
+	return r.write(entry, err)
+}
+
+func (r *nvmeRecorder) NvmeGetLogPage(nsid uint32, logId uint32, rae bool, size int) ([]byte, error) {
+	entry := &RecordEntry{
+		Op:    RecordOpNvmeGetLogPage,
+		Nsid:  nsid,
+		LogId: logId,
+		Rae:   rae,
+		Size:  size,
+	}
+
+	data, err := r.nvme.NvmeGetLogPage(nsid, logId, rae, size)
+	entry.Output = cloneBytes(data)
+	entry.setError(err)
+	return data, r.write(entry, err)
+}
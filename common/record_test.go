@@ -0,0 +1,192 @@
+package common_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/jc-lab/go-dparm/ata"
+	"github.com/jc-lab/go-dparm/common"
+	"github.com/jc-lab/go-dparm/nvme"
+	"github.com/jc-lab/go-dparm/tcg"
+	"github.com/jc-lab/go-dparm/test/ata_sim"
+	"github.com/jc-lab/go-dparm/test/nvme_sim"
+	"github.com/jc-lab/go-dparm/test/tcg_sim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ataSession runs the commands that are recorded and then replayed
+func ataSession(t *testing.T, handle *common.DriveHandleImpl) (string, []byte) {
+	require.NoError(t, handle.Init())
+
+	device, err := tcg.NewTcgDevice(handle)
+	require.NoError(t, err)
+	msid, err := device.GetDefaultPassword()
+	require.NoError(t, err)
+
+	data := make([]byte, 512)
+	require.NoError(t, handle.AtaDoTaskFileCmd(false, false, lba48Tf(ata.ATA_OP_READ_PIO_EXT, 8, 1), data, 3))
+
+	tf := &ata.Tf{Command: ata.ATA_OP_CHECKPOWERMODE1}
+	require.NoError(t, handle.AtaDoTaskFileCmd(false, false, tf, nil, 3))
+	return msid, append(data, tf.Lob.Nsect)
+}
+
+func TestRecordReplayAta(t *testing.T) {
+	config := ata_sim.DefaultConfig()
+	config.Path = t.TempDir() + "/drive.img"
+	config.Security = tcg_sim.NewTPer(tcg_sim.DefaultConfig())
+	drive, err := ata_sim.NewDrive(config)
+	require.NoError(t, err)
+
+	sector := bytes.Repeat([]byte{0xa5}, 512)
+	require.NoError(t, drive.DoTaskFileCmd(true, false, lba48Tf(ata.ATA_OP_WRITE_PIO_EXT, 8, 1), sector, 3))
+
+	var recording bytes.Buffer
+	recorder, err := common.NewRecordingDriverHandle(drive, &recording)
+	require.NoError(t, err)
+	recorded := &common.DriveHandleImpl{Dh: recorder}
+	defer recorded.Close()
+	msid, output := ataSession(t, recorded)
+	assert.Equal(t, tcg_sim.DefaultConfig().MSID, msid)
+	assert.Equal(t, sector, output[:512])
+
+	replayer, err := common.NewReplayDriverHandle(bytes.NewReader(recording.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, drive.GetDriverName(), replayer.GetDriverName())
+	assert.Equal(t, drive.GetDrivingType(), replayer.GetDrivingType())
+
+	replayed := &common.DriveHandleImpl{Dh: replayer}
+	replayedMsid, replayedOutput := ataSession(t, replayed)
+	assert.Equal(t, msid, replayedMsid)
+	assert.Equal(t, output, replayedOutput)
+
+	expectedInfo, actualInfo := *recorded.GetDriveInfo(), *replayed.GetDriveInfo()
+	expectedInfo.AtaIdentity, actualInfo.AtaIdentity = nil, nil
+	assert.Equal(t, expectedInfo, actualInfo)
+	assert.True(t, actualInfo.TcgOpalSscV200)
+
+	// everything has been replayed
+	err = replayed.AtaDoTaskFileCmd(false, false, &ata.Tf{Command: ata.ATA_OP_CHECKPOWERMODE1}, nil, 3)
+	assert.ErrorIs(t, err, common.ErrReplayEnd)
+}
+
+func TestRecordReplayNvme(t *testing.T) {
+	controller := nvme_sim.NewController(nvme_sim.DefaultConfig())
+
+	var recording bytes.Buffer
+	recorder, err := common.NewRecordingDriverHandle(controller, &recording)
+	require.NoError(t, err)
+
+	session := func(dh common.DriverHandle) ([]byte, uint32, error) {
+		handle := &common.DriveHandleImpl{Dh: dh}
+		require.NoError(t, handle.Init())
+		assert.Equal(t, "GO-DPARM NVME SIMULATOR", handle.GetDriveInfo().Model)
+
+		smart, err := handle.NvmeGetLogPage(0xffffffff, uint32(nvme.NVME_GET_LOG_PAGE_SMART), false, 512)
+		require.NoError(t, err)
+
+		cmd := &nvme.NvmeAdminCmd{Opcode: uint8(nvme.NVME_ADMIN_OP_GET_FEATURES), Cdw10: 0x04}
+		require.NoError(t, nvmeAdmin(handle, cmd))
+
+		formatErr := nvmeAdmin(handle, &nvme.NvmeAdminCmd{Opcode: uint8(nvme.NVME_ADMIN_OP_FORMAT_NVM), Nsid: 1, Cdw10: 5})
+		return smart, cmd.Result, formatErr
+	}
+
+	smart, temperature, formatErr := session(recorder)
+	requireNvmeStatus(t, nvme.NVME_SC_INVALID_FORMAT, formatErr)
+
+	replayer, err := common.NewReplayDriverHandle(&recording)
+	require.NoError(t, err)
+	replayedSmart, replayedTemperature, replayedFormatErr := session(replayer)
+	assert.Equal(t, smart, replayedSmart)
+	assert.Equal(t, temperature, replayedTemperature)
+	requireNvmeStatus(t, nvme.NVME_SC_INVALID_FORMAT, replayedFormatErr)
+}
+
+func TestReplayMismatch(t *testing.T) {
+	config := ata_sim.DefaultConfig()
+	config.Path = t.TempDir() + "/drive.img"
+	drive, err := ata_sim.NewDrive(config)
+	require.NoError(t, err)
+
+	var recording bytes.Buffer
+	recorder, err := common.NewRecordingDriverHandle(drive, &recording)
+	require.NoError(t, err)
+	require.NoError(t, recorder.(common.AtaDriverHandle).DoTaskFileCmd(false, false, lba48Tf(ata.ATA_OP_READ_PIO_EXT, 0, 1), make([]byte, 512), 3))
+
+	replayer, err := common.NewReplayDriverHandle(&recording)
+	require.NoError(t, err)
+	err = replayer.(common.AtaDriverHandle).DoTaskFileCmd(false, false, lba48Tf(ata.ATA_OP_READ_PIO_EXT, 1, 1), make([]byte, 512), 3)
+	assert.ErrorIs(t, err, common.ErrReplayMismatch)
+	err = replayer.SecurityCommand(false, false, 1, 1, make([]byte, 512), 3)
+	assert.ErrorIs(t, err, common.ErrReplayMismatch)
+
+	_, err = common.NewReplayDriverHandle(bytes.NewReader(nil))
+	assert.Error(t, err)
+}
+
+// recordedInputs returns the buffers sent to the drive in a recording
+func recordedInputs(t *testing.T, recording []byte) [][]byte {
+	var inputs [][]byte
+	decoder := json.NewDecoder(bytes.NewReader(recording))
+	for decoder.More() {
+		var entry common.RecordEntry
+		require.NoError(t, decoder.Decode(&entry))
+		inputs = append(inputs, entry.Input)
+	}
+	return inputs
+}
+
+func TestRecordRedactsCredentials(t *testing.T) {
+	const owner = "owner-secret-password"
+	takeOwnership := func(dh common.DriverHandle) {
+		handle := &common.DriveHandleImpl{Dh: dh}
+		require.NoError(t, handle.Init())
+		device, err := tcg.NewTcgDevice(handle)
+		require.NoError(t, err)
+		device.SetPasswordHasher(tcg.RawPasswordHasher)
+		require.NoError(t, device.TakeOwnership(owner))
+
+		tf := &ata.Tf{Command: ata.ATA_OP_SECURITY_SET_PASS}
+		require.NoError(t, handle.AtaDoTaskFileCmd(true, false, tf, securityData(false, "ata-secret"), 3))
+	}
+	record := func(options ...common.RecordOptions) []byte {
+		config := ata_sim.DefaultConfig()
+		config.Path = t.TempDir() + "/drive.img"
+		config.Security = tcg_sim.NewTPer(tcg_sim.DefaultConfig())
+		drive, err := ata_sim.NewDrive(config)
+		require.NoError(t, err)
+
+		var recording bytes.Buffer
+		recorder, err := common.NewRecordingDriverHandle(drive, &recording, options...)
+		require.NoError(t, err)
+		takeOwnership(recorder)
+		return recording.Bytes()
+	}
+	contains := func(inputs [][]byte, secret string) bool {
+		for _, input := range inputs {
+			if bytes.Contains(input, []byte(secret)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	recording := record()
+	inputs := recordedInputs(t, recording)
+	assert.False(t, contains(inputs, owner))
+	assert.False(t, contains(inputs, tcg_sim.DefaultConfig().MSID))
+	assert.False(t, contains(inputs, "ata-secret"))
+	assert.Equal(t, make([]byte, 32), inputs[len(inputs)-1][2:34])
+
+	// the redacted commands still match
+	replayer, err := common.NewReplayDriverHandle(bytes.NewReader(recording))
+	require.NoError(t, err)
+	takeOwnership(replayer)
+
+	inputs = recordedInputs(t, record(common.RecordOptions{IncludeCredentials: true}))
+	assert.True(t, contains(inputs, owner))
+	assert.True(t, contains(inputs, "ata-secret"))
+}
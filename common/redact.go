@@ -0,0 +1,44 @@
+package common
+
+import (
+	"github.com/jc-lab/go-dparm/ata"
+	"github.com/jc-lab/go-dparm/nvme"
+	"github.com/jc-lab/go-dparm/tcg"
+)
+
+// securityProtocolTcg is the security protocol of the TCG ComPackets
+const securityProtocolTcg = 0x01
+
+// redactSecurityData returns a copy of a buffer sent by SecurityCommand without its credentials
+func redactSecurityData(protocol uint8, buffer []byte) []byte {
+	data := cloneBytes(buffer)
+	if protocol == securityProtocolTcg {
+		tcg.RedactCredentials(data)
+	}
+	return data
+}
+
+// redactTaskFileData returns a copy of a buffer sent by DoTaskFileCmd without its credentials
+func redactTaskFileData(tf *ata.Tf, data []byte) []byte {
+	switch tf.Command {
+	case ata.ATA_OP_SECURITY_SET_PASS, ata.ATA_OP_SECURITY_UNLOCK, ata.ATA_OP_SECURITY_ERASE_UNIT,
+		ata.ATA_OP_SECURITY_DISABLE:
+		data = cloneBytes(data)
+		// the password is word 1 to 16 of the sector
+		for i := 2; i < 34 && i < len(data); i++ {
+			data[i] = 0
+		}
+		return data
+	case ata.ATA_OP_TRUSTED_SEND, ata.ATA_OP_TRUSTED_SEND_DMA:
+		return redactSecurityData(tf.Lob.Feat, data)
+	}
+	return cloneBytes(data)
+}
+
+// redactNvmeAdminData returns a copy of a buffer sent by DoNvmeAdminPassthru without its credentials
+func redactNvmeAdminData(cmd *nvme.NvmeAdminCmd) []byte {
+	if nvme.AdminOpCode(cmd.Opcode) == nvme.NVME_ADMIN_OP_SECURITY_SEND {
+		return redactSecurityData(uint8(cmd.Cdw10>>24), cmd.DataBuffer)
+	}
+	return cloneBytes(cmd.DataBuffer)
+}
@@ -0,0 +1,191 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+
+	"github.com/jc-lab/go-dparm/ata"
+	"github.com/jc-lab/go-dparm/nvme"
+)
+
+var (
+	ErrReplayMismatch = errors.New("command does not match the recording")
+	ErrReplayEnd      = errors.New("end of recording")
+)
+
+type replayer struct {
+	open RecordEntry
+
+	mu      sync.Mutex
+	entries []RecordEntry
+	next    int
+}
+
+type ataReplayer struct {
+	*replayer
+}
+
+type nvmeReplayer struct {
+	*replayer
+}
+
+// NewReplayDriverHandle reads a recording made by NewRecordingDriverHandle.
+// The returned handle answers the commands with the recorded results in the recorded order,
+// a command that differs from the next recorded one fails with ErrReplayMismatch.
+// The credentials of a redacted recording are not compared.
+func NewReplayDriverHandle(reader io.Reader) (DriverHandle, error) {
+	r := &replayer{}
+
+	decoder := json.NewDecoder(bufio.NewReader(reader))
+	for {
+		var entry RecordEntry
+		if err := decoder.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		r.entries = append(r.entries, entry)
+	}
+
+	if len(r.entries) == 0 || r.entries[0].Op != RecordOpOpen {
+		return nil, fmt.Errorf("invalid recording: missing %s entry", RecordOpOpen)
+	}
+	r.open = r.entries[0]
+	r.entries = r.entries[1:]
+
+	if r.open.Ata {
+		return &ataReplayer{r}, nil
+	} else if r.open.Nvme {
+		return &nvmeReplayer{r}, nil
+	}
+	return r, nil
+}
+
+// take returns the next entry if it is op and matches
+func (r *replayer) take(op string, match func(entry *RecordEntry) bool) (*RecordEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.next >= len(r.entries) {
+		return nil, ErrReplayEnd
+	}
+	index := r.next
+	entry := &r.entries[index]
+	if entry.Op != op || !match(entry) {
+		return nil, fmt.Errorf("%w: entry %d is %s", ErrReplayMismatch, index+1, entry.Op)
+	}
+	r.next++
+	return entry, nil
+}
+
+func (e *RecordEntry) replayError() error {
+	if e.Status != nvme.NVME_SC_SUCCESS {
+		return e.Status
+	}
+	if e.Error != "" {
+		return errors.New(e.Error)
+	}
+	return nil
+}
+
+func (r *replayer) GetDriverName() string {
+	return r.open.DriverName
+}
+
+func (r *replayer) GetDrivingType() DrivingType {
+	return r.open.DrivingType
+}
+
+func (r *replayer) ReopenWritable() error {
+	entry, err := r.take(RecordOpReopenWritable, func(entry *RecordEntry) bool {
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return entry.replayError()
+}
+
+func (r *replayer) Close() {
+}
+
+func (r *replayer) SecurityCommand(rw bool, dma bool, protocol uint8, comId uint16, buffer []byte, timeoutSecs int) error {
+	input := buffer
+	if rw && r.open.Redacted {
+		input = redactSecurityData(protocol, buffer)
+	}
+	entry, err := r.take(RecordOpSecurityCommand, func(entry *RecordEntry) bool {
+		return entry.Rw == rw && entry.Dma == dma && entry.Protocol == protocol && entry.ComId == comId &&
+			entry.Size == len(buffer) && (!rw || bytes.Equal(entry.Input, input))
+	})
+	if err != nil {
+		return err
+	}
+	copy(buffer, entry.Output)
+	return entry.replayError()
+}
+
+func (r *ataReplayer) GetIdentity() []byte {
+	return cloneBytes(r.open.Identity)
+}
+
+func (r *ataReplayer) DoTaskFileCmd(rw bool, dma bool, tf *ata.Tf, data []byte, timeoutSecs int) error {
+	input := data
+	if rw && r.open.Redacted {
+		input = redactTaskFileData(tf, data)
+	}
+	entry, err := r.take(RecordOpDoTaskFileCmd, func(entry *RecordEntry) bool {
+		return entry.Rw == rw && entry.Dma == dma && entry.Tf != nil && *entry.Tf == *tf &&
+			entry.Size == len(data) && (!rw || bytes.Equal(entry.Input, input))
+	})
+	if err != nil {
+		return err
+	}
+	if entry.TfOut != nil {
+		*tf = *entry.TfOut
+	}
+	copy(data, entry.Output)
+	return entry.replayError()
+}
+
+func (r *nvmeReplayer) GetIdentity() []byte {
+	return cloneBytes(r.open.Identity)
+}
+
+func (r *nvmeReplayer) DoNvmeAdminPassthru(cmd *nvme.NvmeAdminCmd) error {
+	toController, _ := nvmeAdminDirection(cmd.Opcode)
+	cmdIn := *cmd
+	cmdIn.DataAddr = 0
+	cmdIn.DataBuffer = nil
+	cmdIn.Result = 0
+	input := cmd.DataBuffer
+	if toController && r.open.Redacted {
+		input = redactNvmeAdminData(cmd)
+	}
+	entry, err := r.take(RecordOpDoNvmeAdminPassthru, func(entry *RecordEntry) bool {
+		return entry.Cmd != nil && reflect.DeepEqual(*entry.Cmd, cmdIn) &&
+			entry.Size == len(cmd.DataBuffer) && (!toController || bytes.Equal(entry.Input, input))
+	})
+	if err != nil {
+		return err
+	}
+	copy(cmd.DataBuffer, entry.Output)
+	cmd.Result = entry.Result
+	return entry.replayError()
+}
+
+func (r *nvmeReplayer) NvmeGetLogPage(nsid uint32, logId uint32, rae bool, size int) ([]byte, error) {
+	entry, err := r.take(RecordOpNvmeGetLogPage, func(entry *RecordEntry) bool {
+		return entry.Nsid == nsid && entry.LogId == logId && entry.Rae == rae && entry.Size == size
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cloneBytes(entry.Output), entry.replayError()
+}
@@ -1,6 +1,10 @@
 package nvme
 
-import "unsafe"
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
 
 type StatusCode uint16
 type AdminOpCode uint8
@@ -158,6 +162,20 @@ const (
 	NVME_SC_DNR = StatusCode(0x4000)
 )
 
+// Error makes a non-zero completion status usable as an error
+func (c StatusCode) Error() string {
+	return fmt.Sprintf("nvme status: %#x", uint16(c))
+}
+
+// StatusCodeOf returns the completion status carried by err, NVME_SC_SUCCESS if there is none
+func StatusCodeOf(err error) (StatusCode, bool) {
+	var sc StatusCode
+	if errors.As(err, &sc) {
+		return sc, true
+	}
+	return NVME_SC_SUCCESS, false
+}
+
 const (
 	NVME_ADMIN_OP_DELETE_SQ      = AdminOpCode(0x00)
 	NVME_ADMIN_OP_CREATE_SQ      = AdminOpCode(0x01)
@@ -208,6 +226,7 @@ const (
 	NVME_GET_LOG_PAGE_ERROR_INFO         = GetLogPageIdentifier(0x01)
 	NVME_GET_LOG_PAGE_SMART              = GetLogPageIdentifier(0x02)
 	NVME_GET_LOG_PAGE_FIRMWARE_SLOT_INFO = GetLogPageIdentifier(0x03)
+	NVME_GET_LOG_PAGE_SANITIZE_STATUS    = GetLogPageIdentifier(0x81)
 )
 
 /**
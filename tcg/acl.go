@@ -0,0 +1,159 @@
+package tcg
+
+import (
+	"fmt"
+)
+
+// Values of the boolean_ACE terms of a BooleanExpr, TCG Storage Architecture Core Specification 5.1.3.14
+const (
+	booleanAnd uint64 = 0
+	booleanOr  uint64 = 1
+	booleanNot uint64 = 2
+)
+
+// ACE is a row of the ACE table, the authorities satisfying its BooleanExpr may access its columns
+type ACE struct {
+	UID OpalUID
+	// Authorities are the authorities the BooleanExpr names
+	Authorities []OpalUID
+	// AnyOf is true when the BooleanExpr is the OR of the authorities, any of them is granted the access
+	AnyOf bool
+	// Expr is the BooleanExpr in infix notation with the authorities in hex
+	Expr string
+	// Columns are the columns the ACE grants access to, nil for every column
+	Columns []uint64
+}
+
+// ReadACL returns the ACEs of the AccessControl entry of a method invoked on a table or an object, the session must
+// be allowed to invoke GetACL
+func ReadACL(session *TcgSession, invoking OpalUID, method OpalMethod) ([]OpalUID, error) {
+	cmd := NewTcgCommand()
+	cmd.Init(ACCESS_CONTROL_TABLE, GETACL)
+	cmd.AddValue(ListValue(UIDValue(invoking), UIDValue(OpalUID(method))))
+	cmd.Complete()
+
+	resp, err := session.SendCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	var aces []OpalUID
+	for _, item := range resp.Result().Results().Item(0).Items() {
+		uid, err := item.UID()
+		if err != nil {
+			return nil, err
+		}
+		aces = append(aces, uid)
+	}
+	return aces, nil
+}
+
+// ReadACE reads the BooleanExpr and the Columns of an ACE
+func ReadACE(session *TcgSession, uid OpalUID) (*ACE, error) {
+	table := append([]uint8{uint8(BYTESTRING8)}, uid[:]...)
+	resp, err := opalGetTable(session, table, uint16(BOOLEAN_EXPR), uint16(ACE_COLUMNS))
+	if err != nil {
+		return nil, err
+	}
+	result := resp.Result()
+
+	ace := &ACE{UID: uid}
+	if err := ace.parseBooleanExpr(result.Named(BOOLEAN_EXPR)); err != nil {
+		return nil, fmt.Errorf("ACE %x: %w", uid[:], err)
+	}
+	// the column is empty or absent when the ACE grants every column
+	for _, item := range result.Named(ACE_COLUMNS).Items() {
+		column, err := item.Uint()
+		if err != nil {
+			return nil, err
+		}
+		ace.Columns = append(ace.Columns, column)
+	}
+	return ace, nil
+}
+
+// parseBooleanExpr evaluates the postfix BooleanExpr into an infix expression and the authorities it names
+func (a *ACE) parseBooleanExpr(expr *TcgValue) error {
+	if !expr.IsList() {
+		return fmt.Errorf("%w: no BooleanExpr", ErrIllegalResponse)
+	}
+
+	a.AnyOf = true
+	var stack []string
+	for _, item := range expr.Items() {
+		switch {
+		case item.IsNamed() && item.Name().matchName(HALF_UID_AUTHORITY_OBJ_REF[:4]):
+			authority, err := item.Value().UID()
+			if err != nil {
+				return err
+			}
+			a.Authorities = append(a.Authorities, authority)
+			stack = append(stack, fmt.Sprintf("%x", authority[:]))
+		case item.IsNamed() && item.Name().matchName(HALF_UID_BOOLEAN_ACE[:4]):
+			op, err := item.Value().Uint()
+			if err != nil {
+				return err
+			}
+			if op == booleanNot {
+				if len(stack) < 1 {
+					return fmt.Errorf("%w: NOT without operand", ErrIllegalResponse)
+				}
+				a.AnyOf = false
+				stack[len(stack)-1] = "NOT " + stack[len(stack)-1]
+				continue
+			}
+			if len(stack) < 2 {
+				return fmt.Errorf("%w: boolean operator %d without operands", ErrIllegalResponse, op)
+			}
+			var name string
+			switch op {
+			case booleanAnd:
+				a.AnyOf = false
+				name = "AND"
+			case booleanOr:
+				name = "OR"
+			default:
+				return fmt.Errorf("%w: boolean operator %d", ErrIllegalResponse, op)
+			}
+			left, right := stack[len(stack)-2], stack[len(stack)-1]
+			stack = append(stack[:len(stack)-2], "("+left+" "+name+" "+right+")")
+		default:
+			return fmt.Errorf("%w: BooleanExpr term %s", ErrIllegalResponse, item)
+		}
+	}
+
+	switch len(stack) {
+	case 0:
+		// an empty BooleanExpr grants nobody
+		a.AnyOf = false
+	case 1:
+		a.Expr = stack[0]
+	default:
+		return fmt.Errorf("%w: BooleanExpr of %d terms", ErrIllegalResponse, len(stack))
+	}
+	return nil
+}
+
+// getACL resolves the ACEs of a method invoked on a table or an object of the Locking SP as Admin1
+func getACL(device TcgDevice, password string, invoking OpalUID, method OpalMethod) ([]ACE, error) {
+	session, err := startLockingSession(device, password)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	uids, err := ReadACL(session, invoking, method)
+	if err != nil {
+		return nil, err
+	}
+
+	aces := make([]ACE, 0, len(uids))
+	for _, uid := range uids {
+		ace, err := ReadACE(session, uid)
+		if err != nil {
+			return nil, err
+		}
+		aces = append(aces, *ace)
+	}
+	return aces, nil
+}
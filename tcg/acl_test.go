@@ -0,0 +1,51 @@
+package tcg_test
+
+import (
+	"testing"
+
+	"github.com/jc-lab/go-dparm/tcg"
+	"github.com/jc-lab/go-dparm/test/tcg_sim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetACL(t *testing.T) {
+	sim := tcg_sim.NewTPer(tcg_sim.DefaultConfig())
+	device := testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+	require.NoError(t, device.TakeOwnership("sid"))
+	require.NoError(t, device.ActivateLockingSP("sid"))
+	require.NoError(t, device.SetUserEnabled("sid", 1, true))
+	require.NoError(t, device.AddUserToLockingRange("sid", 1, 1))
+
+	aces, err := device.GetACL("sid", tcg.LockingRangeUID(1), tcg.SET)
+	require.NoError(t, err)
+	byUID := make(map[tcg.OpalUID]tcg.ACE)
+	for _, ace := range aces {
+		byUID[ace.UID] = ace
+	}
+
+	rdLocked, ok := byUID[tcg.LockingRangeRdLockedACE(1)]
+	require.True(t, ok)
+	assert.Equal(t, []tcg.OpalUID{tcg.ADMINS_UID, tcg.USER1_UID}, rdLocked.Authorities)
+	assert.True(t, rdLocked.AnyOf)
+	assert.Equal(t, "(0000000900000002 OR 0000000900030001)", rdLocked.Expr)
+	assert.Equal(t, []uint64{uint64(tcg.LOCKING_READ_LOCKED)}, rdLocked.Columns)
+	assert.Contains(t, byUID, tcg.LockingRangeWrLockedACE(1))
+
+	// User1 has no access to range 2
+	aces, err = device.GetACL("sid", tcg.LockingRangeUID(2), tcg.SET)
+	require.NoError(t, err)
+	require.NotEmpty(t, aces)
+	for _, ace := range aces {
+		assert.NotContains(t, ace.Authorities, tcg.USER1_UID)
+	}
+
+	// Anybody may ask for the ACL of the Admin SP
+	session := tcg.NewTcgSession(device)
+	require.NoError(t, session.Start(tcg.ADMINSP_UID, "", tcg.UID_HEXFF))
+	uids, err := tcg.ReadACL(session, tcg.C_PIN_SID, tcg.SET)
+	session.Close()
+	require.NoError(t, err)
+	assert.Len(t, uids, 1)
+	assert.Equal(t, 0, sim.OpenSessions())
+}
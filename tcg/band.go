@@ -0,0 +1,334 @@
+package tcg
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// offsetUID returns uid with n added to the last two bytes of its row
+func offsetUID(uid OpalUID, n uint16) OpalUID {
+	binary.BigEndian.PutUint16(uid[6:], binary.BigEndian.Uint16(uid[6:])+n)
+	return uid
+}
+
+// BandUID returns the Locking table object of an Enterprise band, 0 is the global range
+func BandUID(index uint8) OpalUID {
+	return offsetUID(LOCKINGRANGE_GLOBAL, uint16(index))
+}
+
+// BandMasterUID returns the authority that owns an Enterprise band
+func BandMasterUID(index uint8) OpalUID {
+	return offsetUID(ENTERPRISE_BANDMASTER0_UID, uint16(index))
+}
+
+func bandMasterCPinUID(index uint8) OpalUID {
+	return offsetUID(C_PIN_ENTERPRISE_BANDMASTER0, uint16(index))
+}
+
+var enterpriseLockingRangeColumns = lockingRangeColumns{
+	"RangeStart", "RangeLength",
+	"ReadLockEnabled", "WriteLockEnabled", "ReadLocked", "WriteLocked",
+	"LockOnReset", "ActiveKey",
+}
+
+func addEnterpriseBoolValue(cmd *TcgCommand, column string, value bool) {
+	cmd.AddToken(STARTNAME)
+	cmd.AddStringToken(column)
+	if value {
+		cmd.AddToken(UINT_01)
+	} else {
+		cmd.AddToken(UINT_00)
+	}
+	cmd.AddToken(ENDNAME)
+}
+
+// enterpriseSet sends the Enterprise Set on an object, values adds the named values
+func enterpriseSet(session *TcgSession, uid OpalUID, values func(cmd *TcgCommand)) error {
+	cmd := NewTcgCommand()
+	cmd.Init(uid, ESET)
+	cmd.AddToken(STARTLIST)
+	// Where is empty for an object
+	cmd.AddToken(STARTLIST)
+	cmd.AddToken(ENDLIST)
+	cmd.AddToken(STARTLIST)
+	values(cmd)
+	cmd.AddToken(ENDLIST)
+	cmd.AddToken(ENDLIST)
+	cmd.Complete()
+
+	_, err := session.SendCommand(cmd)
+	return err
+}
+
+// setEnterpriseCPin sets the PIN column of a C_PIN object, the password is hashed with the hasher of the drive
+func (p *TcgDeviceEnterprise) setEnterpriseCPin(session *TcgSession, cpin OpalUID, password string) error {
+	return enterpriseSet(session, cpin, func(cmd *TcgCommand) {
+		cmd.AddToken(STARTNAME)
+		cmd.AddStringToken("PIN")
+		cmd.AddRawToken(pinToken(hashPassword(p, password)))
+		cmd.AddToken(ENDNAME)
+	})
+}
+
+func (p *TcgDeviceEnterprise) startSession(sp OpalUID, authority OpalUID, password string, noHash bool) (*TcgSession, error) {
+	session := NewTcgSession(p)
+	session.SetNoHashPassword(noHash)
+	if err := session.Start(sp, password, authority); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// changePassword sets the C_PIN of authority, password is the current one
+func (p *TcgDeviceEnterprise) changePassword(sp OpalUID, authority OpalUID, cpin OpalUID, password string, noHash bool, newPassword string) error {
+	session, err := p.startSession(sp, authority, password, noHash)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	return p.setEnterpriseCPin(session, cpin, newPassword)
+}
+
+func (p *TcgDeviceEnterprise) getMaxRanges() (uint64, error) {
+	session := NewTcgSession(p)
+	if err := session.Start(ENTERPRISE_LOCKINGSP_UID, "", UID_HEXFF); err != nil {
+		return 0, err
+	}
+	defer session.Close()
+
+	info := ENTERPRISE_LOCKING_INFO_TABLE
+	table := append([]uint8{uint8(BYTESTRING8)}, info[:]...)
+	resp, err := p.EnterpriseGetTable(session, table, []uint8("MaxRanges"), []uint8("MaxRanges"))
+	if err != nil {
+		return 0, err
+	}
+	return resp.Result().Named("MaxRanges").Uint()
+}
+
+// TakeOwnership changes the SID, EraseMaster and every BandMaster password from the MSID to newPassword
+func (p *TcgDeviceEnterprise) TakeOwnership(newPassword string) error {
+	msid, err := p.GetDefaultPassword()
+	if err != nil {
+		return err
+	}
+	maxRanges, err := p.getMaxRanges()
+	if err != nil {
+		return err
+	}
+
+	if err := p.changePassword(ADMINSP_UID, SID_UID, C_PIN_SID, msid, true, newPassword); err != nil {
+		return fmt.Errorf("SID: %w", err)
+	}
+	if err := p.changePassword(ENTERPRISE_LOCKINGSP_UID, ENTERPRISE_ERASEMASTER_UID, C_PIN_ENTERPRISE_ERASEMASTER, msid, true, newPassword); err != nil {
+		return fmt.Errorf("EraseMaster: %w", err)
+	}
+	for i := uint64(0); i <= maxRanges && i <= 0xff; i++ {
+		index := uint8(i)
+		if err := p.changePassword(ENTERPRISE_LOCKINGSP_UID, BandMasterUID(index), bandMasterCPinUID(index), msid, true, newPassword); err != nil {
+			return fmt.Errorf("BandMaster%d: %w", index, err)
+		}
+	}
+	return nil
+}
+
+// SetBandMasterPassword changes the password of the BandMaster of a band
+func (p *TcgDeviceEnterprise) SetBandMasterPassword(index uint8, password string, newPassword string) error {
+	return p.changePassword(ENTERPRISE_LOCKINGSP_UID, BandMasterUID(index), bandMasterCPinUID(index), password, false, newPassword)
+}
+
+// SetEraseMasterPassword changes the password of the EraseMaster
+func (p *TcgDeviceEnterprise) SetEraseMasterPassword(password string, newPassword string) error {
+	return p.changePassword(ENTERPRISE_LOCKINGSP_UID, ENTERPRISE_ERASEMASTER_UID, C_PIN_ENTERPRISE_ERASEMASTER, password, false, newPassword)
+}
+
+// setBandValues sends Set on a band as its BandMaster
+func (p *TcgDeviceEnterprise) setBandValues(password string, index uint8, values func(cmd *TcgCommand)) error {
+	session, err := p.startSession(ENTERPRISE_LOCKINGSP_UID, BandMasterUID(index), password, false)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	return enterpriseSet(session, BandUID(index), values)
+}
+
+// SetupLockingRange sets RangeStart and RangeLength of a numbered band as its BandMaster
+func (p *TcgDeviceEnterprise) SetupLockingRange(password string, index uint8, start uint64, length uint64) error {
+	if index == 0 {
+		return fmt.Errorf("the global range covers the whole drive")
+	}
+
+	return p.setBandValues(password, index, func(cmd *TcgCommand) {
+		cmd.AddToken(STARTNAME)
+		cmd.AddStringToken("RangeStart")
+		cmd.AddNumberToken(start)
+		cmd.AddToken(ENDNAME)
+		cmd.AddToken(STARTNAME)
+		cmd.AddStringToken("RangeLength")
+		cmd.AddNumberToken(length)
+		cmd.AddToken(ENDNAME)
+	})
+}
+
+// ConfigureLockingRange sets ReadLockEnabled, WriteLockEnabled and LockOnReset of a band as its BandMaster
+func (p *TcgDeviceEnterprise) ConfigureLockingRange(password string, index uint8, readLockEnabled bool, writeLockEnabled bool, lockOnReset bool) error {
+	return p.setBandValues(password, index, func(cmd *TcgCommand) {
+		addEnterpriseBoolValue(cmd, "ReadLockEnabled", readLockEnabled)
+		addEnterpriseBoolValue(cmd, "WriteLockEnabled", writeLockEnabled)
+		cmd.AddToken(STARTNAME)
+		cmd.AddStringToken("LockOnReset")
+		cmd.AddToken(STARTLIST)
+		if lockOnReset {
+			// power cycle
+			cmd.AddToken(UINT_00)
+		}
+		cmd.AddToken(ENDLIST)
+		cmd.AddToken(ENDNAME)
+	})
+}
+
+// SetLockingState sets ReadLocked and WriteLocked of a band as its BandMaster
+func (p *TcgDeviceEnterprise) SetLockingState(password string, index uint8, state OpalLockingState) error {
+	var readLocked, writeLocked bool
+	switch state {
+	case READWRITE:
+	case READONLY:
+		writeLocked = true
+	case LOCKED:
+		readLocked, writeLocked = true, true
+	default:
+		return fmt.Errorf("unsupported locking state: %d", state)
+	}
+
+	return p.setBandValues(password, index, func(cmd *TcgCommand) {
+		addEnterpriseBoolValue(cmd, "ReadLocked", readLocked)
+		addEnterpriseBoolValue(cmd, "WriteLocked", writeLocked)
+	})
+}
+
+// GetLockingRange reads a band as its BandMaster
+func (p *TcgDeviceEnterprise) GetLockingRange(password string, index uint8) (*LockingRange, error) {
+	session, err := p.startSession(ENTERPRISE_LOCKINGSP_UID, BandMasterUID(index), password, false)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	return p.getBand(session, index)
+}
+
+func (p *TcgDeviceEnterprise) getBand(session *TcgSession, index uint8) (*LockingRange, error) {
+	uid := BandUID(index)
+	table := append([]uint8{uint8(BYTESTRING8)}, uid[:]...)
+	resp, err := p.EnterpriseGetTable(session, table, []uint8("RangeStart"), []uint8("ActiveKey"))
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeLockingRange(resp.Result(), index, enterpriseLockingRangeColumns)
+}
+
+// GetLockingRanges returns the global band followed by every numbered band, every BandMaster has to use password
+func (p *TcgDeviceEnterprise) GetLockingRanges(password string) ([]LockingRange, error) {
+	maxRanges, err := p.getMaxRanges()
+	if err != nil {
+		return nil, err
+	}
+
+	ranges := make([]LockingRange, 0, maxRanges+1)
+	for i := uint64(0); i <= maxRanges && i <= 0xff; i++ {
+		lockingRange, err := p.GetLockingRange(password, uint8(i))
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, *lockingRange)
+	}
+
+	return ranges, nil
+}
+
+// EraseLockingRange cryptographically erases a band as the EraseMaster, the band is unlocked afterwards. Erase also
+// resets the PIN of the BandMaster of the band to the MSID, the BandMasterPassword of the options is set again
+// after relocking and verifying the band as the BandMaster with the MSID. Without it the PIN is left at the MSID.
+func (p *TcgDeviceEnterprise) EraseLockingRange(password string, index uint8, options ...EraseOptions) error {
+	opts := eraseOptionsOf(options)
+	if (opts.Relock || opts.Verify) && opts.BandMasterPassword == "" {
+		return fmt.Errorf("relocking or verifying band %d needs the BandMaster password", index)
+	}
+
+	var before *LockingRange
+	if opts.Verify {
+		var err error
+		if before, err = p.GetLockingRange(opts.BandMasterPassword, index); err != nil {
+			return err
+		}
+	}
+
+	msid, err := p.GetDefaultPassword()
+	if err != nil {
+		return err
+	}
+	if err := p.eraseBand(password, index); err != nil {
+		return err
+	}
+	if opts.BandMasterPassword == "" {
+		return nil
+	}
+
+	session, err := p.startSession(ENTERPRISE_LOCKINGSP_UID, BandMasterUID(index), msid, true)
+	if err != nil {
+		return fmt.Errorf("BandMaster%d after Erase reset its PIN to the MSID: %w", index, err)
+	}
+	defer session.Close()
+
+	var after *LockingRange
+	if opts.Relock {
+		err = enterpriseSet(session, BandUID(index), func(cmd *TcgCommand) {
+			addEnterpriseBoolValue(cmd, "ReadLockEnabled", true)
+			addEnterpriseBoolValue(cmd, "WriteLockEnabled", true)
+			addEnterpriseBoolValue(cmd, "ReadLocked", true)
+			addEnterpriseBoolValue(cmd, "WriteLocked", true)
+		})
+	}
+	if err == nil && opts.Verify {
+		after, err = p.getBand(session, index)
+	}
+	// the password is restored even when relocking failed, so the BandMaster is not left at the MSID
+	if pinErr := p.setEnterpriseCPin(session, bandMasterCPinUID(index), opts.BandMasterPassword); pinErr != nil {
+		if err != nil {
+			return fmt.Errorf("%w, BandMaster%d PIN left at the MSID: %v", err, index, pinErr)
+		}
+		return fmt.Errorf("BandMaster%d PIN left at the MSID: %w", index, pinErr)
+	}
+	if err != nil || !opts.Verify {
+		return err
+	}
+
+	// Erase resets the locks of the band and LockOnReset to power cycle
+	expected := *before
+	expected.ReadLockEnabled, expected.WriteLockEnabled = false, false
+	expected.ReadLocked, expected.WriteLocked = false, false
+	expected.LockOnReset = true
+	if opts.Relock {
+		expected = relockLockingRange(expected)
+	}
+	return verifyErasedRange(expected, after)
+}
+
+func (p *TcgDeviceEnterprise) eraseBand(password string, index uint8) error {
+	session, err := p.startSession(ENTERPRISE_LOCKINGSP_UID, ENTERPRISE_ERASEMASTER_UID, password, false)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	cmd := NewTcgCommand()
+	cmd.Init(BandUID(index), ERASE)
+	cmd.AddToken(STARTLIST)
+	cmd.AddToken(ENDLIST)
+	cmd.Complete()
+
+	_, err = session.SendCommand(cmd)
+	return err
+}
@@ -0,0 +1,79 @@
+package tcg
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/jc-lab/go-dparm/internal"
+)
+
+var (
+	ErrBlockSIDNotSupported = errors.New("the drive does not support Block SID authentication")
+	ErrSIDBlocked           = errors.New("SID authentication is blocked")
+)
+
+// Block SID Authentication command, TCG Storage Feature Set: Block SID Authentication 3.1
+const (
+	blockSIDComId = 0x0005
+
+	// blockSIDClearOnHardwareReset is the Hardware Reset bit of the Clear Events field
+	blockSIDClearOnHardwareReset = 0x01
+)
+
+// GetBlockSIDFeature returns the Block SID Authentication feature, nil when the drive does not support it
+func (p *TcgDeviceImpl) GetBlockSIDFeature() *BlockSIDFeature {
+	return p.dh.TcgDiscovery.BlockSID
+}
+
+// BlockSID blocks authentication as SID until the next power cycle, so the SID password can no longer be taken
+// or changed. clearOnHardwareReset also lifts the block on a hardware reset.
+func (p *TcgDeviceImpl) BlockSID(clearOnHardwareReset bool) error {
+	feature := p.dh.TcgDiscovery.BlockSID
+	if feature == nil {
+		return ErrBlockSIDNotSupported
+	}
+
+	request := internal.NewAlignedBuffer(IO_BUFFER_ALIGNMENT, comIdBufferLength).GetBuffer()
+	if clearOnHardwareReset {
+		request[0] = blockSIDClearOnHardwareReset
+	}
+	if err := p.dh.SecurityCommand(true, false, comIdProtocol, blockSIDComId, request, 5); err != nil {
+		return err
+	}
+
+	feature.SIDBlocked = true
+	feature.HardwareReset = clearOnHardwareReset
+	return nil
+}
+
+// sidBlockedError is a failed authentication as SID while the drive blocks SID, it unwraps to the error of the TPer
+type sidBlockedError struct {
+	err error
+}
+
+func (e *sidBlockedError) Error() string {
+	return ErrSIDBlocked.Error() + ": " + e.err.Error()
+}
+
+func (e *sidBlockedError) Is(target error) bool {
+	return target == ErrSIDBlocked
+}
+
+func (e *sidBlockedError) Unwrap() error {
+	return e.err
+}
+
+// checkSIDBlocked explains a session start as SID rejected with NOT_AUTHORIZED by the Block SID state of the drive
+func checkSIDBlocked(device TcgDevice, authority []uint8, err error) error {
+	var tcgErr *TcgError
+	if !errors.As(err, &tcgErr) || tcgErr.Status != NOT_AUTHORIZED {
+		return err
+	}
+	if !bytes.Equal(authority, append([]uint8{uint8(BYTESTRING8)}, SID_UID[:]...)) {
+		return err
+	}
+	if feature := device.GetBlockSIDFeature(); feature == nil || !feature.SIDBlocked {
+		return err
+	}
+	return &sidBlockedError{err: err}
+}
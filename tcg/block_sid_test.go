@@ -0,0 +1,57 @@
+package tcg_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jc-lab/go-dparm/tcg"
+	"github.com/jc-lab/go-dparm/test/tcg_sim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockSIDNotSupported(t *testing.T) {
+	device := testOpalDevice(t, tcg_sim.NewTPer(tcg_sim.DefaultConfig()))
+	assert.Nil(t, device.GetBlockSIDFeature())
+	assert.True(t, errors.Is(device.BlockSID(false), tcg.ErrBlockSIDNotSupported))
+}
+
+func TestBlockSID(t *testing.T) {
+	config := tcg_sim.DefaultConfig()
+	config.BlockSID = true
+	sim := tcg_sim.NewTPer(config)
+	device := testOpalDevice(t, sim)
+	feature := device.GetBlockSIDFeature()
+	require.NotNil(t, feature)
+	assert.Equal(t, tcg.BlockSIDFeature{Version: 1}, *feature)
+
+	require.NoError(t, device.BlockSID(false))
+	assert.True(t, device.GetBlockSIDFeature().SIDBlocked)
+	err := device.TakeOwnership("sid")
+	assert.True(t, errors.Is(err, tcg.ErrSIDBlocked))
+	var tcgErr *tcg.TcgError
+	require.True(t, errors.As(err, &tcgErr))
+	assert.Equal(t, tcg.NOT_AUTHORIZED, tcgErr.Status)
+
+	// a hardware reset keeps the block unless the command asked for it
+	sim.HardwareReset()
+	feature = testOpalDevice(t, sim).GetBlockSIDFeature()
+	assert.Equal(t, tcg.BlockSIDFeature{Version: 1, SIDBlocked: true}, *feature)
+
+	sim.PowerCycle()
+	device = testOpalDevice(t, sim)
+	assert.False(t, device.GetBlockSIDFeature().SIDBlocked)
+	require.NoError(t, device.TakeOwnership("sid"))
+
+	require.NoError(t, device.BlockSID(true))
+	feature = testOpalDevice(t, sim).GetBlockSIDFeature()
+	assert.Equal(t, tcg.BlockSIDFeature{Version: 1, SIDValueState: true, SIDBlocked: true, HardwareReset: true}, *feature)
+	err = device.RevertTPer("sid", false, true)
+	assert.True(t, errors.Is(err, tcg.ErrSIDBlocked))
+
+	sim.HardwareReset()
+	device = testOpalDevice(t, sim)
+	assert.False(t, device.GetBlockSIDFeature().SIDBlocked)
+	require.NoError(t, device.RevertTPer("sid", false, true))
+	assert.Equal(t, 0, sim.OpenSessions())
+}
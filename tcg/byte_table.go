@@ -0,0 +1,108 @@
+package tcg
+
+import (
+	"fmt"
+)
+
+func writeByteTableChunk(session *TcgSession, table OpalUID, offset uint64, data []byte) error {
+	cmd := NewTcgCommand()
+	cmd.Init(table, SET)
+	cmd.AddToken(STARTLIST)
+	cmd.AddToken(STARTNAME)
+	cmd.AddToken(WHERE)
+	cmd.AddNumberToken(offset)
+	cmd.AddToken(ENDNAME)
+	cmd.AddToken(STARTNAME)
+	cmd.AddToken(VALUES)
+	cmd.AddValue(BytesValue(data))
+	cmd.AddToken(ENDNAME)
+	cmd.AddToken(ENDLIST)
+	cmd.Complete()
+
+	_, err := session.SendCommand(cmd)
+	return err
+}
+
+func readByteTableChunk(session *TcgSession, table OpalUID, offset uint64, length int) ([]byte, error) {
+	cmd := NewTcgCommand()
+	cmd.Init(table, GET)
+	cmd.AddToken(STARTLIST)
+	cmd.AddToken(STARTLIST)
+	cmd.AddToken(STARTNAME)
+	cmd.AddToken(STARTROW)
+	cmd.AddNumberToken(offset)
+	cmd.AddToken(ENDNAME)
+	cmd.AddToken(STARTNAME)
+	cmd.AddToken(ENDROW)
+	cmd.AddNumberToken(offset + uint64(length) - 1)
+	cmd.AddToken(ENDNAME)
+	cmd.AddToken(ENDLIST)
+	cmd.AddToken(ENDLIST)
+	cmd.Complete()
+
+	resp, err := session.SendCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := resp.Result().Results().Item(0).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != length {
+		return nil, fmt.Errorf("short byte table read: %d of %d bytes", len(data), length)
+	}
+	return data, nil
+}
+
+// readByteTable reads length bytes of a byte table in chunks that fit the negotiated response size
+func readByteTable(session *TcgSession, table OpalUID, offset uint64, length int) ([]byte, error) {
+	chunkSize := session.tcgDevice.GetProperties().MaxReadChunk()
+
+	out := make([]byte, 0, length)
+	for len(out) < length {
+		n := length - len(out)
+		if n > chunkSize {
+			n = chunkSize
+		}
+		data, err := readByteTableChunk(session, table, offset+uint64(len(out)), n)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, data...)
+	}
+	return out, nil
+}
+
+// writeByteTable writes data to a byte table in chunks that fit the negotiated ComPacket size
+func writeByteTable(session *TcgSession, table OpalUID, offset uint64, data []byte) error {
+	chunkSize := session.tcgDevice.GetProperties().MaxWriteChunk()
+	for start := 0; start < len(data); start += chunkSize {
+		end := start + chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		if err := writeByteTableChunk(session, table, offset+uint64(start), data[start:end]); err != nil {
+			return fmt.Errorf("write at %d: %w", offset+uint64(start), err)
+		}
+	}
+	return nil
+}
+
+// getByteTableSize reads the Rows column of the row of the Table table describing a byte table, a byte table has
+// one byte per row
+func getByteTableSize(session *TcgSession, table OpalUID) (uint64, error) {
+	row := TableRowUID(table)
+	resp, err := opalGetTable(session, append([]uint8{uint8(BYTESTRING8)}, row[:]...), uint16(TABLE_ROWS), uint16(TABLE_ROWS))
+	if err != nil {
+		return 0, err
+	}
+	return resp.Result().Named(TABLE_ROWS).Uint()
+}
+
+// TableRowUID returns the row of the Table table describing a table
+func TableRowUID(table OpalUID) OpalUID {
+	uid := TABLE_TABLE
+	copy(uid[4:], table[:4])
+	return uid
+}
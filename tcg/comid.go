@@ -0,0 +1,149 @@
+package tcg
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jc-lab/go-dparm/internal"
+)
+
+var (
+	ErrStackResetFailed            = errors.New("stack reset failed")
+	ErrComIdManagementNotSupported = errors.New("the TPer does not support ComID management")
+)
+
+// ComIdState is the state of a ComID reported by VERIFY_COMID_VALID
+type ComIdState uint32
+
+const (
+	ComIdInvalid ComIdState = 0 + iota
+	ComIdInactive
+	ComIdIssued
+	ComIdAssociated
+)
+
+// ComID management, TCG Storage Architecture Core Specification 3.3.4.3 and 3.3.4.7
+const (
+	comIdProtocol = 0x02
+
+	comIdRequestVerifyComIdValid uint32 = 0x00000001
+	comIdRequestStackReset       uint32 = 0x00000002
+
+	comIdBufferLength   = 512
+	comIdRequestTimeout = 10000 * time.Millisecond
+)
+
+// comIdRequest sends a ComID management request and polls its response until the TPer has processed it,
+// it returns the response data
+func (p *TcgDeviceImpl) comIdRequest(comId uint16, requestCode uint32) ([]byte, error) {
+	beginAt := time.Now()
+
+	request := internal.NewAlignedBuffer(IO_BUFFER_ALIGNMENT, comIdBufferLength).GetBuffer()
+	binary.BigEndian.PutUint16(request[0:], comId)
+	binary.BigEndian.PutUint32(request[4:], requestCode)
+	if err := p.dh.SecurityCommand(true, false, comIdProtocol, comId, request, 5); err != nil {
+		return nil, err
+	}
+
+	resp := internal.NewAlignedBuffer(IO_BUFFER_ALIGNMENT, comIdBufferLength).GetBuffer()
+	for {
+		if err := p.dh.SecurityCommand(false, false, comIdProtocol, comId, resp, 5); err != nil {
+			return nil, err
+		}
+		if binary.BigEndian.Uint16(resp[0:]) != comId || binary.BigEndian.Uint32(resp[4:]) != requestCode {
+			return nil, fmt.Errorf("%w: ComID 0x%04x request 0x%x", ErrIllegalResponse, binary.BigEndian.Uint16(resp[0:]), binary.BigEndian.Uint32(resp[4:]))
+		}
+
+		// the available data length stays zero while the request is pending
+		length := int(binary.BigEndian.Uint16(resp[10:]))
+		if length != 0 {
+			if 12+length > len(resp) {
+				return nil, ErrIllegalResponse
+			}
+			return append([]byte{}, resp[12:12+length]...), nil
+		}
+
+		if time.Since(beginAt) > comIdRequestTimeout {
+			return nil, fmt.Errorf("timeout")
+		}
+		time.Sleep(25 * time.Millisecond)
+	}
+}
+
+// GetComId returns the ComID commands are sent on, the dynamic ComID after AllocateComId
+func (p *TcgDeviceImpl) GetComId() uint16 {
+	if p.dh.comId != 0 {
+		return p.dh.comId
+	}
+	return p.dev.GetBaseComId()
+}
+
+// AllocateComId issues a dynamic ComID with GET_COMID, later commands are sent on it
+func (p *TcgDeviceImpl) AllocateComId() (uint16, error) {
+	tper := p.dh.TcgDiscovery.TPer
+	if tper == nil || !tper.ComIDManagement {
+		return 0, ErrComIdManagementNotSupported
+	}
+
+	resp := internal.NewAlignedBuffer(IO_BUFFER_ALIGNMENT, comIdBufferLength).GetBuffer()
+	if err := p.dh.SecurityCommand(false, false, comIdProtocol, 0x0000, resp, 5); err != nil {
+		return 0, err
+	}
+	comId := binary.BigEndian.Uint16(resp[0:])
+	if comId == 0 {
+		return 0, fmt.Errorf("%w: no ComID issued", ErrIllegalResponse)
+	}
+
+	p.dh.comId = comId
+	return comId, nil
+}
+
+// VerifyComIdValid returns the state of a ComID
+func (p *TcgDeviceImpl) VerifyComIdValid(comId uint16) (ComIdState, error) {
+	data, err := p.comIdRequest(comId, comIdRequestVerifyComIdValid)
+	if err != nil {
+		return ComIdInvalid, err
+	}
+	if len(data) < 4 {
+		return ComIdInvalid, ErrIllegalResponse
+	}
+	return ComIdState(binary.BigEndian.Uint32(data)), nil
+}
+
+// StackReset aborts every session and transaction on the ComID of the device and exchanges the properties again,
+// the TPer returns to its default properties on a reset
+func (p *TcgDeviceImpl) StackReset() error {
+	data, err := p.comIdRequest(p.dev.GetComId(), comIdRequestStackReset)
+	if err != nil {
+		return err
+	}
+	if len(data) < 4 {
+		return ErrIllegalResponse
+	}
+	if binary.BigEndian.Uint32(data) != 0 {
+		return ErrStackResetFailed
+	}
+
+	p.dh.Properties = DefaultProperties()
+	if properties, err := ExchangeProperties(p.dev); err == nil {
+		p.dh.Properties = properties
+	}
+	return nil
+}
+
+func (p *TcgDeviceImpl) IsAutoStackReset() bool {
+	return p.dh.autoStackReset
+}
+
+// SetAutoStackReset makes a session start that fails with SP_BUSY or NO_SESSIONS_AVAILABLE reset the stack and retry
+// once, for sessions left open by a process that did not close them
+func (p *TcgDeviceImpl) SetAutoStackReset(enable bool) {
+	p.dh.autoStackReset = enable
+}
+
+// isSessionBusy returns true when a session start failed for sessions that are still open
+func isSessionBusy(err error) bool {
+	return errors.Is(err, ErrSpBusy) || errors.Is(err, ErrNoSessionsAvailable)
+}
@@ -0,0 +1,95 @@
+package tcg_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jc-lab/go-dparm/tcg"
+	"github.com/jc-lab/go-dparm/test/tcg_sim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// leakSession opens a session that is never closed, like a process that crashed in the middle of a session
+func leakSession(t *testing.T, device tcg.TcgDevice) {
+	session := tcg.NewTcgSession(device)
+	session.NoAutoClose()
+	require.NoError(t, session.Start(tcg.ADMINSP_UID, "", tcg.UID_HEXFF))
+}
+
+func TestStackReset(t *testing.T) {
+	sim := tcg_sim.NewTPer(tcg_sim.DefaultConfig())
+	device := testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+	comId := device.GetComId()
+	assert.Equal(t, device.GetBaseComId(), comId)
+
+	state, err := device.VerifyComIdValid(comId)
+	require.NoError(t, err)
+	assert.Equal(t, tcg.ComIdIssued, state)
+
+	leakSession(t, device)
+	state, err = device.VerifyComIdValid(comId)
+	require.NoError(t, err)
+	assert.Equal(t, tcg.ComIdAssociated, state)
+
+	_, err = device.GetDefaultPassword()
+	var tcgErr *tcg.TcgError
+	require.True(t, errors.As(err, &tcgErr))
+	assert.Equal(t, tcg.SP_BUSY, tcgErr.Status)
+
+	require.NoError(t, device.StackReset())
+	assert.Equal(t, 0, sim.OpenSessions())
+	// the properties are exchanged again after the reset
+	assert.NotNil(t, sim.HostProperties())
+
+	msid, err := device.GetDefaultPassword()
+	require.NoError(t, err)
+	assert.Equal(t, tcg_sim.DefaultConfig().MSID, msid)
+
+	state, err = device.VerifyComIdValid(0x1234)
+	require.NoError(t, err)
+	assert.Equal(t, tcg.ComIdInvalid, state)
+}
+
+func TestAutoStackReset(t *testing.T) {
+	sim := tcg_sim.NewTPer(tcg_sim.DefaultConfig())
+	device := testOpalDevice(t, sim)
+	assert.False(t, device.IsAutoStackReset())
+
+	leakSession(t, device)
+	device.SetAutoStackReset(true)
+	msid, err := device.GetDefaultPassword()
+	require.NoError(t, err)
+	assert.Equal(t, tcg_sim.DefaultConfig().MSID, msid)
+	assert.Equal(t, 0, sim.OpenSessions())
+}
+
+func TestAllocateComId(t *testing.T) {
+	device := testOpalDevice(t, tcg_sim.NewTPer(tcg_sim.DefaultConfig())).(*tcg.TcgDeviceOpal2)
+	_, err := device.AllocateComId()
+	assert.True(t, errors.Is(err, tcg.ErrComIdManagementNotSupported))
+
+	config := tcg_sim.DefaultConfig()
+	config.ComIdManagement = true
+	sim := tcg_sim.NewTPer(config)
+	device = testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+	comId, err := device.AllocateComId()
+	require.NoError(t, err)
+	assert.NotEqual(t, config.BaseComId, comId)
+	assert.Equal(t, comId, device.GetComId())
+
+	// the session is associated with the dynamic ComID only
+	leakSession(t, device)
+	state, err := device.VerifyComIdValid(comId)
+	require.NoError(t, err)
+	assert.Equal(t, tcg.ComIdAssociated, state)
+	state, err = device.VerifyComIdValid(config.BaseComId)
+	require.NoError(t, err)
+	assert.Equal(t, tcg.ComIdIssued, state)
+
+	require.NoError(t, device.StackReset())
+	assert.Equal(t, 0, sim.OpenSessions())
+	msid, err := device.GetDefaultPassword()
+	require.NoError(t, err)
+	assert.Equal(t, config.MSID, msid)
+}
@@ -16,6 +16,13 @@ type TcgCommand struct {
 	CmdBuf *internal.AlignedBuffer
 	Header *OpalHeader
 	CmdPtr *uint8
+
+	// size is the ComPacket length including padding, set by Complete
+	size int
+
+	// invoking and method are the UIDs of the method call set by Init, reported by TcgError
+	invoking OpalUID
+	method   OpalMethod
 }
 
 type InvokingUid interface {
@@ -39,6 +46,7 @@ func (cmd *TcgCommand) addByteToken(data uint8) error {
 func (cmd *TcgCommand) Reset() {
 	cmd.CmdBuf.Reset()
 	cmd.CmdBuf.SetWritePos(int(unsafe.Sizeof(*cmd.Header)))
+	cmd.size = 0
 }
 
 // invokingUid: OpalUID | Buf([]byte), method: OpalMethod | Buf([]byte)
@@ -48,11 +56,18 @@ func (cmd *TcgCommand) Init(invokingUid invokingUID, method cmdMethod) error {
 		return err
 	}
 
+	cmd.invoking = OpalUID{}
+	cmd.method = OpalMethod{}
+
 	switch uid := invokingUid.(type) {
 	case OpalUID:
 		cmd.AddToken(uid)
+		cmd.invoking = uid
 	case Buf:
 		cmd.AddRawToken(uid)
+		if len(uid) == 9 && uid[0] == uint8(BYTESTRING8) {
+			copy(cmd.invoking[:], uid[1:])
+		}
 	default:
 		return ErrInvalidParamType
 	}
@@ -61,8 +76,12 @@ func (cmd *TcgCommand) Init(invokingUid invokingUID, method cmdMethod) error {
 	case OpalMethod:
 		cmd.CmdBuf.WriteByte(byte(BYTESTRING8))
 		cmd.CmdBuf.Write(m[:])
+		cmd.method = m
 	case Buf:
 		cmd.AddRawToken(m)
+		if len(m) == 9 && m[0] == uint8(BYTESTRING8) {
+			copy(cmd.method[:], m[1:])
+		}
 	}
 
 	return nil
@@ -176,6 +195,16 @@ func (cmd *TcgCommand) AddNumberToken(value uint64) error {
 	return nil
 }
 
+// AddValue appends the token stream of every value, see ListValue and NamedValue
+func (cmd *TcgCommand) AddValue(values ...*TcgValue) error {
+	for _, v := range values {
+		if err := cmd.AddRawToken(v.Encode()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (cmd *TcgCommand) Complete(eod ...bool) error {
 	eodVar := true
 	if len(eod) != 0 {
@@ -203,6 +232,7 @@ func (cmd *TcgCommand) Complete(eod ...bool) error {
 	}
 	header.Pkt.Length = uint32(cmd.CmdBuf.GetPos()) - uint32(unsafe.Sizeof(header.Cp)) - uint32(unsafe.Sizeof(header.Pkt))
 	header.Cp.Length = uint32(cmd.CmdBuf.GetPos()) - uint32(unsafe.Sizeof(header.Cp))
+	cmd.size = cmd.CmdBuf.GetPos()
 
 	cmd.CmdBuf.ResetWrite()
 	if err := struc.Pack(cmd.CmdBuf, header); err != nil {
@@ -229,9 +259,14 @@ func (cmd *TcgCommand) GetCmdPtr() *uint8 {
 }
 
 func (cmd *TcgCommand) GetCmdSize() uint32 {
-	x := cmd.CmdBuf.GetPos() & 511
+	// the write position is back at the header after Complete
+	size := cmd.size
+	if size < cmd.CmdBuf.GetPos() {
+		size = cmd.CmdBuf.GetPos()
+	}
+	x := size & 511
 	if x != 0 {
-		return uint32(512 - x + cmd.CmdBuf.GetPos())
+		return uint32(512 - x + size)
 	}
-	return uint32(cmd.CmdBuf.GetPos())
+	return uint32(size)
 }
@@ -0,0 +1,99 @@
+package tcg
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNoSuchDataStore     = errors.New("the drive has no such DataStore table")
+	ErrDataStoreOutOfRange = errors.New("access beyond the end of the DataStore table")
+)
+
+// DataStoreUID returns a DataStore table of the Locking SP, 0 is the DataStore table every drive has and the others
+// are the Additional DataStore Tables
+func DataStoreUID(index uint8) OpalUID {
+	uid := DATASTORE
+	binary.BigEndian.PutUint32(uid[0:], binary.BigEndian.Uint32(DATASTORE[0:])+uint32(index))
+	return uid
+}
+
+// checkDataStoreIndex checks a DataStore table against the Additional DataStore Tables feature
+func checkDataStoreIndex(feature *DataStoreFeature, index uint8) error {
+	if index == 0 {
+		return nil
+	}
+	if feature == nil || uint16(index) >= feature.MaxTables {
+		return fmt.Errorf("%w: DataStore %d", ErrNoSuchDataStore, index)
+	}
+	return nil
+}
+
+// checkDataStoreRange checks an access against the size of the DataStore table from the Table table
+func checkDataStoreRange(session *TcgSession, table OpalUID, offset uint64, length int) error {
+	size, err := getByteTableSize(session, table)
+	if err != nil {
+		return err
+	}
+	if offset > size || uint64(length) > size-offset {
+		return fmt.Errorf("%w: %d bytes at %d, the table has %d bytes", ErrDataStoreOutOfRange, length, offset, size)
+	}
+	return nil
+}
+
+// getDataStoreSize returns the size of a DataStore table as Admin1
+func getDataStoreSize(device TcgDevice, feature *DataStoreFeature, password string, index uint8) (uint64, error) {
+	if err := checkDataStoreIndex(feature, index); err != nil {
+		return 0, err
+	}
+
+	session, err := startLockingSession(device, password)
+	if err != nil {
+		return 0, err
+	}
+	defer session.Close()
+
+	return getByteTableSize(session, DataStoreUID(index))
+}
+
+// readDataStore reads a DataStore table as Admin1
+func readDataStore(device TcgDevice, feature *DataStoreFeature, password string, index uint8, offset uint64, length int) ([]byte, error) {
+	if err := checkDataStoreIndex(feature, index); err != nil {
+		return nil, err
+	}
+
+	session, err := startLockingSession(device, password)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	table := DataStoreUID(index)
+	if err := checkDataStoreRange(session, table, offset, length); err != nil {
+		return nil, err
+	}
+	return readByteTable(session, table, offset, length)
+}
+
+// writeDataStore writes a DataStore table as Admin1
+func writeDataStore(device TcgDevice, feature *DataStoreFeature, password string, index uint8, offset uint64, data []byte) error {
+	if err := checkDataStoreIndex(feature, index); err != nil {
+		return err
+	}
+
+	session, err := startLockingSession(device, password)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	table := DataStoreUID(index)
+	if err := checkDataStoreRange(session, table, offset, len(data)); err != nil {
+		return err
+	}
+	if err := writeByteTable(session, table, offset, data); err != nil {
+		return fmt.Errorf("DataStore %d: %w", index, err)
+	}
+	return nil
+}
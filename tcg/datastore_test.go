@@ -0,0 +1,76 @@
+package tcg_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jc-lab/go-dparm/tcg"
+	"github.com/jc-lab/go-dparm/test/tcg_sim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataStore(t *testing.T) {
+	config := tcg_sim.DefaultConfig()
+	config.MaxComPacketSize = 1024
+	sim := tcg_sim.NewTPer(config)
+	device := testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+	require.NoError(t, device.TakeOwnership("sid"))
+	require.NoError(t, device.ActivateLockingSP("sid"))
+	assert.Nil(t, device.GetDataStoreFeature())
+
+	size, err := device.GetDataStoreSize("sid", 0)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(config.DataStoreSize), size)
+
+	// larger than a ComPacket
+	blob := make([]byte, 5000)
+	for i := range blob {
+		blob[i] = uint8(i * 7)
+	}
+	require.NoError(t, device.WriteDataStore("sid", 0, 0x100, blob))
+	data, err := device.ReadDataStore("sid", 0, 0x100, len(blob))
+	require.NoError(t, err)
+	assert.Equal(t, blob, data)
+
+	data, err = device.ReadDataStore("sid", 0, size-16, 16)
+	require.NoError(t, err)
+	assert.Len(t, data, 16)
+
+	err = device.WriteDataStore("sid", 0, size-16, make([]byte, 17))
+	assert.True(t, errors.Is(err, tcg.ErrDataStoreOutOfRange))
+	_, err = device.ReadDataStore("sid", 0, size+1, 0)
+	assert.True(t, errors.Is(err, tcg.ErrDataStoreOutOfRange))
+	_, err = device.ReadDataStore("sid", 1, 0, 16)
+	assert.True(t, errors.Is(err, tcg.ErrNoSuchDataStore))
+	assert.Error(t, device.WriteDataStore("wrong", 0, 0, blob))
+	assert.Equal(t, 0, sim.OpenSessions())
+}
+
+func TestAdditionalDataStoreTables(t *testing.T) {
+	config := tcg_sim.DefaultConfig()
+	config.DataStoreTables = 3
+	config.DataStoreSize = 0x1000
+	sim := tcg_sim.NewTPer(config)
+	device := testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+	require.NoError(t, device.TakeOwnership("sid"))
+	require.NoError(t, device.ActivateLockingSP("sid"))
+
+	feature := device.GetDataStoreFeature()
+	require.NotNil(t, feature)
+	assert.Equal(t, uint16(3), feature.MaxTables)
+	assert.Equal(t, uint32(0x3000), feature.MaxTotalSize)
+	assert.Equal(t, tcg.DATASTORE, tcg.DataStoreUID(0))
+	assert.Equal(t, tcg.OpalUID{0x00, 0x00, 0x10, 0x03, 0x00, 0x00, 0x00, 0x00}, tcg.DataStoreUID(2))
+
+	for i := uint8(0); i < 3; i++ {
+		require.NoError(t, device.WriteDataStore("sid", i, 0, []byte{'t', 'e', 'n', 'a', 'n', 't', '0' + i}))
+	}
+	for i := uint8(0); i < 3; i++ {
+		data, err := device.ReadDataStore("sid", i, 0, 7)
+		require.NoError(t, err)
+		assert.Equal(t, "tenant"+string(rune('0'+i)), string(data))
+	}
+	_, err := device.GetDataStoreSize("sid", 3)
+	assert.True(t, errors.Is(err, tcg.ErrNoSuchDataStore))
+}
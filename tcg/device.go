@@ -14,6 +14,8 @@ const (
 	OpalV1Device
 	OpalV2Device
 	OpalEnterpriseDevice
+	PyriteDevice
+	RubyDevice
 )
 
 type TcgDevice interface {
@@ -31,6 +33,25 @@ type TcgDevice interface {
 
 	GetBaseComId() uint16
 	GetNumComIds() uint16
+	// GetComId returns the ComID commands are sent on
+	GetComId() uint16
+	// StackReset aborts every session on the ComID with the STACK_RESET ComID management command
+	StackReset() error
+	IsAutoStackReset() bool
+	// SetAutoStackReset makes session starts reset the stack and retry once when the TPer has no free session
+	SetAutoStackReset(enable bool)
+	// GetProperties returns the communication properties agreed with the TPer
+	GetProperties() *TcgProperties
+	// GetBlockSIDFeature returns the Block SID Authentication feature, nil when the drive does not support it
+	GetBlockSIDFeature() *BlockSIDFeature
+	// BlockSID blocks authentication as SID until the next power cycle
+	BlockSID(clearOnHardwareReset bool) error
+	// Random returns n bytes of the random number generator of the TPer
+	Random(n int) ([]byte, error)
+	GetPasswordHasher() PasswordHasher
+	// SetPasswordHasher sets the hasher deriving the PINs of the drive from passwords, for drives provisioned by
+	// another tool
+	SetPasswordHasher(hasher PasswordHasher)
 
 	Exec(cmd *TcgCommand, protocol uint8) (*TcgResponse, error)
 
@@ -38,6 +59,13 @@ type TcgDevice interface {
 
 	OpalGetTable(session *TcgSession, table []uint8, startCol, endCol uint16) (*TcgResponse, error)
 	RevertTPer(password string, isPsid, isAdminSp bool) error
+
+	// TakeOwnership changes the SID password from the MSID to newPassword
+	TakeOwnership(newPassword string) error
+	// ActivateLockingSP activates the Locking SP, Admin1 of the Locking SP gets the SID password
+	ActivateLockingSP(sidPassword string) error
+	// SetAdmin1Password changes the password of Admin1 of the Locking SP
+	SetAdmin1Password(password string, newPassword string) error
 }
 
 type TcgDeviceImpl struct {
@@ -53,31 +81,48 @@ func NewTcgDevice(dch DriveCommandHandler) (TcgDevice, error) {
 	}
 	base.dev = &base
 
+	var tcgDevice TcgDevice = &base
 	switch {
 	case tcgDriveHandle.TcgOpalSscV100:
 		device := &TcgDeviceOpal1{
 			base,
 		}
 		device.dev = device
-
-		return device, nil
+		tcgDevice = device
 	case tcgDriveHandle.TcgOpalSscV200:
 		device := &TcgDeviceOpal2{
 			base,
 		}
 		device.dev = device
-
-		return device, nil
+		tcgDevice = device
 	case tcgDriveHandle.TcgEnterprise:
 		device := &TcgDeviceEnterprise{
 			base,
 		}
 		device.dev = device
+		tcgDevice = device
+	case tcgDriveHandle.TcgRuby:
+		device := &TcgDeviceRuby{
+			base,
+		}
+		device.dev = device
+		tcgDevice = device
+	case tcgDriveHandle.TcgPyriteV100, tcgDriveHandle.TcgPyriteV200:
+		device := &TcgDevicePyrite{
+			base,
+		}
+		device.dev = device
+		tcgDevice = device
+	}
 
-		return device, nil
+	// the default properties stay in use when the TPer rejects the exchange
+	if tcgDevice.IsAnySSC() {
+		if properties, err := ExchangeProperties(tcgDevice); err == nil {
+			tcgDriveHandle.Properties = properties
+		}
 	}
 
-	return &base, nil
+	return tcgDevice, nil
 }
 
 func (p *TcgDeviceImpl) GetSerial() string {
@@ -97,78 +142,28 @@ func (p *TcgDeviceImpl) IsLockingSupported() bool {
 }
 
 func (p *TcgDeviceImpl) IsLockingEnabled() bool {
-	tcgDh := p.dh
-	if !tcgDh.TcgLocking {
-		return false
-	}
-	data, ok := tcgDh.TcgRawFeatures[uint16(FcLocking)]
-	if !ok {
-		return false
-	}
-
-	feature := (*Discovery0LockingFeature)(unsafe.Pointer(&data[0]))
-
-	return (feature.B04>>1)&0x01 != 0
+	feature := p.dh.TcgDiscovery.Locking
+	return feature != nil && feature.LockingEnabled
 }
 
 func (p *TcgDeviceImpl) IsLocked() bool {
-	tcgDh := p.dh
-	if !tcgDh.TcgLocking {
-		return false
-	}
-	data, ok := tcgDh.TcgRawFeatures[uint16(FcLocking)]
-	if !ok {
-		return false
-	}
-
-	feature := (*Discovery0LockingFeature)(unsafe.Pointer(&data[0]))
-
-	return (feature.B04>>2)&0x01 != 0
+	feature := p.dh.TcgDiscovery.Locking
+	return feature != nil && feature.Locked
 }
 
 func (p *TcgDeviceImpl) IsMBREnabled() bool {
-	tcgDh := p.dh
-	if !tcgDh.TcgLocking {
-		return false
-	}
-	data, ok := tcgDh.TcgRawFeatures[uint16(FcLocking)]
-	if !ok {
-		return false
-	}
-
-	feature := (*Discovery0LockingFeature)(unsafe.Pointer(&data[0]))
-
-	return (feature.B04>>4)&0x01 != 0
+	feature := p.dh.TcgDiscovery.Locking
+	return feature != nil && feature.MBREnabled
 }
 
 func (p *TcgDeviceImpl) IsMBRDone() bool {
-	tcgDh := p.dh
-	if !tcgDh.TcgLocking {
-		return false
-	}
-	data, ok := tcgDh.TcgRawFeatures[uint16(FcLocking)]
-	if !ok {
-		return false
-	}
-
-	feature := (*Discovery0LockingFeature)(unsafe.Pointer(&data[0]))
-
-	return (feature.B04>>5)&0x01 != 0
+	feature := p.dh.TcgDiscovery.Locking
+	return feature != nil && feature.MBRDone
 }
 
 func (p *TcgDeviceImpl) IsMediaEncryption() bool {
-	tcgDh := p.dh
-	if !tcgDh.TcgLocking {
-		return false
-	}
-	data, ok := tcgDh.TcgRawFeatures[uint16(FcLocking)]
-	if !ok {
-		return false
-	}
-
-	feature := (*Discovery0LockingFeature)(unsafe.Pointer(&data[0]))
-
-	return (feature.B04>>3)&0x01 != 0
+	feature := p.dh.TcgDiscovery.Locking
+	return feature != nil && feature.MediaEncryption
 }
 
 func (p *TcgDeviceImpl) Exec(cmd *TcgCommand, protocol uint8) (*TcgResponse, error) {
@@ -178,19 +173,22 @@ func (p *TcgDeviceImpl) Exec(cmd *TcgCommand, protocol uint8) (*TcgResponse, err
 	if !p.dev.IsAnySSC() {
 		return nil, fmt.Errorf("not supported")
 	}
+	if cmd.size > p.dh.Properties.MaxComPacketSize {
+		return nil, ErrComPacketTooLarge
+	}
 
-	if err := p.dh.SecurityCommand(true, false, protocol, p.dev.GetBaseComId(), unsafe.Slice((*byte)(unsafe.Pointer(cmd.GetCmdPtr())), cmd.GetCmdSize()), 5); err != nil {
+	if err := p.dh.SecurityCommand(true, false, protocol, p.dev.GetComId(), unsafe.Slice((*byte)(unsafe.Pointer(cmd.GetCmdPtr())), cmd.GetCmdSize()), 5); err != nil {
 		return nil, err
 	}
 
-	resp := NewTcgResponse()
+	resp := NewTcgResponse(p.dh.Properties.responseBufferSize())
 	for first := true; first || (resp.header.Cp.Outstanding != 0 && resp.header.Cp.MinTransfer == 0); {
 		spentTime := time.Since(beginAt)
 		time.Sleep(25 * time.Millisecond)
 		resp.Reset()
 		first = false
 
-		if err := p.dh.SecurityCommand(false, false, protocol, p.dev.GetBaseComId(), unsafe.Slice((*byte)(unsafe.Pointer(resp.GetRespBuf())), resp.GetRespBufSize()), 5); err != nil {
+		if err := p.dh.SecurityCommand(false, false, protocol, p.dev.GetComId(), unsafe.Slice((*byte)(unsafe.Pointer(resp.GetRespBuf())), resp.GetRespBufSize()), 5); err != nil {
 			return resp, err
 		}
 
@@ -214,6 +212,10 @@ func (p *TcgDeviceImpl) GetNumComIds() uint16 {
 	return 0
 }
 
+func (p *TcgDeviceImpl) GetProperties() *TcgProperties {
+	return p.dh.Properties
+}
+
 func (p *TcgDeviceImpl) GetDefaultPassword() (string, error) {
 	return "", fmt.Errorf("not supported")
 }
@@ -225,3 +227,15 @@ func (p *TcgDeviceImpl) OpalGetTable(session *TcgSession, table []uint8, startCo
 func (p *TcgDeviceImpl) RevertTPer(password string, isPsid bool, isAdminSp bool) error {
 	return fmt.Errorf("not supported")
 }
+
+func (p *TcgDeviceImpl) TakeOwnership(newPassword string) error {
+	return fmt.Errorf("not supported")
+}
+
+func (p *TcgDeviceImpl) ActivateLockingSP(sidPassword string) error {
+	return fmt.Errorf("not supported")
+}
+
+func (p *TcgDeviceImpl) SetAdmin1Password(password string, newPassword string) error {
+	return fmt.Errorf("not supported")
+}
@@ -1,40 +1,31 @@
 package tcg
 
-import (
-	"encoding/binary"
-	"unsafe"
-)
-
 type TcgDeviceEnterprise struct {
 	TcgDeviceImpl
 }
 
+func (p *TcgDeviceEnterprise) IsAnySSC() bool {
+	return true
+}
+
 func (p *TcgDeviceEnterprise) GetDeviceType() TcgDeviceType {
 	return OpalEnterpriseDevice
 }
 
 func (p *TcgDeviceEnterprise) GetBaseComId() uint16 {
-	tcgDh := p.dh
-	rawBuf, ok := tcgDh.TcgRawFeatures[uint16(FcEnterprise)]
-	if !ok {
+	feature := p.dh.TcgDiscovery.Enterprise
+	if feature == nil {
 		return 0
 	}
-
-	feature := (*Discovery0EnterpriseSSCFeature)(unsafe.Pointer(&rawBuf[0]))
-
-	return binary.BigEndian.Uint16(unsafe.Slice((*byte)(unsafe.Pointer(&feature.BaseComID)), 2))
+	return feature.BaseComID
 }
 
 func (p *TcgDeviceEnterprise) GetNumComIds() uint16 {
-	tcgDh := p.dh
-	rawBuf, ok := tcgDh.TcgRawFeatures[uint16(FcEnterprise)]
-	if !ok {
+	feature := p.dh.TcgDiscovery.Enterprise
+	if feature == nil {
 		return 0
 	}
-
-	feature := (*Discovery0EnterpriseSSCFeature)(unsafe.Pointer(&rawBuf[0]))
-
-	return binary.BigEndian.Uint16(unsafe.Slice((*byte)(unsafe.Pointer(&feature.NumberComIDs)), 2))
+	return feature.NumComIDs
 }
 
 func (p *TcgDeviceEnterprise) RevertTPer(password string, isPsid, isAdminSp bool) error {
@@ -50,6 +41,7 @@ func (p *TcgDeviceEnterprise) RevertTPer(password string, isPsid, isAdminSp bool
 	if err := sess.Start(ADMINSP_UID, password, uid); err != nil {
 		return err
 	}
+	defer sess.Close()
 
 	if isAdminSp {
 		cmd.Init(ADMINSP_UID, REVERT)
@@ -60,19 +52,17 @@ func (p *TcgDeviceEnterprise) RevertTPer(password string, isPsid, isAdminSp bool
 	cmd.AddToken(ENDLIST)
 	cmd.Complete()
 
-	sess.NoAutoClose()
-
 	_, err := sess.SendCommand(cmd)
-	if err != nil {
-		return err
+	if err == nil {
+		sess.NoAutoClose()
 	}
 
-	return nil
+	return err
 }
 
 func (p *TcgDeviceEnterprise) EnterpriseGetTable(session *TcgSession, table []uint8, startCol, endCol []uint8) (*TcgResponse, error) {
 	cmd := NewTcgCommand()
-	cmd.Init(Buf(table), GET)
+	cmd.Init(Buf(table), EGET)
 	cmd.AddToken(STARTLIST)
 
 	cmd.AddToken(STARTLIST)
@@ -101,6 +91,7 @@ func (p *TcgDeviceEnterprise) GetDefaultPassword() (string, error) {
 	if err := session.Start(ADMINSP_UID, "", UID_HEXFF); err != nil {
 		return "", err
 	}
+	defer session.Close()
 
 	table := append([]uint8{uint8(BYTESTRING8)}, C_PIN_MSID[:]...)
 
@@ -109,10 +100,10 @@ func (p *TcgDeviceEnterprise) GetDefaultPassword() (string, error) {
 		return "", err
 	}
 
-	passwdToken := resp.GetToken(4)
-	if passwdToken == nil {
-		return "", ErrIllegalResponse
+	passwd, err := resp.Result().Named("PIN").Bytes()
+	if err != nil {
+		return "", err
 	}
 
-	return passwdToken.GetString()
+	return string(passwd), nil
 }
@@ -0,0 +1,100 @@
+package tcg_test
+
+import (
+	"testing"
+
+	"github.com/jc-lab/go-dparm/tcg"
+	"github.com/jc-lab/go-dparm/test/tcg_sim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEnterpriseDevice(t *testing.T, sim *tcg_sim.TPer) *tcg.TcgDeviceEnterprise {
+	device, err := tcg.NewTcgDevice(sim)
+	require.NoError(t, err)
+	require.Equal(t, tcg.OpalEnterpriseDevice, device.GetDeviceType())
+	return device.(*tcg.TcgDeviceEnterprise)
+}
+
+func TestEnterpriseBands(t *testing.T) {
+	config := tcg_sim.DefaultConfig()
+	config.SSC = tcg_sim.SSCEnterprise
+	config.MaxRanges = 4
+	sim := tcg_sim.NewTPer(config)
+	device := testEnterpriseDevice(t, sim)
+	assert.True(t, device.IsAnySSC())
+
+	msid, err := device.GetDefaultPassword()
+	require.NoError(t, err)
+	assert.Equal(t, config.MSID, msid)
+
+	require.NoError(t, device.TakeOwnership("owner"))
+	ranges, err := device.GetLockingRanges("owner")
+	require.NoError(t, err)
+	require.Len(t, ranges, 5)
+	for i, band := range ranges {
+		assert.Equal(t, uint8(i), band.Index)
+		assert.True(t, band.LockOnReset)
+		assert.False(t, band.ReadLocked)
+	}
+
+	require.NoError(t, device.SetBandMasterPassword(1, "owner", "band1"))
+	require.NoError(t, device.SetupLockingRange("band1", 1, 0x1000, 0x800))
+	require.NoError(t, device.ConfigureLockingRange("band1", 1, true, true, true))
+	require.NoError(t, device.SetLockingState("band1", 1, tcg.READONLY))
+	assert.Error(t, device.SetupLockingRange("owner", 0, 0, 0x800))
+
+	band, err := device.GetLockingRange("band1", 1)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0x1000), band.RangeStart)
+	assert.Equal(t, uint64(0x800), band.RangeLength)
+	assert.True(t, band.ReadLockEnabled)
+	assert.True(t, band.WriteLockEnabled)
+	assert.False(t, band.ReadLocked)
+	assert.True(t, band.WriteLocked)
+
+	// the BandMaster of band 1 can not be authenticated with another password
+	_, err = device.GetLockingRange("owner", 1)
+	assert.Error(t, err)
+	assert.Error(t, device.SetLockingState("band1", 2, tcg.READWRITE))
+	assert.Error(t, device.EraseLockingRange("band1", 1))
+	assert.Equal(t, 0, sim.OpenSessions())
+
+	sim.PowerCycle()
+	band, err = device.GetLockingRange("band1", 1)
+	require.NoError(t, err)
+	assert.True(t, band.ReadLocked)
+	assert.True(t, band.WriteLocked)
+
+	require.NoError(t, device.SetEraseMasterPassword("owner", "erase"))
+	// Erase resets the BandMaster PIN to the MSID, the password of the options is set again
+	require.NoError(t, device.EraseLockingRange("erase", 1, tcg.EraseOptions{BandMasterPassword: "band1"}))
+	band, err = device.GetLockingRange("band1", 1)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0x1000), band.RangeStart)
+	assert.False(t, band.ReadLockEnabled)
+	assert.False(t, band.ReadLocked)
+	assert.False(t, band.WriteLocked)
+	assert.Equal(t, 0, sim.OpenSessions())
+}
+
+func TestEnterpriseRevertTPer(t *testing.T) {
+	config := tcg_sim.DefaultConfig()
+	config.SSC = tcg_sim.SSCEnterprise
+	sim := tcg_sim.NewTPer(config)
+	device := testEnterpriseDevice(t, sim)
+	require.NoError(t, device.TakeOwnership("owner"))
+
+	// RevertSP can not be invoked in the AdminSP, the failed session is closed
+	assert.Error(t, device.RevertTPer("owner", false, false))
+	assert.Equal(t, 0, sim.OpenSessions())
+
+	require.NoError(t, device.RevertTPer("owner", false, true))
+	assert.Equal(t, 0, sim.OpenSessions())
+	msid, err := device.GetDefaultPassword()
+	require.NoError(t, err)
+	session := tcg.NewTcgSession(device)
+	session.SetNoHashPassword(true)
+	require.NoError(t, session.Start(tcg.ADMINSP_UID, msid, tcg.SID_UID))
+	session.Close()
+}
@@ -1,11 +1,5 @@
 package tcg
 
-import (
-	"encoding/binary"
-
-	"unsafe"
-)
-
 type TcgDeviceOpal1 struct {
 	TcgDeviceImpl
 }
@@ -19,27 +13,19 @@ func (p *TcgDeviceOpal1) GetDeviceType() TcgDeviceType {
 }
 
 func (p *TcgDeviceOpal1) GetBaseComId() uint16 {
-	tcgDh := p.dh
-	rawBuf, ok := tcgDh.TcgRawFeatures[uint16(FcOpalSscV100)]
-	if !ok {
+	feature := p.dh.TcgDiscovery.OpalV100
+	if feature == nil {
 		return 0
 	}
-
-	feature := (*Discovery0OpalSSCFeatureV100)(unsafe.Pointer(&rawBuf[0]))
-
-	return binary.BigEndian.Uint16(unsafe.Slice((*byte)(unsafe.Pointer(&feature.BaseComID)), 2))
+	return feature.BaseComID
 }
 
 func (p *TcgDeviceOpal1) GetNumComIds() uint16 {
-	tcgDh := p.dh
-	rawBuf, ok := tcgDh.TcgRawFeatures[uint16(FcOpalSscV100)]
-	if !ok {
+	feature := p.dh.TcgDiscovery.OpalV100
+	if feature == nil {
 		return 0
 	}
-
-	feature := (*Discovery0OpalSSCFeatureV100)(unsafe.Pointer(&rawBuf[0]))
-
-	return binary.BigEndian.Uint16(unsafe.Slice((*byte)(unsafe.Pointer(&feature.NumComIDs)), 2))
+	return feature.NumComIDs
 }
 
 func (p *TcgDeviceOpal1) RevertTPer(password string, isPsid, isAdminSp bool) error {
@@ -54,6 +40,129 @@ func (p *TcgDeviceOpal1) GetDefaultPassword() (string, error) {
 	return getDefaultPassword(p)
 }
 
+func (p *TcgDeviceOpal1) TakeOwnership(newPassword string) error {
+	return takeOwnership(p, newPassword)
+}
+
+func (p *TcgDeviceOpal1) ActivateLockingSP(sidPassword string) error {
+	return activateLockingSP(p, sidPassword)
+}
+
+func (p *TcgDeviceOpal1) SetAdmin1Password(password string, newPassword string) error {
+	return setAdmin1Password(p, password, newPassword)
+}
+
+// SetupLockingRange sets RangeStart and RangeLength of a numbered range as Admin1
+func (p *TcgDeviceOpal1) SetupLockingRange(password string, index uint8, start uint64, length uint64) error {
+	return setupLockingRange(p, password, index, start, length)
+}
+
+// ConfigureLockingRange sets ReadLockEnabled, WriteLockEnabled and LockOnReset of a range as Admin1
+func (p *TcgDeviceOpal1) ConfigureLockingRange(password string, index uint8, readLockEnabled bool, writeLockEnabled bool, lockOnReset bool) error {
+	return configureLockingRange(p, password, index, readLockEnabled, writeLockEnabled, lockOnReset)
+}
+
+// SetLockingState sets ReadLocked and WriteLocked of a range as Admin1
+func (p *TcgDeviceOpal1) SetLockingState(password string, index uint8, state OpalLockingState) error {
+	return setLockingState(p, password, index, state)
+}
+
+func (p *TcgDeviceOpal1) GetLockingRange(password string, index uint8) (*LockingRange, error) {
+	return getLockingRange(p, password, index)
+}
+
+// GetLockingRanges returns the global range followed by every numbered range
+func (p *TcgDeviceOpal1) GetLockingRanges(password string) ([]LockingRange, error) {
+	return getLockingRanges(p, password)
+}
+
+// EraseLockingRange cryptographically erases a range as Admin1 with GenKey on its ActiveKey
+func (p *TcgDeviceOpal1) EraseLockingRange(password string, index uint8, options ...EraseOptions) error {
+	return eraseLockingRange(p, password, index, eraseOptionsOf(options))
+}
+
+// SetMBREnable sets the Enable column of MBRControl as Admin1
+func (p *TcgDeviceOpal1) SetMBREnable(password string, enable bool) error {
+	return setMBRControl(p, password, MBRENABLE, enable)
+}
+
+// SetMBRDone sets the Done column of MBRControl as Admin1
+func (p *TcgDeviceOpal1) SetMBRDone(password string, done bool) error {
+	return setMBRControl(p, password, MBRDONE, done)
+}
+
+// WriteMBR uploads a pre-boot authentication image to the shadow MBR as Admin1 and verifies it
+func (p *TcgDeviceOpal1) WriteMBR(password string, image []byte) error {
+	return writeMBR(p, password, image)
+}
+
+func (p *TcgDeviceOpal1) ReadMBR(password string, offset uint64, length int) ([]byte, error) {
+	return getMBR(p, password, offset, length)
+}
+
+// GetDataStoreFeature returns the Additional DataStore Tables feature, nil when the drive has a single DataStore table
+func (p *TcgDeviceOpal1) GetDataStoreFeature() *DataStoreFeature {
+	return p.dh.TcgDiscovery.DataStore
+}
+
+// GetDataStoreSize returns the size of a DataStore table as Admin1
+func (p *TcgDeviceOpal1) GetDataStoreSize(password string, index uint8) (uint64, error) {
+	return getDataStoreSize(p, p.GetDataStoreFeature(), password, index)
+}
+
+// ReadDataStore reads length bytes at offset of a DataStore table as Admin1
+func (p *TcgDeviceOpal1) ReadDataStore(password string, index uint8, offset uint64, length int) ([]byte, error) {
+	return readDataStore(p, p.GetDataStoreFeature(), password, index, offset, length)
+}
+
+// WriteDataStore writes data at offset of a DataStore table as Admin1
+func (p *TcgDeviceOpal1) WriteDataStore(password string, index uint8, offset uint64, data []byte) error {
+	return writeDataStore(p, p.GetDataStoreFeature(), password, index, offset, data)
+}
+
+// GetAuthorities returns every row of the Authority table of the Locking SP
+func (p *TcgDeviceOpal1) GetAuthorities(password string) ([]Authority, error) {
+	return getAuthorities(p, password)
+}
+
+// SetUserEnabled enables or disables UserN of the Locking SP as Admin1
+func (p *TcgDeviceOpal1) SetUserEnabled(password string, user uint8, enabled bool) error {
+	return setAuthorityEnabled(p, password, UserUID(user), enabled)
+}
+
+// SetUserPassword sets the C_PIN of UserN of the Locking SP as Admin1
+func (p *TcgDeviceOpal1) SetUserPassword(password string, user uint8, newPassword string) error {
+	return setAuthorityPassword(p, password, UserUID(user), newPassword)
+}
+
+// AddUserToLockingRange allows UserN to lock and unlock a range
+func (p *TcgDeviceOpal1) AddUserToLockingRange(password string, user uint8, index uint8) error {
+	return setLockingRangeAccess(p, password, UserUID(user), index, true)
+}
+
+// RemoveUserFromLockingRange revokes the access of UserN to a range
+func (p *TcgDeviceOpal1) RemoveUserFromLockingRange(password string, user uint8, index uint8) error {
+	return setLockingRangeAccess(p, password, UserUID(user), index, false)
+}
+
+// SetLockingStateAs sets ReadLocked and WriteLocked of a range as the authority, a user added to the range for example
+func (p *TcgDeviceOpal1) SetLockingStateAs(authority OpalUID, password string, index uint8, state OpalLockingState) error {
+	return setLockingStateAs(p, authority, password, index, state)
+}
+
+// GetTryLimits returns the try limit and failed authentications of SID and of the Admins and Users of the Locking SP,
+// an empty password reads them as Anybody and leaves out the ones the ACL hides
+func (p *TcgDeviceOpal1) GetTryLimits(sidPassword string, admin1Password string) ([]TryLimitStatus, error) {
+	// the Opal 1.00 feature does not report the authorities
+	return getTryLimits(p, 0, 0, sidPassword, admin1Password)
+}
+
+// GetACL returns the ACEs granting a method invoked on a table or an object of the Locking SP with the authorities
+// of their BooleanExpr, SET on LockingRangeUID(1) for example names who can lock and unlock range 1
+func (p *TcgDeviceOpal1) GetACL(password string, invoking OpalUID, method OpalMethod) ([]ACE, error) {
+	return getACL(p, password, invoking, method)
+}
+
 type TcgDeviceOpal2 struct {
 	TcgDeviceImpl
 }
@@ -67,27 +176,19 @@ func (p *TcgDeviceOpal2) GetDeviceType() TcgDeviceType {
 }
 
 func (p *TcgDeviceOpal2) GetBaseComId() uint16 {
-	tcgDh := p.dh
-	rawBuf, ok := tcgDh.TcgRawFeatures[uint16(FcOpalSscV200)]
-	if !ok {
+	feature := p.dh.TcgDiscovery.OpalV200
+	if feature == nil {
 		return 0
 	}
-
-	feature := (*Discovery0OpalSSCFeatureV200)(unsafe.Pointer(&rawBuf[0]))
-
-	return binary.BigEndian.Uint16(unsafe.Slice((*byte)(unsafe.Pointer(&feature.BaseComID)), 2))
+	return feature.BaseComID
 }
 
 func (p *TcgDeviceOpal2) GetNumComIds() uint16 {
-	tcgDh := p.dh
-	rawBuf, ok := tcgDh.TcgRawFeatures[uint16(FcOpalSscV200)]
-	if !ok {
+	feature := p.dh.TcgDiscovery.OpalV200
+	if feature == nil {
 		return 0
 	}
-
-	feature := (*Discovery0OpalSSCFeatureV200)(unsafe.Pointer(&rawBuf[0]))
-
-	return binary.BigEndian.Uint16(unsafe.Slice((*byte)(unsafe.Pointer(&feature.NumComIDs)), 2))
+	return feature.NumComIDs
 }
 
 func (p *TcgDeviceOpal2) RevertTPer(password string, isPsid, isAdminSp bool) error {
@@ -102,6 +203,153 @@ func (p *TcgDeviceOpal2) GetDefaultPassword() (string, error) {
 	return getDefaultPassword(p)
 }
 
+func (p *TcgDeviceOpal2) TakeOwnership(newPassword string) error {
+	return takeOwnershipSSC(p, p.dh.TcgDiscovery.OpalV200, newPassword)
+}
+
+func (p *TcgDeviceOpal2) ActivateLockingSP(sidPassword string) error {
+	return activateLockingSP(p, sidPassword)
+}
+
+func (p *TcgDeviceOpal2) SetAdmin1Password(password string, newPassword string) error {
+	return setAdmin1Password(p, password, newPassword)
+}
+
+// SetupLockingRange sets RangeStart and RangeLength of a numbered range as Admin1
+func (p *TcgDeviceOpal2) SetupLockingRange(password string, index uint8, start uint64, length uint64) error {
+	return setupLockingRange(p, password, index, start, length)
+}
+
+// ConfigureLockingRange sets ReadLockEnabled, WriteLockEnabled and LockOnReset of a range as Admin1
+func (p *TcgDeviceOpal2) ConfigureLockingRange(password string, index uint8, readLockEnabled bool, writeLockEnabled bool, lockOnReset bool) error {
+	return configureLockingRange(p, password, index, readLockEnabled, writeLockEnabled, lockOnReset)
+}
+
+// SetLockingState sets ReadLocked and WriteLocked of a range as Admin1
+func (p *TcgDeviceOpal2) SetLockingState(password string, index uint8, state OpalLockingState) error {
+	return setLockingState(p, password, index, state)
+}
+
+func (p *TcgDeviceOpal2) GetLockingRange(password string, index uint8) (*LockingRange, error) {
+	return getLockingRange(p, password, index)
+}
+
+// GetLockingRanges returns the global range followed by every numbered range
+func (p *TcgDeviceOpal2) GetLockingRanges(password string) ([]LockingRange, error) {
+	return getLockingRanges(p, password)
+}
+
+// EraseLockingRange cryptographically erases a range as Admin1 with GenKey on its ActiveKey
+func (p *TcgDeviceOpal2) EraseLockingRange(password string, index uint8, options ...EraseOptions) error {
+	return eraseLockingRange(p, password, index, eraseOptionsOf(options))
+}
+
+// SetMBREnable sets the Enable column of MBRControl as Admin1
+func (p *TcgDeviceOpal2) SetMBREnable(password string, enable bool) error {
+	return setMBRControl(p, password, MBRENABLE, enable)
+}
+
+// SetMBRDone sets the Done column of MBRControl as Admin1
+func (p *TcgDeviceOpal2) SetMBRDone(password string, done bool) error {
+	return setMBRControl(p, password, MBRDONE, done)
+}
+
+// WriteMBR uploads a pre-boot authentication image to the shadow MBR as Admin1 and verifies it
+func (p *TcgDeviceOpal2) WriteMBR(password string, image []byte) error {
+	return writeMBR(p, password, image)
+}
+
+func (p *TcgDeviceOpal2) ReadMBR(password string, offset uint64, length int) ([]byte, error) {
+	return getMBR(p, password, offset, length)
+}
+
+// GetDataStoreFeature returns the Additional DataStore Tables feature, nil when the drive has a single DataStore table
+func (p *TcgDeviceOpal2) GetDataStoreFeature() *DataStoreFeature {
+	return p.dh.TcgDiscovery.DataStore
+}
+
+// GetDataStoreSize returns the size of a DataStore table as Admin1
+func (p *TcgDeviceOpal2) GetDataStoreSize(password string, index uint8) (uint64, error) {
+	return getDataStoreSize(p, p.GetDataStoreFeature(), password, index)
+}
+
+// ReadDataStore reads length bytes at offset of a DataStore table as Admin1
+func (p *TcgDeviceOpal2) ReadDataStore(password string, index uint8, offset uint64, length int) ([]byte, error) {
+	return readDataStore(p, p.GetDataStoreFeature(), password, index, offset, length)
+}
+
+// WriteDataStore writes data at offset of a DataStore table as Admin1
+func (p *TcgDeviceOpal2) WriteDataStore(password string, index uint8, offset uint64, data []byte) error {
+	return writeDataStore(p, p.GetDataStoreFeature(), password, index, offset, data)
+}
+
+// GetAuthorities returns every row of the Authority table of the Locking SP
+func (p *TcgDeviceOpal2) GetAuthorities(password string) ([]Authority, error) {
+	return getAuthorities(p, password)
+}
+
+// SetUserEnabled enables or disables UserN of the Locking SP as Admin1
+func (p *TcgDeviceOpal2) SetUserEnabled(password string, user uint8, enabled bool) error {
+	return setAuthorityEnabled(p, password, UserUID(user), enabled)
+}
+
+// SetUserPassword sets the C_PIN of UserN of the Locking SP as Admin1
+func (p *TcgDeviceOpal2) SetUserPassword(password string, user uint8, newPassword string) error {
+	return setAuthorityPassword(p, password, UserUID(user), newPassword)
+}
+
+// AddUserToLockingRange allows UserN to lock and unlock a range
+func (p *TcgDeviceOpal2) AddUserToLockingRange(password string, user uint8, index uint8) error {
+	return setLockingRangeAccess(p, password, UserUID(user), index, true)
+}
+
+// RemoveUserFromLockingRange revokes the access of UserN to a range
+func (p *TcgDeviceOpal2) RemoveUserFromLockingRange(password string, user uint8, index uint8) error {
+	return setLockingRangeAccess(p, password, UserUID(user), index, false)
+}
+
+// SetLockingStateAs sets ReadLocked and WriteLocked of a range as the authority, a user added to the range for example
+func (p *TcgDeviceOpal2) SetLockingStateAs(authority OpalUID, password string, index uint8, state OpalLockingState) error {
+	return setLockingStateAs(p, authority, password, index, state)
+}
+
+// GetTryLimits returns the try limit and failed authentications of SID and of the Admins and Users of the Locking SP,
+// an empty password reads them as Anybody and leaves out the ones the ACL hides
+func (p *TcgDeviceOpal2) GetTryLimits(sidPassword string, admin1Password string) ([]TryLimitStatus, error) {
+	var numAdmins, numUsers uint16
+	if feature := p.dh.TcgDiscovery.OpalV200; feature != nil {
+		numAdmins, numUsers = feature.NumLockingAdmins, feature.NumLockingUsers
+	}
+	return getTryLimits(p, numAdmins, numUsers, sidPassword, admin1Password)
+}
+
+// GetACL returns the ACEs granting a method invoked on a table or an object of the Locking SP with the authorities
+// of their BooleanExpr, SET on LockingRangeUID(1) for example names who can lock and unlock range 1
+func (p *TcgDeviceOpal2) GetACL(password string, invoking OpalUID, method OpalMethod) ([]ACE, error) {
+	return getACL(p, password, invoking, method)
+}
+
+// GetSingleUserFeature returns the Single User Mode feature, nil when the drive does not support it
+func (p *TcgDeviceOpal2) GetSingleUserFeature() *SingleUserFeature {
+	return p.dh.TcgDiscovery.SingleUser
+}
+
+// ActivateLockingSPSingleUser activates the Locking SP with ranges in Single User Mode, the owners of the ranges
+// start with the SID password
+func (p *TcgDeviceOpal2) ActivateLockingSPSingleUser(sidPassword string, mode SingleUserMode) error {
+	return activateLockingSPSingleUser(p, p.GetSingleUserFeature(), sidPassword, mode)
+}
+
+// ReactivateLockingSP changes the ranges in Single User Mode as Admin1, an empty newAdmin1Password keeps the password
+func (p *TcgDeviceOpal2) ReactivateLockingSP(password string, mode SingleUserMode, newAdmin1Password string) error {
+	return reactivateLockingSP(p, p.GetSingleUserFeature(), password, mode, newAdmin1Password)
+}
+
+// SetSingleUserPassword changes the password of the owner of a range in Single User Mode
+func (p *TcgDeviceOpal2) SetSingleUserPassword(index uint8, password string, newPassword string) error {
+	return setSingleUserPassword(p, index, password, newPassword)
+}
+
 func revertTPer(device TcgDevice, password string, isPsid bool) error {
 	sess := NewTcgSession(device)
 
@@ -114,6 +362,7 @@ func revertTPer(device TcgDevice, password string, isPsid bool) error {
 	if err := sess.Start(ADMINSP_UID, password, uid); err != nil {
 		return err
 	}
+	defer sess.Close()
 
 	cmd := NewTcgCommand()
 	cmd.Init(ADMINSP_UID, REVERT)
@@ -160,6 +409,7 @@ func getDefaultPassword(device TcgDevice) (string, error) {
 	if err := session.Start(ADMINSP_UID, "", UID_HEXFF); err != nil {
 		return "", err
 	}
+	defer session.Close()
 
 	msid := C_PIN_MSID
 	table := append([]uint8{uint8(BYTESTRING8)}, msid[:]...)
@@ -169,10 +419,75 @@ func getDefaultPassword(device TcgDevice) (string, error) {
 		return "", err
 	}
 
-	passwdToken := resp.GetToken(4)
-	if passwdToken == nil {
-		return "", ErrIllegalResponse
+	passwd, err := resp.Result().Named(CREDENTIAL_PIN).Bytes()
+	if err != nil {
+		return "", err
+	}
+
+	return string(passwd), nil
+}
+
+// SetCPin sets the PIN column of a C_PIN object, the password is hashed with the hasher of the drive rather than the
+// hasher the session authenticated with
+func SetCPin(session *TcgSession, cpin OpalUID, password string) error {
+	cmd := NewTcgCommand()
+	cmd.Init(cpin, SET)
+	cmd.AddToken(STARTLIST)
+	cmd.AddToken(STARTNAME)
+	cmd.AddToken(VALUES)
+	cmd.AddToken(STARTLIST)
+	cmd.AddToken(STARTNAME)
+	cmd.AddToken(CREDENTIAL_PIN)
+	cmd.AddRawToken(pinToken(hashPassword(session.tcgDevice, password)))
+	cmd.AddToken(ENDNAME)
+	cmd.AddToken(ENDLIST)
+	cmd.AddToken(ENDNAME)
+	cmd.AddToken(ENDLIST)
+	cmd.Complete()
+
+	_, err := session.SendCommand(cmd)
+	return err
+}
+
+func takeOwnership(device TcgDevice, newPassword string) error {
+	msid, err := device.GetDefaultPassword()
+	if err != nil {
+		return err
+	}
+
+	session := NewTcgSession(device)
+	session.SetNoHashPassword(true)
+	if err := session.Start(ADMINSP_UID, msid, SID_UID); err != nil {
+		return err
+	}
+	defer session.Close()
+
+	return SetCPin(session, C_PIN_SID, newPassword)
+}
+
+func activateLockingSP(device TcgDevice, sidPassword string) error {
+	session := NewTcgSession(device)
+	if err := session.Start(ADMINSP_UID, sidPassword, SID_UID); err != nil {
+		return err
+	}
+	defer session.Close()
+
+	cmd := NewTcgCommand()
+	cmd.Init(LOCKINGSP_UID, ACTIVATE)
+	cmd.AddToken(STARTLIST)
+	cmd.AddToken(ENDLIST)
+	cmd.Complete()
+
+	_, err := session.SendCommand(cmd)
+	return err
+}
+
+func setAdmin1Password(device TcgDevice, password string, newPassword string) error {
+	session := NewTcgSession(device)
+	if err := session.Start(LOCKINGSP_UID, password, ADMIN1_UID); err != nil {
+		return err
 	}
+	defer session.Close()
 
-	return passwdToken.GetString()
+	return SetCPin(session, C_PIN_ADMIN1, newPassword)
 }
@@ -0,0 +1,291 @@
+package tcg_test
+
+import (
+	"testing"
+
+	"github.com/jc-lab/go-dparm/tcg"
+	"github.com/jc-lab/go-dparm/test/tcg_sim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testOpalDevice(t *testing.T, dch tcg.DriveCommandHandler) tcg.TcgDevice {
+	device, err := tcg.NewTcgDevice(dch)
+	require.NoError(t, err)
+	return device
+}
+
+func setGlobalLocked(session *tcg.TcgSession, locked bool) error {
+	state := tcg.UINT_00
+	if locked {
+		state = tcg.UINT_01
+	}
+
+	cmd := tcg.NewTcgCommand()
+	cmd.Init(tcg.LOCKINGRANGE_GLOBAL, tcg.SET)
+	cmd.AddToken(tcg.STARTLIST)
+	cmd.AddToken(tcg.STARTNAME)
+	cmd.AddToken(tcg.VALUES)
+	cmd.AddToken(tcg.STARTLIST)
+	for _, col := range []tcg.OpalToken{tcg.LOCKING_READ_LOCK_ENABLED, tcg.LOCKING_WRITE_LOCK_ENABLED} {
+		cmd.AddToken(tcg.STARTNAME)
+		cmd.AddToken(col)
+		cmd.AddToken(tcg.UINT_01)
+		cmd.AddToken(tcg.ENDNAME)
+	}
+	for _, col := range []tcg.OpalToken{tcg.LOCKING_READ_LOCKED, tcg.LOCKING_WRITE_LOCKED} {
+		cmd.AddToken(tcg.STARTNAME)
+		cmd.AddToken(col)
+		cmd.AddToken(state)
+		cmd.AddToken(tcg.ENDNAME)
+	}
+	cmd.AddToken(tcg.ENDLIST)
+	cmd.AddToken(tcg.ENDNAME)
+	cmd.AddToken(tcg.ENDLIST)
+	cmd.Complete()
+
+	_, err := session.SendCommand(cmd)
+	return err
+}
+
+func TestOpal2Discovery(t *testing.T) {
+	sim := tcg_sim.NewTPer(tcg_sim.DefaultConfig())
+	device := testOpalDevice(t, sim)
+
+	assert.Equal(t, tcg.OpalV2Device, device.GetDeviceType())
+	assert.True(t, device.IsAnySSC())
+	assert.Equal(t, uint16(0x07fe), device.GetBaseComId())
+	assert.Equal(t, "SIMTPER000000001", device.GetSerial())
+	assert.True(t, device.IsLockingSupported())
+	assert.False(t, device.IsLockingEnabled())
+	assert.False(t, device.IsLocked())
+}
+
+func TestGetDefaultPassword(t *testing.T) {
+	sim := tcg_sim.NewTPer(tcg_sim.DefaultConfig())
+	device := testOpalDevice(t, sim)
+
+	msid, err := device.GetDefaultPassword()
+	require.NoError(t, err)
+	assert.Equal(t, sim.GetConfig().MSID, msid)
+	assert.Equal(t, 0, sim.OpenSessions())
+}
+
+func TestSessionAuthentication(t *testing.T) {
+	sim := tcg_sim.NewTPer(tcg_sim.DefaultConfig())
+	device := testOpalDevice(t, sim)
+	msid := sim.GetConfig().MSID
+
+	session := tcg.NewTcgSession(device)
+	session.SetNoHashPassword(true)
+	require.NoError(t, session.Start(tcg.ADMINSP_UID, msid, tcg.SID_UID))
+	require.NoError(t, tcg.SetCPin(session, tcg.C_PIN_SID, "password"))
+	session.Close()
+	assert.Equal(t, 0, sim.OpenSessions())
+
+	session = tcg.NewTcgSession(device)
+	err := session.Start(tcg.ADMINSP_UID, "wrong", tcg.SID_UID)
+	var tcgErr *tcg.TcgError
+	require.ErrorAs(t, err, &tcgErr)
+	assert.Equal(t, tcg.NOT_AUTHORIZED, tcgErr.Status)
+
+	session = tcg.NewTcgSession(device)
+	require.NoError(t, session.Start(tcg.ADMINSP_UID, "password", tcg.SID_UID))
+	session.Close()
+
+	session = tcg.NewTcgSession(device)
+	require.NoError(t, session.Start(tcg.ADMINSP_UID, "", tcg.UID_HEXFF))
+	sid := append([]uint8{uint8(tcg.BYTESTRING8)}, tcg.SID_UID[:]...)
+	assert.Error(t, session.Authenticate(sid, "wrong"))
+	assert.NoError(t, session.Authenticate(sid, "password"))
+	session.Close()
+	assert.Equal(t, 0, sim.OpenSessions())
+}
+
+func TestRevertTPer(t *testing.T) {
+	config := tcg_sim.DefaultConfig()
+	sim := tcg_sim.NewTPer(config)
+	device := testOpalDevice(t, sim)
+
+	session := tcg.NewTcgSession(device)
+	session.SetNoHashPassword(true)
+	require.NoError(t, session.Start(tcg.ADMINSP_UID, config.MSID, tcg.SID_UID))
+	require.NoError(t, tcg.SetCPin(session, tcg.C_PIN_SID, "password"))
+	session.Close()
+
+	require.NoError(t, device.RevertTPer(config.PSID, true, true))
+	assert.Equal(t, 0, sim.OpenSessions())
+
+	session = tcg.NewTcgSession(device)
+	session.SetNoHashPassword(true)
+	require.NoError(t, session.Start(tcg.ADMINSP_UID, config.MSID, tcg.SID_UID))
+	require.NoError(t, tcg.SetCPin(session, tcg.C_PIN_SID, "password"))
+	session.Close()
+
+	assert.Error(t, device.RevertTPer("wrong", false, true))
+	require.NoError(t, device.RevertTPer("password", false, true))
+	assert.Equal(t, 0, sim.OpenSessions())
+
+	session = tcg.NewTcgSession(device)
+	session.SetNoHashPassword(true)
+	assert.NoError(t, session.Start(tcg.ADMINSP_UID, config.MSID, tcg.SID_UID))
+	session.Close()
+}
+
+func TestGlobalRangeLock(t *testing.T) {
+	config := tcg_sim.DefaultConfig()
+	config.LockingSPActive = true
+	sim := tcg_sim.NewTPer(config)
+	device := testOpalDevice(t, sim)
+	assert.True(t, device.IsLockingEnabled())
+
+	session := tcg.NewTcgSession(device)
+	session.SetNoHashPassword(true)
+	require.NoError(t, session.Start(tcg.LOCKINGSP_UID, config.MSID, tcg.ADMIN1_UID))
+	require.NoError(t, setGlobalLocked(session, true))
+	session.Close()
+
+	device = testOpalDevice(t, sim)
+	assert.True(t, device.IsLocked())
+
+	session = tcg.NewTcgSession(device)
+	session.SetNoHashPassword(true)
+	require.NoError(t, session.Start(tcg.LOCKINGSP_UID, config.MSID, tcg.ADMIN1_UID))
+	require.NoError(t, setGlobalLocked(session, false))
+	session.Close()
+
+	device = testOpalDevice(t, sim)
+	assert.False(t, device.IsLocked())
+
+	sim.PowerCycle()
+	device = testOpalDevice(t, sim)
+	assert.True(t, device.IsLocked())
+}
+
+func TestTakeOwnershipAndActivate(t *testing.T) {
+	config := tcg_sim.DefaultConfig()
+	sim := tcg_sim.NewTPer(config)
+	device := testOpalDevice(t, sim)
+
+	require.NoError(t, device.TakeOwnership("sid-password"))
+	assert.Equal(t, 0, sim.OpenSessions())
+
+	// the MSID is no longer the SID password
+	session := tcg.NewTcgSession(device)
+	session.SetNoHashPassword(true)
+	err := session.Start(tcg.ADMINSP_UID, config.MSID, tcg.SID_UID)
+	var tcgErr *tcg.TcgError
+	require.ErrorAs(t, err, &tcgErr)
+	assert.Equal(t, tcg.NOT_AUTHORIZED, tcgErr.Status)
+
+	assert.Error(t, device.ActivateLockingSP("wrong"))
+	require.NoError(t, device.ActivateLockingSP("sid-password"))
+	assert.Equal(t, 0, sim.OpenSessions())
+
+	device = testOpalDevice(t, sim)
+	assert.True(t, device.IsLockingEnabled())
+
+	// Admin1 starts with the SID password
+	require.NoError(t, device.SetAdmin1Password("sid-password", "admin1-password"))
+	assert.Error(t, device.SetAdmin1Password("sid-password", "other"))
+
+	session = tcg.NewTcgSession(device)
+	require.NoError(t, session.Start(tcg.LOCKINGSP_UID, "admin1-password", tcg.ADMIN1_UID))
+	require.NoError(t, setGlobalLocked(session, true))
+	session.Close()
+	assert.Equal(t, 0, sim.OpenSessions())
+
+	// activating again is harmless
+	require.NoError(t, device.ActivateLockingSP("sid-password"))
+	session = tcg.NewTcgSession(device)
+	require.NoError(t, session.Start(tcg.LOCKINGSP_UID, "admin1-password", tcg.ADMIN1_UID))
+	session.Close()
+
+	require.NoError(t, device.RevertTPer("sid-password", false, true))
+	device = testOpalDevice(t, sim)
+	assert.False(t, device.IsLockingEnabled())
+	require.NoError(t, device.TakeOwnership("sid-password"))
+}
+
+func TestLockingRanges(t *testing.T) {
+	sim := tcg_sim.NewTPer(tcg_sim.DefaultConfig())
+	device := testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+	require.NoError(t, device.TakeOwnership("sid"))
+	require.NoError(t, device.ActivateLockingSP("sid"))
+	require.NoError(t, device.SetAdmin1Password("sid", "admin1"))
+
+	require.NoError(t, device.SetupLockingRange("admin1", 1, 0x1000, 0x800))
+	require.NoError(t, device.ConfigureLockingRange("admin1", 1, true, true, true))
+	require.NoError(t, device.SetLockingState("admin1", 1, tcg.READONLY))
+	assert.Error(t, device.SetupLockingRange("admin1", 0, 0, 0x800))
+	assert.Error(t, device.SetLockingState("admin1", 1, tcg.ARCHIVELOCKED))
+	assert.Error(t, device.SetLockingState("wrong", 1, tcg.LOCKED))
+
+	lockingRange, err := device.GetLockingRange("admin1", 1)
+	require.NoError(t, err)
+	assert.Equal(t, uint8(1), lockingRange.Index)
+	assert.Equal(t, uint64(0x1000), lockingRange.RangeStart)
+	assert.Equal(t, uint64(0x800), lockingRange.RangeLength)
+	assert.True(t, lockingRange.ReadLockEnabled)
+	assert.True(t, lockingRange.WriteLockEnabled)
+	assert.False(t, lockingRange.ReadLocked)
+	assert.True(t, lockingRange.WriteLocked)
+	assert.True(t, lockingRange.LockOnReset)
+	assert.NotEqual(t, tcg.OpalUID{}, lockingRange.ActiveKey)
+
+	require.NoError(t, device.ConfigureLockingRange("admin1", 0, true, true, false))
+	require.NoError(t, device.SetLockingState("admin1", 0, tcg.LOCKED))
+
+	ranges, err := device.GetLockingRanges("admin1")
+	require.NoError(t, err)
+	require.Len(t, ranges, 9)
+	assert.Equal(t, tcg.LOCKINGRANGE_GLOBAL, tcg.LockingRangeUID(0))
+	assert.True(t, ranges[0].ReadLocked)
+	assert.False(t, ranges[0].LockOnReset)
+	assert.Equal(t, *lockingRange, ranges[1])
+	assert.Equal(t, uint8(8), ranges[8].Index)
+	assert.Equal(t, 0, sim.OpenSessions())
+
+	// range 1 locks on power cycle, the global range stays as it is
+	require.NoError(t, device.SetLockingState("admin1", 1, tcg.READWRITE))
+	require.NoError(t, device.SetLockingState("admin1", 0, tcg.READWRITE))
+	sim.PowerCycle()
+	ranges, err = device.GetLockingRanges("admin1")
+	require.NoError(t, err)
+	assert.True(t, ranges[1].ReadLocked && ranges[1].WriteLocked)
+	assert.False(t, ranges[0].ReadLocked || ranges[0].WriteLocked)
+}
+
+func TestShadowMBR(t *testing.T) {
+	sim := tcg_sim.NewTPer(tcg_sim.DefaultConfig())
+	device := testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+	require.NoError(t, device.TakeOwnership("sid"))
+	require.NoError(t, device.ActivateLockingSP("sid"))
+
+	image := make([]byte, 10000)
+	for i := range image {
+		image[i] = uint8(i * 7)
+	}
+	require.NoError(t, device.WriteMBR("sid", image))
+	assert.Error(t, device.WriteMBR("wrong", image))
+
+	data, err := device.ReadMBR("sid", 4000, 3000)
+	require.NoError(t, err)
+	assert.Equal(t, image[4000:7000], data)
+
+	require.NoError(t, device.SetMBREnable("sid", true))
+	device = testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+	assert.True(t, device.IsMBREnabled())
+	assert.False(t, device.IsMBRDone())
+
+	require.NoError(t, device.SetMBRDone("sid", true))
+	device = testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+	assert.True(t, device.IsMBRDone())
+
+	// Done is cleared on power cycle
+	sim.PowerCycle()
+	device = testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+	assert.True(t, device.IsMBREnabled())
+	assert.False(t, device.IsMBRDone())
+	assert.Equal(t, 0, sim.OpenSessions())
+}
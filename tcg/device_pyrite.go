@@ -0,0 +1,177 @@
+package tcg
+
+import (
+	"fmt"
+)
+
+// TcgDevicePyrite is a Pyrite 1.00 or 2.00 drive, it has a Locking SP with the global range only and no media encryption
+type TcgDevicePyrite struct {
+	TcgDeviceImpl
+}
+
+func (p *TcgDevicePyrite) feature() *SSCFeature {
+	if p.dh.TcgDiscovery.PyriteV200 != nil {
+		return p.dh.TcgDiscovery.PyriteV200
+	}
+	return p.dh.TcgDiscovery.PyriteV100
+}
+
+func (p *TcgDevicePyrite) IsAnySSC() bool {
+	return true
+}
+
+func (p *TcgDevicePyrite) GetDeviceType() TcgDeviceType {
+	return PyriteDevice
+}
+
+// GetVersion returns 2 for Pyrite 2.00 and 1 for Pyrite 1.00
+func (p *TcgDevicePyrite) GetVersion() int {
+	if p.dh.TcgDiscovery.PyriteV200 != nil {
+		return 2
+	}
+	return 1
+}
+
+func (p *TcgDevicePyrite) GetBaseComId() uint16 {
+	feature := p.feature()
+	if feature == nil {
+		return 0
+	}
+	return feature.BaseComID
+}
+
+func (p *TcgDevicePyrite) GetNumComIds() uint16 {
+	feature := p.feature()
+	if feature == nil {
+		return 0
+	}
+	return feature.NumComIDs
+}
+
+func (p *TcgDevicePyrite) RevertTPer(password string, isPsid, isAdminSp bool) error {
+	return revertTPer(p, password, isPsid)
+}
+
+func (p *TcgDevicePyrite) OpalGetTable(session *TcgSession, table []uint8, startCol, endCol uint16) (*TcgResponse, error) {
+	return opalGetTable(session, table, startCol, endCol)
+}
+
+func (p *TcgDevicePyrite) GetDefaultPassword() (string, error) {
+	return getDefaultPassword(p)
+}
+
+func (p *TcgDevicePyrite) TakeOwnership(newPassword string) error {
+	return takeOwnershipSSC(p, p.feature(), newPassword)
+}
+
+func (p *TcgDevicePyrite) ActivateLockingSP(sidPassword string) error {
+	return activateLockingSP(p, sidPassword)
+}
+
+func (p *TcgDevicePyrite) SetAdmin1Password(password string, newPassword string) error {
+	return setAdmin1Password(p, password, newPassword)
+}
+
+// ConfigureLockingRange sets ReadLockEnabled, WriteLockEnabled and LockOnReset of the global range as Admin1
+func (p *TcgDevicePyrite) ConfigureLockingRange(password string, index uint8, readLockEnabled bool, writeLockEnabled bool, lockOnReset bool) error {
+	return configureLockingRange(p, password, index, readLockEnabled, writeLockEnabled, lockOnReset)
+}
+
+// SetLockingState sets ReadLocked and WriteLocked of the global range as Admin1
+func (p *TcgDevicePyrite) SetLockingState(password string, index uint8, state OpalLockingState) error {
+	return setLockingState(p, password, index, state)
+}
+
+func (p *TcgDevicePyrite) GetLockingRange(password string, index uint8) (*LockingRange, error) {
+	return getLockingRange(p, password, index)
+}
+
+// SetMBREnable sets the Enable column of MBRControl as Admin1
+func (p *TcgDevicePyrite) SetMBREnable(password string, enable bool) error {
+	return setMBRControl(p, password, MBRENABLE, enable)
+}
+
+// SetMBRDone sets the Done column of MBRControl as Admin1
+func (p *TcgDevicePyrite) SetMBRDone(password string, done bool) error {
+	return setMBRControl(p, password, MBRDONE, done)
+}
+
+// WriteMBR uploads a pre-boot authentication image to the shadow MBR as Admin1 and verifies it
+func (p *TcgDevicePyrite) WriteMBR(password string, image []byte) error {
+	return writeMBR(p, password, image)
+}
+
+func (p *TcgDevicePyrite) ReadMBR(password string, offset uint64, length int) ([]byte, error) {
+	return getMBR(p, password, offset, length)
+}
+
+// GetDataStoreFeature returns the Additional DataStore Tables feature, nil when the drive has a single DataStore table
+func (p *TcgDevicePyrite) GetDataStoreFeature() *DataStoreFeature {
+	return p.dh.TcgDiscovery.DataStore
+}
+
+// GetDataStoreSize returns the size of a DataStore table as Admin1
+func (p *TcgDevicePyrite) GetDataStoreSize(password string, index uint8) (uint64, error) {
+	return getDataStoreSize(p, p.GetDataStoreFeature(), password, index)
+}
+
+// ReadDataStore reads length bytes at offset of a DataStore table as Admin1
+func (p *TcgDevicePyrite) ReadDataStore(password string, index uint8, offset uint64, length int) ([]byte, error) {
+	return readDataStore(p, p.GetDataStoreFeature(), password, index, offset, length)
+}
+
+// WriteDataStore writes data at offset of a DataStore table as Admin1
+func (p *TcgDevicePyrite) WriteDataStore(password string, index uint8, offset uint64, data []byte) error {
+	return writeDataStore(p, p.GetDataStoreFeature(), password, index, offset, data)
+}
+
+// GetDataRemovalFeature returns the Supported Data Removal Mechanism feature, nil when the drive does not report it
+func (p *TcgDevicePyrite) GetDataRemovalFeature() *DataRemovalFeature {
+	return p.dh.TcgDiscovery.DataRemoval
+}
+
+// GetActiveDataRemovalMechanism reads the mechanism a Revert uses from the DataRemovalMechanism table of the Admin SP
+func (p *TcgDevicePyrite) GetActiveDataRemovalMechanism() (DataRemovalMechanism, error) {
+	session := NewTcgSession(p)
+	if err := session.Start(ADMINSP_UID, "", UID_HEXFF); err != nil {
+		return 0, err
+	}
+	defer session.Close()
+
+	uid := DATA_REMOVAL_MECHANISM
+	table := append([]uint8{uint8(BYTESTRING8)}, uid[:]...)
+	resp, err := opalGetTable(session, table, uint16(ACTIVE_DATA_REMOVAL_MECHANISM), uint16(ACTIVE_DATA_REMOVAL_MECHANISM))
+	if err != nil {
+		return 0, err
+	}
+	mechanism, err := resp.Result().Named(ACTIVE_DATA_REMOVAL_MECHANISM).Uint()
+	if err != nil {
+		return 0, err
+	}
+	return DataRemovalMechanism(mechanism), nil
+}
+
+// SetActiveDataRemovalMechanism selects the mechanism a Revert uses as SID
+func (p *TcgDevicePyrite) SetActiveDataRemovalMechanism(sidPassword string, mechanism DataRemovalMechanism) error {
+	if feature := p.GetDataRemovalFeature(); feature != nil && !feature.IsSupported(mechanism) {
+		return fmt.Errorf("unsupported data removal mechanism: %d", mechanism)
+	}
+
+	session := NewTcgSession(p)
+	if err := session.Start(ADMINSP_UID, sidPassword, SID_UID); err != nil {
+		return err
+	}
+	defer session.Close()
+
+	cmd := NewTcgCommand()
+	cmd.Init(DATA_REMOVAL_MECHANISM, SET)
+	cmd.AddValue(ListValue(
+		NamedValue(VALUES, ListValue(
+			NamedValue(ACTIVE_DATA_REMOVAL_MECHANISM, UintValue(uint64(mechanism))),
+		)),
+	))
+	cmd.Complete()
+
+	_, err := session.SendCommand(cmd)
+	return err
+}
@@ -0,0 +1,98 @@
+package tcg_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jc-lab/go-dparm/tcg"
+	"github.com/jc-lab/go-dparm/test/tcg_sim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPyriteDevice(t *testing.T) {
+	config := tcg_sim.DefaultConfig()
+	config.SSC = tcg_sim.SSCPyrite2
+	config.BaseComId = 0x1004
+	sim := tcg_sim.NewTPer(config)
+
+	device, err := tcg.NewTcgDevice(sim)
+	require.NoError(t, err)
+	require.Equal(t, tcg.PyriteDevice, device.GetDeviceType())
+	pyrite := device.(*tcg.TcgDevicePyrite)
+	assert.True(t, pyrite.IsAnySSC())
+	assert.Equal(t, 2, pyrite.GetVersion())
+	assert.Equal(t, uint16(0x1004), pyrite.GetBaseComId())
+	assert.False(t, pyrite.IsMediaEncryption())
+
+	msid, err := pyrite.GetDefaultPassword()
+	require.NoError(t, err)
+	assert.Equal(t, config.MSID, msid)
+
+	feature := pyrite.GetDataRemovalFeature()
+	require.NotNil(t, feature)
+	assert.True(t, feature.IsSupported(tcg.DataRemovalOverwrite))
+	assert.True(t, feature.IsSupported(tcg.DataRemovalBlockErase))
+	assert.False(t, feature.IsSupported(tcg.DataRemovalCryptoErase))
+	assert.Equal(t, 180*time.Minute, feature.RemovalTime(tcg.DataRemovalOverwrite))
+	assert.Equal(t, 30*time.Second, feature.RemovalTime(tcg.DataRemovalBlockErase))
+
+	mechanism, err := pyrite.GetActiveDataRemovalMechanism()
+	require.NoError(t, err)
+	assert.Equal(t, tcg.DataRemovalOverwrite, mechanism)
+
+	require.NoError(t, pyrite.TakeOwnership("owner"))
+	assert.Error(t, pyrite.SetActiveDataRemovalMechanism("owner", tcg.DataRemovalCryptoErase))
+	assert.Error(t, pyrite.SetActiveDataRemovalMechanism("wrong", tcg.DataRemovalBlockErase))
+	require.NoError(t, pyrite.SetActiveDataRemovalMechanism("owner", tcg.DataRemovalBlockErase))
+	mechanism, err = pyrite.GetActiveDataRemovalMechanism()
+	require.NoError(t, err)
+	assert.Equal(t, tcg.DataRemovalBlockErase, mechanism)
+
+	require.NoError(t, pyrite.ActivateLockingSP("owner"))
+	require.NoError(t, pyrite.ConfigureLockingRange("owner", 0, true, true, true))
+	require.NoError(t, pyrite.SetLockingState("owner", 0, tcg.LOCKED))
+	global, err := pyrite.GetLockingRange("owner", 0)
+	require.NoError(t, err)
+	assert.True(t, global.ReadLocked)
+	assert.True(t, global.WriteLocked)
+	_, err = pyrite.GetLockingRange("owner", 1)
+	assert.Error(t, err)
+
+	require.NoError(t, pyrite.RevertTPer("owner", false, true))
+	msid, err = pyrite.GetDefaultPassword()
+	require.NoError(t, err)
+	assert.Equal(t, config.MSID, msid)
+	require.NoError(t, pyrite.TakeOwnership("owner"))
+	assert.Equal(t, 0, sim.OpenSessions())
+}
+
+func TestRubyDevice(t *testing.T) {
+	config := tcg_sim.DefaultConfig()
+	config.SSC = tcg_sim.SSCRuby
+	config.BaseComId = 0x1008
+	config.NumComIds = 2
+	config.MaxRanges = 2
+	sim := tcg_sim.NewTPer(config)
+
+	device, err := tcg.NewTcgDevice(sim)
+	require.NoError(t, err)
+	require.Equal(t, tcg.RubyDevice, device.GetDeviceType())
+	ruby := device.(*tcg.TcgDeviceRuby)
+	assert.True(t, ruby.IsAnySSC())
+	assert.Equal(t, uint16(0x1008), ruby.GetBaseComId())
+	assert.Equal(t, uint16(2), ruby.GetNumComIds())
+	assert.True(t, ruby.IsMediaEncryption())
+
+	require.NoError(t, ruby.TakeOwnership("owner"))
+	require.NoError(t, ruby.ActivateLockingSP("owner"))
+	require.NoError(t, ruby.SetupLockingRange("owner", 1, 0x1000, 0x800))
+	ranges, err := ruby.GetLockingRanges("owner")
+	require.NoError(t, err)
+	require.Len(t, ranges, 3)
+	assert.Equal(t, uint64(0x1000), ranges[1].RangeStart)
+
+	require.NoError(t, ruby.RevertTPer("owner", false, true))
+	require.NoError(t, ruby.TakeOwnership("owner"))
+	assert.Equal(t, 0, sim.OpenSessions())
+}
@@ -0,0 +1,189 @@
+package tcg
+
+// TcgDeviceRuby is a Ruby drive, the Opal 2 based SSC for data center NVMe drives
+type TcgDeviceRuby struct {
+	TcgDeviceImpl
+}
+
+func (p *TcgDeviceRuby) IsAnySSC() bool {
+	return true
+}
+
+func (p *TcgDeviceRuby) GetDeviceType() TcgDeviceType {
+	return RubyDevice
+}
+
+func (p *TcgDeviceRuby) GetBaseComId() uint16 {
+	feature := p.dh.TcgDiscovery.Ruby
+	if feature == nil {
+		return 0
+	}
+	return feature.BaseComID
+}
+
+func (p *TcgDeviceRuby) GetNumComIds() uint16 {
+	feature := p.dh.TcgDiscovery.Ruby
+	if feature == nil {
+		return 0
+	}
+	return feature.NumComIDs
+}
+
+func (p *TcgDeviceRuby) RevertTPer(password string, isPsid, isAdminSp bool) error {
+	return revertTPer(p, password, isPsid)
+}
+
+func (p *TcgDeviceRuby) OpalGetTable(session *TcgSession, table []uint8, startCol, endCol uint16) (*TcgResponse, error) {
+	return opalGetTable(session, table, startCol, endCol)
+}
+
+func (p *TcgDeviceRuby) GetDefaultPassword() (string, error) {
+	return getDefaultPassword(p)
+}
+
+func (p *TcgDeviceRuby) TakeOwnership(newPassword string) error {
+	return takeOwnershipSSC(p, p.dh.TcgDiscovery.Ruby, newPassword)
+}
+
+func (p *TcgDeviceRuby) ActivateLockingSP(sidPassword string) error {
+	return activateLockingSP(p, sidPassword)
+}
+
+func (p *TcgDeviceRuby) SetAdmin1Password(password string, newPassword string) error {
+	return setAdmin1Password(p, password, newPassword)
+}
+
+// SetupLockingRange sets RangeStart and RangeLength of a numbered range as Admin1
+func (p *TcgDeviceRuby) SetupLockingRange(password string, index uint8, start uint64, length uint64) error {
+	return setupLockingRange(p, password, index, start, length)
+}
+
+// ConfigureLockingRange sets ReadLockEnabled, WriteLockEnabled and LockOnReset of a range as Admin1
+func (p *TcgDeviceRuby) ConfigureLockingRange(password string, index uint8, readLockEnabled bool, writeLockEnabled bool, lockOnReset bool) error {
+	return configureLockingRange(p, password, index, readLockEnabled, writeLockEnabled, lockOnReset)
+}
+
+// SetLockingState sets ReadLocked and WriteLocked of a range as Admin1
+func (p *TcgDeviceRuby) SetLockingState(password string, index uint8, state OpalLockingState) error {
+	return setLockingState(p, password, index, state)
+}
+
+func (p *TcgDeviceRuby) GetLockingRange(password string, index uint8) (*LockingRange, error) {
+	return getLockingRange(p, password, index)
+}
+
+// GetLockingRanges returns the global range followed by every numbered range
+func (p *TcgDeviceRuby) GetLockingRanges(password string) ([]LockingRange, error) {
+	return getLockingRanges(p, password)
+}
+
+// EraseLockingRange cryptographically erases a range as Admin1 with GenKey on its ActiveKey
+func (p *TcgDeviceRuby) EraseLockingRange(password string, index uint8, options ...EraseOptions) error {
+	return eraseLockingRange(p, password, index, eraseOptionsOf(options))
+}
+
+// SetMBREnable sets the Enable column of MBRControl as Admin1
+func (p *TcgDeviceRuby) SetMBREnable(password string, enable bool) error {
+	return setMBRControl(p, password, MBRENABLE, enable)
+}
+
+// SetMBRDone sets the Done column of MBRControl as Admin1
+func (p *TcgDeviceRuby) SetMBRDone(password string, done bool) error {
+	return setMBRControl(p, password, MBRDONE, done)
+}
+
+// WriteMBR uploads a pre-boot authentication image to the shadow MBR as Admin1 and verifies it
+func (p *TcgDeviceRuby) WriteMBR(password string, image []byte) error {
+	return writeMBR(p, password, image)
+}
+
+func (p *TcgDeviceRuby) ReadMBR(password string, offset uint64, length int) ([]byte, error) {
+	return getMBR(p, password, offset, length)
+}
+
+// GetDataStoreFeature returns the Additional DataStore Tables feature, nil when the drive has a single DataStore table
+func (p *TcgDeviceRuby) GetDataStoreFeature() *DataStoreFeature {
+	return p.dh.TcgDiscovery.DataStore
+}
+
+// GetDataStoreSize returns the size of a DataStore table as Admin1
+func (p *TcgDeviceRuby) GetDataStoreSize(password string, index uint8) (uint64, error) {
+	return getDataStoreSize(p, p.GetDataStoreFeature(), password, index)
+}
+
+// ReadDataStore reads length bytes at offset of a DataStore table as Admin1
+func (p *TcgDeviceRuby) ReadDataStore(password string, index uint8, offset uint64, length int) ([]byte, error) {
+	return readDataStore(p, p.GetDataStoreFeature(), password, index, offset, length)
+}
+
+// WriteDataStore writes data at offset of a DataStore table as Admin1
+func (p *TcgDeviceRuby) WriteDataStore(password string, index uint8, offset uint64, data []byte) error {
+	return writeDataStore(p, p.GetDataStoreFeature(), password, index, offset, data)
+}
+
+// GetAuthorities returns every row of the Authority table of the Locking SP
+func (p *TcgDeviceRuby) GetAuthorities(password string) ([]Authority, error) {
+	return getAuthorities(p, password)
+}
+
+// SetUserEnabled enables or disables UserN of the Locking SP as Admin1
+func (p *TcgDeviceRuby) SetUserEnabled(password string, user uint8, enabled bool) error {
+	return setAuthorityEnabled(p, password, UserUID(user), enabled)
+}
+
+// SetUserPassword sets the C_PIN of UserN of the Locking SP as Admin1
+func (p *TcgDeviceRuby) SetUserPassword(password string, user uint8, newPassword string) error {
+	return setAuthorityPassword(p, password, UserUID(user), newPassword)
+}
+
+// AddUserToLockingRange allows UserN to lock and unlock a range
+func (p *TcgDeviceRuby) AddUserToLockingRange(password string, user uint8, index uint8) error {
+	return setLockingRangeAccess(p, password, UserUID(user), index, true)
+}
+
+// RemoveUserFromLockingRange revokes the access of UserN to a range
+func (p *TcgDeviceRuby) RemoveUserFromLockingRange(password string, user uint8, index uint8) error {
+	return setLockingRangeAccess(p, password, UserUID(user), index, false)
+}
+
+// SetLockingStateAs sets ReadLocked and WriteLocked of a range as the authority, a user added to the range for example
+func (p *TcgDeviceRuby) SetLockingStateAs(authority OpalUID, password string, index uint8, state OpalLockingState) error {
+	return setLockingStateAs(p, authority, password, index, state)
+}
+
+// GetTryLimits returns the try limit and failed authentications of SID and of the Admins and Users of the Locking SP,
+// an empty password reads them as Anybody and leaves out the ones the ACL hides
+func (p *TcgDeviceRuby) GetTryLimits(sidPassword string, admin1Password string) ([]TryLimitStatus, error) {
+	var numAdmins, numUsers uint16
+	if feature := p.dh.TcgDiscovery.Ruby; feature != nil {
+		numAdmins, numUsers = feature.NumLockingAdmins, feature.NumLockingUsers
+	}
+	return getTryLimits(p, numAdmins, numUsers, sidPassword, admin1Password)
+}
+
+// GetACL returns the ACEs granting a method invoked on a table or an object of the Locking SP with the authorities
+// of their BooleanExpr, SET on LockingRangeUID(1) for example names who can lock and unlock range 1
+func (p *TcgDeviceRuby) GetACL(password string, invoking OpalUID, method OpalMethod) ([]ACE, error) {
+	return getACL(p, password, invoking, method)
+}
+
+// GetSingleUserFeature returns the Single User Mode feature, nil when the drive does not support it
+func (p *TcgDeviceRuby) GetSingleUserFeature() *SingleUserFeature {
+	return p.dh.TcgDiscovery.SingleUser
+}
+
+// ActivateLockingSPSingleUser activates the Locking SP with ranges in Single User Mode, the owners of the ranges
+// start with the SID password
+func (p *TcgDeviceRuby) ActivateLockingSPSingleUser(sidPassword string, mode SingleUserMode) error {
+	return activateLockingSPSingleUser(p, p.GetSingleUserFeature(), sidPassword, mode)
+}
+
+// ReactivateLockingSP changes the ranges in Single User Mode as Admin1, an empty newAdmin1Password keeps the password
+func (p *TcgDeviceRuby) ReactivateLockingSP(password string, mode SingleUserMode, newAdmin1Password string) error {
+	return reactivateLockingSP(p, p.GetSingleUserFeature(), password, mode, newAdmin1Password)
+}
+
+// SetSingleUserPassword changes the password of the owner of a range in Single User Mode
+func (p *TcgDeviceRuby) SetSingleUserPassword(index uint8, password string, newPassword string) error {
+	return setSingleUserPassword(p, index, password, newPassword)
+}
@@ -0,0 +1,407 @@
+package tcg
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// TPerFeature is the decoded TPer feature descriptor
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_Storage-Opal_SSC_v2.01_rev1.00.pdf
+// 3.1.1.2
+type TPerFeature struct {
+	Version          uint8
+	Sync             bool
+	Async            bool
+	AckNak           bool
+	BufferManagement bool
+	Streaming        bool
+	ComIDManagement  bool
+}
+
+// LockingFeature is the decoded Locking feature descriptor
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_Storage-Opal_SSC_v2.01_rev1.00.pdf
+// 3.1.1.3
+type LockingFeature struct {
+	Version          uint8
+	LockingSupported bool
+	LockingEnabled   bool
+	Locked           bool
+	MediaEncryption  bool
+	MBREnabled       bool
+	MBRDone          bool
+	// MBRShadowingNotSupported and HWResetSupported are reported by Opal 2.x drives
+	MBRShadowingNotSupported bool
+	HWResetSupported         bool
+}
+
+// GeometryFeature is the decoded Geometry Reporting feature descriptor
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_Storage-Opal_SSC_v2.01_rev1.00.pdf
+// 3.1.1.4
+type GeometryFeature struct {
+	Version              uint8
+	Align                bool
+	LogicalBlockSize     uint32
+	AlignmentGranularity uint64
+	LowestAlignedLBA     uint64
+}
+
+// SSCFeature is the decoded descriptor of an SSC: Opal 1.00, Opal 2.00, Enterprise, Opalite, Pyrite 1.00/2.00 or Ruby.
+// Fields the SSC does not define are zero.
+type SSCFeature struct {
+	Version       uint8
+	BaseComID     uint16
+	NumComIDs     uint16
+	RangeCrossing bool
+	// NumLockingAdmins and NumLockingUsers are the authorities of the Locking SP, Opal 2.00 and Ruby
+	NumLockingAdmins uint16
+	NumLockingUsers  uint16
+	// InitialCPinSIDIndicator is 0x00 when the initial C_PIN_SID is the MSID, 0xFF when it is vendor unique
+	InitialCPinSIDIndicator uint8
+	// CPinSIDRevertBehavior is 0x00 when C_PIN_SID becomes the MSID on Revert, 0xFF when it is vendor unique
+	CPinSIDRevertBehavior uint8
+}
+
+// SingleUserFeature is the decoded Single User Mode feature descriptor
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_Storage-Opal_Feature_Set_Single_User_Mode_v1-00_r1-00-Final.pdf
+// 4.2.1
+type SingleUserFeature struct {
+	Version              uint8
+	NumberLockingObjects uint32
+	Any                  bool
+	All                  bool
+	// Policy is set when the ownership of the ranges is given to the Admins authority
+	Policy bool
+}
+
+// DataStoreFeature is the decoded DataStore Table feature descriptor
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_Storage-Opal_Feature_Set-Additional_DataStore_Tables_v1_00_r1_00_Final.pdf
+// 4.1.1
+type DataStoreFeature struct {
+	Version            uint8
+	MaxTables          uint16
+	MaxTotalSize       uint32
+	TableSizeAlignment uint32
+}
+
+// BlockSIDFeature is the decoded Block SID Authentication feature descriptor
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_Storage_Feature_Set_Block_SID_Authentication_v1.01_r1.00.pdf
+// 4.1.1
+type BlockSIDFeature struct {
+	Version uint8
+	// SIDValueState is set when C_PIN_SID differs from the MSID
+	SIDValueState bool
+	SIDBlocked    bool
+	// LockingSPFreezeSupported and LockingSPFrozen are reported by version 2 of the feature
+	LockingSPFreezeSupported bool
+	LockingSPFrozen          bool
+	// HardwareReset is set when a hardware reset clears the block
+	HardwareReset bool
+}
+
+// NamespaceLockingFeature is the decoded Configurable Namespace Locking feature descriptor
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_Storage_Feature_Set_Namespaces_v1p00_r1p19_pub.pdf
+// 4.1.1
+type NamespaceLockingFeature struct {
+	Version uint8
+	// RangeC is set when ranges can be created per namespace, RangeP when ranges have been created
+	RangeC                bool
+	RangeP                bool
+	MaxKeyCount           uint32
+	UnusedKeyCount        uint32
+	MaxRangesPerNamespace uint32
+}
+
+// ShadowMBRMultiNamespaceFeature is the decoded Shadow MBR for Multiple Namespaces feature descriptor
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_Storage_Feature_Set_Namespaces_v1p00_r1p19_pub.pdf
+// 4.2.1
+type ShadowMBRMultiNamespaceFeature struct {
+	Version uint8
+	// AnyNamespace is set when the shadow MBR can be presented on any namespace
+	AnyNamespace bool
+}
+
+// DataRemovalFeature is the decoded Supported Data Removal Mechanism feature descriptor
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_Storage-Feature_Set_Supported_Data_Removal_Mechanism_v1p00_r1p00.pdf
+// 4.1.1
+type DataRemovalFeature struct {
+	Version     uint8
+	Processing  bool
+	Interrupted bool
+	// Supported is a bit mask of the supported mechanisms
+	Supported uint8
+	// TimeFormat has a bit per mechanism, set when the time is in minutes instead of seconds
+	TimeFormat uint8
+	// Time is the time of each mechanism in units of 2 seconds or 2 minutes
+	Time [6]uint16
+}
+
+// DataRemovalMechanism is a data removal mechanism of Pyrite 2.00, the value is its bit in the Supported Data Removal Mechanism feature
+type DataRemovalMechanism uint8
+
+const (
+	DataRemovalOverwrite DataRemovalMechanism = 0 + iota
+	DataRemovalBlockErase
+	DataRemovalCryptoErase
+	DataRemovalUnmap
+	DataRemovalResetWritePointers
+	DataRemovalVendorSpecific
+)
+
+func (f *DataRemovalFeature) IsSupported(mechanism DataRemovalMechanism) bool {
+	return mechanism < 8 && f.Supported&(1<<mechanism) != 0
+}
+
+// RemovalTime returns the time the drive reports for a mechanism, zero when it is not reported
+func (f *DataRemovalFeature) RemovalTime(mechanism DataRemovalMechanism) time.Duration {
+	if int(mechanism) >= len(f.Time) {
+		return 0
+	}
+	unit := 2 * time.Second
+	if f.TimeFormat&(1<<mechanism) != 0 {
+		unit = 2 * time.Minute
+	}
+	return time.Duration(f.Time[mechanism]) * unit
+}
+
+// Level0Discovery is the decoded response of a Level 0 Discovery
+type Level0Discovery struct {
+	Header Discovery0Header
+
+	TPer                    *TPerFeature
+	Locking                 *LockingFeature
+	Geometry                *GeometryFeature
+	OpalV100                *SSCFeature
+	OpalV200                *SSCFeature
+	Enterprise              *SSCFeature
+	Opalite                 *SSCFeature
+	PyriteV100              *SSCFeature
+	PyriteV200              *SSCFeature
+	Ruby                    *SSCFeature
+	SingleUser              *SingleUserFeature
+	DataStore               *DataStoreFeature
+	BlockSID                *BlockSIDFeature
+	NamespaceLocking        *NamespaceLockingFeature
+	ShadowMBRMultiNamespace *ShadowMBRMultiNamespaceFeature
+	DataRemoval             *DataRemovalFeature
+
+	// Unknown keeps the descriptors of unknown feature codes
+	Unknown map[uint16][]byte
+
+	raw map[uint16][]byte
+}
+
+// ParseLevel0Discovery decodes the header and every feature descriptor of a Level 0 Discovery response
+func ParseLevel0Discovery(buf []byte) (*Level0Discovery, error) {
+	const headerSize = 48
+
+	if len(buf) < headerSize {
+		return nil, fmt.Errorf("invalid data: short level 0 discovery header")
+	}
+
+	d := &Level0Discovery{}
+	d.Header.Length = binary.BigEndian.Uint32(buf[0:])
+	d.Header.Revision = binary.BigEndian.Uint32(buf[4:])
+	copy(d.Header.Reserved03[:], buf[16:32])
+
+	// the length does not include the length field
+	end := int(d.Header.Length) + 4
+	if end > len(buf) {
+		return nil, fmt.Errorf("invalid data: length overflow")
+	}
+
+	for offset := headerSize; offset+4 <= end; {
+		length := int(buf[offset+3]) + 4
+		if offset+length > end {
+			return nil, fmt.Errorf("invalid data: feature 0x%04x overflows the response", binary.BigEndian.Uint16(buf[offset:]))
+		}
+		d.AddFeature(append([]byte{}, buf[offset:offset+length]...))
+		offset += length
+	}
+
+	return d, nil
+}
+
+// Level0Info returns the feature flags and descriptors of the discovery
+func (d *Level0Discovery) Level0Info() TcgLevel0Info {
+	info := TcgLevel0Info{
+		TcgTper:              d.TPer != nil,
+		TcgLocking:           d.Locking != nil,
+		TcgGeometryReporting: d.Geometry != nil,
+		TcgOpalSscV100:       d.OpalV100 != nil,
+		TcgOpalSscV200:       d.OpalV200 != nil,
+		TcgEnterprise:        d.Enterprise != nil,
+		TcgSingleUser:        d.SingleUser != nil,
+		TcgDataStore:         d.DataStore != nil,
+		TcgPyriteV100:        d.PyriteV100 != nil,
+		TcgPyriteV200:        d.PyriteV200 != nil,
+		TcgRuby:              d.Ruby != nil,
+
+		TcgRawFeatures: make(map[uint16][]byte),
+		TcgDiscovery:   d,
+	}
+	for code, descriptor := range d.raw {
+		info.TcgRawFeatures[code] = descriptor
+	}
+	return info
+}
+
+// featureData returns the descriptor padded with zeros to at least size bytes
+func featureData(descriptor []byte, size int) []byte {
+	if len(descriptor) >= size {
+		return descriptor
+	}
+	data := make([]byte, size)
+	copy(data, descriptor)
+	return data
+}
+
+func parseSSCFeature(data []byte, code FeatureCode) *SSCFeature {
+	data = featureData(data, 16)
+	feature := &SSCFeature{
+		Version:   data[2] >> 4,
+		BaseComID: binary.BigEndian.Uint16(data[4:]),
+		NumComIDs: binary.BigEndian.Uint16(data[6:]),
+	}
+
+	switch code {
+	case FcOpalSscV100, FcEnterprise:
+		feature.RangeCrossing = data[8]&0x01 != 0
+	case FcOpalSscV200, FcRuby:
+		feature.RangeCrossing = data[8]&0x01 != 0
+		feature.NumLockingAdmins = binary.BigEndian.Uint16(data[9:])
+		feature.NumLockingUsers = binary.BigEndian.Uint16(data[11:])
+		feature.InitialCPinSIDIndicator = data[13]
+		feature.CPinSIDRevertBehavior = data[14]
+	case FcOpalite, FcPyriteV100, FcPyriteV200:
+		feature.InitialCPinSIDIndicator = data[13]
+		feature.CPinSIDRevertBehavior = data[14]
+	}
+
+	return feature
+}
+
+// AddFeature decodes a feature descriptor, a descriptor with an unknown feature code is kept in Unknown
+func (d *Level0Discovery) AddFeature(descriptor []byte) {
+	if len(descriptor) < 4 {
+		return
+	}
+	code := FeatureCode(binary.BigEndian.Uint16(descriptor))
+	version := descriptor[2] >> 4
+
+	if d.raw == nil {
+		d.raw = make(map[uint16][]byte)
+	}
+	d.raw[uint16(code)] = descriptor
+
+	switch code {
+	case FcTPer:
+		data := featureData(descriptor, 5)
+		d.TPer = &TPerFeature{
+			Version:          version,
+			Sync:             data[4]&0x01 != 0,
+			Async:            data[4]&0x02 != 0,
+			AckNak:           data[4]&0x04 != 0,
+			BufferManagement: data[4]&0x08 != 0,
+			Streaming:        data[4]&0x10 != 0,
+			ComIDManagement:  data[4]&0x40 != 0,
+		}
+	case FcLocking:
+		data := featureData(descriptor, 5)
+		d.Locking = &LockingFeature{
+			Version:                  version,
+			LockingSupported:         data[4]&0x01 != 0,
+			LockingEnabled:           data[4]&0x02 != 0,
+			Locked:                   data[4]&0x04 != 0,
+			MediaEncryption:          data[4]&0x08 != 0,
+			MBREnabled:               data[4]&0x10 != 0,
+			MBRDone:                  data[4]&0x20 != 0,
+			MBRShadowingNotSupported: data[4]&0x40 != 0,
+			HWResetSupported:         data[4]&0x80 != 0,
+		}
+	case FcGeometryReporting:
+		data := featureData(descriptor, 32)
+		d.Geometry = &GeometryFeature{
+			Version:              version,
+			Align:                data[4]&0x01 != 0,
+			LogicalBlockSize:     binary.BigEndian.Uint32(data[12:]),
+			AlignmentGranularity: binary.BigEndian.Uint64(data[16:]),
+			LowestAlignedLBA:     binary.BigEndian.Uint64(data[24:]),
+		}
+	case FcOpalSscV100:
+		d.OpalV100 = parseSSCFeature(descriptor, code)
+	case FcOpalSscV200:
+		d.OpalV200 = parseSSCFeature(descriptor, code)
+	case FcEnterprise:
+		d.Enterprise = parseSSCFeature(descriptor, code)
+	case FcOpalite:
+		d.Opalite = parseSSCFeature(descriptor, code)
+	case FcPyriteV100:
+		d.PyriteV100 = parseSSCFeature(descriptor, code)
+	case FcPyriteV200:
+		d.PyriteV200 = parseSSCFeature(descriptor, code)
+	case FcRuby:
+		d.Ruby = parseSSCFeature(descriptor, code)
+	case FcSingleUser:
+		data := featureData(descriptor, 9)
+		d.SingleUser = &SingleUserFeature{
+			Version:              version,
+			NumberLockingObjects: binary.BigEndian.Uint32(data[4:]),
+			Any:                  data[8]&0x01 != 0,
+			All:                  data[8]&0x02 != 0,
+			Policy:               data[8]&0x04 != 0,
+		}
+	case FcDataStore:
+		data := featureData(descriptor, 16)
+		d.DataStore = &DataStoreFeature{
+			Version:            version,
+			MaxTables:          binary.BigEndian.Uint16(data[6:]),
+			MaxTotalSize:       binary.BigEndian.Uint32(data[8:]),
+			TableSizeAlignment: binary.BigEndian.Uint32(data[12:]),
+		}
+	case FcBlockSID:
+		data := featureData(descriptor, 6)
+		d.BlockSID = &BlockSIDFeature{
+			Version:                  version,
+			SIDValueState:            data[4]&0x01 != 0,
+			SIDBlocked:               data[4]&0x02 != 0,
+			LockingSPFreezeSupported: data[4]&0x04 != 0,
+			LockingSPFrozen:          data[4]&0x08 != 0,
+			HardwareReset:            data[5]&0x01 != 0,
+		}
+	case FcNamespaceLocking:
+		data := featureData(descriptor, 20)
+		d.NamespaceLocking = &NamespaceLockingFeature{
+			Version:               version,
+			RangeC:                data[4]&0x80 != 0,
+			RangeP:                data[4]&0x40 != 0,
+			MaxKeyCount:           binary.BigEndian.Uint32(data[8:]),
+			UnusedKeyCount:        binary.BigEndian.Uint32(data[12:]),
+			MaxRangesPerNamespace: binary.BigEndian.Uint32(data[16:]),
+		}
+	case FcShadowMBRMultiNamespace:
+		data := featureData(descriptor, 5)
+		d.ShadowMBRMultiNamespace = &ShadowMBRMultiNamespaceFeature{
+			Version:      version,
+			AnyNamespace: data[4]&0x01 != 0,
+		}
+	case FcDataRemoval:
+		data := featureData(descriptor, 20)
+		feature := &DataRemovalFeature{
+			Version:     version,
+			Processing:  data[5]&0x01 != 0,
+			Interrupted: data[5]&0x02 != 0,
+			Supported:   data[6],
+			TimeFormat:  data[7],
+		}
+		for i := range feature.Time {
+			feature.Time[i] = binary.BigEndian.Uint16(data[8+2*i:])
+		}
+		d.DataRemoval = feature
+	default:
+		if d.Unknown == nil {
+			d.Unknown = make(map[uint16][]byte)
+		}
+		d.Unknown[uint16(code)] = descriptor
+	}
+}
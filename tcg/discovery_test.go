@@ -0,0 +1,133 @@
+package tcg_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/jc-lab/go-dparm/tcg"
+	"github.com/jc-lab/go-dparm/test/tcg_sim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func descriptor(code tcg.FeatureCode, version uint8, body ...byte) []byte {
+	out := []byte{uint8(code >> 8), uint8(code), version << 4, uint8(len(body))}
+	return append(out, body...)
+}
+
+func discoveryResponse(descriptors ...[]byte) []byte {
+	out := make([]byte, 48)
+	for _, d := range descriptors {
+		out = append(out, d...)
+	}
+	binary.BigEndian.PutUint32(out[0:], uint32(len(out)-4))
+	binary.BigEndian.PutUint32(out[4:], 1)
+	return append(out, make([]byte, 64)...)
+}
+
+func TestParseLevel0Discovery(t *testing.T) {
+	buf := discoveryResponse(
+		descriptor(tcg.FcTPer, 1, 0x51, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0),
+		descriptor(tcg.FcLocking, 2, 0xcb, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0),
+		descriptor(tcg.FcGeometryReporting, 1, 0x01, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x10, 0, 0, 0, 0, 0, 0, 0, 0, 8, 0, 0, 0, 0, 0, 0, 0, 1),
+		descriptor(tcg.FcOpalSscV200, 1, 0x10, 0x00, 0x00, 0x02, 0x00, 0x00, 0x04, 0x00, 0x08, 0xff, 0x00, 0, 0, 0, 0, 0),
+		descriptor(tcg.FcSingleUser, 1, 0, 0, 0, 9, 0x05, 0, 0, 0, 0, 0, 0, 0),
+		descriptor(tcg.FcDataStore, 1, 0, 0, 0, 9, 0, 0x10, 0, 0, 0, 0, 0x02, 0),
+		descriptor(tcg.FcPyriteV200, 1, 0x07, 0xfe, 0x00, 0x01, 0, 0, 0, 0, 0, 0x00, 0xff, 0, 0, 0, 0, 0),
+		descriptor(tcg.FcBlockSID, 2, 0x03, 0x01, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0),
+		descriptor(tcg.FcNamespaceLocking, 1, 0x80, 0, 0, 0, 0, 0, 0, 0x20, 0, 0, 0, 0x1f, 0, 0, 0, 0x08, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0),
+		descriptor(tcg.FcDataRemoval, 1, 0, 0x01, 0x24, 0x04, 0, 0, 0, 0x05, 0, 0, 0, 0x02, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0),
+		descriptor(tcg.FeatureCode(0xc001), 1, 0xde, 0xad, 0xbe, 0xef),
+	)
+
+	discovery, err := tcg.ParseLevel0Discovery(buf)
+	require.NoError(t, err)
+
+	require.NotNil(t, discovery.TPer)
+	assert.Equal(t, tcg.TPerFeature{Version: 1, Sync: true, Streaming: true, ComIDManagement: true}, *discovery.TPer)
+
+	require.NotNil(t, discovery.Locking)
+	assert.Equal(t, tcg.LockingFeature{
+		Version:                  2,
+		LockingSupported:         true,
+		LockingEnabled:           true,
+		MediaEncryption:          true,
+		MBRShadowingNotSupported: true,
+		HWResetSupported:         true,
+	}, *discovery.Locking)
+
+	require.NotNil(t, discovery.Geometry)
+	assert.True(t, discovery.Geometry.Align)
+	assert.Equal(t, uint32(0x1000), discovery.Geometry.LogicalBlockSize)
+	assert.Equal(t, uint64(8), discovery.Geometry.AlignmentGranularity)
+	assert.Equal(t, uint64(1), discovery.Geometry.LowestAlignedLBA)
+
+	require.NotNil(t, discovery.OpalV200)
+	assert.Equal(t, tcg.SSCFeature{
+		Version:                 1,
+		BaseComID:               0x1000,
+		NumComIDs:               2,
+		NumLockingAdmins:        4,
+		NumLockingUsers:         8,
+		InitialCPinSIDIndicator: 0xff,
+	}, *discovery.OpalV200)
+	assert.Nil(t, discovery.OpalV100)
+
+	require.NotNil(t, discovery.SingleUser)
+	assert.Equal(t, tcg.SingleUserFeature{Version: 1, NumberLockingObjects: 9, Any: true, Policy: true}, *discovery.SingleUser)
+
+	require.NotNil(t, discovery.DataStore)
+	assert.Equal(t, tcg.DataStoreFeature{Version: 1, MaxTables: 9, MaxTotalSize: 0x100000, TableSizeAlignment: 0x200}, *discovery.DataStore)
+
+	require.NotNil(t, discovery.PyriteV200)
+	assert.Equal(t, uint16(0x07fe), discovery.PyriteV200.BaseComID)
+	assert.Equal(t, uint8(0xff), discovery.PyriteV200.CPinSIDRevertBehavior)
+
+	require.NotNil(t, discovery.BlockSID)
+	assert.Equal(t, tcg.BlockSIDFeature{Version: 2, SIDValueState: true, SIDBlocked: true, HardwareReset: true}, *discovery.BlockSID)
+
+	require.NotNil(t, discovery.NamespaceLocking)
+	assert.True(t, discovery.NamespaceLocking.RangeC)
+	assert.False(t, discovery.NamespaceLocking.RangeP)
+	assert.Equal(t, uint32(0x20), discovery.NamespaceLocking.MaxKeyCount)
+	assert.Equal(t, uint32(0x1f), discovery.NamespaceLocking.UnusedKeyCount)
+	assert.Equal(t, uint32(8), discovery.NamespaceLocking.MaxRangesPerNamespace)
+
+	require.NotNil(t, discovery.DataRemoval)
+	assert.True(t, discovery.DataRemoval.Processing)
+	assert.Equal(t, uint8(0x24), discovery.DataRemoval.Supported)
+	assert.Equal(t, uint8(0x04), discovery.DataRemoval.TimeFormat)
+	assert.Equal(t, [6]uint16{0, 5, 0, 2, 0, 0}, discovery.DataRemoval.Time)
+
+	assert.Equal(t, map[uint16][]byte{0xc001: descriptor(tcg.FeatureCode(0xc001), 1, 0xde, 0xad, 0xbe, 0xef)}, discovery.Unknown)
+
+	info := discovery.Level0Info()
+	assert.True(t, info.TcgOpalSscV200)
+	assert.True(t, info.TcgSingleUser)
+	assert.False(t, info.TcgEnterprise)
+	assert.Len(t, info.TcgRawFeatures, 11)
+
+	// the last descriptor runs past the length of the header
+	binary.BigEndian.PutUint32(buf[0:], binary.BigEndian.Uint32(buf[0:])-2)
+	_, err = tcg.ParseLevel0Discovery(buf)
+	assert.Error(t, err)
+}
+
+func TestLevel0DiscoveryOfDevice(t *testing.T) {
+	config := tcg_sim.DefaultConfig()
+	config.NumLockingAdmins = 3
+	config.NumLockingUsers = 0x105
+	sim := tcg_sim.NewTPer(config)
+
+	info, _ := sim.GetTcgLevel0InfoAndSerial()
+	require.NotNil(t, info.TcgDiscovery.OpalV200)
+	assert.Equal(t, uint16(3), info.TcgDiscovery.OpalV200.NumLockingAdmins)
+	assert.Equal(t, uint16(0x105), info.TcgDiscovery.OpalV200.NumLockingUsers)
+
+	device, err := tcg.NewTcgDevice(sim)
+	require.NoError(t, err)
+	assert.Equal(t, config.BaseComId, device.GetBaseComId())
+	assert.Equal(t, config.NumComIds, device.GetNumComIds())
+	assert.True(t, device.IsMediaEncryption())
+	assert.False(t, device.IsLockingEnabled())
+}
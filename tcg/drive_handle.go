@@ -12,8 +12,13 @@ type TcgLevel0Info struct {
 	TcgEnterprise        bool
 	TcgSingleUser        bool
 	TcgDataStore         bool
+	TcgPyriteV100        bool
+	TcgPyriteV200        bool
+	TcgRuby              bool
 
 	TcgRawFeatures map[uint16][]byte
+	// TcgDiscovery is the decoded discovery, NewTcgDriveHandle decodes TcgRawFeatures when it is nil
+	TcgDiscovery *Level0Discovery
 }
 
 type DriveCommandHandler interface {
@@ -25,13 +30,29 @@ type TcgDriveHandle struct {
 	DriveCommandHandler
 	TcgLevel0Info
 	serial string
+
+	// Properties are the communication properties of the TPer, NewTcgDevice exchanges them
+	Properties *TcgProperties
+
+	// comId is the dynamic ComID issued by AllocateComId, zero for the base ComID
+	comId          uint16
+	autoStackReset bool
+	// passwordHasher derives the PINs from passwords, nil for DefaultPasswordHasher
+	passwordHasher PasswordHasher
 }
 
 func NewTcgDriveHandle(dc DriveCommandHandler) *TcgDriveHandle {
 	h := &TcgDriveHandle{
 		DriveCommandHandler: dc,
+		Properties:          DefaultProperties(),
 	}
 	h.TcgLevel0Info, h.serial = dc.GetTcgLevel0InfoAndSerial()
+	if h.TcgDiscovery == nil {
+		h.TcgDiscovery = &Level0Discovery{}
+		for _, descriptor := range h.TcgRawFeatures {
+			h.TcgDiscovery.AddFeature(descriptor)
+		}
+	}
 
 	return h
 }
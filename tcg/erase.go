@@ -0,0 +1,101 @@
+package tcg
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrEraseVerifyFailed = errors.New("the erased range does not have the expected state")
+)
+
+// EraseOptions are the optional steps after the cryptographic erase of a range
+type EraseOptions struct {
+	// Relock enables and sets the read and write locks of the range after the erase
+	Relock bool
+	// Verify reads the range before and after the erase and checks that only the expected columns changed
+	Verify bool
+	// BandMasterPassword is the password of the BandMaster of an Enterprise band, the EraseMaster can not read or
+	// lock bands. Erase resets the BandMaster PIN to the MSID, it is set back to BandMasterPassword.
+	BandMasterPassword string
+}
+
+func eraseOptionsOf(options []EraseOptions) EraseOptions {
+	if len(options) == 0 {
+		return EraseOptions{}
+	}
+	return options[0]
+}
+
+// relockLockingRange returns the state of a range after setting its locks
+func relockLockingRange(lockingRange LockingRange) LockingRange {
+	lockingRange.ReadLockEnabled = true
+	lockingRange.WriteLockEnabled = true
+	lockingRange.ReadLocked = true
+	lockingRange.WriteLocked = true
+	return lockingRange
+}
+
+// verifyErasedRange compares the range read after the erase with the expected one
+func verifyErasedRange(expected LockingRange, actual *LockingRange) error {
+	if *actual != expected {
+		return fmt.Errorf("%w: range %d is %+v, expected %+v", ErrEraseVerifyFailed, expected.Index, *actual, expected)
+	}
+	return nil
+}
+
+// genKey replaces the media encryption key of a K_AES object, the data encrypted with the old key is lost
+func genKey(session *TcgSession, key OpalUID) error {
+	cmd := NewTcgCommand()
+	cmd.Init(key, GENKEY)
+	cmd.AddValue(ListValue())
+	cmd.Complete()
+
+	_, err := session.SendCommand(cmd)
+	return err
+}
+
+// eraseLockingRange cryptographically erases a range as Admin1 by GenKey on its ActiveKey, the bounds and the
+// locks of the range stay as they are
+func eraseLockingRange(device TcgDevice, password string, index uint8, options EraseOptions) error {
+	session, err := startLockingSession(device, password)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	before, err := readLockingRange(session, index)
+	if err != nil {
+		return err
+	}
+	if before.ActiveKey == (OpalUID{}) {
+		return fmt.Errorf("range %d has no ActiveKey", index)
+	}
+
+	if err := genKey(session, before.ActiveKey); err != nil {
+		return err
+	}
+
+	expected := *before
+	if options.Relock {
+		expected = relockLockingRange(expected)
+		err = setLockingRangeValues(session, index, func(cmd *TcgCommand) {
+			addBoolValue(cmd, LOCKING_READ_LOCK_ENABLED, true)
+			addBoolValue(cmd, LOCKING_WRITE_LOCK_ENABLED, true)
+			addBoolValue(cmd, LOCKING_READ_LOCKED, true)
+			addBoolValue(cmd, LOCKING_WRITE_LOCKED, true)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if !options.Verify {
+		return nil
+	}
+	after, err := readLockingRange(session, index)
+	if err != nil {
+		return err
+	}
+	return verifyErasedRange(expected, after)
+}
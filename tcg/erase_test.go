@@ -0,0 +1,88 @@
+package tcg_test
+
+import (
+	"testing"
+
+	"github.com/jc-lab/go-dparm/tcg"
+	"github.com/jc-lab/go-dparm/test/tcg_sim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEraseLockingRange(t *testing.T) {
+	sim := tcg_sim.NewTPer(tcg_sim.DefaultConfig())
+	device := testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+	require.NoError(t, device.TakeOwnership("sid"))
+	require.NoError(t, device.ActivateLockingSP("sid"))
+	require.NoError(t, device.SetupLockingRange("sid", 1, 0x1000, 0x800))
+
+	key1, key2 := sim.RangeKey(1), sim.RangeKey(2)
+	require.NotNil(t, key1)
+	require.NoError(t, device.EraseLockingRange("sid", 1))
+	assert.NotEqual(t, key1, sim.RangeKey(1))
+	assert.Equal(t, key2, sim.RangeKey(2))
+
+	// the bounds and the locks are kept
+	lockingRange, err := device.GetLockingRange("sid", 1)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0x1000), lockingRange.RangeStart)
+	assert.Equal(t, uint64(0x800), lockingRange.RangeLength)
+	assert.False(t, lockingRange.ReadLockEnabled || lockingRange.ReadLocked)
+
+	key1 = sim.RangeKey(1)
+	require.NoError(t, device.EraseLockingRange("sid", 1, tcg.EraseOptions{Relock: true, Verify: true}))
+	assert.NotEqual(t, key1, sim.RangeKey(1))
+	lockingRange, err = device.GetLockingRange("sid", 1)
+	require.NoError(t, err)
+	assert.True(t, lockingRange.ReadLockEnabled && lockingRange.WriteLockEnabled)
+	assert.True(t, lockingRange.ReadLocked && lockingRange.WriteLocked)
+
+	key0 := sim.RangeKey(0)
+	require.NoError(t, device.EraseLockingRange("sid", 0, tcg.EraseOptions{Verify: true}))
+	assert.NotEqual(t, key0, sim.RangeKey(0))
+
+	key1 = sim.RangeKey(1)
+	assert.Error(t, device.EraseLockingRange("wrong", 1))
+	assert.Equal(t, key1, sim.RangeKey(1))
+	assert.Equal(t, 0, sim.OpenSessions())
+}
+
+func TestEraseBand(t *testing.T) {
+	config := tcg_sim.DefaultConfig()
+	config.SSC = tcg_sim.SSCEnterprise
+	config.MaxRanges = 2
+	sim := tcg_sim.NewTPer(config)
+	device := testEnterpriseDevice(t, sim)
+	require.NoError(t, device.TakeOwnership("owner"))
+	require.NoError(t, device.SetBandMasterPassword(1, "owner", "band1"))
+	require.NoError(t, device.SetEraseMasterPassword("owner", "erase"))
+	require.NoError(t, device.SetupLockingRange("band1", 1, 0x1000, 0x800))
+	require.NoError(t, device.ConfigureLockingRange("band1", 1, false, false, false))
+
+	assert.Error(t, device.EraseLockingRange("erase", 1, tcg.EraseOptions{Relock: true}))
+	band1CPin := tcg.C_PIN_ENTERPRISE_BANDMASTER0
+	band1CPin[7]++
+	band1Pin := sim.PIN(band1CPin)
+
+	key := sim.RangeKey(1)
+	require.NotNil(t, key)
+	require.NoError(t, device.EraseLockingRange("erase", 1, tcg.EraseOptions{
+		Relock:             true,
+		Verify:             true,
+		BandMasterPassword: "band1",
+	}))
+	assert.NotEqual(t, key, sim.RangeKey(1))
+
+	band, err := device.GetLockingRange("band1", 1)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0x1000), band.RangeStart)
+	assert.True(t, band.ReadLockEnabled && band.WriteLockEnabled)
+	assert.True(t, band.ReadLocked && band.WriteLocked)
+	assert.True(t, band.LockOnReset)
+	assert.Equal(t, band1Pin, sim.PIN(band1CPin))
+
+	// without the BandMaster password the PIN is left at the MSID
+	require.NoError(t, device.EraseLockingRange("erase", 1))
+	assert.Equal(t, []byte(config.MSID), sim.PIN(band1CPin))
+	assert.Equal(t, 0, sim.OpenSessions())
+}
@@ -2,12 +2,95 @@ package tcg
 
 import (
 	"fmt"
+	"strings"
 )
 
+// Method status codes, TCG Storage Architecture Core Specification 5.1.5
+var methodStatusNames = map[MethodStatus]struct {
+	name        string
+	description string
+}{
+	SUCCESS:               {"SUCCESS", "the method succeeded"},
+	NOT_AUTHORIZED:        {"NOT_AUTHORIZED", "the password is wrong or the authorities of the session may not invoke the method"},
+	SP_BUSY:               {"SP_BUSY", "the SP is in use by another session"},
+	SP_FAILED:             {"SP_FAILED", "the SP has failed"},
+	SP_DISABLED:           {"SP_DISABLED", "the SP is disabled"},
+	SP_FROZEN:             {"SP_FROZEN", "the SP is frozen"},
+	NO_SESSIONS_AVAILABLE: {"NO_SESSIONS_AVAILABLE", "the TPer has no free session"},
+	UNIQUENESS_CONFLICT:   {"UNIQUENESS_CONFLICT", "a value of a unique column is already in use"},
+	INSUFFICIENT_SPACE:    {"INSUFFICIENT_SPACE", "the SP has not enough space"},
+	INSUFFICIENT_ROWS:     {"INSUFFICIENT_ROWS", "the table has not enough free rows"},
+	INVALID_FUNCTION:      {"INVALID_FUNCTION", "the method is not supported"},
+	INVALID_PARAMETER:     {"INVALID_PARAMETER", "a parameter of the method is invalid"},
+	INVALID_REFERENCE:     {"INVALID_REFERENCE", "a reference of the method is invalid"},
+	TPER_MALFUNCTION:      {"TPER_MALFUNCTION", "the TPer has malfunctioned"},
+	TRANSACTION_FAILURE:   {"TRANSACTION_FAILURE", "the transaction has failed"},
+	RESPONSE_OVERFLOW:     {"RESPONSE_OVERFLOW", "the response does not fit in the ComPacket"},
+	AUTHORITY_LOCKED_OUT:  {"AUTHORITY_LOCKED_OUT", "the authority is locked out after reaching its TryLimit"},
+	FAIL:                  {"FAIL", "the method has failed"},
+}
+
+func (s MethodStatus) String() string {
+	if status, ok := methodStatusNames[s]; ok {
+		return status.name
+	}
+	return fmt.Sprintf("MethodStatus(0x%02x)", int(s))
+}
+
+// Description explains the status code
+func (s MethodStatus) Description() string {
+	if status, ok := methodStatusNames[s]; ok {
+		return status.description
+	}
+	return "unknown status"
+}
+
+// Sentinel errors of the status codes, errors.Is matches any TcgError of the same status
+var (
+	ErrNotAuthorized       error = &TcgError{Status: NOT_AUTHORIZED}
+	ErrSpBusy              error = &TcgError{Status: SP_BUSY}
+	ErrSpFailed            error = &TcgError{Status: SP_FAILED}
+	ErrSpDisabled          error = &TcgError{Status: SP_DISABLED}
+	ErrSpFrozen            error = &TcgError{Status: SP_FROZEN}
+	ErrNoSessionsAvailable error = &TcgError{Status: NO_SESSIONS_AVAILABLE}
+	ErrUniquenessConflict  error = &TcgError{Status: UNIQUENESS_CONFLICT}
+	ErrInsufficientSpace   error = &TcgError{Status: INSUFFICIENT_SPACE}
+	ErrInsufficientRows    error = &TcgError{Status: INSUFFICIENT_ROWS}
+	ErrInvalidFunction     error = &TcgError{Status: INVALID_FUNCTION}
+	ErrInvalidParameter    error = &TcgError{Status: INVALID_PARAMETER}
+	ErrInvalidReference    error = &TcgError{Status: INVALID_REFERENCE}
+	ErrTPerMalfunction     error = &TcgError{Status: TPER_MALFUNCTION}
+	ErrTransactionFailure  error = &TcgError{Status: TRANSACTION_FAILURE}
+	ErrResponseOverflow    error = &TcgError{Status: RESPONSE_OVERFLOW}
+	ErrAuthorityLockedOut  error = &TcgError{Status: AUTHORITY_LOCKED_OUT}
+	ErrFail                error = &TcgError{Status: FAIL}
+)
+
+// TcgError is a method that failed with a status code, Invoking and Method identify the method call and the session
+// numbers the session it was sent on, they are zero when unknown
 type TcgError struct {
 	Status MethodStatus
+
+	Invoking       OpalUID
+	Method         OpalMethod
+	HostSessionNum uint32
+	TPerSessionNum uint32
 }
 
 func (e *TcgError) Error() string {
-	return fmt.Sprintf("tcg error: %d", e.Status)
+	var b strings.Builder
+	fmt.Fprintf(&b, "tcg error: %s (0x%02x), %s", e.Status, int(e.Status), e.Status.Description())
+	if e.Method != (OpalMethod{}) {
+		fmt.Fprintf(&b, ", method %x invoked on %x", e.Method[:], e.Invoking[:])
+	}
+	if e.HostSessionNum != 0 || e.TPerSessionNum != 0 {
+		fmt.Fprintf(&b, ", session %d:%d", e.HostSessionNum, e.TPerSessionNum)
+	}
+	return b.String()
+}
+
+// Is matches the sentinel error of the status
+func (e *TcgError) Is(target error) bool {
+	t, ok := target.(*TcgError)
+	return ok && t.Status == e.Status
 }
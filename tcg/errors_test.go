@@ -0,0 +1,63 @@
+package tcg_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jc-lab/go-dparm/tcg"
+	"github.com/jc-lab/go-dparm/test/tcg_sim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMethodStatusNames(t *testing.T) {
+	assert.Equal(t, "AUTHORITY_LOCKED_OUT", tcg.AUTHORITY_LOCKED_OUT.String())
+	assert.Equal(t, "MethodStatus(0x20)", tcg.MethodStatus(0x20).String())
+	assert.NotEmpty(t, tcg.SP_FROZEN.Description())
+
+	err := fmt.Errorf("unlock: %w", &tcg.TcgError{
+		Status:         tcg.SP_FROZEN,
+		Invoking:       tcg.LOCKINGSP_UID,
+		Method:         tcg.ACTIVATE,
+		HostSessionNum: 105,
+		TPerSessionNum: 4097,
+	})
+	assert.True(t, errors.Is(err, tcg.ErrSpFrozen))
+	assert.False(t, errors.Is(err, tcg.ErrNotAuthorized))
+	assert.Equal(t, "unlock: tcg error: SP_FROZEN (0x06), the SP is frozen, method 0000000600000203 invoked on 0000020500000002, session 105:4097", err.Error())
+}
+
+func TestMethodStatusErrors(t *testing.T) {
+	config := tcg_sim.DefaultConfig()
+	config.TryLimit = 2
+	sim := tcg_sim.NewTPer(config)
+	device := testOpalDevice(t, sim)
+	require.NoError(t, device.TakeOwnership("sid"))
+	require.NoError(t, device.ActivateLockingSP("sid"))
+
+	// without a password the session is Anybody's, the Set of the range carries the session numbers
+	opal := device.(*tcg.TcgDeviceOpal2)
+	err := opal.SetLockingStateAs(tcg.UserUID(1), "", 1, tcg.LOCKED)
+	var tcgErr *tcg.TcgError
+	require.True(t, errors.As(err, &tcgErr))
+	assert.True(t, errors.Is(err, tcg.ErrNotAuthorized))
+	assert.Equal(t, tcg.SET, tcgErr.Method)
+	assert.Equal(t, tcg.LockingRangeUID(1), tcgErr.Invoking)
+	assert.NotZero(t, tcgErr.TPerSessionNum)
+
+	// a wrong password differs from a locked out authority
+	session := tcg.NewTcgSession(device)
+	err = session.Start(tcg.ADMINSP_UID, "wrong", tcg.SID_UID)
+	assert.True(t, errors.Is(err, tcg.ErrNotAuthorized))
+	assert.False(t, errors.Is(err, tcg.ErrAuthorityLockedOut))
+	require.True(t, errors.As(err, &tcgErr))
+	assert.Equal(t, tcg.STARTSESSION, tcgErr.Method)
+	assert.Equal(t, tcg.SMUID_UID, tcgErr.Invoking)
+
+	err = session.Start(tcg.ADMINSP_UID, "wrong", tcg.SID_UID)
+	assert.True(t, errors.Is(err, tcg.ErrNotAuthorized))
+	err = session.Start(tcg.ADMINSP_UID, "sid", tcg.SID_UID)
+	assert.True(t, errors.Is(err, tcg.ErrAuthorityLockedOut))
+	assert.Equal(t, 0, sim.OpenSessions())
+}
@@ -0,0 +1,253 @@
+package tcg
+
+import (
+	"fmt"
+)
+
+// LockingRange is a row of the Locking table of the Locking SP, range 0 is the global range
+type LockingRange struct {
+	Index            uint8
+	RangeStart       uint64
+	RangeLength      uint64
+	ReadLockEnabled  bool
+	WriteLockEnabled bool
+	ReadLocked       bool
+	WriteLocked      bool
+	// LockOnReset is set when the range locks on power cycle
+	LockOnReset bool
+	ActiveKey   OpalUID
+}
+
+// LockingRangeUID returns the Locking table object of a range, 0 is the global range
+func LockingRangeUID(index uint8) OpalUID {
+	if index == 0 {
+		return LOCKINGRANGE_GLOBAL
+	}
+	uid := LOCKINGRANGE_GLOBAL
+	uid[5] = 0x03
+	uid[7] = index
+	return uid
+}
+
+// lockingRangeColumns are the column names of a Locking table row from RangeStart to ActiveKey
+type lockingRangeColumns [8]valueName
+
+var opalLockingRangeColumns = lockingRangeColumns{
+	LOCKING_RANGE_START, LOCKING_RANGE_LENGTH,
+	LOCKING_READ_LOCK_ENABLED, LOCKING_WRITE_LOCK_ENABLED, LOCKING_READ_LOCKED, LOCKING_WRITE_LOCKED,
+	LOCKING_LOCK_ON_RESET, LOCKING_ACTIVE_KEY,
+}
+
+// decodeLockingRange decodes the Get result of a Locking table row
+func decodeLockingRange(result *TcgResult, index uint8, columns lockingRangeColumns) (*LockingRange, error) {
+	var err error
+	lockingRange := &LockingRange{Index: index}
+	if lockingRange.RangeStart, err = result.Named(columns[0]).Uint(); err != nil {
+		return nil, err
+	}
+	if lockingRange.RangeLength, err = result.Named(columns[1]).Uint(); err != nil {
+		return nil, err
+	}
+	flags := []*bool{
+		&lockingRange.ReadLockEnabled,
+		&lockingRange.WriteLockEnabled,
+		&lockingRange.ReadLocked,
+		&lockingRange.WriteLocked,
+	}
+	for i, flag := range flags {
+		if *flag, err = result.Named(columns[2+i]).Bool(); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, resetType := range result.Named(columns[6]).Items() {
+		if v, err := resetType.Uint(); err == nil && v == 0 {
+			lockingRange.LockOnReset = true
+		}
+	}
+
+	if key, err := result.Named(columns[7]).UID(); err == nil {
+		lockingRange.ActiveKey = key
+	}
+
+	return lockingRange, nil
+}
+
+func startLockingSession(device TcgDevice, password string) (*TcgSession, error) {
+	return startLockingSessionAs(device, ADMIN1_UID, password)
+}
+
+func startLockingSessionAs(device TcgDevice, authority OpalUID, password string) (*TcgSession, error) {
+	session := NewTcgSession(device)
+	if err := session.Start(LOCKINGSP_UID, password, authority); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func addBoolValue(cmd *TcgCommand, column OpalToken, value bool) {
+	cmd.AddToken(STARTNAME)
+	cmd.AddToken(column)
+	if value {
+		cmd.AddToken(UINT_01)
+	} else {
+		cmd.AddToken(UINT_00)
+	}
+	cmd.AddToken(ENDNAME)
+}
+
+// setLockingRangeValues sends Set on a locking range, values adds the named values
+func setLockingRangeValues(session *TcgSession, index uint8, values func(cmd *TcgCommand)) error {
+	cmd := NewTcgCommand()
+	cmd.Init(LockingRangeUID(index), SET)
+	cmd.AddToken(STARTLIST)
+	cmd.AddToken(STARTNAME)
+	cmd.AddToken(VALUES)
+	cmd.AddToken(STARTLIST)
+	values(cmd)
+	cmd.AddToken(ENDLIST)
+	cmd.AddToken(ENDNAME)
+	cmd.AddToken(ENDLIST)
+	cmd.Complete()
+
+	_, err := session.SendCommand(cmd)
+	return err
+}
+
+func setupLockingRange(device TcgDevice, password string, index uint8, start uint64, length uint64) error {
+	session, err := startLockingSession(device, password)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	return SetupLockingRangeIn(session, index, start, length)
+}
+
+// SetupLockingRangeIn sets the start and the length of a range in a session of the Locking SP, to take part in a
+// transaction
+func SetupLockingRangeIn(session *TcgSession, index uint8, start uint64, length uint64) error {
+	if index == 0 {
+		return fmt.Errorf("the global range covers the whole drive")
+	}
+
+	return setLockingRangeValues(session, index, func(cmd *TcgCommand) {
+		cmd.AddToken(STARTNAME)
+		cmd.AddToken(LOCKING_RANGE_START)
+		cmd.AddNumberToken(start)
+		cmd.AddToken(ENDNAME)
+		cmd.AddToken(STARTNAME)
+		cmd.AddToken(LOCKING_RANGE_LENGTH)
+		cmd.AddNumberToken(length)
+		cmd.AddToken(ENDNAME)
+	})
+}
+
+func configureLockingRange(device TcgDevice, password string, index uint8, readLockEnabled bool, writeLockEnabled bool, lockOnReset bool) error {
+	session, err := startLockingSession(device, password)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	return ConfigureLockingRangeIn(session, index, readLockEnabled, writeLockEnabled, lockOnReset)
+}
+
+// ConfigureLockingRangeIn sets ReadLockEnabled, WriteLockEnabled and LockOnReset of a range in a session of the Locking
+// SP, to take part in a transaction
+func ConfigureLockingRangeIn(session *TcgSession, index uint8, readLockEnabled bool, writeLockEnabled bool, lockOnReset bool) error {
+	return setLockingRangeValues(session, index, func(cmd *TcgCommand) {
+		addBoolValue(cmd, LOCKING_READ_LOCK_ENABLED, readLockEnabled)
+		addBoolValue(cmd, LOCKING_WRITE_LOCK_ENABLED, writeLockEnabled)
+		cmd.AddToken(STARTNAME)
+		cmd.AddToken(LOCKING_LOCK_ON_RESET)
+		cmd.AddToken(STARTLIST)
+		if lockOnReset {
+			// power cycle
+			cmd.AddToken(UINT_00)
+		}
+		cmd.AddToken(ENDLIST)
+		cmd.AddToken(ENDNAME)
+	})
+}
+
+func setLockingState(device TcgDevice, password string, index uint8, state OpalLockingState) error {
+	return setLockingStateAs(device, ADMIN1_UID, password, index, state)
+}
+
+// setLockingStateAs sets ReadLocked and WriteLocked of a range as any authority the RdLocked and WrLocked ACEs of the
+// range grant
+func setLockingStateAs(device TcgDevice, authority OpalUID, password string, index uint8, state OpalLockingState) error {
+	var readLocked, writeLocked bool
+	switch state {
+	case READWRITE:
+	case READONLY:
+		writeLocked = true
+	case LOCKED:
+		readLocked, writeLocked = true, true
+	default:
+		return fmt.Errorf("unsupported locking state: %d", state)
+	}
+
+	session, err := startLockingSessionAs(device, authority, password)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	return setLockingRangeValues(session, index, func(cmd *TcgCommand) {
+		addBoolValue(cmd, LOCKING_READ_LOCKED, readLocked)
+		addBoolValue(cmd, LOCKING_WRITE_LOCKED, writeLocked)
+	})
+}
+
+func readLockingRange(session *TcgSession, index uint8) (*LockingRange, error) {
+	uid := LockingRangeUID(index)
+	table := append([]uint8{uint8(BYTESTRING8)}, uid[:]...)
+	resp, err := opalGetTable(session, table, uint16(LOCKING_RANGE_START), uint16(LOCKING_ACTIVE_KEY))
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeLockingRange(resp.Result(), index, opalLockingRangeColumns)
+}
+
+func getLockingRange(device TcgDevice, password string, index uint8) (*LockingRange, error) {
+	session, err := startLockingSession(device, password)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	return readLockingRange(session, index)
+}
+
+func getLockingRanges(device TcgDevice, password string) ([]LockingRange, error) {
+	session, err := startLockingSession(device, password)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	info := LOCKING_INFO_TABLE
+	table := append([]uint8{uint8(BYTESTRING8)}, info[:]...)
+	resp, err := opalGetTable(session, table, uint16(LOCKINGINFO_MAXRANGES), uint16(LOCKINGINFO_MAXRANGES))
+	if err != nil {
+		return nil, err
+	}
+	maxRanges, err := resp.Result().Named(LOCKINGINFO_MAXRANGES).Uint()
+	if err != nil {
+		return nil, err
+	}
+
+	ranges := make([]LockingRange, 0, maxRanges+1)
+	for i := uint64(0); i <= maxRanges && i <= 0xff; i++ {
+		lockingRange, err := readLockingRange(session, uint8(i))
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, *lockingRange)
+	}
+
+	return ranges, nil
+}
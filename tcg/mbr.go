@@ -0,0 +1,66 @@
+package tcg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrMBRVerifyFailed = errors.New("shadow MBR read back does not match")
+)
+
+func setMBRControl(device TcgDevice, password string, column OpalToken, value bool) error {
+	session, err := startLockingSession(device, password)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	cmd := NewTcgCommand()
+	cmd.Init(MBRCONTROL, SET)
+	cmd.AddToken(STARTLIST)
+	cmd.AddToken(STARTNAME)
+	cmd.AddToken(VALUES)
+	cmd.AddToken(STARTLIST)
+	addBoolValue(cmd, column, value)
+	cmd.AddToken(ENDLIST)
+	cmd.AddToken(ENDNAME)
+	cmd.AddToken(ENDLIST)
+	cmd.Complete()
+
+	_, err = session.SendCommand(cmd)
+	return err
+}
+
+func getMBR(device TcgDevice, password string, offset uint64, length int) ([]byte, error) {
+	session, err := startLockingSession(device, password)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	return readByteTable(session, MBR, offset, length)
+}
+
+// writeMBR uploads image to the start of the MBR table and reads it back
+func writeMBR(device TcgDevice, password string, image []byte) error {
+	session, err := startLockingSession(device, password)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	if err := writeByteTable(session, MBR, 0, image); err != nil {
+		return fmt.Errorf("shadow MBR %w", err)
+	}
+
+	written, err := readByteTable(session, MBR, 0, len(image))
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(written, image) {
+		return ErrMBRVerifyFailed
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package tcg
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+var (
+	ErrUnknownPasswordHasher = errors.New("unknown password hasher")
+)
+
+// PasswordHasher derives the PIN sent to the TPer from a password. A drive only accepts the PINs of the hasher its
+// passwords were set with, so the hasher of a drive provisioned by another tool must match that tool.
+type PasswordHasher interface {
+	// Name identifies the hasher, PasswordHasherByName returns the hasher of a name
+	Name() string
+	// HashPassword returns the PIN of a password without the header of its byte string atom, serial is the serial
+	// number of the drive
+	HashPassword(serial string, password string) []byte
+}
+
+var (
+	// DefaultPasswordHasher is PBKDF2-HMAC-SHA1 with 75000 iterations salted by the serial, the password is truncated
+	// to 32 bytes
+	DefaultPasswordHasher PasswordHasher = defaultPasswordHasher{}
+	// LegacyPasswordHasher is DefaultPasswordHasher with the byte string header of the 32 bytes key kept in the PIN,
+	// as the PINs set by earlier versions of this package
+	LegacyPasswordHasher PasswordHasher = legacyPasswordHasher{}
+	// SedutilPasswordHasher is the hashing of sedutil-cli, PBKDF2-HMAC-SHA1 with 75000 iterations salted by the
+	// serial padded with spaces to 20 bytes. An empty password is not hashed.
+	SedutilPasswordHasher PasswordHasher = sedutilPasswordHasher{}
+	// RawPasswordHasher sends the password truncated to 32 bytes as the PIN, for the MSID, a PSID and tools storing
+	// the PIN as entered
+	RawPasswordHasher PasswordHasher = rawPasswordHasher{}
+	// SHA256PasswordHasher sends the SHA-256 digest of the password as the PIN, as BitLocker and some vendor tools do
+	SHA256PasswordHasher PasswordHasher = sha256PasswordHasher{}
+)
+
+var passwordHashers = []PasswordHasher{
+	DefaultPasswordHasher,
+	LegacyPasswordHasher,
+	SedutilPasswordHasher,
+	RawPasswordHasher,
+	SHA256PasswordHasher,
+}
+
+// PasswordHasherByName returns the built-in hasher of a name, to restore a hasher recorded for a drive
+func PasswordHasherByName(name string) (PasswordHasher, error) {
+	for _, hasher := range passwordHashers {
+		if strings.EqualFold(hasher.Name(), name) {
+			return hasher, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownPasswordHasher, name)
+}
+
+type defaultPasswordHasher struct{}
+
+func (defaultPasswordHasher) Name() string {
+	return "dparm"
+}
+
+func (defaultPasswordHasher) HashPassword(serial string, password string) []byte {
+	return pbkdf2.Key([]byte(truncatePassword(password)), []byte(serial), 75000, 32, sha1.New)
+}
+
+type legacyPasswordHasher struct{}
+
+func (legacyPasswordHasher) Name() string {
+	return "dparm-legacy"
+}
+
+func (legacyPasswordHasher) HashPassword(serial string, password string) []byte {
+	return pinToken(DefaultPasswordHasher.HashPassword(serial, password))
+}
+
+type sedutilPasswordHasher struct{}
+
+func (sedutilPasswordHasher) Name() string {
+	return "sedutil"
+}
+
+func (sedutilPasswordHasher) HashPassword(serial string, password string) []byte {
+	if password == "" {
+		return []byte{}
+	}
+	salt := []byte(serial)
+	for len(salt) < 20 {
+		salt = append(salt, ' ')
+	}
+	return pbkdf2.Key([]byte(password), salt, 75000, 32, sha1.New)
+}
+
+type rawPasswordHasher struct{}
+
+func (rawPasswordHasher) Name() string {
+	return "raw"
+}
+
+func (rawPasswordHasher) HashPassword(serial string, password string) []byte {
+	return []byte(truncatePassword(password))
+}
+
+type sha256PasswordHasher struct{}
+
+func (sha256PasswordHasher) Name() string {
+	return "sha256"
+}
+
+func (sha256PasswordHasher) HashPassword(serial string, password string) []byte {
+	digest := sha256.Sum256([]byte(password))
+	return digest[:]
+}
+
+// maxPasswordLength is the length the default and the raw PINs truncate a password to
+const maxPasswordLength = 32
+
+// truncatePassword cuts a password to maxPasswordLength bytes, as TcgHashPassword always did
+func truncatePassword(password string) string {
+	if len(password) > maxPasswordLength {
+		return password[:maxPasswordLength]
+	}
+	return password
+}
+
+// pinToken returns the byte string atom carrying a PIN, every PIN sent to the TPer is encoded here
+func pinToken(pin []byte) []byte {
+	return append([]uint8{0xd0, uint8(len(pin))}, pin...)
+}
+
+// hashPassword returns the PIN of a password with the hasher of the drive
+func hashPassword(device TcgDevice, password string) []byte {
+	return device.GetPasswordHasher().HashPassword(device.GetSerial(), password)
+}
+
+func (p *TcgDeviceImpl) GetPasswordHasher() PasswordHasher {
+	if p.dh.passwordHasher == nil {
+		return DefaultPasswordHasher
+	}
+	return p.dh.passwordHasher
+}
+
+// SetPasswordHasher sets the hasher of the passwords of the drive, nil restores DefaultPasswordHasher
+func (p *TcgDeviceImpl) SetPasswordHasher(hasher PasswordHasher) {
+	p.dh.passwordHasher = hasher
+}
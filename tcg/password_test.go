@@ -0,0 +1,150 @@
+package tcg_test
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/jc-lab/go-dparm/tcg"
+	"github.com/jc-lab/go-dparm/test/tcg_sim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+func TestPasswordHashers(t *testing.T) {
+	serial := "SIMTPER000000001"
+	derived := pbkdf2.Key([]byte("password"), []byte(serial), 75000, 32, sha1.New)
+	assert.Equal(t, derived, tcg.DefaultPasswordHasher.HashPassword(serial, "password"))
+	assert.Equal(t, append([]byte{0xd0, 0x20}, derived...), tcg.LegacyPasswordHasher.HashPassword(serial, "password"))
+
+	derived = pbkdf2.Key([]byte("password"), []byte(serial+"    "), 75000, 32, sha1.New)
+	assert.Equal(t, derived, tcg.SedutilPasswordHasher.HashPassword(serial, "password"))
+	assert.Empty(t, tcg.SedutilPasswordHasher.HashPassword(serial, ""))
+
+	// a 40 byte password is truncated to 32 bytes before it is used
+	long := "0123456789abcdef0123456789abcdef01234567"
+	assert.Equal(t, []byte(long[:32]), tcg.RawPasswordHasher.HashPassword(serial, long))
+	assert.Equal(t, tcg.DefaultPasswordHasher.HashPassword(serial, long[:32]), tcg.DefaultPasswordHasher.HashPassword(serial, long))
+	digest := sha256.Sum256([]byte("password"))
+	assert.Equal(t, digest[:], tcg.SHA256PasswordHasher.HashPassword(serial, "password"))
+
+	for _, hasher := range []tcg.PasswordHasher{
+		tcg.DefaultPasswordHasher, tcg.LegacyPasswordHasher, tcg.SedutilPasswordHasher, tcg.RawPasswordHasher,
+		tcg.SHA256PasswordHasher,
+	} {
+		found, err := tcg.PasswordHasherByName(hasher.Name())
+		require.NoError(t, err)
+		assert.Equal(t, hasher, found)
+	}
+	_, err := tcg.PasswordHasherByName("md5")
+	assert.True(t, errors.Is(err, tcg.ErrUnknownPasswordHasher))
+}
+
+func TestPasswordHasherAuthenticate(t *testing.T) {
+	for _, hasher := range []tcg.PasswordHasher{
+		tcg.DefaultPasswordHasher, tcg.LegacyPasswordHasher, tcg.SedutilPasswordHasher, tcg.RawPasswordHasher,
+		tcg.SHA256PasswordHasher,
+	} {
+		sim := tcg_sim.NewTPer(tcg_sim.DefaultConfig())
+		device := testOpalDevice(t, sim)
+		device.SetPasswordHasher(hasher)
+		require.NoError(t, device.TakeOwnership("password"), hasher.Name())
+		assert.Equal(t, hasher.HashPassword(device.GetSerial(), "password"), sim.PIN(tcg.C_PIN_SID), hasher.Name())
+		assert.Equal(t, append([]byte{0xd0, uint8(len(sim.PIN(tcg.C_PIN_SID)))}, sim.PIN(tcg.C_PIN_SID)...),
+			tcg.TcgHashPassword(device, false, "password"), hasher.Name())
+
+		session := tcg.NewTcgSession(device)
+		require.NoError(t, session.Start(tcg.ADMINSP_UID, "password", tcg.SID_UID), hasher.Name())
+		session.Close()
+		session = tcg.NewTcgSession(device)
+		assert.Error(t, session.Start(tcg.ADMINSP_UID, "other", tcg.SID_UID), hasher.Name())
+	}
+
+	// a PIN set with the token of TcgHashPassword without hashing authenticates a session without hashing
+	sim := tcg_sim.NewTPer(tcg_sim.DefaultConfig())
+	device := testOpalDevice(t, sim)
+	require.NoError(t, device.TakeOwnership("password"))
+	session := tcg.NewTcgSession(device)
+	require.NoError(t, session.Start(tcg.ADMINSP_UID, "password", tcg.SID_UID))
+	cmd := tcg.NewTcgCommand()
+	cmd.Init(tcg.C_PIN_SID, tcg.SET)
+	cmd.AddToken(tcg.STARTLIST)
+	cmd.AddToken(tcg.STARTNAME)
+	cmd.AddToken(tcg.VALUES)
+	cmd.AddToken(tcg.STARTLIST)
+	cmd.AddToken(tcg.STARTNAME)
+	cmd.AddToken(tcg.CREDENTIAL_PIN)
+	cmd.AddRawToken(tcg.TcgHashPassword(device, true, "raw"))
+	cmd.AddToken(tcg.ENDNAME)
+	cmd.AddToken(tcg.ENDLIST)
+	cmd.AddToken(tcg.ENDNAME)
+	cmd.AddToken(tcg.ENDLIST)
+	cmd.Complete()
+	_, err := session.SendCommand(cmd)
+	require.NoError(t, err)
+	session.Close()
+	assert.Equal(t, []byte("raw"), sim.PIN(tcg.C_PIN_SID))
+
+	session = tcg.NewTcgSession(device)
+	session.SetNoHashPassword(true)
+	require.NoError(t, session.Start(tcg.ADMINSP_UID, "raw", tcg.SID_UID))
+	session.Close()
+	assert.Equal(t, 0, sim.OpenSessions())
+}
+
+func TestLongRawPassword(t *testing.T) {
+	sim := tcg_sim.NewTPer(tcg_sim.DefaultConfig())
+	device := testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+	long := "0123456789abcdef0123456789abcdef01234567"
+	assert.Equal(t, append([]byte{0xd0, 0x20}, long[:32]...), tcg.TcgHashPassword(device, true, long))
+
+	// a PIN set with the raw hasher authenticates a session without hashing of the same password
+	device.SetPasswordHasher(tcg.RawPasswordHasher)
+	require.NoError(t, device.TakeOwnership(long))
+	assert.Equal(t, []byte(long[:32]), sim.PIN(tcg.C_PIN_SID))
+	device.SetPasswordHasher(nil)
+
+	session := tcg.NewTcgSession(device)
+	session.SetNoHashPassword(true)
+	require.NoError(t, session.Start(tcg.ADMINSP_UID, long, tcg.SID_UID))
+	session.Close()
+	assert.Equal(t, 0, sim.OpenSessions())
+}
+
+func TestSedutilProvisionedDrive(t *testing.T) {
+	config := tcg_sim.DefaultConfig()
+	sim := tcg_sim.NewTPer(config)
+	device := testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+	assert.Equal(t, tcg.DefaultPasswordHasher, device.GetPasswordHasher())
+	device.SetPasswordHasher(tcg.SedutilPasswordHasher)
+	require.NoError(t, device.TakeOwnership("sid"))
+	require.NoError(t, device.ActivateLockingSP("sid"))
+
+	// the PIN sedutil-cli would have set
+	pin := pbkdf2.Key([]byte("sid"), []byte(config.Serial+"    "), 75000, 32, sha1.New)
+	assert.Equal(t, pin, sim.PIN(tcg.C_PIN_SID))
+
+	// the hasher recorded for the drive is restored by name
+	device = testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+	_, err := device.GetLockingRange("sid", 0)
+	assert.Error(t, err)
+	hasher, err := tcg.PasswordHasherByName("sedutil")
+	require.NoError(t, err)
+	device.SetPasswordHasher(hasher)
+	_, err = device.GetLockingRange("sid", 0)
+	require.NoError(t, err)
+
+	// a session can override the hasher of the drive
+	device.SetPasswordHasher(nil)
+	session := tcg.NewTcgSession(device)
+	session.SetPasswordHasher(tcg.SedutilPasswordHasher)
+	require.NoError(t, session.Start(tcg.LOCKINGSP_UID, "sid", tcg.ADMIN1_UID))
+	session.Close()
+
+	session = tcg.NewTcgSession(device)
+	session.SetNoHashPassword(true)
+	assert.Equal(t, tcg.RawPasswordHasher, session.GetPasswordHasher())
+	assert.Equal(t, 0, sim.OpenSessions())
+}
@@ -0,0 +1,180 @@
+package tcg
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrComPacketTooLarge = errors.New("command exceeds the MaxComPacketSize of the TPer")
+)
+
+const (
+	comPacketHeaderLen = 20
+	packetHeaderLen    = 24
+	subPacketHeaderLen = 12
+
+	// minComPacketSize is the smallest MaxComPacketSize the Core Specification allows
+	minComPacketSize = 1024
+
+	// dataChunkOverhead is the room for the headers and the Set/Get tokens around a chunk of a byte table
+	dataChunkOverhead = 128
+)
+
+// TcgProperties are the communication properties the host and the TPer agreed on in the Properties exchange,
+// TCG Storage Architecture Core Specification 5.2.2.1
+type TcgProperties struct {
+	// MaxComPacketSize is the largest ComPacket the host may send
+	MaxComPacketSize int
+	// MaxResponseComPacketSize is the largest ComPacket the TPer returns
+	MaxResponseComPacketSize int
+	MaxPacketSize            int
+	// MaxIndTokenSize is the largest atom the TPer accepts
+	MaxIndTokenSize int
+	MaxPackets      int
+	MaxSubpackets   int
+	MaxMethods      int
+	MaxSessions     int
+
+	// TPer keeps every property the TPer reported by name
+	TPer map[string]uint64
+}
+
+// DefaultProperties returns the properties every Opal TPer supports, they are used until the Properties
+// exchange succeeds
+func DefaultProperties() *TcgProperties {
+	return &TcgProperties{
+		MaxComPacketSize:         MIN_BUFFER_LENGTH,
+		MaxResponseComPacketSize: MIN_BUFFER_LENGTH,
+		MaxPacketSize:            MIN_BUFFER_LENGTH - comPacketHeaderLen,
+		MaxIndTokenSize:          MIN_BUFFER_LENGTH - comPacketHeaderLen - packetHeaderLen - subPacketHeaderLen,
+		MaxPackets:               1,
+		MaxSubpackets:            1,
+		MaxMethods:               1,
+		MaxSessions:              1,
+	}
+}
+
+// hostProperties are the properties the host proposes, the TPer lowers them to what it supports
+func hostProperties() *TcgProperties {
+	return &TcgProperties{
+		MaxComPacketSize:         MAX_BUFFER_LENGTH,
+		MaxResponseComPacketSize: MAX_BUFFER_LENGTH,
+		MaxPacketSize:            MAX_BUFFER_LENGTH - comPacketHeaderLen,
+		MaxIndTokenSize:          MAX_BUFFER_LENGTH - comPacketHeaderLen - packetHeaderLen - subPacketHeaderLen,
+		MaxPackets:               1,
+		MaxSubpackets:            1,
+		MaxMethods:               1,
+	}
+}
+
+// MaxWriteChunk returns the number of bytes of a byte table a single Set carries
+func (p *TcgProperties) MaxWriteChunk() int {
+	size := p.MaxComPacketSize
+	if packet := p.MaxPacketSize + comPacketHeaderLen; packet < size {
+		size = packet
+	}
+	size -= dataChunkOverhead
+	// the data is sent as a single long atom
+	if token := p.MaxIndTokenSize - 4; token < size {
+		size = token
+	}
+	return size &^ 3
+}
+
+// MaxReadChunk returns the number of bytes of a byte table a single Get returns
+func (p *TcgProperties) MaxReadChunk() int {
+	return (p.MaxResponseComPacketSize - dataChunkOverhead) &^ 3
+}
+
+// responseBufferSize returns the receive buffer size, a multiple of 512 bytes
+func (p *TcgProperties) responseBufferSize() uint32 {
+	size := p.MaxResponseComPacketSize
+	if size < MIN_BUFFER_LENGTH {
+		size = MIN_BUFFER_LENGTH
+	}
+	return uint32(size+511) &^ 511
+}
+
+// ExchangeProperties runs the Properties method of the session manager and returns the agreed properties
+func ExchangeProperties(device TcgDevice) (*TcgProperties, error) {
+	host := hostProperties()
+	hostValues := []struct {
+		name  string
+		value int
+	}{
+		{"MaxComPacketSize", host.MaxComPacketSize},
+		{"MaxResponseComPacketSize", host.MaxResponseComPacketSize},
+		{"MaxPacketSize", host.MaxPacketSize},
+		{"MaxIndTokenSize", host.MaxIndTokenSize},
+		{"MaxPackets", host.MaxPackets},
+		{"MaxSubpackets", host.MaxSubpackets},
+		{"MaxMethods", host.MaxMethods},
+	}
+	var items []*TcgValue
+	for _, v := range hostValues {
+		items = append(items, NamedValue(v.name, UintValue(uint64(v.value))))
+	}
+
+	cmd := NewTcgCommand()
+	cmd.Init(SMUID_UID, PROPERTIES)
+	cmd.AddValue(ListValue(NamedValue(HOSTPROPERTIES, ListValue(items...))))
+	cmd.Complete()
+
+	// Properties is sent outside of a session
+	resp, err := NewTcgSession(device).SendCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// the results are the TPer properties followed by the host properties the TPer accepted
+	results := resp.Result().Results()
+	tper := results.Item(0)
+	if !tper.IsList() {
+		return nil, fmt.Errorf("%w: no TPer properties", ErrIllegalResponse)
+	}
+
+	props := &TcgProperties{TPer: make(map[string]uint64)}
+	for _, item := range tper.Items() {
+		name, err := item.Name().Bytes()
+		if err != nil {
+			continue
+		}
+		if value, err := item.Value().Uint(); err == nil {
+			props.TPer[string(name)] = value
+		}
+	}
+
+	accepted := results.Named(HOSTPROPERTIES)
+	// a property is the lowest of the host proposal, the value the TPer accepted and the TPer property
+	agree := func(name string, hostValue int) int {
+		if v, err := accepted.Named(name).Uint(); err == nil && int(v) < hostValue {
+			hostValue = int(v)
+		}
+		if v, ok := props.TPer[name]; ok && v != 0 && int(v) < hostValue {
+			hostValue = int(v)
+		}
+		return hostValue
+	}
+	props.MaxComPacketSize = agree("MaxComPacketSize", host.MaxComPacketSize)
+	props.MaxResponseComPacketSize = agree("MaxResponseComPacketSize", host.MaxResponseComPacketSize)
+	props.MaxPacketSize = agree("MaxPacketSize", host.MaxPacketSize)
+	props.MaxIndTokenSize = agree("MaxIndTokenSize", host.MaxIndTokenSize)
+	props.MaxPackets = agree("MaxPackets", host.MaxPackets)
+	props.MaxSubpackets = agree("MaxSubpackets", host.MaxSubpackets)
+	props.MaxMethods = agree("MaxMethods", host.MaxMethods)
+	props.MaxSessions = 1
+	if v, ok := props.TPer["MaxSessions"]; ok && v != 0 {
+		props.MaxSessions = int(v)
+	}
+
+	// a TPer without MaxResponseComPacketSize answers with up to MaxComPacketSize
+	if _, ok := props.TPer["MaxResponseComPacketSize"]; !ok {
+		props.MaxResponseComPacketSize = props.MaxComPacketSize
+	}
+
+	if props.MaxComPacketSize < minComPacketSize || props.MaxIndTokenSize <= 0 {
+		return nil, fmt.Errorf("%w: MaxComPacketSize %d, MaxIndTokenSize %d", ErrIllegalResponse, props.MaxComPacketSize, props.MaxIndTokenSize)
+	}
+	return props, nil
+}
@@ -0,0 +1,77 @@
+package tcg_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jc-lab/go-dparm/tcg"
+	"github.com/jc-lab/go-dparm/test/tcg_sim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExchangeProperties(t *testing.T) {
+	config := tcg_sim.DefaultConfig()
+	config.MaxComPacketSize = 16384
+	config.MaxSessions = 2
+	sim := tcg_sim.NewTPer(config)
+	device := testOpalDevice(t, sim)
+
+	props := device.GetProperties()
+	assert.Equal(t, 16384, props.MaxComPacketSize)
+	assert.Equal(t, 16384, props.MaxResponseComPacketSize)
+	assert.Equal(t, 16384-20, props.MaxPacketSize)
+	assert.Equal(t, 16384-56, props.MaxIndTokenSize)
+	assert.Equal(t, 2, props.MaxSessions)
+	assert.Equal(t, uint64(2), props.TPer["MaxAuthentications"])
+
+	host := sim.HostProperties()
+	assert.Equal(t, uint64(tcg.MAX_BUFFER_LENGTH), host["MaxComPacketSize"])
+	assert.Equal(t, uint64(1), host["MaxPackets"])
+
+	msid, err := device.GetDefaultPassword()
+	require.NoError(t, err)
+	assert.Equal(t, config.MSID, msid)
+}
+
+func TestShadowMBRComPacketSize(t *testing.T) {
+	for _, size := range []int{1024, 16384} {
+		config := tcg_sim.DefaultConfig()
+		config.MaxComPacketSize = size
+		sim := tcg_sim.NewTPer(config)
+		device := testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+		require.Equal(t, size, device.GetProperties().MaxComPacketSize)
+		require.NoError(t, device.TakeOwnership("sid"))
+		require.NoError(t, device.ActivateLockingSP("sid"))
+
+		image := make([]byte, 40000)
+		for i := range image {
+			image[i] = uint8(i * 13)
+		}
+		require.NoError(t, device.WriteMBR("sid", image), "MaxComPacketSize %d", size)
+
+		data, err := device.ReadMBR("sid", 1000, 20000)
+		require.NoError(t, err)
+		assert.Equal(t, image[1000:21000], data)
+		assert.Equal(t, 0, sim.OpenSessions())
+	}
+}
+
+func TestComPacketTooLarge(t *testing.T) {
+	config := tcg_sim.DefaultConfig()
+	config.MaxComPacketSize = 1024
+	device := testOpalDevice(t, tcg_sim.NewTPer(config))
+
+	session := tcg.NewTcgSession(device)
+	require.NoError(t, session.Start(tcg.ADMINSP_UID, "", tcg.UID_HEXFF))
+	defer session.Close()
+
+	cmd := tcg.NewTcgCommand()
+	cmd.Init(tcg.C_PIN_MSID, tcg.SET)
+	cmd.AddValue(tcg.ListValue(tcg.NamedValue(tcg.VALUES, tcg.ListValue(
+		tcg.NamedValue(tcg.CREDENTIAL_PIN, tcg.BytesValue(make([]byte, 1500))),
+	))))
+	cmd.Complete()
+	_, err := session.SendCommand(cmd)
+	assert.True(t, errors.Is(err, tcg.ErrComPacketTooLarge))
+}
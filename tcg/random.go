@@ -0,0 +1,118 @@
+package tcg
+
+import (
+	"errors"
+	"fmt"
+)
+
+// maxRandomCount is the largest Count of Random every TPer accepts, TCG Storage Opal SSC 5.1.4
+const maxRandomCount = 32
+
+// Random returns n bytes of the random number generator of the TPer. It invokes Random as Anybody on the Admin SP,
+// or on the Locking SP when the Admin SP does not let Anybody invoke it, in chunks of the largest count the TPer
+// accepts.
+func (p *TcgDeviceImpl) Random(n int) ([]byte, error) {
+	if n <= 0 {
+		return []byte{}, nil
+	}
+
+	reader := NewRandomReader(p.dev)
+	defer reader.Close()
+
+	data := make([]byte, n)
+	if _, err := reader.Read(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// RandomReader reads the random number generator of the TPer. It keeps an Anybody session open from the first Read
+// until Close, the session takes one of the sessions the TPer allows.
+type RandomReader struct {
+	device  TcgDevice
+	session *TcgSession
+	sp      OpalUID
+}
+
+// NewRandomReader returns a reader of the random number generator of the TPer, it must be closed
+func NewRandomReader(device TcgDevice) *RandomReader {
+	return &RandomReader{device: device}
+}
+
+// Read fills p with random bytes, it returns an error rather than a short read
+func (r *RandomReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if r.session == nil {
+		if err := r.open(ADMINSP_UID); err != nil {
+			return 0, err
+		}
+	}
+	err := readRandom(r.session, p)
+	if (errors.Is(err, ErrNotAuthorized) || errors.Is(err, ErrInvalidFunction)) && r.sp == ADMINSP_UID &&
+		r.device.IsLockingEnabled() {
+		r.Close()
+		lockingSP := LOCKINGSP_UID
+		if r.device.GetDeviceType() == OpalEnterpriseDevice {
+			lockingSP = ENTERPRISE_LOCKINGSP_UID
+		}
+		if err := r.open(lockingSP); err != nil {
+			return 0, err
+		}
+		err = readRandom(r.session, p)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close ends the session of the reader, a later Read starts a new one
+func (r *RandomReader) Close() error {
+	if r.session != nil {
+		r.session.Close()
+		r.session = nil
+	}
+	return nil
+}
+
+func (r *RandomReader) open(sp OpalUID) error {
+	session := NewTcgSession(r.device)
+	if err := session.Start(sp, "", UID_HEXFF); err != nil {
+		return err
+	}
+	r.session = session
+	r.sp = sp
+	return nil
+}
+
+// readRandom fills p with Random invoked on the SP of the session
+func readRandom(session *TcgSession, p []byte) error {
+	for filled := 0; filled < len(p); {
+		count := len(p) - filled
+		if count > maxRandomCount {
+			count = maxRandomCount
+		}
+
+		cmd := NewTcgCommand()
+		cmd.Init(THISSP_UID, RANDOM)
+		cmd.AddValue(ListValue(UintValue(uint64(count))))
+		cmd.Complete()
+
+		resp, err := session.SendCommand(cmd)
+		if err != nil {
+			return err
+		}
+		chunk, err := resp.Result().Results().Item(0).Bytes()
+		if err != nil {
+			return err
+		}
+		if len(chunk) != count {
+			return fmt.Errorf("%w: Random returned %d bytes of %d", ErrIllegalResponse, len(chunk), count)
+		}
+		filled += copy(p[filled:], chunk)
+	}
+	return nil
+}
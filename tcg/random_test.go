@@ -0,0 +1,51 @@
+package tcg_test
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/jc-lab/go-dparm/tcg"
+	"github.com/jc-lab/go-dparm/test/tcg_sim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRandom(t *testing.T) {
+	sim := tcg_sim.NewTPer(tcg_sim.DefaultConfig())
+	device := testOpalDevice(t, sim)
+
+	data, err := device.Random(100)
+	require.NoError(t, err)
+	assert.Len(t, data, 100)
+	assert.NotEqual(t, make([]byte, 100), data)
+
+	data, err = device.Random(0)
+	require.NoError(t, err)
+	assert.Empty(t, data)
+
+	// the reader keeps its session across small reads
+	reader := tcg.NewRandomReader(device)
+	buf := make([]byte, 70)
+	_, err = io.ReadFull(reader, buf)
+	require.NoError(t, err)
+	assert.False(t, bytes.Equal(make([]byte, 70), buf))
+	_, err = io.ReadFull(bufio.NewReaderSize(reader, 16), buf[:5])
+	require.NoError(t, err)
+	assert.Equal(t, 1, sim.OpenSessions())
+	require.NoError(t, reader.Close())
+	assert.Equal(t, 0, sim.OpenSessions())
+}
+
+func TestRandomEnterprise(t *testing.T) {
+	config := tcg_sim.DefaultConfig()
+	config.SSC = tcg_sim.SSCEnterprise
+	sim := tcg_sim.NewTPer(config)
+	device := testEnterpriseDevice(t, sim)
+
+	data, err := device.Random(33)
+	require.NoError(t, err)
+	assert.Len(t, data, 33)
+	assert.Equal(t, 0, sim.OpenSessions())
+}
@@ -0,0 +1,107 @@
+package tcg
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// RedactCredentials zeroes the credentials a ComPacket sends to the TPer: the HostChallenge of StartSession, the
+// challenge of Authenticate, the PIN of a Set on a C_PIN object and the Admin1 PIN of Reactivate. The length of the
+// packet and of its atoms is kept, two packets that differ only in their credentials are equal once redacted.
+func RedactCredentials(comPacket []byte) {
+	const payloadOffset = comPacketHeaderLen + packetHeaderLen + subPacketHeaderLen
+	if len(comPacket) < payloadOffset {
+		return
+	}
+	length := int(binary.BigEndian.Uint32(comPacket[payloadOffset-4:]))
+	if length > len(comPacket)-payloadOffset {
+		length = len(comPacket) - payloadOffset
+	}
+	redactPayload(comPacket[payloadOffset : payloadOffset+length])
+}
+
+// flatToken is an atom or a control token of a token stream, end is the position after it
+type flatToken struct {
+	atom  *TcgValue
+	token OpalToken
+	end   int
+}
+
+func (t *flatToken) is(token OpalToken) bool {
+	return t.atom == nil && t.token == token
+}
+
+// redactPayload zeroes the byte sequences of the named values of a method call that carry a credential
+func redactPayload(payload []byte) {
+	d := &tokenDecoder{data: payload}
+	var tokens []flatToken
+	for {
+		for d.pos < len(d.data) && d.data[d.pos] == uint8(EMPTYATOM) {
+			d.pos++
+		}
+		if d.pos >= len(d.data) {
+			break
+		}
+		if b := d.data[d.pos]; b >= 0xf0 {
+			d.pos++
+			tokens = append(tokens, flatToken{token: OpalToken(b), end: d.pos})
+			continue
+		}
+		atom, err := d.atom()
+		if err != nil {
+			break
+		}
+		tokens = append(tokens, flatToken{atom: atom, end: d.pos})
+	}
+
+	if len(tokens) < 3 || !tokens[0].is(CALL) {
+		return
+	}
+	invoking, err := tokens[1].atom.Bytes()
+	if err != nil || len(invoking) != len(OpalUID{}) {
+		return
+	}
+	method, err := tokens[2].atom.Bytes()
+	if err != nil || len(method) != len(OpalMethod{}) {
+		return
+	}
+
+	for i := 3; i+3 < len(tokens); i++ {
+		named := tokens[i : i+4]
+		if !named[0].is(STARTNAME) || !named[2].atom.IsBytes() || !named[3].is(ENDNAME) {
+			continue
+		}
+		if !isCredentialName(invoking, method, named[1].atom) {
+			continue
+		}
+		data, _ := named[2].atom.Bytes()
+		for j := named[2].end - len(data); j < named[2].end; j++ {
+			payload[j] = 0
+		}
+	}
+}
+
+func isCredentialName(invoking []byte, method []byte, name *TcgValue) bool {
+	if text, err := name.Bytes(); err == nil {
+		// the Enterprise SSC names the parameters and the columns
+		return string(text) == "Challenge" || string(text) == "PIN"
+	}
+	n, err := name.Uint()
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case bytes.Equal(method, STARTSESSION[:]):
+		// HostChallenge
+		return n == 0
+	case bytes.Equal(method, AUTHENTICATE[:]):
+		// Proof
+		return n == 0
+	case bytes.Equal(method, SET[:]):
+		return bytes.Equal(invoking[:4], C_PIN_TABLE[:4]) && n == uint64(CREDENTIAL_PIN)
+	case bytes.Equal(method, REACTIVATE[:]):
+		return n == SUM_REACTIVATE_ADMIN1_PIN
+	}
+	return false
+}
@@ -18,11 +18,20 @@ type TcgResponse struct {
 	header *OpalHeader
 	ptr    *uint8
 	tokens []*TcgTokenVO
+	result *TcgResult
+	size   uint32
 }
 
-func NewTcgResponse() *TcgResponse {
+// NewTcgResponse allocates a response buffer of size bytes, MIN_BUFFER_LENGTH by default
+func NewTcgResponse(size ...uint32) *TcgResponse {
+	sizeVar := uint32(MIN_BUFFER_LENGTH)
+	if len(size) != 0 && size[0] > sizeVar {
+		sizeVar = size[0]
+	}
+
 	newResp :=  &TcgResponse{
-		buf: internal.NewAlignedBuffer(IO_BUFFER_ALIGNMENT, MIN_BUFFER_LENGTH),
+		buf:  internal.NewAlignedBuffer(IO_BUFFER_ALIGNMENT, int(sizeVar)),
+		size: sizeVar,
 	}
 	newResp.ptr = newResp.buf.GetPointer()
 	newResp.header = (*OpalHeader)(unsafe.Pointer(newResp.ptr))
@@ -39,7 +48,7 @@ func (p *TcgResponse) GetRespBuf() *uint8 {
 }
 
 func (p *TcgResponse) GetRespBufSize() uint32 {
-	return MIN_BUFFER_LENGTH
+	return p.size
 }
 
 func (p *TcgResponse) Commit() error {
@@ -52,7 +61,7 @@ func (p *TcgResponse) Commit() error {
 	var curTokenLen uint32
 	var tempTokenBuf []uint8
 
-	if uintptr(unsafe.Pointer(end)) > uintptr(unsafe.Pointer(p.ptr)) + MIN_BUFFER_LENGTH {
+	if uintptr(unsafe.Pointer(end)) > uintptr(unsafe.Pointer(p.ptr)) + uintptr(p.size) {
 		return fmt.Errorf("illegal data")
 	}
 
@@ -87,9 +96,23 @@ func (p *TcgResponse) Commit() error {
 
 	p.tokens = respTokens
 
+	result, err := ParseResult(unsafe.Slice((*uint8)(unsafe.Add(unsafe.Pointer(p.ptr), unsafe.Sizeof(*p.header))), subpktLen))
+	if err != nil {
+		return err
+	}
+	p.result = result
+
 	return nil
 }
 
+// Result returns the response decoded into a tree by Commit
+func (p *TcgResponse) Result() *TcgResult {
+	if p.result == nil {
+		return &TcgResult{}
+	}
+	return p.result
+}
+
 func (p *TcgResponse) GetTokenCount() int {
 	return len(p.tokens)
 }
@@ -100,3 +123,69 @@ func (p *TcgResponse) GetToken(index int) *TcgTokenVO {
 	}
 	return p.tokens[index]
 }
+
+// TcgResult is a method call or a method response decoded into a tree,
+// TCG Storage Architecture Core Specification 3.2.4
+type TcgResult struct {
+	// Values are the top level values before the end of data token
+	Values []*TcgValue
+	// Status is the method status list after the end of data token, nil when there is none
+	Status *TcgValue
+}
+
+// ParseResult decodes the payload of a subpacket
+func ParseResult(data []byte) (*TcgResult, error) {
+	values, err := ParseTokens(data)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &TcgResult{Values: values}
+	for i, v := range values {
+		if v.IsToken(ENDOFDATA) {
+			result.Values = values[:i]
+			if i+1 < len(values) && values[i+1].IsList() {
+				result.Status = values[i+1]
+			}
+			break
+		}
+	}
+	return result, nil
+}
+
+// IsEndOfSession returns true when the TPer closed the session
+func (r *TcgResult) IsEndOfSession() bool {
+	return len(r.Values) > 0 && r.Values[0].IsToken(ENDOFSESSION)
+}
+
+// IsCall returns true for a method call, such as the SyncSession of the session manager
+func (r *TcgResult) IsCall() bool {
+	return len(r.Values) > 0 && r.Values[0].IsToken(CALL)
+}
+
+// Results returns the result list of a method response or the parameter list of a method call
+func (r *TcgResult) Results() *TcgValue {
+	for i := len(r.Values) - 1; i >= 0; i-- {
+		if r.Values[i].IsList() {
+			return r.Values[i]
+		}
+	}
+	return nil
+}
+
+// Named looks up a named value in the results
+func (r *TcgResult) Named(name valueName) *TcgValue {
+	return r.Results().Named(name)
+}
+
+// MethodStatus returns the status code of the status list
+func (r *TcgResult) MethodStatus() (MethodStatus, error) {
+	if r.Status == nil {
+		return 0, ErrIllegalResponse
+	}
+	status, err := r.Status.Item(0).Uint()
+	if err != nil {
+		return 0, err
+	}
+	return MethodStatus(status), nil
+}
@@ -16,6 +16,11 @@ type TcgSession struct {
 	hostSessionNum, tperSessionNum uint64
 
 	noHashPassword, autoClose bool
+	// passwordHasher overrides the hasher of the drive when set
+	passwordHasher PasswordHasher
+
+	// inTransaction is set between StartTransaction and Commit or Abort
+	inTransaction bool
 
 	timeout uint32
 }
@@ -30,9 +35,14 @@ func NewTcgSession(tcgDevice TcgDevice) *TcgSession {
 
 func (p *TcgSession) Close() {
 	if p.autoClose && p.sessionOpened {
+		// the TPer aborts the transaction with the session, abort it first to be explicit
+		if p.inTransaction {
+			p.Abort()
+		}
 		p.sessionOpened = false
 
-		cmd := &TcgCommand{}
+		cmd := NewTcgCommand()
+		cmd.Reset()
 		cmd.AddToken(ENDOFSESSION)
 		cmd.Complete(false)
 
@@ -48,6 +58,26 @@ func (p *TcgSession) SetNoHashPassword(noHash bool) {
 	p.noHashPassword = noHash
 }
 
+// GetPasswordHasher returns the hasher of the host challenges, RawPasswordHasher when the password is not hashed
+func (p *TcgSession) GetPasswordHasher() PasswordHasher {
+	if p.noHashPassword {
+		return RawPasswordHasher
+	}
+	if p.passwordHasher != nil {
+		return p.passwordHasher
+	}
+	return p.tcgDevice.GetPasswordHasher()
+}
+
+// SetPasswordHasher sets the hasher of the host challenges of the session, nil uses the hasher of the drive
+func (p *TcgSession) SetPasswordHasher(hasher PasswordHasher) {
+	p.passwordHasher = hasher
+}
+
+func (p *TcgSession) hashPassword(password string) []byte {
+	return p.GetPasswordHasher().HashPassword(p.tcgDevice.GetSerial(), password)
+}
+
 func (p *TcgSession) NoAutoClose() {
 	p.autoClose = false
 }
@@ -82,12 +112,7 @@ func (p *TcgSession) Start(sp OpalUID, hostChallenge string, signAuthority signA
 	if hostChallenge != "" && !isEnterprise {
 		cmd.AddToken(STARTNAME)
 		cmd.AddToken(UINT_00)
-		if !p.noHashPassword {
-			hashed := TcgHashPassword(p.tcgDevice, false, hostChallenge)
-			cmd.AddStringToken(string(hashed))
-		} else {
-			cmd.AddStringToken(hostChallenge)
-		}
+		cmd.AddRawToken(pinToken(p.hashPassword(hostChallenge)))
 		cmd.AddToken(ENDNAME)
 
 		cmd.AddToken(STARTNAME)
@@ -108,30 +133,38 @@ func (p *TcgSession) Start(sp OpalUID, hostChallenge string, signAuthority signA
 	cmd.Complete()
 
 	resp, err := p.SendCommand(cmd)
-	if err != nil {
-		return err
+	if err != nil && isSessionBusy(err) && p.tcgDevice.IsAutoStackReset() {
+		if resetErr := p.tcgDevice.StackReset(); resetErr != nil {
+			return fmt.Errorf("%w, stack reset: %v", err, resetErr)
+		}
+		resp, err = p.SendCommand(cmd)
 	}
-
-	hsnToken := resp.GetToken(4)
-	tsnToken := resp.GetToken(5)
-	if hsnToken == nil || tsnToken == nil {
-		return ErrIllegalResponse
+	if err != nil {
+		return checkSIDBlocked(p.tcgDevice, buf, err)
 	}
 
-	temp, err := hsnToken.GetUint32()
+	// SyncSession carries the host session number followed by the TPer session number
+	syncSession := resp.Result().Results()
+	temp, err := syncSession.Item(0).Uint()
 	if err != nil {
 		return err
 	}
-	p.hostSessionNum = uint64(binary.BigEndian.Uint32(unsafe.Slice((*byte)(unsafe.Pointer(&temp)), 4)))
+	hsn := uint32(temp)
+	p.hostSessionNum = uint64(binary.BigEndian.Uint32(unsafe.Slice((*byte)(unsafe.Pointer(&hsn)), 4)))
 
-	temp, err = tsnToken.GetUint32()
+	temp, err = syncSession.Item(1).Uint()
 	if err != nil {
 		return err
 	}
-	p.tperSessionNum = uint64(binary.BigEndian.Uint32(unsafe.Slice((*byte)(unsafe.Pointer(&temp)), 4)))
+	tsn := uint32(temp)
+	p.tperSessionNum = uint64(binary.BigEndian.Uint32(unsafe.Slice((*byte)(unsafe.Pointer(&tsn)), 4)))
+	p.sessionOpened = true
 
 	if hostChallenge != "" && isEnterprise {
-		return p.Authenticate(buf, hostChallenge)
+		if err := p.Authenticate(buf, hostChallenge); err != nil {
+			p.Close()
+			return err
+		}
 	}
 
 	return nil
@@ -154,12 +187,7 @@ func (p *TcgSession) Authenticate(authority []uint8, challenge string) error {
 		} else {
 			cmd.AddToken(UINT_00)
 		}
-		if !p.noHashPassword {
-			hashed := TcgHashPassword(p.tcgDevice, false, challenge)
-			cmd.AddStringToken(string(hashed), len(hashed))
-		} else {
-			cmd.AddStringToken(challenge, len(challenge))
-		}
+		cmd.AddRawToken(pinToken(p.hashPassword(challenge)))
 		cmd.AddToken(ENDNAME)
 	}
 	cmd.AddToken(ENDLIST)
@@ -170,69 +198,79 @@ func (p *TcgSession) Authenticate(authority []uint8, challenge string) error {
 		return err
 	}
 
-	tempToken := resp.GetToken(1)
-	if tempToken == nil {
-		return ErrIllegalResponse
-	}
-
-	temp, err := tempToken.GetUint8()
+	// the method result is True when the authority was authenticated
+	authenticated, err := resp.Result().Results().Item(0).Bool()
 	if err != nil {
 		return err
 	}
-
-	if temp != 0 {
-		return fmt.Errorf("authentication failed: %d", temp)
+	if !authenticated {
+		return p.methodError(cmd, NOT_AUTHORIZED)
 	}
 
 	return nil
 }
 
-func (p *TcgSession) SendCommand(cmd *TcgCommand) (*TcgResponse, error) {
-	cmd.SetHSN(uint32(p.hostSessionNum))
-	cmd.SetTSN(uint32(p.tperSessionNum))
-	cmd.SetComId(p.tcgDevice.GetBaseComId())
+// GetSessionNumbers returns the host and TPer session numbers of the session, zero before Start
+func (p *TcgSession) GetSessionNumbers() (hostSessionNum uint32, tperSessionNum uint32) {
+	hsn := uint32(p.hostSessionNum)
+	tsn := uint32(p.tperSessionNum)
+	return binary.BigEndian.Uint32(unsafe.Slice((*byte)(unsafe.Pointer(&hsn)), 4)),
+		binary.BigEndian.Uint32(unsafe.Slice((*byte)(unsafe.Pointer(&tsn)), 4))
+}
 
-	resp, err := p.tcgDevice.Exec(cmd, 0x01)
-	if err != nil {
-		return nil, err
+// methodError returns the error of a method call of the session that failed with status
+func (p *TcgSession) methodError(cmd *TcgCommand, status MethodStatus) *TcgError {
+	hsn, tsn := p.GetSessionNumbers()
+	return &TcgError{
+		Status:         status,
+		Invoking:       cmd.invoking,
+		Method:         cmd.method,
+		HostSessionNum: hsn,
+		TPerSessionNum: tsn,
 	}
+}
 
-	respHeader := (*OpalHeader)(unsafe.Pointer(resp.GetRespBuf()))
-	if respHeader.Cp.Length == 0 || respHeader.Pkt.Length == 0 || respHeader.Subpkt.Length == 0 {
-		// payload is not received
-		return resp, ErrIllegalResponse
+func (p *TcgSession) SendCommand(cmd *TcgCommand) (*TcgResponse, error) {
+	resp, err := p.exec(cmd)
+	if err != nil {
+		return resp, err
 	}
 
-	tempToken := resp.GetToken(0)
-	if tempToken == nil {
-		return resp, ErrIllegalResponse
-	}
-	if tempToken.Type() == ENDOFSESSION {
+	result := resp.Result()
+	if result.IsEndOfSession() {
 		return resp, nil
 	}
 
-	tokenA := resp.GetToken(resp.GetTokenCount() - 1)
-	tokenB := resp.GetToken(resp.GetTokenCount() - 5)
-	if tokenA == nil || tokenB == nil {
-		return resp, ErrIllegalResponse
+	methodStatus, err := result.MethodStatus()
+	if err != nil {
+		return resp, err
 	}
 
-	if tokenA.Type() != ENDLIST || tokenB.Type() != STARTLIST {
-		// no method status
-		return resp, ErrIllegalResponse
+	if methodStatus != SUCCESS {
+		return resp, p.methodError(cmd, methodStatus)
 	}
 
-	tempToken = resp.GetToken(resp.GetTokenCount() - 4)
-	methodStatus, err := tempToken.GetUint8()
+	return resp, nil
+}
+
+// exec sends a ComPacket of the session and receives the response with a payload
+func (p *TcgSession) exec(cmd *TcgCommand) (*TcgResponse, error) {
+	cmd.SetHSN(uint32(p.hostSessionNum))
+	cmd.SetTSN(uint32(p.tperSessionNum))
+	cmd.SetComId(p.tcgDevice.GetComId())
+
+	resp, err := p.tcgDevice.Exec(cmd, 0x01)
 	if err != nil {
-		return resp, err
+		return nil, err
 	}
 
-	if methodStatus != uint8(SUCCESS) {
-		return resp, &TcgError{
-			Status: MethodStatus(methodStatus),
-		}
+	respHeader := (*OpalHeader)(unsafe.Pointer(resp.GetRespBuf()))
+	if respHeader.Cp.Length == 0 || respHeader.Pkt.Length == 0 || respHeader.Subpkt.Length == 0 {
+		// payload is not received
+		return resp, ErrIllegalResponse
+	}
+	if len(resp.Result().Values) == 0 {
+		return resp, ErrIllegalResponse
 	}
-
 	return resp, nil
 }
@@ -0,0 +1,116 @@
+package tcg
+
+import (
+	"errors"
+)
+
+var (
+	ErrSingleUserModeNotSupported = errors.New("the drive does not support Single User Mode")
+)
+
+// SingleUserMode selects the ranges of the Locking SP in Single User Mode, the SingleUserSelectionList and the
+// RangeStartRangeLengthPolicy of Activate and Reactivate. A range in Single User Mode is owned by a single user,
+// the Admins can no longer lock or unlock it.
+type SingleUserMode struct {
+	// All puts every range in Single User Mode
+	All bool
+	// Ranges are the ranges put in Single User Mode when All is false, 0 is the global range
+	Ranges []uint8
+	// AdminsOwnRangeBounds lets the Admins instead of the range owners set RangeStart and RangeLength
+	AdminsOwnRangeBounds bool
+}
+
+// values returns the Single User Mode parameters of Activate and Reactivate
+func (m *SingleUserMode) values() []*TcgValue {
+	var selection *TcgValue
+	if m.All {
+		// the Locking table selects every range
+		selection = UIDValue(LOCKING_TABLE)
+	} else {
+		var ranges []*TcgValue
+		for _, index := range m.Ranges {
+			ranges = append(ranges, UIDValue(LockingRangeUID(index)))
+		}
+		selection = ListValue(ranges...)
+	}
+
+	var policy uint64
+	if m.AdminsOwnRangeBounds {
+		policy = 1
+	}
+
+	return []*TcgValue{
+		NamedValue(SUM_SELECTION_LIST, selection),
+		NamedValue(SUM_RANGE_START_LENGTH_POLICY, UintValue(policy)),
+	}
+}
+
+// SingleUserUID returns the user owning a range in Single User Mode, User1 owns the global range and UserN+1 range N
+func SingleUserUID(index uint8) OpalUID {
+	return UserUID(index + 1)
+}
+
+// activateLockingSPSingleUser activates the Locking SP as SID with some ranges in Single User Mode,
+// the owners of the ranges are enabled with the SID password
+func activateLockingSPSingleUser(device TcgDevice, feature *SingleUserFeature, sidPassword string, mode SingleUserMode) error {
+	if feature == nil {
+		return ErrSingleUserModeNotSupported
+	}
+
+	session := NewTcgSession(device)
+	if err := session.Start(ADMINSP_UID, sidPassword, SID_UID); err != nil {
+		return err
+	}
+	defer session.Close()
+
+	cmd := NewTcgCommand()
+	cmd.Init(LOCKINGSP_UID, ACTIVATE)
+	cmd.AddValue(ListValue(mode.values()...))
+	cmd.Complete()
+
+	_, err := session.SendCommand(cmd)
+	return err
+}
+
+// reactivateLockingSP changes the ranges in Single User Mode as Admin1, the Locking SP returns to the state after
+// Activate. An empty newAdmin1Password keeps the password of Admin1.
+func reactivateLockingSP(device TcgDevice, feature *SingleUserFeature, password string, mode SingleUserMode, newAdmin1Password string) error {
+	if feature == nil {
+		return ErrSingleUserModeNotSupported
+	}
+
+	session, err := startLockingSession(device, password)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	values := mode.values()
+	if newAdmin1Password != "" {
+		values = append(values, NamedValue(SUM_REACTIVATE_ADMIN1_PIN, BytesValue(hashPassword(device, newAdmin1Password))))
+	}
+
+	cmd := NewTcgCommand()
+	cmd.Init(THISSP_UID, REACTIVATE)
+	cmd.AddValue(ListValue(values...))
+	cmd.Complete()
+
+	_, err = session.SendCommand(cmd)
+	if err == nil {
+		// the TPer ends the session once Reactivate completes
+		session.NoAutoClose()
+	}
+	return err
+}
+
+// setSingleUserPassword changes the password of the owner of a range in Single User Mode as that owner
+func setSingleUserPassword(device TcgDevice, index uint8, password string, newPassword string) error {
+	user := SingleUserUID(index)
+	session, err := startLockingSessionAs(device, user, password)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	return SetCPin(session, CPinUID(user), newPassword)
+}
@@ -0,0 +1,74 @@
+package tcg_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jc-lab/go-dparm/tcg"
+	"github.com/jc-lab/go-dparm/test/tcg_sim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSingleUserModeNotSupported(t *testing.T) {
+	device := testOpalDevice(t, tcg_sim.NewTPer(tcg_sim.DefaultConfig())).(*tcg.TcgDeviceOpal2)
+	assert.Nil(t, device.GetSingleUserFeature())
+	require.NoError(t, device.TakeOwnership("sid"))
+	err := device.ActivateLockingSPSingleUser("sid", tcg.SingleUserMode{All: true})
+	assert.True(t, errors.Is(err, tcg.ErrSingleUserModeNotSupported))
+}
+
+func TestSingleUserMode(t *testing.T) {
+	config := tcg_sim.DefaultConfig()
+	config.SingleUser = true
+	// every range needs an owner, User1 owns the global range
+	config.NumLockingUsers = config.MaxRanges + 1
+	sim := tcg_sim.NewTPer(config)
+	device := testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+	feature := device.GetSingleUserFeature()
+	require.NotNil(t, feature)
+	assert.Equal(t, uint32(0), feature.NumberLockingObjects)
+	assert.False(t, feature.Any)
+
+	require.NoError(t, device.TakeOwnership("sid"))
+	require.NoError(t, device.ActivateLockingSPSingleUser("sid", tcg.SingleUserMode{Ranges: []uint8{1, 2}}))
+
+	// the discovery is read again by a new device
+	feature = testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2).GetSingleUserFeature()
+	assert.Equal(t, uint32(2), feature.NumberLockingObjects)
+	assert.True(t, feature.Any)
+	assert.False(t, feature.All)
+	assert.False(t, feature.Policy)
+
+	assert.Equal(t, tcg.USER2_UID, tcg.SingleUserUID(1))
+	// Admin1 lost the ranges in Single User Mode but keeps the others
+	assert.Error(t, device.SetLockingState("sid", 1, tcg.LOCKED))
+	assert.Error(t, device.SetupLockingRange("sid", 1, 0x1000, 0x800))
+	require.NoError(t, device.SetLockingState("sid", 3, tcg.LOCKED))
+
+	// the owner of range 1 starts with the SID password
+	require.NoError(t, device.SetLockingStateAs(tcg.SingleUserUID(1), "sid", 1, tcg.LOCKED))
+	require.NoError(t, device.SetSingleUserPassword(1, "sid", "tenant1"))
+	lockingRange, err := device.GetLockingRange("sid", 1)
+	require.NoError(t, err)
+	assert.True(t, lockingRange.ReadLocked && lockingRange.WriteLocked)
+	require.NoError(t, device.SetLockingStateAs(tcg.SingleUserUID(1), "tenant1", 1, tcg.READWRITE))
+	assert.Error(t, device.SetLockingStateAs(tcg.SingleUserUID(1), "tenant1", 2, tcg.LOCKED))
+	assert.Error(t, device.SetLockingStateAs(tcg.SingleUserUID(3), "sid", 3, tcg.LOCKED))
+
+	require.NoError(t, device.ReactivateLockingSP("sid", tcg.SingleUserMode{All: true, AdminsOwnRangeBounds: true}, "admin1"))
+	assert.Equal(t, 0, sim.OpenSessions())
+	feature = testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2).GetSingleUserFeature()
+	assert.Equal(t, uint32(config.MaxRanges+1), feature.NumberLockingObjects)
+	assert.True(t, feature.Any)
+	assert.True(t, feature.All)
+	assert.True(t, feature.Policy)
+
+	// the Admins set the bounds, the owners lock the ranges
+	assert.Error(t, device.SetLockingState("sid", 1, tcg.LOCKED))
+	require.NoError(t, device.SetupLockingRange("admin1", 1, 0x1000, 0x800))
+	assert.Error(t, device.SetLockingState("admin1", 3, tcg.LOCKED))
+	require.NoError(t, device.SetLockingStateAs(tcg.SingleUserUID(0), "admin1", 0, tcg.LOCKED))
+	require.NoError(t, device.SetLockingStateAs(tcg.SingleUserUID(3), "admin1", 3, tcg.LOCKED))
+	assert.Equal(t, 0, sim.OpenSessions())
+}
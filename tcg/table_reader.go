@@ -0,0 +1,205 @@
+package tcg
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// TableKind is the Kind column of the Table table
+type TableKind uint64
+
+const (
+	ObjectTable TableKind = 1
+	ByteTable   TableKind = 2
+)
+
+// Row is a row of an object table, Cells are keyed by column number. Enterprise SSC drives name the columns, their
+// cells are in NamedCells.
+type Row struct {
+	UID        OpalUID
+	Cells      map[uint64]*TcgValue
+	NamedCells map[string]*TcgValue
+}
+
+// TableInfo is a row of the Table table describing a table of the SP
+type TableInfo struct {
+	// UID is the UID of the table, not of its row in the Table table
+	UID  OpalUID
+	Name string
+	Kind TableKind
+	// Rows is the number of rows of an object table or the size of a byte table
+	Rows uint64
+}
+
+// TableReader reads the tables of the SP of a session with Next and Get, or EGet on Enterprise SSC drives
+type TableReader struct {
+	session    *TcgSession
+	enterprise bool
+}
+
+func NewTableReader(session *TcgSession) *TableReader {
+	return &TableReader{
+		session:    session,
+		enterprise: session.tcgDevice.GetDeviceType() == OpalEnterpriseDevice,
+	}
+}
+
+// RowUIDs returns the UIDs of every row of an object table
+func (r *TableReader) RowUIDs(table OpalUID) ([]OpalUID, error) {
+	return nextRows(r.session, table)
+}
+
+// ReadRow reads every column of a row the ACL lets the session read
+func (r *TableReader) ReadRow(uid OpalUID) (*Row, error) {
+	cmd := NewTcgCommand()
+	if r.enterprise {
+		cmd.Init(uid, EGET)
+	} else {
+		cmd.Init(uid, GET)
+	}
+	// an empty Cellblock selects every column
+	cmd.AddValue(ListValue(ListValue()))
+	cmd.Complete()
+
+	resp, err := r.session.SendCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	row := &Row{UID: uid, Cells: make(map[uint64]*TcgValue)}
+	for _, cell := range resp.Result().Results().Item(0).Items() {
+		if !cell.IsNamed() {
+			return nil, fmt.Errorf("%w: cell %s", ErrValueType, cell)
+		}
+		if column, err := cell.Name().Uint(); err == nil {
+			row.Cells[column] = cell.Value()
+			continue
+		}
+		name, err := cell.Name().Bytes()
+		if err != nil {
+			return nil, fmt.Errorf("%w: column name %s", ErrValueType, cell.Name())
+		}
+		if row.NamedCells == nil {
+			row.NamedCells = make(map[string]*TcgValue)
+		}
+		row.NamedCells[string(name)] = cell.Value()
+	}
+	return row, nil
+}
+
+// ReadTable reads every row of an object table, the rows the ACL hides from the session are left out
+func (r *TableReader) ReadTable(table OpalUID) ([]Row, error) {
+	uids, err := r.RowUIDs(table)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]Row, 0, len(uids))
+	for _, uid := range uids {
+		row, err := r.ReadRow(uid)
+		if errors.Is(err, ErrNotAuthorized) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %x: %w", uid[:], err)
+		}
+		rows = append(rows, *row)
+	}
+	return rows, nil
+}
+
+// ListSPs reads the SP table of the Admin SP
+func (r *TableReader) ListSPs() ([]Row, error) {
+	return r.ReadTable(SP_TABLE)
+}
+
+// ListColumns reads the Column table
+func (r *TableReader) ListColumns() ([]Row, error) {
+	return r.ReadTable(COLUMN_TABLE)
+}
+
+// ListTables reads the Table table
+func (r *TableReader) ListTables() ([]TableInfo, error) {
+	rows, err := r.ReadTable(TABLE_TABLE)
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make([]TableInfo, 0, len(rows))
+	for _, row := range rows {
+		// the row of a table in the Table table carries the table half UID as its object ID
+		info := TableInfo{}
+		copy(info.UID[:4], row.UID[4:])
+		if name, err := row.Cells[uint64(TABLE_NAME)].Bytes(); err == nil {
+			info.Name = string(name)
+		}
+		kind, err := row.Cells[uint64(TABLE_KIND)].Uint()
+		if err != nil {
+			return nil, fmt.Errorf("table %x kind: %w", info.UID[:], err)
+		}
+		info.Kind = TableKind(kind)
+		if rows, err := row.Cells[uint64(TABLE_ROWS)].Uint(); err == nil {
+			info.Rows = rows
+		}
+		tables = append(tables, info)
+	}
+	return tables, nil
+}
+
+type tableDump struct {
+	UID  string    `json:"uid"`
+	Name string    `json:"name"`
+	Kind TableKind `json:"kind"`
+	Rows uint64    `json:"rows,omitempty"`
+	// Contents are the readable rows of an object table, the contents of byte tables are left out
+	Contents []rowDump `json:"contents,omitempty"`
+}
+
+type rowDump struct {
+	UID   string               `json:"uid"`
+	Cells map[string]*TcgValue `json:"cells"`
+}
+
+// DumpJSON writes every table of the Table table with the rows of the object tables the session can read,
+// the tables whose rows the ACL does not let the session enumerate are listed without contents
+func (r *TableReader) DumpJSON(w io.Writer) error {
+	tables, err := r.ListTables()
+	if err != nil {
+		return err
+	}
+
+	dump := make([]tableDump, 0, len(tables))
+	for _, table := range tables {
+		entry := tableDump{
+			UID:  hex.EncodeToString(table.UID[:]),
+			Name: table.Name,
+			Kind: table.Kind,
+			Rows: table.Rows,
+		}
+		if table.Kind == ObjectTable {
+			rows, err := r.ReadTable(table.UID)
+			if err != nil && !errors.Is(err, ErrNotAuthorized) {
+				return fmt.Errorf("table %s: %w", table.Name, err)
+			}
+			for _, row := range rows {
+				cells := make(map[string]*TcgValue, len(row.Cells)+len(row.NamedCells))
+				for column, value := range row.Cells {
+					cells[strconv.FormatUint(column, 10)] = value
+				}
+				for name, value := range row.NamedCells {
+					cells[name] = value
+				}
+				entry.Contents = append(entry.Contents, rowDump{UID: hex.EncodeToString(row.UID[:]), Cells: cells})
+			}
+		}
+		dump = append(dump, entry)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(dump)
+}
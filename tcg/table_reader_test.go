@@ -0,0 +1,112 @@
+package tcg_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/jc-lab/go-dparm/tcg"
+	"github.com/jc-lab/go-dparm/test/tcg_sim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableReaderAdminSP(t *testing.T) {
+	sim := tcg_sim.NewTPer(tcg_sim.DefaultConfig())
+	device := testOpalDevice(t, sim)
+
+	session := tcg.NewTcgSession(device)
+	require.NoError(t, session.Start(tcg.ADMINSP_UID, "", tcg.UID_HEXFF))
+	defer session.Close()
+	reader := tcg.NewTableReader(session)
+
+	sps, err := reader.ListSPs()
+	require.NoError(t, err)
+	require.Len(t, sps, 2)
+	assert.Equal(t, tcg.ADMINSP_UID, sps[0].UID)
+	name, err := sps[1].Cells[1].Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, "Locking", string(name))
+
+	tables, err := reader.ListTables()
+	require.NoError(t, err)
+	names := make(map[string]tcg.TableInfo)
+	for _, table := range tables {
+		names[table.Name] = table
+	}
+	assert.Equal(t, tcg.TableInfo{UID: tcg.C_PIN_TABLE, Name: "C_PIN", Kind: tcg.ObjectTable}, names["C_PIN"])
+	assert.Contains(t, names, "Column")
+
+	columns, err := reader.ListColumns()
+	require.NoError(t, err)
+	assert.Len(t, columns, 4)
+
+	// Anybody cannot enumerate the Authority table
+	_, err = reader.RowUIDs(tcg.AUTHORITY_TABLE)
+	assert.ErrorIs(t, err, tcg.ErrNotAuthorized)
+}
+
+func TestTableReaderDump(t *testing.T) {
+	config := tcg_sim.DefaultConfig()
+	sim := tcg_sim.NewTPer(config)
+	device := testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+	require.NoError(t, device.TakeOwnership("sid"))
+	require.NoError(t, device.ActivateLockingSP("sid"))
+
+	session := tcg.NewTcgSession(device)
+	require.NoError(t, session.Start(tcg.LOCKINGSP_UID, "sid", tcg.ADMIN1_UID))
+	var out bytes.Buffer
+	require.NoError(t, tcg.NewTableReader(session).DumpJSON(&out))
+	session.Close()
+
+	var dump []struct {
+		UID      string
+		Name     string
+		Kind     tcg.TableKind
+		Rows     uint64
+		Contents []struct {
+			UID   string
+			Cells map[string]json.RawMessage
+		}
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &dump))
+	tables := make(map[string]int)
+	for i, table := range dump {
+		tables[table.Name] = i
+	}
+
+	mbr := dump[tables["MBR"]]
+	assert.Equal(t, tcg.ByteTable, mbr.Kind)
+	assert.Equal(t, uint64(config.MBRSize), mbr.Rows)
+	assert.Empty(t, mbr.Contents)
+
+	// the PINs are not readable
+	cpins := dump[tables["C_PIN"]]
+	assert.Len(t, cpins.Contents, config.NumLockingAdmins+config.NumLockingUsers)
+	for _, row := range cpins.Contents {
+		assert.NotContains(t, row.Cells, "3")
+		assert.Contains(t, row.Cells, "5")
+	}
+
+	locking := dump[tables["Locking"]]
+	require.Len(t, locking.Contents, config.MaxRanges+1)
+	assert.Equal(t, "0000080200000001", locking.Contents[0].UID)
+	assert.Equal(t, "0", string(locking.Contents[0].Cells["7"]))
+	assert.Equal(t, 0, sim.OpenSessions())
+}
+
+func TestTableReaderEnterprise(t *testing.T) {
+	config := tcg_sim.DefaultConfig()
+	config.SSC = tcg_sim.SSCEnterprise
+	device := testEnterpriseDevice(t, tcg_sim.NewTPer(config))
+
+	session := tcg.NewTcgSession(device)
+	require.NoError(t, session.Start(tcg.ENTERPRISE_LOCKINGSP_UID, "", tcg.UID_HEXFF))
+	defer session.Close()
+
+	row, err := tcg.NewTableReader(session).ReadRow(tcg.ENTERPRISE_LOCKING_INFO_TABLE)
+	require.NoError(t, err)
+	maxRanges, err := row.NamedCells["MaxRanges"].Uint()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(config.MaxRanges), maxRanges)
+}
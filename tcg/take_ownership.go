@@ -0,0 +1,44 @@
+package tcg
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrSIDNotMSID is returned by TakeOwnership when the MSID is refused and the drive reports a vendor unique SID password
+	ErrSIDNotMSID = errors.New("the SID password is not the MSID")
+)
+
+// sidNotMSIDError is a refused MSID on a drive with a vendor unique initial SID, it unwraps to the error of the TPer
+type sidNotMSIDError struct {
+	err       error
+	indicator uint8
+}
+
+func (e *sidNotMSIDError) Error() string {
+	return fmt.Sprintf("%s: initial indicator 0x%02x: %s", ErrSIDNotMSID, e.indicator, e.err)
+}
+
+func (e *sidNotMSIDError) Is(target error) bool {
+	return target == ErrSIDNotMSID
+}
+
+func (e *sidNotMSIDError) Unwrap() error {
+	return e.err
+}
+
+// takeOwnershipSSC runs takeOwnership and explains a refused MSID with the initial C_PIN_SID indicator of the
+// feature. The Block SID feature, when the drive has it, tells whether the SID still holds its initial value.
+func takeOwnershipSSC(device TcgDevice, feature *SSCFeature, newPassword string) error {
+	err := takeOwnership(device, newPassword)
+
+	var tcgErr *TcgError
+	if feature == nil || feature.InitialCPinSIDIndicator == 0 || !errors.As(err, &tcgErr) || tcgErr.Status != NOT_AUTHORIZED {
+		return err
+	}
+	if blockSID := device.GetBlockSIDFeature(); blockSID != nil && !blockSID.SIDValueState {
+		return err
+	}
+	return &sidNotMSIDError{err: err, indicator: feature.InitialCPinSIDIndicator}
+}
@@ -0,0 +1,53 @@
+package tcg_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jc-lab/go-dparm/tcg"
+	"github.com/jc-lab/go-dparm/test/tcg_sim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTakeOwnershipVendorSID(t *testing.T) {
+	for _, ssc := range []tcg_sim.SSC{tcg_sim.SSCOpal2, tcg_sim.SSCPyrite2, tcg_sim.SSCRuby} {
+		config := tcg_sim.DefaultConfig()
+		config.SSC = ssc
+		config.SID = "VENDORSID"
+		sim := tcg_sim.NewTPer(config)
+
+		device, err := tcg.NewTcgDevice(sim)
+		require.NoError(t, err)
+		err = device.TakeOwnership("owner")
+		assert.True(t, errors.Is(err, tcg.ErrSIDNotMSID), "SSC %d: %v", ssc, err)
+		assert.True(t, errors.Is(err, tcg.ErrNotAuthorized), "SSC %d: %v", ssc, err)
+		var tcgErr *tcg.TcgError
+		assert.True(t, errors.As(err, &tcgErr), "SSC %d: %v", ssc, err)
+		assert.Equal(t, 0, sim.OpenSessions())
+	}
+}
+
+func TestTakeOwnershipOwnedDrive(t *testing.T) {
+	// the revert behavior does not tell the current SID, a drive owned by someone else only refuses the MSID
+	config := tcg_sim.DefaultConfig()
+	config.VendorRevertSID = true
+	sim := tcg_sim.NewTPer(config)
+	device, err := tcg.NewTcgDevice(sim)
+	require.NoError(t, err)
+	require.NoError(t, device.TakeOwnership("other"))
+	err = device.TakeOwnership("owner")
+	assert.True(t, errors.Is(err, tcg.ErrNotAuthorized), "%v", err)
+	assert.False(t, errors.Is(err, tcg.ErrSIDNotMSID), "%v", err)
+
+	// the Block SID feature reports a vendor SID that still holds its initial value
+	config = tcg_sim.DefaultConfig()
+	config.SID = "VENDORSID"
+	config.BlockSID = true
+	sim = tcg_sim.NewTPer(config)
+	device, err = tcg.NewTcgDevice(sim)
+	require.NoError(t, err)
+	err = device.TakeOwnership("owner")
+	assert.True(t, errors.Is(err, tcg.ErrSIDNotMSID), "%v", err)
+	assert.Equal(t, 0, sim.OpenSessions())
+}
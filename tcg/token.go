@@ -16,8 +16,8 @@ func (p *TcgTokenVO) Type() OpalToken {
 	typeVal := p.buf[0]
 	switch {
 	case typeVal & 0x80 == 0:
-		// tiny atom
-		if typeVal & 0x40 == 0 {
+		// tiny atom, bit 6 is the sign bit
+		if typeVal & 0x40 != 0 {
 			return DTA_TOKENID_SINT
 		} else {
 			return DTA_TOKENID_UINT
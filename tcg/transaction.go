@@ -0,0 +1,110 @@
+package tcg
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrTransactionsNotSupported = errors.New("the TPer does not support transactions")
+	ErrTransactionActive        = errors.New("a transaction is already active on the session")
+	ErrNoTransaction            = errors.New("no transaction is active on the session")
+)
+
+// Status codes of the StartTransaction and EndTransaction tokens, TCG Storage Architecture Core Specification 3.2.4.4
+const (
+	transactionOk    uint64 = 0x00
+	transactionAbort uint64 = 0x01
+)
+
+// maxTransactionLimitProperty is the TPer property with the number of transactions a session may nest
+const maxTransactionLimitProperty = "MaxTransactionLimit"
+
+// InTransaction returns true between StartTransaction and Commit or Abort
+func (p *TcgSession) InTransaction() bool {
+	return p.inTransaction
+}
+
+// StartTransaction starts a transaction, the methods of the session take effect together at Commit or not at all.
+// The TPer aborts the transaction when the session ends before Commit.
+func (p *TcgSession) StartTransaction() error {
+	if p.inTransaction {
+		return ErrTransactionActive
+	}
+	if limit, ok := p.tcgDevice.GetProperties().TPer[maxTransactionLimitProperty]; ok && limit == 0 {
+		return ErrTransactionsNotSupported
+	}
+
+	if err := p.sendTransactionToken(STARTTRANSACTON, transactionOk); err != nil {
+		return err
+	}
+	p.inTransaction = true
+	return nil
+}
+
+// Commit ends the transaction and applies its methods, an error means the TPer aborted the transaction
+func (p *TcgSession) Commit() error {
+	if !p.inTransaction {
+		return ErrNoTransaction
+	}
+	p.inTransaction = false
+	return p.sendTransactionToken(ENDTRANSACTON, transactionOk)
+}
+
+// Abort ends the transaction and discards its methods
+func (p *TcgSession) Abort() error {
+	if !p.inTransaction {
+		return ErrNoTransaction
+	}
+	p.inTransaction = false
+	err := p.sendTransactionToken(ENDTRANSACTON, transactionAbort)
+	// the TPer reports the aborted transaction with a non-zero status
+	if errors.Is(err, ErrTransactionFailure) {
+		return nil
+	}
+	return err
+}
+
+// Transaction runs fn in a transaction, it commits when fn succeeds and aborts when fn fails so that a multi-step
+// setup is applied completely or not at all. SetupLockingRangeIn, ConfigureLockingRangeIn, SetAuthorityEnabledIn,
+// SetLockingRangeAccessIn and SetCPin are the provisioning steps of the Locking SP that run in the session.
+func (p *TcgSession) Transaction(fn func() error) error {
+	if err := p.StartTransaction(); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		if abortErr := p.Abort(); abortErr != nil {
+			return fmt.Errorf("%w, abort: %v", err, abortErr)
+		}
+		return err
+	}
+	return p.Commit()
+}
+
+// sendTransactionToken sends a transaction control token with its status code, the TPer answers with the same token
+// and the status of the transaction
+func (p *TcgSession) sendTransactionToken(token OpalToken, status uint64) error {
+	cmd := NewTcgCommand()
+	cmd.Reset()
+	cmd.AddToken(token)
+	cmd.AddNumberToken(status)
+	cmd.Complete(false)
+
+	resp, err := p.exec(cmd)
+	if err != nil {
+		return err
+	}
+
+	values := resp.Result().Values
+	if len(values) < 2 || !values[0].IsToken(token) {
+		return fmt.Errorf("%w: no transaction token in the response", ErrIllegalResponse)
+	}
+	result, err := values[1].Uint()
+	if err != nil {
+		return err
+	}
+	if result != transactionOk {
+		return p.methodError(cmd, TRANSACTION_FAILURE)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package tcg_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jc-lab/go-dparm/tcg"
+	"github.com/jc-lab/go-dparm/test/tcg_sim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransaction(t *testing.T) {
+	sim := tcg_sim.NewTPer(tcg_sim.DefaultConfig())
+	device := testOpalDevice(t, sim)
+	require.NoError(t, device.TakeOwnership("sid"))
+	require.NoError(t, device.ActivateLockingSP("sid"))
+	user1Pin := sim.PIN(tcg.CPinUID(tcg.UserUID(1)))
+	pin := func(password string) []byte {
+		return device.GetPasswordHasher().HashPassword(device.GetSerial(), password)
+	}
+
+	session := tcg.NewTcgSession(device)
+	require.NoError(t, session.Start(tcg.LOCKINGSP_UID, "sid", tcg.ADMIN1_UID))
+	assert.True(t, errors.Is(session.Commit(), tcg.ErrNoTransaction))
+
+	// a failing step aborts the steps before it
+	err := session.Transaction(func() error {
+		require.NoError(t, tcg.SetCPin(session, tcg.CPinUID(tcg.UserUID(1)), "user1"))
+		assert.True(t, errors.Is(session.StartTransaction(), tcg.ErrTransactionActive))
+		return tcg.SetCPin(session, tcg.CPinUID(tcg.UserUID(100)), "user100")
+	})
+	assert.True(t, errors.Is(err, tcg.ErrInvalidParameter))
+	assert.False(t, session.InTransaction())
+	assert.Equal(t, user1Pin, sim.PIN(tcg.CPinUID(tcg.UserUID(1))))
+
+	err = session.Transaction(func() error {
+		if err := tcg.SetCPin(session, tcg.CPinUID(tcg.UserUID(1)), "user1"); err != nil {
+			return err
+		}
+		return tcg.SetCPin(session, tcg.CPinUID(tcg.UserUID(2)), "user2")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, pin("user1"), sim.PIN(tcg.CPinUID(tcg.UserUID(1))))
+	assert.Equal(t, pin("user2"), sim.PIN(tcg.CPinUID(tcg.UserUID(2))))
+
+	// the end of the session aborts the transaction
+	require.NoError(t, session.StartTransaction())
+	require.NoError(t, tcg.SetCPin(session, tcg.CPinUID(tcg.UserUID(1)), "other"))
+	require.NoError(t, session.Abort())
+	require.NoError(t, session.StartTransaction())
+	require.NoError(t, tcg.SetCPin(session, tcg.CPinUID(tcg.UserUID(2)), "other"))
+	session.Close()
+	assert.Equal(t, pin("user1"), sim.PIN(tcg.CPinUID(tcg.UserUID(1))))
+	assert.Equal(t, pin("user2"), sim.PIN(tcg.CPinUID(tcg.UserUID(2))))
+	assert.Equal(t, 0, sim.OpenSessions())
+}
+
+func TestTransactionProvisioning(t *testing.T) {
+	sim := tcg_sim.NewTPer(tcg_sim.DefaultConfig())
+	device := testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+	require.NoError(t, device.TakeOwnership("sid"))
+	require.NoError(t, device.ActivateLockingSP("sid"))
+
+	type state struct {
+		lockingRange *tcg.LockingRange
+		rdLocked     *tcg.ACE
+		wrLocked     *tcg.ACE
+		user1        tcg.Authority
+		user1Pin     []byte
+	}
+	readState := func() state {
+		var s state
+		var err error
+		s.lockingRange, err = device.GetLockingRange("sid", 1)
+		require.NoError(t, err)
+		authorities, err := device.GetAuthorities("sid")
+		require.NoError(t, err)
+		for _, authority := range authorities {
+			if authority.UID == tcg.USER1_UID {
+				s.user1 = authority
+			}
+		}
+		session := tcg.NewTcgSession(device)
+		require.NoError(t, session.Start(tcg.LOCKINGSP_UID, "sid", tcg.ADMIN1_UID))
+		defer session.Close()
+		s.rdLocked, err = tcg.ReadACE(session, tcg.LockingRangeRdLockedACE(1))
+		require.NoError(t, err)
+		s.wrLocked, err = tcg.ReadACE(session, tcg.LockingRangeWrLockedACE(1))
+		require.NoError(t, err)
+		s.user1Pin = sim.PIN(tcg.CPinUID(tcg.USER1_UID))
+		return s
+	}
+	provision := func(session *tcg.TcgSession) error {
+		if err := tcg.SetupLockingRangeIn(session, 1, 0x1000, 0x800); err != nil {
+			return err
+		}
+		if err := tcg.ConfigureLockingRangeIn(session, 1, true, true, true); err != nil {
+			return err
+		}
+		if err := tcg.SetAuthorityEnabledIn(session, tcg.USER1_UID, true); err != nil {
+			return err
+		}
+		if err := tcg.SetCPin(session, tcg.CPinUID(tcg.USER1_UID), "user1"); err != nil {
+			return err
+		}
+		return tcg.SetLockingRangeAccessIn(session, tcg.USER1_UID, 1, true)
+	}
+
+	// a failure after every step but the last leaves the drive as it was
+	before := readState()
+	session := tcg.NewTcgSession(device)
+	require.NoError(t, session.Start(tcg.LOCKINGSP_UID, "sid", tcg.ADMIN1_UID))
+	err := session.Transaction(func() error {
+		if err := provision(session); err != nil {
+			return err
+		}
+		return tcg.SetCPin(session, tcg.CPinUID(tcg.UserUID(100)), "user100")
+	})
+	assert.True(t, errors.Is(err, tcg.ErrInvalidParameter), "%v", err)
+	session.Close()
+	assert.Equal(t, before, readState())
+	assert.Error(t, device.SetLockingStateAs(tcg.USER1_UID, "user1", 1, tcg.LOCKED))
+
+	session = tcg.NewTcgSession(device)
+	require.NoError(t, session.Start(tcg.LOCKINGSP_UID, "sid", tcg.ADMIN1_UID))
+	require.NoError(t, session.Transaction(func() error {
+		return provision(session)
+	}))
+	session.Close()
+	after := readState()
+	assert.Equal(t, uint64(0x1000), after.lockingRange.RangeStart)
+	assert.Equal(t, uint64(0x800), after.lockingRange.RangeLength)
+	assert.True(t, after.lockingRange.ReadLockEnabled && after.lockingRange.WriteLockEnabled)
+	assert.True(t, after.user1.Enabled)
+	assert.Contains(t, after.rdLocked.Authorities, tcg.USER1_UID)
+	assert.Contains(t, after.wrLocked.Authorities, tcg.USER1_UID)
+	require.NoError(t, device.SetLockingStateAs(tcg.USER1_UID, "user1", 1, tcg.LOCKED))
+	assert.Equal(t, 0, sim.OpenSessions())
+}
@@ -0,0 +1,116 @@
+package tcg
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Authorities the Opal SSC requires of the Locking SP, used when the SSC feature does not report them
+const (
+	minLockingAdmins = 4
+	minLockingUsers  = 8
+	// maxAuthorityIndex is the last Admin and User AdminUID and UserUID can number
+	maxAuthorityIndex = math.MaxUint8
+)
+
+// TryLimitStatus is the failed authentication counter of the C_PIN of an authority
+type TryLimitStatus struct {
+	Authority OpalUID
+	// TryLimit is the number of failed authentications that locks the authority out, zero is unlimited
+	TryLimit uint64
+	// Tries is the number of failed authentications since the last successful one
+	Tries uint64
+	// Persistence keeps Tries across power cycles
+	Persistence bool
+}
+
+// IsLockedOut returns true when the authority can no longer authenticate until Tries is reset
+func (s *TryLimitStatus) IsLockedOut() bool {
+	return s.TryLimit != 0 && s.Tries >= s.TryLimit
+}
+
+func readTryLimitStatus(session *TcgSession, authority OpalUID, cpin OpalUID) (*TryLimitStatus, error) {
+	table := append([]uint8{uint8(BYTESTRING8)}, cpin[:]...)
+	resp, err := opalGetTable(session, table, uint16(CREDENTIAL_TRY_LIMIT), uint16(CREDENTIAL_PERSISTENCE))
+	if err != nil {
+		return nil, err
+	}
+	result := resp.Result()
+
+	status := &TryLimitStatus{Authority: authority}
+	if status.TryLimit, err = result.Named(CREDENTIAL_TRY_LIMIT).Uint(); err != nil {
+		return nil, err
+	}
+	if status.Tries, err = result.Named(CREDENTIAL_TRIES).Uint(); err != nil {
+		return nil, err
+	}
+	if status.Persistence, err = result.Named(CREDENTIAL_PERSISTENCE).Bool(); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// readTryLimits reads the counters of authorities, the ones the ACL hides from the session are left out
+func readTryLimits(session *TcgSession, authorities []OpalUID, cpinOf func(OpalUID) OpalUID) ([]TryLimitStatus, error) {
+	var statuses []TryLimitStatus
+	for _, authority := range authorities {
+		status, err := readTryLimitStatus(session, authority, cpinOf(authority))
+		if errors.Is(err, ErrNotAuthorized) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("authority %x: %w", authority[:], err)
+		}
+		statuses = append(statuses, *status)
+	}
+	return statuses, nil
+}
+
+// getTryLimits reads the counters of SID as SID and of the Admins and Users of the Locking SP as Admin1, an empty
+// password reads them as Anybody. The authentication resets the counter of SID and Admin1, only Anybody sees their
+// failed tries. The Locking SP is read when the drive reports locking enabled, numAdmins and numUsers of zero fall
+// back to the authorities every Opal drive has and counts above maxAuthorityIndex are cut to it.
+func getTryLimits(device TcgDevice, numAdmins uint16, numUsers uint16, sidPassword string, admin1Password string) ([]TryLimitStatus, error) {
+	session := NewTcgSession(device)
+	if err := session.Start(ADMINSP_UID, sidPassword, SID_UID); err != nil {
+		return nil, err
+	}
+	statuses, err := readTryLimits(session, []OpalUID{SID_UID}, func(OpalUID) OpalUID { return C_PIN_SID })
+	session.Close()
+	if err != nil || !device.IsLockingEnabled() {
+		return statuses, err
+	}
+
+	if numAdmins == 0 {
+		numAdmins = minLockingAdmins
+	}
+	if numUsers == 0 {
+		numUsers = minLockingUsers
+	}
+	if numAdmins > maxAuthorityIndex {
+		numAdmins = maxAuthorityIndex
+	}
+	if numUsers > maxAuthorityIndex {
+		numUsers = maxAuthorityIndex
+	}
+	var authorities []OpalUID
+	for i := uint16(1); i <= numAdmins; i++ {
+		authorities = append(authorities, AdminUID(uint8(i)))
+	}
+	for i := uint16(1); i <= numUsers; i++ {
+		authorities = append(authorities, UserUID(uint8(i)))
+	}
+
+	session, err = startLockingSession(device, admin1Password)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	locking, err := readTryLimits(session, authorities, CPinUID)
+	if err != nil {
+		return nil, err
+	}
+	return append(statuses, locking...), nil
+}
@@ -0,0 +1,99 @@
+package tcg_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jc-lab/go-dparm/tcg"
+	"github.com/jc-lab/go-dparm/test/tcg_sim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func findTryLimit(statuses []tcg.TryLimitStatus, authority tcg.OpalUID) *tcg.TryLimitStatus {
+	for i := range statuses {
+		if statuses[i].Authority == authority {
+			return &statuses[i]
+		}
+	}
+	return nil
+}
+
+func TestTryLimits(t *testing.T) {
+	config := tcg_sim.DefaultConfig()
+	config.TryLimit = 3
+	sim := tcg_sim.NewTPer(config)
+	device := testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+	require.NoError(t, device.TakeOwnership("sid"))
+	require.NoError(t, device.ActivateLockingSP("sid"))
+	require.NoError(t, device.SetUserEnabled("sid", 1, true))
+	require.NoError(t, device.SetUserPassword("sid", 1, "user1"))
+
+	for i := 0; i < 3; i++ {
+		err := device.SetLockingStateAs(tcg.UserUID(1), "wrong", 0, tcg.LOCKED)
+		assert.True(t, errors.Is(err, tcg.ErrNotAuthorized))
+	}
+	err := device.SetLockingStateAs(tcg.UserUID(1), "user1", 0, tcg.LOCKED)
+	assert.True(t, errors.Is(err, tcg.ErrAuthorityLockedOut))
+
+	// the discovery of a new device reports the Locking SP enabled
+	device = testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+	statuses, err := device.GetTryLimits("sid", "sid")
+	require.NoError(t, err)
+	assert.Len(t, statuses, 1+config.NumLockingAdmins+config.NumLockingUsers)
+	assert.Equal(t, tcg.TryLimitStatus{Authority: tcg.SID_UID, TryLimit: 3}, *findTryLimit(statuses, tcg.SID_UID))
+	user1 := findTryLimit(statuses, tcg.UserUID(1))
+	require.NotNil(t, user1)
+	assert.Equal(t, uint64(3), user1.Tries)
+	assert.True(t, user1.IsLockedOut())
+	assert.False(t, findTryLimit(statuses, tcg.UserUID(2)).IsLockedOut())
+
+	// the ACL of the simulator hides the C_PINs from Anybody
+	statuses, err = device.GetTryLimits("", "")
+	require.NoError(t, err)
+	assert.Empty(t, statuses)
+	assert.Equal(t, 0, sim.OpenSessions())
+}
+
+func TestPublicTryLimits(t *testing.T) {
+	config := tcg_sim.DefaultConfig()
+	config.TryLimit = 5
+	config.PublicTryLimits = true
+	sim := tcg_sim.NewTPer(config)
+	device := testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+	require.NoError(t, device.TakeOwnership("sid"))
+
+	// Anybody sees the failed tries an authentication as SID would reset
+	assert.Error(t, device.RevertTPer("wrong", false, true))
+	assert.Error(t, device.RevertTPer("wrong", false, true))
+	statuses, err := device.GetTryLimits("", "")
+	require.NoError(t, err)
+	assert.Equal(t, []tcg.TryLimitStatus{{Authority: tcg.SID_UID, TryLimit: 5, Tries: 2}}, statuses)
+
+	require.NoError(t, device.ActivateLockingSP("sid"))
+	_, err = device.GetLockingRange("wrong", 0)
+	assert.Error(t, err)
+	device = testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+	statuses, err = device.GetTryLimits("", "")
+	require.NoError(t, err)
+	assert.Len(t, statuses, 1+config.NumLockingAdmins+config.NumLockingUsers)
+	assert.Equal(t, uint64(1), findTryLimit(statuses, tcg.ADMIN1_UID).Tries)
+	assert.Equal(t, 0, sim.OpenSessions())
+}
+
+func TestTryLimitsManyUsers(t *testing.T) {
+	config := tcg_sim.DefaultConfig()
+	config.NumLockingUsers = 300
+	sim := tcg_sim.NewTPer(config)
+	device := testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+	require.NoError(t, device.TakeOwnership("sid"))
+	require.NoError(t, device.ActivateLockingSP("sid"))
+
+	// the users above 255 can not be numbered by UserUID, they are not read instead of wrapping to User0
+	device = testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+	statuses, err := device.GetTryLimits("sid", "sid")
+	require.NoError(t, err)
+	assert.Len(t, statuses, 1+config.NumLockingAdmins+255)
+	assert.NotNil(t, findTryLimit(statuses, tcg.UserUID(255)))
+	assert.Nil(t, findTryLimit(statuses, tcg.UserUID(0)))
+}
@@ -14,14 +14,22 @@ const (
 type FeatureCode uint16
 
 const (
-	FcTPer              FeatureCode = 0x0001
-	FcLocking           FeatureCode = 0x0002
-	FcGeometryReporting FeatureCode = 0x0003
-	FcEnterprise        FeatureCode = 0x0100
-	FcDataStore         FeatureCode = 0x0202
-	FcSingleUser        FeatureCode = 0x0201
-	FcOpalSscV100       FeatureCode = 0x0200
-	FcOpalSscV200       FeatureCode = 0x0203
+	FcTPer                    FeatureCode = 0x0001
+	FcLocking                 FeatureCode = 0x0002
+	FcGeometryReporting       FeatureCode = 0x0003
+	FcEnterprise              FeatureCode = 0x0100
+	FcDataStore               FeatureCode = 0x0202
+	FcSingleUser              FeatureCode = 0x0201
+	FcOpalSscV100             FeatureCode = 0x0200
+	FcOpalSscV200             FeatureCode = 0x0203
+	FcOpalite                 FeatureCode = 0x0301
+	FcPyriteV100              FeatureCode = 0x0302
+	FcPyriteV200              FeatureCode = 0x0303
+	FcRuby                    FeatureCode = 0x0304
+	FcBlockSID                FeatureCode = 0x0402
+	FcNamespaceLocking        FeatureCode = 0x0403
+	FcDataRemoval             FeatureCode = 0x0404
+	FcShadowMBRMultiNamespace FeatureCode = 0x0407
 )
 
 type VersionField struct {
@@ -331,26 +339,38 @@ var (
 	ADMIN1_UID                 OpalUID = [8]byte{0x00, 0x00, 0x00, 0x09, 0x00, 0x01, 0x00, 0x01}
 	USER1_UID                  OpalUID = [8]byte{0x00, 0x00, 0x00, 0x09, 0x00, 0x03, 0x00, 0x01}
 	USER2_UID                  OpalUID = [8]byte{0x00, 0x00, 0x00, 0x09, 0x00, 0x03, 0x00, 0x02}
+	ADMINS_UID                 OpalUID = [8]byte{0x00, 0x00, 0x00, 0x09, 0x00, 0x00, 0x00, 0x02}
+	USERS_UID                  OpalUID = [8]byte{0x00, 0x00, 0x00, 0x09, 0x00, 0x03, 0x00, 0x00}
 	PSID_UID                   OpalUID = [8]byte{0x00, 0x00, 0x00, 0x09, 0x00, 0x01, 0xff, 0x01}
 	ENTERPRISE_BANDMASTER0_UID OpalUID = [8]byte{0x00, 0x00, 0x00, 0x09, 0x00, 0x00, 0x80, 0x01}
 	ENTERPRISE_ERASEMASTER_UID OpalUID = [8]byte{0x00, 0x00, 0x00, 0x09, 0x00, 0x00, 0x84, 0x01}
 
 	/* tables */
+	LOCKING_TABLE                 OpalUID = [8]byte{0x00, 0x00, 0x08, 0x02, 0x00, 0x00, 0x00, 0x00}
 	LOCKINGRANGE_GLOBAL           OpalUID = [8]byte{0x00, 0x00, 0x08, 0x02, 0x00, 0x00, 0x00, 0x01}
 	LOCKINGRANGE_ACE_RDLOCKED     OpalUID = [8]byte{0x00, 0x00, 0x00, 0x08, 0x00, 0x03, 0xE0, 0x01}
 	LOCKINGRANGE_ACE_WRLOCKED     OpalUID = [8]byte{0x00, 0x00, 0x00, 0x08, 0x00, 0x03, 0xE8, 0x01}
 	MBRCONTROL                    OpalUID = [8]byte{0x00, 0x00, 0x08, 0x03, 0x00, 0x00, 0x00, 0x01}
 	MBR                           OpalUID = [8]byte{0x00, 0x00, 0x08, 0x04, 0x00, 0x00, 0x00, 0x00}
+	TABLE_TABLE                   OpalUID = [8]byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}
+	COLUMN_TABLE                  OpalUID = [8]byte{0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00}
+	ACCESS_CONTROL_TABLE          OpalUID = [8]byte{0x00, 0x00, 0x00, 0x07, 0x00, 0x00, 0x00, 0x00}
+	SP_TABLE                      OpalUID = [8]byte{0x00, 0x00, 0x02, 0x05, 0x00, 0x00, 0x00, 0x00}
+	DATASTORE                     OpalUID = [8]byte{0x00, 0x00, 0x10, 0x01, 0x00, 0x00, 0x00, 0x00}
 	AUTHORITY_TABLE               OpalUID = [8]byte{0x00, 0x00, 0x00, 0x09, 0x00, 0x00, 0x00, 0x00}
 	C_PIN_TABLE                   OpalUID = [8]byte{0x00, 0x00, 0x00, 0x0B, 0x00, 0x00, 0x00, 0x00}
 	LOCKING_INFO_TABLE            OpalUID = [8]byte{0x00, 0x00, 0x08, 0x01, 0x00, 0x00, 0x00, 0x01}
 	ENTERPRISE_LOCKING_INFO_TABLE OpalUID = [8]byte{0x00, 0x00, 0x08, 0x01, 0x00, 0x00, 0x00, 0x00}
+	DATA_REMOVAL_MECHANISM        OpalUID = [8]byte{0x00, 0x00, 0x11, 0x01, 0x00, 0x00, 0x00, 0x01}
 
 	/* C_PIN_TABLE object ID's */
 	C_PIN_MSID   OpalUID = [8]byte{0x00, 0x00, 0x00, 0x0B, 0x00, 0x00, 0x84, 0x02}
 	C_PIN_SID    OpalUID = [8]byte{0x00, 0x00, 0x00, 0x0B, 0x00, 0x00, 0x00, 0x01}
 	C_PIN_ADMIN1 OpalUID = [8]byte{0x00, 0x00, 0x00, 0x0B, 0x00, 0x01, 0x00, 0x01}
 
+	C_PIN_ENTERPRISE_BANDMASTER0 OpalUID = [8]byte{0x00, 0x00, 0x00, 0x0B, 0x00, 0x00, 0x80, 0x01}
+	C_PIN_ENTERPRISE_ERASEMASTER OpalUID = [8]byte{0x00, 0x00, 0x00, 0x0B, 0x00, 0x00, 0x84, 0x01}
+
 	/* half UID's (only first 4 bytes used) */
 	HALF_UID_AUTHORITY_OBJ_REF OpalUID = [8]byte{0x00, 0x00, 0x0C, 0x05, 0xff, 0xff, 0xff, 0xff}
 	HALF_UID_BOOLEAN_ACE       OpalUID = [8]byte{0x00, 0x00, 0x04, 0x0E, 0xff, 0xff, 0xff, 0xff}
@@ -375,6 +395,7 @@ var (
 	SET           OpalMethod = [8]byte{0x00, 0x00, 0x00, 0x06, 0x00, 0x00, 0x00, 0x17}
 	AUTHENTICATE  OpalMethod = [8]byte{0x00, 0x00, 0x00, 0x06, 0x00, 0x00, 0x00, 0x1c}
 	RANDOM        OpalMethod = [8]byte{0x00, 0x00, 0x00, 0x06, 0x00, 0x00, 0x06, 0x01}
+	REACTIVATE    OpalMethod = [8]byte{0x00, 0x00, 0x00, 0x06, 0x00, 0x00, 0x08, 0x01}
 	ERASE         OpalMethod = [8]byte{0x00, 0x00, 0x00, 0x06, 0x00, 0x00, 0x08, 0x03}
 )
 
@@ -389,6 +410,14 @@ const (
 	FALSE        OpalToken = 0x00
 	BOOLEAN_EXPR OpalToken = 0x03
 
+	/*
+	 * ACE table
+	 */
+	ACE_UID         OpalToken = 0x00
+	ACE_NAME        OpalToken = 0x01
+	ACE_COMMON_NAME OpalToken = 0x02
+	ACE_COLUMNS     OpalToken = 0x04
+
 	/**
 	 * Cell Blocks
 	 */
@@ -446,10 +475,49 @@ const (
 	LOCKINGINFO_ENCRYPT_SUPPORT OpalToken = 0x03
 	LOCKINGINFO_MAXRANGES       OpalToken = 0x04
 
+	/*
+	 * Table Table
+	 *
+	 * Reference: https://trustedcomputinggroup.org/wp-content/uploads/TCG_Storage_Architecture_Core_Spec_v2.01_r1.00.pdf
+	 * Table Table Description
+	 * */
+	TABLE_UID                            OpalToken = 0x00
+	TABLE_NAME                           OpalToken = 0x01
+	TABLE_COMMON_NAME                    OpalToken = 0x02
+	TABLE_TEMPLATE_ID                    OpalToken = 0x03
+	TABLE_KIND                           OpalToken = 0x04
+	TABLE_COLUMN                         OpalToken = 0x05
+	TABLE_NUM_COLUMNS                    OpalToken = 0x06
+	TABLE_ROWS                           OpalToken = 0x07
+	TABLE_ROWS_FREE                      OpalToken = 0x08
+	TABLE_ROW_BYTES                      OpalToken = 0x09
+	TABLE_LAST_ID                        OpalToken = 0x0A
+	TABLE_MIN_SIZE                       OpalToken = 0x0B
+	TABLE_MAX_SIZE                       OpalToken = 0x0C
+	TABLE_MANDATORY_WRITE_GRANULARITY    OpalToken = 0x0D
+	TABLE_RECOMMENDED_ACCESS_GRANULARITY OpalToken = 0x0E
+
+	/*
+	 * Authority Table
+	 *
+	 * Reference: https://trustedcomputinggroup.org/wp-content/uploads/TCG_Storage_Architecture_Core_Spec_v2.01_r1.00.pdf
+	 * Authority Table Description
+	 * */
+	AUTHORITY_UID         OpalToken = 0x00
+	AUTHORITY_NAME        OpalToken = 0x01
+	AUTHORITY_COMMON_NAME OpalToken = 0x02
+	AUTHORITY_IS_CLASS    OpalToken = 0x03
+	AUTHORITY_CLASS       OpalToken = 0x04
+	AUTHORITY_ENABLED     OpalToken = 0x05
+	AUTHORITY_CREDENTIAL  OpalToken = 0x0A
+
 	/* mbr control */
 	MBRENABLE OpalToken = 0x01
 	MBRDONE   OpalToken = 0x02
 
+	/* data removal mechanism */
+	ACTIVE_DATA_REMOVAL_MECHANISM OpalToken = 0x01
+
 	/* properties */
 	HOSTPROPERTIES OpalToken = 0x00
 
@@ -472,6 +540,19 @@ const (
 	WHERE           OpalToken = 0x00
 )
 
+/*
+ * Single User Mode parameters of Activate and Reactivate, the names do not fit the one byte of an OpalToken
+ *
+ * Reference: https://trustedcomputinggroup.org/wp-content/uploads/TCG_Storage-Opal_Feature_Set_Single_User_Mode_v1-00_r1-00-Final.pdf
+ * */
+const (
+	SUM_SELECTION_LIST             uint64 = 0x060000
+	SUM_RANGE_START_LENGTH_POLICY  uint64 = 0x060001
+	SUM_ACTIVATE_DATASTORE_SIZES   uint64 = 0x060002
+	SUM_REACTIVATE_ADMIN1_PIN      uint64 = 0x060002
+	SUM_REACTIVATE_DATASTORE_SIZES uint64 = 0x060003
+)
+
 type OpalTinyAtom int
 
 const (
@@ -0,0 +1,246 @@
+package tcg
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrBooleanExprNotAnyOf is returned when an authority is added to or removed from an ACE whose BooleanExpr is not
+	// an OR of authorities, rewriting it as one would change who is granted the access
+	ErrBooleanExprNotAnyOf = errors.New("the BooleanExpr of the ACE is not an OR of authorities")
+	// ErrEmptyBooleanExpr is returned when the last authority of an ACE would be removed, the ACE would grant nobody
+	ErrEmptyBooleanExpr = errors.New("the BooleanExpr of the ACE would be empty")
+)
+
+// Authority is a row of the Authority table of the Locking SP
+type Authority struct {
+	UID        OpalUID
+	CommonName string
+	IsClass    bool
+	// Class is the class authority the authority belongs to, Admins or Users
+	Class   OpalUID
+	Enabled bool
+}
+
+// AdminUID returns the AdminN authority of the Locking SP, n starts at 1
+func AdminUID(n uint8) OpalUID {
+	uid := ADMIN1_UID
+	uid[7] = n
+	return uid
+}
+
+// UserUID returns the UserN authority of the Locking SP, n starts at 1
+func UserUID(n uint8) OpalUID {
+	uid := USER1_UID
+	uid[7] = n
+	return uid
+}
+
+// CPinUID returns the C_PIN object holding the password of an Admin or User authority of the Locking SP
+func CPinUID(authority OpalUID) OpalUID {
+	uid := C_PIN_TABLE
+	copy(uid[4:], authority[4:])
+	return uid
+}
+
+// LockingRangeRdLockedACE returns ACE_Locking_RangeN_Set_RdLocked, the ACE granting Set on ReadLocked of a range,
+// 0 is the global range
+func LockingRangeRdLockedACE(index uint8) OpalUID {
+	uid := LOCKINGRANGE_ACE_RDLOCKED
+	uid[7] = index
+	return uid
+}
+
+// LockingRangeWrLockedACE returns ACE_Locking_RangeN_Set_WrLocked, the ACE granting Set on WriteLocked of a range,
+// 0 is the global range
+func LockingRangeWrLockedACE(index uint8) OpalUID {
+	uid := LOCKINGRANGE_ACE_WRLOCKED
+	uid[7] = index
+	return uid
+}
+
+// nextRows returns the UIDs of every row of an object table with the Next method
+func nextRows(session *TcgSession, table OpalUID) ([]OpalUID, error) {
+	cmd := NewTcgCommand()
+	cmd.Init(table, NEXT)
+	cmd.AddValue(ListValue())
+	cmd.Complete()
+
+	resp, err := session.SendCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []OpalUID
+	for _, item := range resp.Result().Results().Item(0).Items() {
+		uid, err := item.UID()
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, uid)
+	}
+	return rows, nil
+}
+
+func readAuthority(session *TcgSession, uid OpalUID) (*Authority, error) {
+	table := append([]uint8{uint8(BYTESTRING8)}, uid[:]...)
+	resp, err := opalGetTable(session, table, uint16(AUTHORITY_UID), uint16(AUTHORITY_ENABLED))
+	if err != nil {
+		return nil, err
+	}
+	result := resp.Result()
+
+	authority := &Authority{UID: uid}
+	if authority.IsClass, err = result.Named(AUTHORITY_IS_CLASS).Bool(); err != nil {
+		return nil, err
+	}
+	if authority.Enabled, err = result.Named(AUTHORITY_ENABLED).Bool(); err != nil {
+		return nil, err
+	}
+	if name, err := result.Named(AUTHORITY_COMMON_NAME).Bytes(); err == nil {
+		authority.CommonName = string(name)
+	}
+	// class authorities have no class
+	if class, err := result.Named(AUTHORITY_CLASS).UID(); err == nil {
+		authority.Class = class
+	}
+	return authority, nil
+}
+
+// getAuthorities reads every row of the Authority table of the Locking SP as Admin1
+func getAuthorities(device TcgDevice, password string) ([]Authority, error) {
+	session, err := startLockingSession(device, password)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	rows, err := nextRows(session, AUTHORITY_TABLE)
+	if err != nil {
+		return nil, err
+	}
+
+	authorities := make([]Authority, 0, len(rows))
+	for _, uid := range rows {
+		authority, err := readAuthority(session, uid)
+		if err != nil {
+			return nil, fmt.Errorf("authority %x: %w", uid[:], err)
+		}
+		authorities = append(authorities, *authority)
+	}
+	return authorities, nil
+}
+
+func setAuthorityEnabled(device TcgDevice, password string, authority OpalUID, enabled bool) error {
+	session, err := startLockingSession(device, password)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	return SetAuthorityEnabledIn(session, authority, enabled)
+}
+
+// SetAuthorityEnabledIn enables or disables an authority of the Locking SP in a session, to take part in a transaction
+func SetAuthorityEnabledIn(session *TcgSession, authority OpalUID, enabled bool) error {
+	cmd := NewTcgCommand()
+	cmd.Init(authority, SET)
+	cmd.AddValue(ListValue(NamedValue(VALUES, ListValue(
+		NamedValue(AUTHORITY_ENABLED, BoolValue(enabled)),
+	))))
+	cmd.Complete()
+
+	_, err := session.SendCommand(cmd)
+	return err
+}
+
+// setAuthorityPassword sets the C_PIN of an authority of the Locking SP as Admin1
+func setAuthorityPassword(device TcgDevice, password string, authority OpalUID, newPassword string) error {
+	session, err := startLockingSession(device, password)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	return SetCPin(session, CPinUID(authority), newPassword)
+}
+
+// writeBooleanExpr sets the BooleanExpr column of an ACE to the OR of the authorities, in postfix notation
+func writeBooleanExpr(session *TcgSession, ace OpalUID, authorities []OpalUID) error {
+	var terms []*TcgValue
+	for i, authority := range authorities {
+		terms = append(terms, NamedValue(HALF_UID_AUTHORITY_OBJ_REF[:4], UIDValue(authority)))
+		if i > 0 {
+			terms = append(terms, NamedValue(HALF_UID_BOOLEAN_ACE[:4], UintValue(1)))
+		}
+	}
+
+	cmd := NewTcgCommand()
+	cmd.Init(ace, SET)
+	cmd.AddValue(ListValue(NamedValue(VALUES, ListValue(
+		NamedValue(BOOLEAN_EXPR, ListValue(terms...)),
+	))))
+	cmd.Complete()
+
+	_, err := session.SendCommand(cmd)
+	return err
+}
+
+// setLockingRangeAccess adds the authority to or removes it from the RdLocked and WrLocked ACEs of a range as Admin1
+func setLockingRangeAccess(device TcgDevice, password string, authority OpalUID, index uint8, grant bool) error {
+	session, err := startLockingSession(device, password)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	return SetLockingRangeAccessIn(session, authority, index, grant)
+}
+
+// SetLockingRangeAccessIn adds the authority to or removes it from the RdLocked and WrLocked ACEs of a range in a
+// session, to take part in a transaction. Both ACEs are checked before either is written, an ACE that is not an OR of
+// authorities or would be left empty is refused.
+func SetLockingRangeAccessIn(session *TcgSession, authority OpalUID, index uint8, grant bool) error {
+	updates := make(map[OpalUID][]OpalUID)
+	aces := []OpalUID{LockingRangeRdLockedACE(index), LockingRangeWrLockedACE(index)}
+	for _, uid := range aces {
+		ace, err := ReadACE(session, uid)
+		if err != nil {
+			return err
+		}
+		// an empty BooleanExpr grants nobody, adding an authority grants it alone
+		if !ace.AnyOf && len(ace.Authorities) > 0 {
+			return fmt.Errorf("ACE %x %s: %w", uid[:], ace.Expr, ErrBooleanExprNotAnyOf)
+		}
+
+		present := false
+		var authorities []OpalUID
+		for _, other := range ace.Authorities {
+			if other == authority {
+				present = true
+				continue
+			}
+			authorities = append(authorities, other)
+		}
+		if present == grant {
+			continue
+		}
+		if grant {
+			authorities = append(authorities, authority)
+		}
+		if len(authorities) == 0 {
+			return fmt.Errorf("ACE %x: %w", uid[:], ErrEmptyBooleanExpr)
+		}
+		updates[uid] = authorities
+	}
+
+	for _, uid := range aces {
+		if authorities, ok := updates[uid]; ok {
+			if err := writeBooleanExpr(session, uid, authorities); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
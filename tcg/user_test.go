@@ -0,0 +1,157 @@
+package tcg_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jc-lab/go-dparm/tcg"
+	"github.com/jc-lab/go-dparm/test/tcg_sim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserUIDs(t *testing.T) {
+	assert.Equal(t, tcg.USER2_UID, tcg.UserUID(2))
+	assert.Equal(t, tcg.ADMIN1_UID, tcg.AdminUID(1))
+	assert.Equal(t, tcg.C_PIN_ADMIN1, tcg.CPinUID(tcg.ADMIN1_UID))
+	assert.Equal(t, tcg.LOCKINGRANGE_ACE_RDLOCKED, tcg.LockingRangeRdLockedACE(1))
+	assert.Equal(t, tcg.LOCKINGRANGE_ACE_WRLOCKED, tcg.LockingRangeWrLockedACE(1))
+}
+
+func TestUserManagement(t *testing.T) {
+	sim := tcg_sim.NewTPer(tcg_sim.DefaultConfig())
+	device := testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+	require.NoError(t, device.TakeOwnership("sid"))
+	require.NoError(t, device.ActivateLockingSP("sid"))
+	require.NoError(t, device.SetAdmin1Password("sid", "admin1"))
+	for _, index := range []uint8{1, 2} {
+		require.NoError(t, device.ConfigureLockingRange("admin1", index, true, true, false))
+	}
+
+	authorities, err := device.GetAuthorities("admin1")
+	require.NoError(t, err)
+	// Anybody, Admins, Admin1-4, Users, User1-8
+	require.Len(t, authorities, 15)
+	byUID := make(map[tcg.OpalUID]tcg.Authority)
+	for _, authority := range authorities {
+		byUID[authority.UID] = authority
+	}
+	assert.True(t, byUID[tcg.ADMIN1_UID].Enabled)
+	assert.Equal(t, tcg.ADMINS_UID, byUID[tcg.ADMIN1_UID].Class)
+	assert.True(t, byUID[tcg.USERS_UID].IsClass)
+	assert.False(t, byUID[tcg.USER1_UID].Enabled)
+	assert.Equal(t, tcg.USERS_UID, byUID[tcg.USER1_UID].Class)
+
+	// a disabled user can not authenticate
+	require.NoError(t, device.SetUserPassword("admin1", 1, "user1"))
+	assert.Error(t, device.SetLockingStateAs(tcg.UserUID(1), "user1", 1, tcg.LOCKED))
+
+	require.NoError(t, device.SetUserEnabled("admin1", 1, true))
+	authorities, err = device.GetAuthorities("admin1")
+	require.NoError(t, err)
+	for _, authority := range authorities {
+		if authority.UID == tcg.USER1_UID {
+			assert.True(t, authority.Enabled)
+		}
+	}
+
+	// an enabled user without access to the range
+	err = device.SetLockingStateAs(tcg.UserUID(1), "user1", 1, tcg.LOCKED)
+	var tcgErr *tcg.TcgError
+	require.True(t, errors.As(err, &tcgErr))
+	assert.Equal(t, tcg.NOT_AUTHORIZED, tcgErr.Status)
+
+	require.NoError(t, device.AddUserToLockingRange("admin1", 1, 1))
+	// adding the user twice does not change the ACEs
+	require.NoError(t, device.AddUserToLockingRange("admin1", 1, 1))
+	require.NoError(t, device.SetLockingStateAs(tcg.UserUID(1), "user1", 1, tcg.LOCKED))
+	lockingRange, err := device.GetLockingRange("admin1", 1)
+	require.NoError(t, err)
+	assert.True(t, lockingRange.ReadLocked && lockingRange.WriteLocked)
+	require.NoError(t, device.SetLockingStateAs(tcg.UserUID(1), "user1", 1, tcg.READWRITE))
+	assert.Error(t, device.SetLockingStateAs(tcg.UserUID(1), "user1", 2, tcg.LOCKED))
+	assert.Error(t, device.SetLockingStateAs(tcg.UserUID(2), "", 1, tcg.LOCKED))
+
+	// Admin1 keeps its access to the range
+	require.NoError(t, device.SetLockingState("admin1", 1, tcg.LOCKED))
+
+	require.NoError(t, device.RemoveUserFromLockingRange("admin1", 1, 1))
+	assert.Error(t, device.SetLockingStateAs(tcg.UserUID(1), "user1", 1, tcg.READWRITE))
+	require.NoError(t, device.SetLockingState("admin1", 1, tcg.READWRITE))
+
+	require.NoError(t, device.SetUserEnabled("admin1", 1, false))
+	assert.Error(t, device.SetUserEnabled("user1", 1, true))
+	assert.Equal(t, 0, sim.OpenSessions())
+}
+
+// setBooleanExpr sets the BooleanExpr of an ACE to the postfix terms, authorities and boolean operators
+func setBooleanExpr(t *testing.T, device tcg.TcgDevice, ace tcg.OpalUID, terms ...any) {
+	var values []*tcg.TcgValue
+	for _, term := range terms {
+		switch v := term.(type) {
+		case tcg.OpalUID:
+			values = append(values, tcg.NamedValue(tcg.HALF_UID_AUTHORITY_OBJ_REF[:4], tcg.UIDValue(v)))
+		case uint64:
+			values = append(values, tcg.NamedValue(tcg.HALF_UID_BOOLEAN_ACE[:4], tcg.UintValue(v)))
+		}
+	}
+
+	session := tcg.NewTcgSession(device)
+	require.NoError(t, session.Start(tcg.LOCKINGSP_UID, "admin1", tcg.ADMIN1_UID))
+	defer session.Close()
+	cmd := tcg.NewTcgCommand()
+	cmd.Init(ace, tcg.SET)
+	cmd.AddValue(tcg.ListValue(tcg.NamedValue(tcg.VALUES, tcg.ListValue(
+		tcg.NamedValue(tcg.BOOLEAN_EXPR, tcg.ListValue(values...)),
+	))))
+	cmd.Complete()
+	_, err := session.SendCommand(cmd)
+	require.NoError(t, err)
+}
+
+func TestLockingRangeAccessBooleanExpr(t *testing.T) {
+	sim := tcg_sim.NewTPer(tcg_sim.DefaultConfig())
+	device := testOpalDevice(t, sim).(*tcg.TcgDeviceOpal2)
+	require.NoError(t, device.TakeOwnership("sid"))
+	require.NoError(t, device.ActivateLockingSP("sid"))
+	require.NoError(t, device.SetAdmin1Password("sid", "admin1"))
+
+	readACE := func(uid tcg.OpalUID) *tcg.ACE {
+		session := tcg.NewTcgSession(device)
+		require.NoError(t, session.Start(tcg.LOCKINGSP_UID, "admin1", tcg.ADMIN1_UID))
+		defer session.Close()
+		ace, err := tcg.ReadACE(session, uid)
+		require.NoError(t, err)
+		return ace
+	}
+
+	// an AND is not rewritten as an OR, neither ACE of the range is changed
+	setBooleanExpr(t, device, tcg.LockingRangeWrLockedACE(1), tcg.ADMINS_UID, tcg.USER1_UID, uint64(0))
+	rdLocked := readACE(tcg.LockingRangeRdLockedACE(1))
+	err := device.AddUserToLockingRange("admin1", 2, 1)
+	assert.True(t, errors.Is(err, tcg.ErrBooleanExprNotAnyOf), "%v", err)
+	err = device.RemoveUserFromLockingRange("admin1", 1, 1)
+	assert.True(t, errors.Is(err, tcg.ErrBooleanExprNotAnyOf), "%v", err)
+	assert.Equal(t, rdLocked, readACE(tcg.LockingRangeRdLockedACE(1)))
+	wrLocked := readACE(tcg.LockingRangeWrLockedACE(1))
+	assert.False(t, wrLocked.AnyOf)
+	assert.Equal(t, []tcg.OpalUID{tcg.ADMINS_UID, tcg.USER1_UID}, wrLocked.Authorities)
+
+	// a NOT is refused as well
+	setBooleanExpr(t, device, tcg.LockingRangeWrLockedACE(1), tcg.USER1_UID, uint64(2))
+	err = device.AddUserToLockingRange("admin1", 2, 1)
+	assert.True(t, errors.Is(err, tcg.ErrBooleanExprNotAnyOf), "%v", err)
+
+	// the last authority of an ACE is not removed
+	setBooleanExpr(t, device, tcg.LockingRangeWrLockedACE(2), tcg.USER1_UID)
+	err = device.RemoveUserFromLockingRange("admin1", 1, 2)
+	assert.True(t, errors.Is(err, tcg.ErrEmptyBooleanExpr), "%v", err)
+	assert.Equal(t, []tcg.OpalUID{tcg.USER1_UID}, readACE(tcg.LockingRangeWrLockedACE(2)).Authorities)
+
+	// an OR is extended
+	require.NoError(t, device.AddUserToLockingRange("admin1", 3, 2))
+	wrLocked = readACE(tcg.LockingRangeWrLockedACE(2))
+	assert.True(t, wrLocked.AnyOf)
+	assert.Equal(t, []tcg.OpalUID{tcg.USER1_UID, tcg.UserUID(3)}, wrLocked.Authorities)
+	assert.Equal(t, 0, sim.OpenSessions())
+}
@@ -1,24 +1,10 @@
 package tcg
 
-import (
-	"crypto/sha1"
-
-	"golang.org/x/crypto/pbkdf2"
-)
-
+// TcgHashPassword returns the byte string atom of the PIN of a password with the hasher of the drive, or with
+// RawPasswordHasher when noHashPassword is set
 func TcgHashPassword(device TcgDevice, noHashPassword bool, password string) []uint8 {
-	var outHash []uint8
-
-	if len(password) > 32 {
-		password = password[:32]
-	}
-
 	if noHashPassword {
-		outHash = append([]uint8{0xd0, uint8(len(password))}, []uint8(password)...)
-	} else {
-		derived := pbkdf2.Key([]byte(password), []byte(device.GetSerial()), 75000, 32, sha1.New)
-		outHash = append([]uint8{0xd0, uint8(len(derived))}, derived...)
+		return pinToken(RawPasswordHasher.HashPassword(device.GetSerial(), password))
 	}
-
-	return outHash
+	return pinToken(hashPassword(device, password))
 }
@@ -0,0 +1,607 @@
+package tcg
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrValueNotFound = errors.New("value not found")
+	ErrValueType     = errors.New("unexpected value type")
+)
+
+// TcgValueKind is the kind of a node of a decoded token stream
+type TcgValueKind int
+
+const (
+	AtomKind TcgValueKind = 0 + iota
+	ListKind
+	NamedKind
+	// TokenKind is a control token other than the list and name delimiters, such as CALL or ENDOFDATA
+	TokenKind
+)
+
+// valueName: OpalToken | uint64 | int | string | []byte
+type valueName any
+
+// TcgValue is a node of a decoded token stream, TCG Storage Architecture Core Specification 3.2.2
+//
+// The accessors are safe on a nil value and report ErrValueNotFound, so a lookup can be chained
+// like result.Named(CREDENTIAL_PIN).Bytes().
+type TcgValue struct {
+	Kind TcgValueKind
+
+	// Token is the control token of a TokenKind value
+	Token OpalToken
+
+	// an atom keeps the big-endian integer or the bytes of a byte sequence
+	data    []byte
+	byteSeq bool
+	signed  bool
+
+	items []*TcgValue
+
+	name  *TcgValue
+	value *TcgValue
+}
+
+func (v *TcgValue) IsAtom() bool {
+	return v != nil && v.Kind == AtomKind
+}
+
+func (v *TcgValue) IsList() bool {
+	return v != nil && v.Kind == ListKind
+}
+
+func (v *TcgValue) IsNamed() bool {
+	return v != nil && v.Kind == NamedKind
+}
+
+// IsToken returns true when v is the control token
+func (v *TcgValue) IsToken(token OpalToken) bool {
+	return v != nil && v.Kind == TokenKind && v.Token == token
+}
+
+// IsBytes returns true for a byte sequence atom
+func (v *TcgValue) IsBytes() bool {
+	return v.IsAtom() && v.byteSeq
+}
+
+// IsSigned returns true for a signed integer atom
+func (v *TcgValue) IsSigned() bool {
+	return v.IsAtom() && !v.byteSeq && v.signed
+}
+
+func (v *TcgValue) Uint() (uint64, error) {
+	if v == nil {
+		return 0, ErrValueNotFound
+	}
+	if v.Kind != AtomKind || v.byteSeq {
+		return 0, ErrValueType
+	}
+	if v.signed {
+		n, err := v.Int()
+		if err != nil {
+			return 0, err
+		}
+		if n < 0 {
+			return 0, fmt.Errorf("%w: negative integer %d", ErrValueType, n)
+		}
+		return uint64(n), nil
+	}
+
+	data := bytes.TrimLeft(v.data, "\x00")
+	if len(data) > 8 {
+		return 0, fmt.Errorf("%w: integer of %d bytes", ErrValueType, len(v.data))
+	}
+	var n uint64
+	for _, b := range data {
+		n = n<<8 | uint64(b)
+	}
+	return n, nil
+}
+
+func (v *TcgValue) Int() (int64, error) {
+	if v == nil {
+		return 0, ErrValueNotFound
+	}
+	if v.Kind != AtomKind || v.byteSeq {
+		return 0, ErrValueType
+	}
+	if !v.signed {
+		n, err := v.Uint()
+		if err != nil {
+			return 0, err
+		}
+		if n > 1<<63-1 {
+			return 0, fmt.Errorf("%w: integer overflow", ErrValueType)
+		}
+		return int64(n), nil
+	}
+
+	if len(v.data) > 8 {
+		return 0, fmt.Errorf("%w: integer of %d bytes", ErrValueType, len(v.data))
+	}
+	var n int64
+	if len(v.data) > 0 && v.data[0]&0x80 != 0 {
+		n = -1
+	}
+	for _, b := range v.data {
+		n = n<<8 | int64(b)
+	}
+	return n, nil
+}
+
+// Bool returns true for a non-zero integer
+func (v *TcgValue) Bool() (bool, error) {
+	n, err := v.Uint()
+	return n != 0, err
+}
+
+// Bytes returns the bytes of a byte sequence atom
+func (v *TcgValue) Bytes() ([]byte, error) {
+	if v == nil {
+		return nil, ErrValueNotFound
+	}
+	if v.Kind != AtomKind || !v.byteSeq {
+		return nil, ErrValueType
+	}
+	return v.data, nil
+}
+
+// UID returns a byte sequence of 8 bytes as an OpalUID
+func (v *TcgValue) UID() (OpalUID, error) {
+	var uid OpalUID
+	data, err := v.Bytes()
+	if err != nil {
+		return uid, err
+	}
+	if len(data) != len(uid) {
+		return uid, fmt.Errorf("%w: uid of %d bytes", ErrValueType, len(data))
+	}
+	copy(uid[:], data)
+	return uid, nil
+}
+
+// Len returns the number of items of a list
+func (v *TcgValue) Len() int {
+	if !v.IsList() {
+		return 0
+	}
+	return len(v.items)
+}
+
+// Items returns the items of a list, nil for any other value
+func (v *TcgValue) Items() []*TcgValue {
+	if !v.IsList() {
+		return nil
+	}
+	return v.items
+}
+
+// Item returns an item of a list, nil when there is no such item
+func (v *TcgValue) Item(index int) *TcgValue {
+	if !v.IsList() || index < 0 || index >= len(v.items) {
+		return nil
+	}
+	return v.items[index]
+}
+
+// Name returns the name of a named value
+func (v *TcgValue) Name() *TcgValue {
+	if !v.IsNamed() {
+		return nil
+	}
+	return v.name
+}
+
+// Value returns the value of a named value
+func (v *TcgValue) Value() *TcgValue {
+	if !v.IsNamed() {
+		return nil
+	}
+	return v.value
+}
+
+// Named returns the value named name in a list, nested lists are searched when the list itself has no such name.
+// It returns nil when there is no such value.
+func (v *TcgValue) Named(name valueName) *TcgValue {
+	if !v.IsList() {
+		return nil
+	}
+	for _, item := range v.items {
+		if item.IsNamed() && item.name.matchName(name) {
+			return item.value
+		}
+	}
+	for _, item := range v.items {
+		if found := item.Named(name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func (v *TcgValue) matchName(name valueName) bool {
+	switch n := name.(type) {
+	case string:
+		data, err := v.Bytes()
+		return err == nil && string(data) == n
+	case []byte:
+		data, err := v.Bytes()
+		return err == nil && bytes.Equal(data, n)
+	case OpalToken:
+		return v.matchUint(uint64(n))
+	case uint64:
+		return v.matchUint(n)
+	case int:
+		return n >= 0 && v.matchUint(uint64(n))
+	}
+	return false
+}
+
+func (v *TcgValue) matchUint(name uint64) bool {
+	n, err := v.Uint()
+	return err == nil && n == name
+}
+
+func (v *TcgValue) String() string {
+	if v == nil {
+		return "<nil>"
+	}
+	switch v.Kind {
+	case ListKind:
+		var b bytes.Buffer
+		b.WriteString("[")
+		for i, item := range v.items {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(item.String())
+		}
+		b.WriteString("]")
+		return b.String()
+	case NamedKind:
+		return v.name.String() + "=" + v.value.String()
+	case TokenKind:
+		return fmt.Sprintf("token(0x%02x)", int(v.Token))
+	}
+	if v.byteSeq {
+		return fmt.Sprintf("%x", v.data)
+	}
+	n, err := v.integer()
+	if err != nil {
+		return fmt.Sprintf("0x%x", v.data)
+	}
+	return n
+}
+
+// integer formats an integer atom in decimal, it fails when the integer does not fit in 64 bits
+func (v *TcgValue) integer() (string, error) {
+	if v.signed {
+		n, err := v.Int()
+		return fmt.Sprint(n), err
+	}
+	n, err := v.Uint()
+	return fmt.Sprint(n), err
+}
+
+// MarshalJSON encodes an integer as a number, an integer wider than 64 bits as a 0x prefixed hex string, a byte
+// sequence as a hex string, a list as an array and a named value
+// as an object with the name and the value
+func (v *TcgValue) MarshalJSON() ([]byte, error) {
+	if v == nil {
+		return []byte("null"), nil
+	}
+	switch v.Kind {
+	case ListKind:
+		items := v.items
+		if items == nil {
+			items = []*TcgValue{}
+		}
+		return json.Marshal(items)
+	case NamedKind:
+		return json.Marshal(struct {
+			Name  *TcgValue `json:"name"`
+			Value *TcgValue `json:"value"`
+		}{v.name, v.value})
+	case TokenKind:
+		return json.Marshal(v.String())
+	}
+	if v.byteSeq {
+		return json.Marshal(hex.EncodeToString(v.data))
+	}
+	n, err := v.integer()
+	if err != nil {
+		// an integer wider than 64 bits is kept exact as a 0x prefixed hex string
+		return json.Marshal(fmt.Sprintf("0x%x", v.data))
+	}
+	return []byte(n), nil
+}
+
+func UintValue(n uint64) *TcgValue {
+	var data []byte
+	for ; n != 0; n >>= 8 {
+		data = append([]byte{uint8(n)}, data...)
+	}
+	return &TcgValue{Kind: AtomKind, data: data}
+}
+
+func IntValue(n int64) *TcgValue {
+	data := []byte{uint8(n)}
+	// keep the shortest two's complement form
+	for rest := n >> 8; ; rest >>= 8 {
+		negative := data[0]&0x80 != 0
+		if rest == 0 && !negative || rest == -1 && negative {
+			break
+		}
+		data = append([]byte{uint8(rest)}, data...)
+	}
+	return &TcgValue{Kind: AtomKind, data: data, signed: true}
+}
+
+func BoolValue(b bool) *TcgValue {
+	if b {
+		return UintValue(1)
+	}
+	return UintValue(0)
+}
+
+func BytesValue(data []byte) *TcgValue {
+	return &TcgValue{Kind: AtomKind, data: append([]byte{}, data...), byteSeq: true}
+}
+
+func StringValue(text string) *TcgValue {
+	return BytesValue([]byte(text))
+}
+
+func UIDValue(uid OpalUID) *TcgValue {
+	return BytesValue(uid[:])
+}
+
+func ListValue(items ...*TcgValue) *TcgValue {
+	return &TcgValue{Kind: ListKind, items: items}
+}
+
+// NamedValue returns a named value, name: OpalToken | uint64 | int | string | []byte | *TcgValue
+func NamedValue(name any, value *TcgValue) *TcgValue {
+	var nameValue *TcgValue
+	switch n := name.(type) {
+	case *TcgValue:
+		nameValue = n
+	case OpalToken:
+		nameValue = UintValue(uint64(n))
+	case uint64:
+		nameValue = UintValue(n)
+	case int:
+		nameValue = UintValue(uint64(n))
+	case string:
+		nameValue = StringValue(n)
+	case []byte:
+		nameValue = BytesValue(n)
+	default:
+		panic(fmt.Sprintf("tcg: invalid name type %T", name))
+	}
+	return &TcgValue{Kind: NamedKind, name: nameValue, value: value}
+}
+
+func TokenValue(token OpalToken) *TcgValue {
+	return &TcgValue{Kind: TokenKind, Token: token}
+}
+
+// Encode returns the token stream of v
+func (v *TcgValue) Encode() []byte {
+	return v.appendTo(nil)
+}
+
+func (v *TcgValue) appendTo(out []byte) []byte {
+	switch v.Kind {
+	case ListKind:
+		out = append(out, uint8(STARTLIST))
+		for _, item := range v.items {
+			out = item.appendTo(out)
+		}
+		return append(out, uint8(ENDLIST))
+	case NamedKind:
+		out = append(out, uint8(STARTNAME))
+		out = v.name.appendTo(out)
+		out = v.value.appendTo(out)
+		return append(out, uint8(ENDNAME))
+	case TokenKind:
+		return append(out, uint8(v.Token))
+	}
+
+	if v.byteSeq {
+		out = appendAtomHeader(out, len(v.data), true, false)
+		return append(out, v.data...)
+	}
+
+	if v.signed {
+		n, _ := v.Int()
+		if n >= -32 && n < 32 {
+			return append(out, 0x40|uint8(n)&0x3f)
+		}
+	} else {
+		n, _ := v.Uint()
+		if n < 64 {
+			return append(out, uint8(n))
+		}
+	}
+	out = appendAtomHeader(out, len(v.data), false, v.signed)
+	return append(out, v.data...)
+}
+
+// appendAtomHeader appends the header of a short, medium or long atom
+func appendAtomHeader(out []byte, length int, byteSeq, signed bool) []byte {
+	var flags uint8
+	if byteSeq {
+		flags |= 0x02
+	}
+	if signed {
+		flags |= 0x01
+	}
+
+	switch {
+	case length < 16:
+		return append(out, 0x80|flags<<4|uint8(length))
+	case length < 2048:
+		return append(out, 0xc0|flags<<3|uint8(length>>8), uint8(length))
+	default:
+		return append(out, 0xe0|flags, uint8(length>>16), uint8(length>>8), uint8(length))
+	}
+}
+
+// ParseTokens decodes a token stream into its top level values, empty atoms are dropped and continued byte
+// sequences are joined
+func ParseTokens(data []byte) ([]*TcgValue, error) {
+	d := &tokenDecoder{data: data}
+
+	var values []*TcgValue
+	for {
+		v, err := d.next()
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			return values, nil
+		}
+		if v.Kind == TokenKind && (v.Token == ENDLIST || v.Token == ENDNAME) {
+			return nil, fmt.Errorf("%w: unexpected token 0x%02x at %d", ErrIllegalResponse, int(v.Token), d.pos-1)
+		}
+		values = append(values, v)
+	}
+}
+
+type tokenDecoder struct {
+	data []byte
+	pos  int
+}
+
+// next returns the next value, ENDLIST and ENDNAME are returned as tokens for the caller to close the list or
+// the name. It returns nil at the end of the stream.
+func (d *tokenDecoder) next() (*TcgValue, error) {
+	for d.pos < len(d.data) && d.data[d.pos] == uint8(EMPTYATOM) {
+		d.pos++
+	}
+	if d.pos >= len(d.data) {
+		return nil, nil
+	}
+
+	b := d.data[d.pos]
+	if b < 0xf0 {
+		return d.atom()
+	}
+	d.pos++
+
+	switch OpalToken(b) {
+	case STARTLIST:
+		list := &TcgValue{Kind: ListKind}
+		for {
+			item, err := d.next()
+			if err != nil {
+				return nil, err
+			}
+			if item == nil {
+				return nil, fmt.Errorf("%w: unterminated list", ErrIllegalResponse)
+			}
+			if item.IsToken(ENDLIST) {
+				return list, nil
+			}
+			if item.IsToken(ENDNAME) {
+				return nil, fmt.Errorf("%w: unexpected end of name at %d", ErrIllegalResponse, d.pos-1)
+			}
+			list.items = append(list.items, item)
+		}
+	case STARTNAME:
+		name, err := d.next()
+		if err != nil {
+			return nil, err
+		}
+		if !name.IsAtom() {
+			return nil, fmt.Errorf("%w: the name of a named value is not an atom", ErrIllegalResponse)
+		}
+		value, err := d.next()
+		if err != nil {
+			return nil, err
+		}
+		if value == nil || value.Kind == TokenKind {
+			return nil, fmt.Errorf("%w: named value without a value", ErrIllegalResponse)
+		}
+		end, err := d.next()
+		if err != nil {
+			return nil, err
+		}
+		if !end.IsToken(ENDNAME) {
+			return nil, fmt.Errorf("%w: unterminated named value", ErrIllegalResponse)
+		}
+		return &TcgValue{Kind: NamedKind, name: name, value: value}, nil
+	}
+
+	return TokenValue(OpalToken(b)), nil
+}
+
+// atom decodes an atom at the current position, a continued byte sequence is joined with the atoms that follow it
+func (d *tokenDecoder) atom() (*TcgValue, error) {
+	v := &TcgValue{Kind: AtomKind}
+	for {
+		start := d.pos
+		b := d.data[d.pos]
+
+		var headerLen, length int
+		var byteSeq, signed bool
+		switch {
+		case b&0x80 == 0:
+			// tiny atom, bit 6 is the sign bit
+			d.pos++
+			v.signed = b&0x40 != 0
+			if v.signed && b&0x20 != 0 {
+				v.data = []byte{b | 0xc0}
+			} else if v.signed || b&0x3f != 0 {
+				v.data = []byte{b & 0x3f}
+			}
+			return v, nil
+		case b&0x40 == 0:
+			// short atom
+			headerLen, length = 1, int(b&0x0f)
+			byteSeq, signed = b&0x20 != 0, b&0x10 != 0
+		case b&0x20 == 0:
+			// medium atom
+			if start+1 >= len(d.data) {
+				return nil, fmt.Errorf("%w: truncated atom header at %d", ErrIllegalResponse, start)
+			}
+			headerLen, length = 2, int(b&0x07)<<8|int(d.data[start+1])
+			byteSeq, signed = b&0x10 != 0, b&0x08 != 0
+		default:
+			// long atom
+			if start+3 >= len(d.data) {
+				return nil, fmt.Errorf("%w: truncated atom header at %d", ErrIllegalResponse, start)
+			}
+			headerLen, length = 4, int(d.data[start+1])<<16|int(d.data[start+2])<<8|int(d.data[start+3])
+			byteSeq, signed = b&0x02 != 0, b&0x01 != 0
+		}
+
+		end := start + headerLen + length
+		if end > len(d.data) {
+			return nil, fmt.Errorf("%w: atom at %d overflows the stream", ErrIllegalResponse, start)
+		}
+		v.data = append(v.data, d.data[start+headerLen:end]...)
+		d.pos = end
+
+		// B and S set together mark a byte sequence continued in the next atom
+		if byteSeq && signed {
+			v.byteSeq = true
+			if d.pos >= len(d.data) || d.data[d.pos] >= 0xf0 || d.data[d.pos]&0x80 == 0 {
+				return nil, fmt.Errorf("%w: continued atom without a continuation", ErrIllegalResponse)
+			}
+			continue
+		}
+		if v.byteSeq && !byteSeq {
+			return nil, fmt.Errorf("%w: a continued byte sequence ends with an integer", ErrIllegalResponse)
+		}
+		v.byteSeq, v.signed = byteSeq, signed
+		return v, nil
+	}
+}
@@ -0,0 +1,151 @@
+package tcg_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/jc-lab/go-dparm/tcg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValueEncoding(t *testing.T) {
+	tests := []struct {
+		value *tcg.TcgValue
+		data  []byte
+	}{
+		{tcg.UintValue(0), []byte{0x00}},
+		{tcg.UintValue(63), []byte{0x3f}},
+		{tcg.UintValue(64), []byte{0x81, 0x40}},
+		{tcg.UintValue(0x12345), []byte{0x83, 0x01, 0x23, 0x45}},
+		{tcg.IntValue(-1), []byte{0x7f}},
+		{tcg.IntValue(-32), []byte{0x60}},
+		{tcg.IntValue(31), []byte{0x5f}},
+		{tcg.IntValue(-33), []byte{0x91, 0xdf}},
+		{tcg.IntValue(200), []byte{0x92, 0x00, 0xc8}},
+		{tcg.BytesValue(nil), []byte{0xa0}},
+		{tcg.StringValue("PIN"), []byte{0xa3, 'P', 'I', 'N'}},
+		{tcg.NamedValue(tcg.CREDENTIAL_PIN, tcg.UintValue(1)), []byte{0xf2, 0x03, 0x01, 0xf3}},
+		{tcg.NamedValue(tcg.SUM_RANGE_START_LENGTH_POLICY, tcg.UintValue(0)), []byte{0xf2, 0x83, 0x06, 0x00, 0x01, 0x00, 0xf3}},
+		{tcg.ListValue(tcg.UintValue(1), tcg.ListValue()), []byte{0xf0, 0x01, 0xf0, 0xf1, 0xf1}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.data, test.value.Encode(), test.value.String())
+
+		values, err := tcg.ParseTokens(test.data)
+		require.NoError(t, err)
+		require.Len(t, values, 1)
+		assert.Equal(t, test.data, values[0].Encode())
+	}
+
+	medium := make([]byte, 300)
+	data := tcg.BytesValue(medium).Encode()
+	assert.Equal(t, []byte{0xd1, 0x2c}, data[:2])
+	long := make([]byte, 0x1234)
+	data = tcg.BytesValue(long).Encode()
+	assert.Equal(t, []byte{0xe2, 0x00, 0x12, 0x34}, data[:4])
+	values, err := tcg.ParseTokens(data)
+	require.NoError(t, err)
+	decoded, err := values[0].Bytes()
+	require.NoError(t, err)
+	assert.Len(t, decoded, 0x1234)
+}
+
+func TestParseTokens(t *testing.T) {
+	values, err := tcg.ParseTokens([]byte{
+		0x7f,             // -1
+		0x92, 0xff, 0x00, // -256
+		0xff,           // empty atom
+		0xb2, 'a', 'b', // continued
+		0xd8, 0x01, 'c', // medium continued
+		0xa1, 'd', // end of the byte sequence
+		0x84, 0, 0, 0x01, 0x00, // 256
+	})
+	require.NoError(t, err)
+	require.Len(t, values, 4)
+
+	n, err := values[0].Int()
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1), n)
+	_, err = values[0].Uint()
+	assert.True(t, errors.Is(err, tcg.ErrValueType))
+
+	n, err = values[1].Int()
+	require.NoError(t, err)
+	assert.Equal(t, int64(-256), n)
+
+	b, err := values[2].Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("abcd"), b)
+
+	u, err := values[3].Uint()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(256), u)
+
+	for _, data := range [][]byte{
+		{0xf0, 0x01},       // unterminated list
+		{0xf1},             // unexpected end of list
+		{0xf2, 0x01, 0x02}, // unterminated name
+		{0xf2, 0xf0, 0xf1, 0x02, 0xf3},
+		{0xa4, 'a'},             // short atom overflow
+		{0xb1, 'a'},             // continued without a continuation
+		{0xb1, 'a', 0x81, 0x01}, // continued with an integer
+	} {
+		_, err := tcg.ParseTokens(data)
+		assert.Error(t, err, "% x", data)
+	}
+}
+
+func TestParseResult(t *testing.T) {
+	// a Get response of C_PIN_MSID where the firmware returns an extra leading column
+	resp := tcg.ListValue(tcg.ListValue(
+		tcg.NamedValue(tcg.CREDENTIAL_UID, tcg.UIDValue(tcg.C_PIN_MSID)),
+		tcg.NamedValue(tcg.CREDENTIAL_PIN, tcg.StringValue("MSID")),
+		tcg.NamedValue("LockOnReset", tcg.ListValue(tcg.UintValue(0), tcg.UintValue(3))),
+	)).Encode()
+	resp = append(resp, uint8(tcg.ENDOFDATA))
+	resp = append(resp, tcg.ListValue(tcg.UintValue(0), tcg.UintValue(0), tcg.UintValue(0)).Encode()...)
+
+	result, err := tcg.ParseResult(resp)
+	require.NoError(t, err)
+	assert.False(t, result.IsCall())
+	status, err := result.MethodStatus()
+	require.NoError(t, err)
+	assert.Equal(t, tcg.SUCCESS, status)
+
+	pin, err := result.Named(tcg.CREDENTIAL_PIN).Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("MSID"), pin)
+	uid, err := result.Named(tcg.CREDENTIAL_UID).UID()
+	require.NoError(t, err)
+	assert.Equal(t, tcg.C_PIN_MSID, uid)
+	assert.Equal(t, 2, result.Named("LockOnReset").Len())
+
+	_, err = result.Named(tcg.CREDENTIAL_TRY_LIMIT).Uint()
+	assert.True(t, errors.Is(err, tcg.ErrValueNotFound))
+	_, err = result.Named(tcg.CREDENTIAL_PIN).Uint()
+	assert.True(t, errors.Is(err, tcg.ErrValueType))
+}
+
+func TestValueJSON(t *testing.T) {
+	value := tcg.ListValue(
+		tcg.UintValue(7),
+		tcg.IntValue(-2),
+		tcg.BytesValue([]byte{0xde, 0xad}),
+		tcg.NamedValue(tcg.CREDENTIAL_PIN, tcg.ListValue()),
+	)
+	data, err := json.Marshal(value)
+	require.NoError(t, err)
+	assert.Equal(t, `[7,-2,"dead",{"name":3,"value":[]}]`, string(data))
+
+	// a 9 byte integer does not fit in a uint64
+	values, err := tcg.ParseTokens([]byte{0x89, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09})
+	require.NoError(t, err)
+	require.Len(t, values, 1)
+	data, err = json.Marshal(values[0])
+	require.NoError(t, err)
+	assert.Equal(t, `"0x010203040506070809"`, string(data))
+	assert.Equal(t, "0x010203040506070809", values[0].String())
+}
@@ -0,0 +1,358 @@
+// Package ata_sim is an in-memory ATA device that implements common.AtaDriverHandle,
+// so task-file based code can be exercised without hardware.
+package ata_sim
+
+import (
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/jc-lab/go-dparm/ata"
+	"github.com/jc-lab/go-dparm/common"
+)
+
+const SectorSize = 512
+
+var (
+	ErrNotSupported   = errors.New("not supported")
+	ErrBufferTooSmall = errors.New("data buffer is smaller than the transfer length")
+)
+
+type SmartAttribute struct {
+	Id        uint8
+	Flags     uint16
+	Current   uint8
+	Worst     uint8
+	Raw       uint64
+	Threshold uint8
+}
+
+type Config struct {
+	Model    string
+	Serial   string
+	Firmware string
+
+	// Sectors is the native capacity in 512-byte sectors
+	Sectors uint64
+	// RotationRate is IDENTIFY word 217, 1 means non-rotating media
+	RotationRate uint16
+
+	// Path of the sparse backing file, a temporary file is used when empty
+	Path string
+
+	SmartAttributes []SmartAttribute
+
+	SecuritySupported      bool
+	EnhancedEraseSupported bool
+	SanitizeSupported      bool
+	MasterPasswordId       uint16
+	MasterPassword         string
+	SecurityUnlockTryLimit int
+
+	// Security answers TRUSTED SEND/RECEIVE, e.g. a tcg_sim.TPer
+	Security SecurityHandler
+}
+
+type SecurityHandler interface {
+	SecurityCommand(rw bool, dma bool, protocol uint8, comId uint16, buffer []byte, timeoutSecs int) error
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Model:        "GO-DPARM ATA SIMULATOR",
+		Serial:       "SIMATA0000000001",
+		Firmware:     "1.0",
+		Sectors:      0x200000,
+		RotationRate: 1,
+		SmartAttributes: []SmartAttribute{
+			{Id: 5, Flags: 0x0033, Current: 100, Worst: 100, Raw: 0, Threshold: 10},
+			{Id: 9, Flags: 0x0032, Current: 100, Worst: 100, Raw: 1234, Threshold: 0},
+			{Id: 12, Flags: 0x0032, Current: 100, Worst: 100, Raw: 56, Threshold: 0},
+			{Id: 194, Flags: 0x0022, Current: 65, Worst: 60, Raw: 35, Threshold: 0},
+		},
+		SecuritySupported:      true,
+		EnhancedEraseSupported: true,
+		SanitizeSupported:      true,
+		MasterPasswordId:       0xfffe,
+		SecurityUnlockTryLimit: 5,
+	}
+}
+
+// Handler scripts the response of one opcode.
+// Returning false falls through to the built-in behaviour.
+type Handler func(rw bool, dma bool, tf *ata.Tf, data []byte) (bool, error)
+
+type powerMode int
+
+const (
+	powerActive powerMode = 0 + iota
+	powerIdle
+	powerStandby
+	powerSleep
+)
+
+// Drive implements common.AtaDriverHandle
+type Drive struct {
+	mu     sync.Mutex
+	config Config
+
+	file     *os.File
+	tempFile bool
+
+	handlers map[ata.OpCode]Handler
+	lastOp   ata.OpCode
+
+	power powerMode
+
+	// HPA
+	maxLba         uint64
+	volatileMaxLba bool
+	persistMaxLba  uint64
+
+	smartEnabled bool
+	smart        []SmartAttribute
+
+	security securityState
+	sanitize sanitizeState
+}
+
+func NewDrive(config Config) (*Drive, error) {
+	d := &Drive{
+		config:       config,
+		handlers:     make(map[ata.OpCode]Handler),
+		maxLba:       config.Sectors - 1,
+		smartEnabled: true,
+		smart:        append([]SmartAttribute{}, config.SmartAttributes...),
+	}
+	d.persistMaxLba = d.maxLba
+	d.security.init(&config)
+
+	var err error
+	if config.Path != "" {
+		d.file, err = os.OpenFile(config.Path, os.O_RDWR|os.O_CREATE, 0600)
+	} else {
+		d.file, err = os.CreateTemp("", "ata_sim-*.img")
+		d.tempFile = true
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := d.file.Truncate(int64(config.Sectors * SectorSize)); err != nil {
+		d.Close()
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// On replaces the built-in handling of an opcode, a nil handler restores it.
+func (d *Drive) On(op ata.OpCode, handler Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if handler == nil {
+		delete(d.handlers, op)
+	} else {
+		d.handlers[op] = handler
+	}
+}
+
+// PowerCycle simulates a power-on reset
+func (d *Drive) PowerCycle() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.power = powerActive
+	d.lastOp = 0
+	if d.volatileMaxLba {
+		d.maxLba = d.persistMaxLba
+		d.volatileMaxLba = false
+	}
+	d.security.powerCycle()
+	d.sanitize.powerCycle()
+}
+
+// SetSmartAttribute updates the current value and raw data of an attribute
+func (d *Drive) SetSmartAttribute(id uint8, current uint8, raw uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range d.smart {
+		if d.smart[i].Id == id {
+			d.smart[i].Current = current
+			if current < d.smart[i].Worst {
+				d.smart[i].Worst = current
+			}
+			d.smart[i].Raw = raw
+		}
+	}
+}
+
+func (d *Drive) GetDriverName() string {
+	return "AtaSimulator"
+}
+
+func (d *Drive) GetDrivingType() common.DrivingType {
+	return common.DrivingAtapi
+}
+
+func (d *Drive) ReopenWritable() error {
+	return nil
+}
+
+func (d *Drive) Close() {
+	if d.file == nil {
+		return
+	}
+	_ = d.file.Close()
+	if d.tempFile {
+		_ = os.Remove(d.file.Name())
+	}
+	d.file = nil
+}
+
+func (d *Drive) SecurityCommand(rw bool, dma bool, protocol uint8, comId uint16, buffer []byte, timeoutSecs int) error {
+	if d.config.Security == nil {
+		return ErrNotSupported
+	}
+	return d.config.Security.SecurityCommand(rw, dma, protocol, comId, buffer, timeoutSecs)
+}
+
+func (d *Drive) GetIdentity() []byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.identify()
+}
+
+// DoTaskFileCmd follows the pass-through drivers: a command that the device aborts
+// returns nil with ATA_STAT_ERR and the ERROR register set in tf.
+func (d *Drive) DoTaskFileCmd(rw bool, dma bool, tf *ata.Tf, data []byte, timeoutSecs int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.file == nil {
+		return os.ErrClosed
+	}
+
+	op := tf.Command
+	defer func() {
+		d.lastOp = op
+	}()
+
+	if handler, ok := d.handlers[op]; ok {
+		handled, err := handler(rw, dma, tf, data)
+		if handled || err != nil {
+			return err
+		}
+	}
+
+	if d.power == powerSleep {
+		// only a reset wakes the device up
+		Fail(tf, ata.ATA_ERR_ABRT)
+		return nil
+	}
+
+	if d.security.erasePrepared && op != ata.ATA_OP_SECURITY_ERASE_UNIT {
+		d.security.erasePrepared = false
+	}
+
+	switch op {
+	case ata.ATA_OP_IDENTIFY:
+		if len(data) < SectorSize {
+			return ErrBufferTooSmall
+		}
+		copy(data, d.identify())
+		Complete(tf)
+		return nil
+	case ata.ATA_OP_READ_PIO, ata.ATA_OP_READ_PIO_ONCE, ata.ATA_OP_READ_DMA,
+		ata.ATA_OP_READ_PIO_EXT, ata.ATA_OP_READ_DMA_EXT:
+		return d.readSectors(tf, data)
+	case ata.ATA_OP_WRITE_PIO, ata.ATA_OP_WRITE_DMA,
+		ata.ATA_OP_WRITE_PIO_EXT, ata.ATA_OP_WRITE_DMA_EXT:
+		return d.writeSectors(tf, data)
+	case ata.ATA_OP_READ_VERIFY, ata.ATA_OP_READ_VERIFY_ONCE, ata.ATA_OP_READ_VERIFY_EXT:
+		return d.verifySectors(tf)
+	case ata.ATA_OP_FLUSHCACHE, ata.ATA_OP_FLUSHCACHE_EXT:
+		d.power = powerActive
+		Complete(tf)
+		return nil
+	case ata.ATA_OP_CHECKPOWERMODE1, ata.ATA_OP_CHECKPOWERMODE2:
+		d.checkPowerMode(tf)
+		return nil
+	case ata.ATA_OP_STANDBYNOW1, ata.ATA_OP_STANDBYNOW2:
+		d.power = powerStandby
+		Complete(tf)
+		return nil
+	case ata.ATA_OP_IDLEIMMEDIATE:
+		d.power = powerIdle
+		Complete(tf)
+		return nil
+	case ata.ATA_OP_SLEEPNOW1, ata.ATA_OP_SLEEPNOW2:
+		d.power = powerSleep
+		Complete(tf)
+		return nil
+	case ata.ATA_OP_SMART:
+		return d.smartCommand(tf, data)
+	case ata.ATA_OP_READ_NATIVE_MAX, ata.ATA_OP_READ_NATIVE_MAX_EXT:
+		d.readNativeMax(tf)
+		return nil
+	case ata.ATA_OP_SET_MAX, ata.ATA_OP_SET_MAX_EXT:
+		d.setMax(tf)
+		return nil
+	case ata.ATA_OP_SECURITY_SET_PASS, ata.ATA_OP_SECURITY_UNLOCK, ata.ATA_OP_SECURITY_ERASE_PREPARE,
+		ata.ATA_OP_SECURITY_ERASE_UNIT, ata.ATA_OP_SECURITY_FREEZE_LOCK, ata.ATA_OP_SECURITY_DISABLE:
+		return d.securityCommand(tf, data)
+	case ata.ATA_OP_SANITIZE:
+		d.sanitizeCommand(tf)
+		return nil
+	case ata.ATA_OP_TRUSTED_SEND, ata.ATA_OP_TRUSTED_SEND_DMA, ata.ATA_OP_TRUSTED_RECV, ata.ATA_OP_TRUSTED_RECV_DMA:
+		return d.trustedCommand(tf, data)
+	}
+
+	Fail(tf, ata.ATA_ERR_ABRT)
+	return nil
+}
+
+// Complete sets the registers of a successfully completed command
+func Complete(tf *ata.Tf) {
+	tf.Status = ata.ATA_STAT_DRDY
+	tf.Error = 0
+}
+
+// Fail sets the registers of an aborted command
+func Fail(tf *ata.Tf, errReg uint8) {
+	tf.Status = ata.ATA_STAT_DRDY | ata.ATA_STAT_ERR
+	tf.Error = errReg
+}
+
+func (d *Drive) checkPowerMode(tf *ata.Tf) {
+	switch d.power {
+	case powerStandby:
+		tf.Lob.Nsect = ata.ATA_POWER_MODE_STANDBY
+	case powerIdle:
+		tf.Lob.Nsect = ata.ATA_POWER_MODE_IDLE
+	default:
+		tf.Lob.Nsect = ata.ATA_POWER_MODE_ACTIVE
+	}
+	Complete(tf)
+}
+
+func (d *Drive) trustedCommand(tf *ata.Tf, data []byte) error {
+	if d.config.Security == nil {
+		Fail(tf, ata.ATA_ERR_ABRT)
+		return nil
+	}
+
+	rw := tf.Command == ata.ATA_OP_TRUSTED_SEND || tf.Command == ata.ATA_OP_TRUSTED_SEND_DMA
+	dma := tf.Command == ata.ATA_OP_TRUSTED_SEND_DMA || tf.Command == ata.ATA_OP_TRUSTED_RECV_DMA
+	comId := uint16(tf.Lob.Lbam) | uint16(tf.Lob.Lbah)<<8
+	length := int(tf.Lob.Nsect) * SectorSize
+	if length > len(data) {
+		return ErrBufferTooSmall
+	}
+
+	if err := d.config.Security.SecurityCommand(rw, dma, tf.Lob.Feat, comId, data[:length], 0); err != nil {
+		Fail(tf, ata.ATA_ERR_ABRT)
+		return nil
+	}
+	Complete(tf)
+	return nil
+}
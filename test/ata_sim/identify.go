@@ -0,0 +1,92 @@
+package ata_sim
+
+import (
+	"bytes"
+
+	"github.com/jc-lab/go-dparm/ata"
+	"github.com/jc-lab/go-dparm/internal"
+	"github.com/lunixbochs/struc"
+)
+
+// ataString fills an IDENTIFY string field, padded with spaces and byte swapped per word
+func ataString(dst []byte, text string) {
+	for i := range dst {
+		dst[i] = ' '
+	}
+	copy(dst, text)
+	internal.AtaSwapWordEndian(dst)
+}
+
+func (d *Drive) identify() []byte {
+	identity := &ata.IdentityDeviceData{}
+
+	ataString(identity.SerialNumber[:], d.config.Serial)
+	ataString(identity.FirmwareRevision[:], d.config.Firmware)
+	ataString(identity.ModelNumber[:], d.config.Model)
+
+	identity.MaximumBlockTransfer = 0x10
+	identity.Capabilities.A = 0x0300 // LBA, DMA
+	identity.MajorRevision = 0x07f0  // ATA8-ACS ... ACS-4
+	identity.UltraDmaSupport = 0x7f
+
+	userSectors := d.maxLba + 1
+	identity.UserAddressableSectors = uint32(internal.Ternary(userSectors > 0x0fffffff, 0x0fffffff, userSectors))
+	identity.Max48bitLba = userSectors
+
+	// word 82: SMART, security, HPA; word 83: 48-bit address; word 84: valid
+	support := uint16(0x0001 | 0x0400)
+	active := uint16(0x0400)
+	if d.smartEnabled {
+		active |= 0x0001
+	}
+	if d.config.SecuritySupported {
+		support |= 0x0002
+		if d.security.enabled {
+			active |= 0x0002
+		}
+	}
+	identity.CommandSetSupport.A = support
+	identity.CommandSetSupport.B = 0x4400
+	identity.CommandSetSupport.C = 0x4000
+	identity.CommandSetActive.A = active
+	identity.CommandSetActive.B = 0x0400
+	identity.CommandSetActive.C = 0x4000
+
+	if d.config.SecuritySupported {
+		identity.NormalSecurityEraseUnit.A = 2
+		if d.config.EnhancedEraseSupported {
+			identity.EnhancedSecurityEraseUnit.A = 2
+		}
+		identity.MasterPasswordId = d.security.masterPasswordId
+		identity.SecurityStatus.A = d.security.status(d.config.EnhancedEraseSupported)
+	}
+
+	if d.config.SanitizeSupported {
+		// sanitize, crypto scramble, overwrite, block erase, antifreeze lock
+		identity.Word59.B = 0x10 | 0x20 | 0x40 | 0x80 | 0x04
+	}
+
+	identity.PhysicalLogicalSectorSize.A = 0x4000
+	identity.NominalMediaRotationRate = d.config.RotationRate
+
+	var buf bytes.Buffer
+	if err := struc.PackWithOptions(&buf, identity, internal.GetStrucOptions()); err != nil {
+		panic(err)
+	}
+	raw := buf.Bytes()
+
+	// word 255: signature and checksum
+	raw[510] = 0xa5
+	setChecksum(raw)
+
+	return raw
+}
+
+// setChecksum sets the last byte so that the 512 bytes sum to zero
+func setChecksum(raw []byte) {
+	var sum uint8
+	for _, b := range raw[:511] {
+		sum += b
+	}
+	raw[511] = -sum
+}
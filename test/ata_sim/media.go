@@ -0,0 +1,163 @@
+package ata_sim
+
+import (
+	"github.com/jc-lab/go-dparm/ata"
+)
+
+func isLba48Command(op ata.OpCode) bool {
+	switch op {
+	case ata.ATA_OP_READ_PIO_EXT, ata.ATA_OP_READ_DMA_EXT, ata.ATA_OP_WRITE_PIO_EXT, ata.ATA_OP_WRITE_DMA_EXT,
+		ata.ATA_OP_READ_VERIFY_EXT, ata.ATA_OP_READ_NATIVE_MAX_EXT, ata.ATA_OP_SET_MAX_EXT, ata.ATA_OP_SANITIZE:
+		return true
+	}
+	return false
+}
+
+// tfLba returns the LBA and sector count of a read/write style command
+func tfLba(tf *ata.Tf) (uint64, uint64) {
+	if isLba48Command(tf.Command) {
+		lba := uint64(tf.Lob.Lbal) | uint64(tf.Lob.Lbam)<<8 | uint64(tf.Lob.Lbah)<<16 |
+			uint64(tf.Hob.Lbal)<<24 | uint64(tf.Hob.Lbam)<<32 | uint64(tf.Hob.Lbah)<<40
+		count := uint64(tf.Lob.Nsect) | uint64(tf.Hob.Nsect)<<8
+		if count == 0 {
+			count = 65536
+		}
+		return lba, count
+	}
+
+	lba := uint64(tf.Lob.Lbal) | uint64(tf.Lob.Lbam)<<8 | uint64(tf.Lob.Lbah)<<16 | uint64(tf.Dev&0x0f)<<24
+	count := uint64(tf.Lob.Nsect)
+	if count == 0 {
+		count = 256
+	}
+	return lba, count
+}
+
+// setTfLba writes lba to the LBA registers, e.g. for READ NATIVE MAX ADDRESS
+func setTfLba(tf *ata.Tf, lba uint64, lba48 bool) {
+	tf.Lob.Lbal = uint8(lba)
+	tf.Lob.Lbam = uint8(lba >> 8)
+	tf.Lob.Lbah = uint8(lba >> 16)
+	if lba48 {
+		tf.IsLba48 = 1
+		tf.Hob.Lbal = uint8(lba >> 24)
+		tf.Hob.Lbam = uint8(lba >> 32)
+		tf.Hob.Lbah = uint8(lba >> 40)
+	} else {
+		tf.Dev = (tf.Dev & 0xf0) | uint8(lba>>24)&0x0f
+	}
+}
+
+// checkMediaAccess sets the error registers and returns false if the range can not be accessed
+func (d *Drive) checkMediaAccess(tf *ata.Tf, lba, count uint64) bool {
+	if d.security.locked {
+		Fail(tf, ata.ATA_ERR_ABRT)
+		return false
+	}
+	if lba+count-1 > d.maxLba {
+		Fail(tf, ata.ATA_ERR_IDNF)
+		setTfLba(tf, lba, isLba48Command(tf.Command))
+		return false
+	}
+	d.power = powerActive
+	return true
+}
+
+func (d *Drive) readSectors(tf *ata.Tf, data []byte) error {
+	lba, count := tfLba(tf)
+	if uint64(len(data)) < count*SectorSize {
+		return ErrBufferTooSmall
+	}
+	if !d.checkMediaAccess(tf, lba, count) {
+		return nil
+	}
+
+	if _, err := d.file.ReadAt(data[:count*SectorSize], int64(lba*SectorSize)); err != nil {
+		Fail(tf, ata.ATA_ERR_UNC)
+		return nil
+	}
+	Complete(tf)
+	return nil
+}
+
+func (d *Drive) writeSectors(tf *ata.Tf, data []byte) error {
+	lba, count := tfLba(tf)
+	if uint64(len(data)) < count*SectorSize {
+		return ErrBufferTooSmall
+	}
+	if !d.checkMediaAccess(tf, lba, count) {
+		return nil
+	}
+
+	if _, err := d.file.WriteAt(data[:count*SectorSize], int64(lba*SectorSize)); err != nil {
+		Fail(tf, ata.ATA_ERR_ABRT)
+		return nil
+	}
+	Complete(tf)
+	return nil
+}
+
+func (d *Drive) verifySectors(tf *ata.Tf) error {
+	lba, count := tfLba(tf)
+	if !d.checkMediaAccess(tf, lba, count) {
+		return nil
+	}
+	Complete(tf)
+	return nil
+}
+
+// eraseMedia discards all user data, the sparse backing file reads back as zeros
+func (d *Drive) eraseMedia() error {
+	if err := d.file.Truncate(0); err != nil {
+		return err
+	}
+	return d.file.Truncate(int64(d.config.Sectors * SectorSize))
+}
+
+func (d *Drive) readNativeMax(tf *ata.Tf) {
+	if d.security.locked {
+		Fail(tf, ata.ATA_ERR_ABRT)
+		return
+	}
+
+	nativeMax := d.config.Sectors - 1
+	if tf.Command == ata.ATA_OP_READ_NATIVE_MAX && nativeMax > 0x0fffffff {
+		nativeMax = 0x0fffffff
+	}
+	setTfLba(tf, nativeMax, tf.Command == ata.ATA_OP_READ_NATIVE_MAX_EXT)
+	Complete(tf)
+}
+
+// setMax implements SET MAX ADDRESS, COUNT bit 0 (VV) keeps the value over a power cycle
+func (d *Drive) setMax(tf *ata.Tf) {
+	if d.security.locked {
+		Fail(tf, ata.ATA_ERR_ABRT)
+		return
+	}
+
+	// SET MAX ADDRESS shall be immediately preceded by READ NATIVE MAX ADDRESS
+	expected := ata.ATA_OP_READ_NATIVE_MAX
+	if tf.Command == ata.ATA_OP_SET_MAX_EXT {
+		expected = ata.ATA_OP_READ_NATIVE_MAX_EXT
+	}
+	if d.lastOp != expected {
+		Fail(tf, ata.ATA_ERR_ABRT)
+		return
+	}
+
+	lba, _ := tfLba(tf)
+	if lba > d.config.Sectors-1 {
+		Fail(tf, ata.ATA_ERR_IDNF)
+		return
+	}
+
+	d.maxLba = lba
+	if tf.Lob.Nsect&0x01 != 0 {
+		d.persistMaxLba = lba
+		d.volatileMaxLba = false
+	} else {
+		d.volatileMaxLba = true
+	}
+	setTfLba(tf, lba, tf.Command == ata.ATA_OP_SET_MAX_EXT)
+	Complete(tf)
+}
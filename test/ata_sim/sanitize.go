@@ -0,0 +1,83 @@
+package ata_sim
+
+import (
+	"github.com/jc-lab/go-dparm/ata"
+)
+
+// sanitizeState models the Sanitize Device feature set, ACS-3 4.17.
+// Sanitize operations complete immediately.
+type sanitizeState struct {
+	frozen     bool
+	antifreeze bool
+	completed  bool
+}
+
+var sanitizeKeys = map[uint16]uint32{
+	ata.SANITIZE_CRYPTO_SCRAMBLE_EXT: ata.SANITIZE_CRYPTO_SCRAMBLE_KEY,
+	ata.SANITIZE_BLOCK_ERASE_EXT:     ata.SANITIZE_BLOCK_ERASE_KEY,
+	ata.SANITIZE_OVERWRITE_EXT:       ata.SANITIZE_OVERWRITE_KEY,
+}
+
+func (s *sanitizeState) powerCycle() {
+	s.frozen = false
+	s.antifreeze = false
+}
+
+func (d *Drive) sanitizeCommand(tf *ata.Tf) {
+	if !d.config.SanitizeSupported {
+		Fail(tf, ata.ATA_ERR_ABRT)
+		return
+	}
+
+	s := &d.sanitize
+	feature := uint16(tf.Hob.Feat)<<8 | uint16(tf.Lob.Feat)
+	lba, _ := tfLba(tf)
+	key := uint32(lba)
+
+	ok := false
+	switch feature {
+	case ata.SANITIZE_STATUS_EXT:
+		ok = true
+	case ata.SANITIZE_FREEZE_LOCK_EXT:
+		if key == ata.SANITIZE_FREEZE_LOCK_KEY && !s.antifreeze {
+			s.frozen = true
+			ok = true
+		}
+	case ata.SANITIZE_ANTIFREEZE_LOCK_EXT:
+		if key == ata.SANITIZE_ANTIFREEZE_LOCK_KEY && !s.frozen {
+			s.antifreeze = true
+			ok = true
+		}
+	case ata.SANITIZE_CRYPTO_SCRAMBLE_EXT, ata.SANITIZE_BLOCK_ERASE_EXT, ata.SANITIZE_OVERWRITE_EXT:
+		if key != sanitizeKeys[feature] || s.frozen || d.security.locked {
+			break
+		}
+		// the overwrite pattern is not kept, the media reads back as zeros
+		if err := d.eraseMedia(); err != nil {
+			break
+		}
+		s.completed = true
+		ok = true
+	}
+
+	// sanitize status is reported in COUNT(15:8) and the progress indicator in LBA(15:0)
+	tf.Hob.Nsect = 0
+	if s.completed {
+		tf.Hob.Nsect |= ata.SANITIZE_FLAG_OPERATION_SUCCEEDED
+	}
+	if s.frozen {
+		tf.Hob.Nsect |= ata.SANITIZE_FLAG_DEVICE_IN_FROZEN
+	}
+	if s.antifreeze {
+		tf.Hob.Nsect |= ata.SANITIZE_FLAG_ANTIFREEZE_BIT
+	}
+	tf.IsLba48 = 1
+	tf.Lob.Lbal = 0xff
+	tf.Lob.Lbam = 0xff
+
+	if ok {
+		Complete(tf)
+	} else {
+		Fail(tf, ata.ATA_ERR_ABRT)
+	}
+}
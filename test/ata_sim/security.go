@@ -0,0 +1,212 @@
+package ata_sim
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/jc-lab/go-dparm/ata"
+)
+
+const passwordLength = 32
+
+// securityState is the ATA Security feature set state machine, ACS-3 4.18
+type securityState struct {
+	enabled       bool
+	locked        bool
+	frozen        bool
+	levelMax      bool
+	erasePrepared bool
+
+	userPassword     [passwordLength]byte
+	masterPassword   [passwordLength]byte
+	masterPasswordId uint16
+
+	tryLimit     int
+	failedTries  int
+	countExpired bool
+}
+
+func toPassword(text string) [passwordLength]byte {
+	var out [passwordLength]byte
+	copy(out[:], text)
+	return out
+}
+
+func (s *securityState) init(config *Config) {
+	s.masterPassword = toPassword(config.MasterPassword)
+	s.masterPasswordId = config.MasterPasswordId
+	s.tryLimit = config.SecurityUnlockTryLimit
+}
+
+func (s *securityState) powerCycle() {
+	s.frozen = false
+	s.erasePrepared = false
+	s.failedTries = 0
+	s.countExpired = false
+	if s.enabled {
+		s.locked = true
+	}
+}
+
+// status returns IDENTIFY word 128
+func (s *securityState) status(enhancedErase bool) uint16 {
+	status := uint16(0x0001)
+	if s.enabled {
+		status |= 0x0002
+	}
+	if s.locked {
+		status |= 0x0004
+	}
+	if s.frozen {
+		status |= 0x0008
+	}
+	if s.countExpired {
+		status |= 0x0010
+	}
+	if enhancedErase {
+		status |= 0x0020
+	}
+	if s.levelMax {
+		status |= 0x0100
+	}
+	return status
+}
+
+// securityPassword is the data block of the SECURITY commands, ACS-3 Table 56
+type securityPassword struct {
+	master   bool
+	levelMax bool
+	enhanced bool
+	password [passwordLength]byte
+	id       uint16
+}
+
+func parseSecurityPassword(data []byte) securityPassword {
+	control := binary.LittleEndian.Uint16(data[0:])
+	p := securityPassword{
+		master:   control&0x0001 != 0,
+		enhanced: control&0x0002 != 0,
+		levelMax: control&0x0100 != 0,
+		id:       binary.LittleEndian.Uint16(data[34:]),
+	}
+	copy(p.password[:], data[2:2+passwordLength])
+	return p
+}
+
+// check compares the password and counts failed attempts,
+// the master password is refused in Maximum level unless allowMaster is set
+func (s *securityState) check(p securityPassword, allowMaster bool) bool {
+	var ok bool
+	if p.master {
+		ok = (allowMaster || !s.levelMax) && bytes.Equal(p.password[:], s.masterPassword[:])
+	} else {
+		ok = bytes.Equal(p.password[:], s.userPassword[:])
+	}
+
+	if !ok {
+		s.failedTries++
+		if s.tryLimit > 0 && s.failedTries >= s.tryLimit {
+			s.countExpired = true
+		}
+	}
+	return ok
+}
+
+func (s *securityState) disable() {
+	s.enabled = false
+	s.locked = false
+	s.levelMax = false
+	s.userPassword = [passwordLength]byte{}
+}
+
+func (d *Drive) securityCommand(tf *ata.Tf, data []byte) error {
+	if !d.config.SecuritySupported {
+		Fail(tf, ata.ATA_ERR_ABRT)
+		return nil
+	}
+
+	s := &d.security
+	op := tf.Command
+
+	var p securityPassword
+	switch op {
+	case ata.ATA_OP_SECURITY_SET_PASS, ata.ATA_OP_SECURITY_UNLOCK,
+		ata.ATA_OP_SECURITY_ERASE_UNIT, ata.ATA_OP_SECURITY_DISABLE:
+		if len(data) < SectorSize {
+			return ErrBufferTooSmall
+		}
+		p = parseSecurityPassword(data)
+	}
+
+	ok := false
+	switch op {
+	case ata.ATA_OP_SECURITY_SET_PASS:
+		if s.frozen || s.locked {
+			break
+		}
+		if p.master {
+			s.masterPassword = p.password
+			if p.id != 0x0000 && p.id != 0xffff {
+				s.masterPasswordId = p.id
+			}
+		} else {
+			s.userPassword = p.password
+			s.levelMax = p.levelMax
+			s.enabled = true
+		}
+		ok = true
+	case ata.ATA_OP_SECURITY_UNLOCK:
+		if s.frozen || s.countExpired {
+			break
+		}
+		if s.check(p, false) {
+			s.locked = false
+			s.failedTries = 0
+			ok = true
+		}
+	case ata.ATA_OP_SECURITY_ERASE_PREPARE:
+		if s.frozen {
+			break
+		}
+		s.erasePrepared = true
+		ok = true
+	case ata.ATA_OP_SECURITY_ERASE_UNIT:
+		prepared := s.erasePrepared
+		s.erasePrepared = false
+		if !prepared || s.frozen || s.countExpired || !s.enabled {
+			break
+		}
+		if p.enhanced && !d.config.EnhancedEraseSupported {
+			break
+		}
+		if s.check(p, true) {
+			if err := d.eraseMedia(); err != nil {
+				return err
+			}
+			s.disable()
+			s.failedTries = 0
+			ok = true
+		}
+	case ata.ATA_OP_SECURITY_FREEZE_LOCK:
+		if s.locked {
+			break
+		}
+		s.frozen = true
+		ok = true
+	case ata.ATA_OP_SECURITY_DISABLE:
+		if s.frozen || s.locked || s.countExpired {
+			break
+		}
+		if s.check(p, false) {
+			s.disable()
+			ok = true
+		}
+	}
+
+	if ok {
+		Complete(tf)
+	} else {
+		Fail(tf, ata.ATA_ERR_ABRT)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package ata_sim
+
+import (
+	"bytes"
+
+	"github.com/jc-lab/go-dparm/ata"
+	"github.com/jc-lab/go-dparm/internal"
+	"github.com/lunixbochs/struc"
+)
+
+func (d *Drive) smartCommand(tf *ata.Tf, data []byte) error {
+	if tf.Lob.Lbam != ata.SMART_LBA_LOW || tf.Lob.Lbah != ata.SMART_LBA_HIGH {
+		Fail(tf, ata.ATA_ERR_ABRT)
+		return nil
+	}
+
+	if tf.Lob.Feat == ata.SMART_FEAT_ENABLE_OPERATIONS {
+		d.smartEnabled = true
+		Complete(tf)
+		return nil
+	}
+	if !d.smartEnabled {
+		Fail(tf, ata.ATA_ERR_ABRT)
+		return nil
+	}
+
+	switch tf.Lob.Feat {
+	case ata.SMART_FEAT_DISABLE_OPERATIONS:
+		d.smartEnabled = false
+	case ata.SMART_FEAT_READ_ATTRIBUTE_VALUES:
+		if len(data) < SectorSize {
+			return ErrBufferTooSmall
+		}
+		copy(data, d.smartValues())
+	case ata.SMART_FEAT_READ_ATTRIBUTE_THRESHOLDS:
+		if len(data) < SectorSize {
+			return ErrBufferTooSmall
+		}
+		copy(data, d.smartThresholds())
+	case ata.SMART_FEAT_RETURN_STATUS:
+		if d.smartThresholdExceeded() {
+			tf.Lob.Lbam = ata.SMART_RETURN_STATUS_MID_EXCEEDED
+			tf.Lob.Lbah = ata.SMART_RETURN_STATUS_HI_EXCEEDED
+		}
+	default:
+		Fail(tf, ata.ATA_ERR_ABRT)
+		return nil
+	}
+
+	Complete(tf)
+	return nil
+}
+
+// smartThresholdExceeded reports whether a pre-failure attribute is at or below its threshold
+func (d *Drive) smartThresholdExceeded() bool {
+	for _, attr := range d.smart {
+		if attr.Flags&0x0001 != 0 && attr.Threshold != 0 && attr.Current <= attr.Threshold {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Drive) smartValues() []byte {
+	values := &ata.SmartAttributeValues{
+		RevNumber:                       0x0010,
+		OfflineDataCollectionCapability: 0x5b,
+		SmartCapability:                 0x0003,
+		ErrorlogCapability:              0x01,
+	}
+	for i, attr := range d.smart {
+		if i >= ata.SMART_ATTRIBUTES_NUMBER {
+			break
+		}
+		out := &values.Attributes[i]
+		out.Id = attr.Id
+		out.Flags = attr.Flags
+		out.Current = attr.Current
+		out.Worst = attr.Worst
+		for j := range out.Raw {
+			out.Raw[j] = uint8(attr.Raw >> (8 * j))
+		}
+	}
+	return packSmartData(values)
+}
+
+func (d *Drive) smartThresholds() []byte {
+	thresholds := &ata.SmartAttributeThresholds{
+		RevNumber: 0x0010,
+	}
+	for i, attr := range d.smart {
+		if i >= ata.SMART_ATTRIBUTES_NUMBER {
+			break
+		}
+		thresholds.Attributes[i].Id = attr.Id
+		thresholds.Attributes[i].Threshold = attr.Threshold
+	}
+	return packSmartData(thresholds)
+}
+
+// packSmartData serializes a SMART data structure and sets its checksum byte
+func packSmartData(data any) []byte {
+	var buf bytes.Buffer
+	if err := struc.PackWithOptions(&buf, data, internal.GetStrucOptions()); err != nil {
+		panic(err)
+	}
+	raw := buf.Bytes()
+	setChecksum(raw)
+
+	return raw
+}
@@ -0,0 +1,260 @@
+// Package nvme_sim is an in-memory NVMe controller that implements common.NvmeDriverHandle,
+// so admin command based code can be exercised without /dev/nvme*.
+package nvme_sim
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/jc-lab/go-dparm/common"
+	common_nvme "github.com/jc-lab/go-dparm/common/nvme"
+	"github.com/jc-lab/go-dparm/nvme"
+)
+
+var (
+	ErrNotSupported = errors.New("not supported")
+	ErrClosed       = errors.New("controller is closed")
+)
+
+// LbaFormat is one entry of the Identify Namespace LBA Format list
+type LbaFormat struct {
+	MetadataSize uint16
+	// DataSize is the LBA data size as a power of two, e.g. 9 for 512 bytes
+	DataSize uint8
+}
+
+type Config struct {
+	Model    string
+	Serial   string
+	Firmware string
+
+	VendorId   uint16
+	Namespaces int
+	// NamespaceSize in logical blocks of the LBA format 0
+	NamespaceSize uint64
+	LbaFormats    []LbaFormat
+
+	// FirmwareSlots is the number of slots, slot 1 is read-only when FirmwareSlot1ReadOnly is set
+	FirmwareSlots         int
+	FirmwareSlot1ReadOnly bool
+	// FirmwareActivateWithoutReset allows commit action 3 to activate immediately
+	FirmwareActivateWithoutReset bool
+
+	// Sanicap, NVMe 1.3 Figure 109: bit 0 crypto erase, bit 1 block erase, bit 2 overwrite
+	Sanicap uint32
+	// SanitizePolls is the number of Sanitize Status log reads before a sanitize operation completes
+	SanitizePolls int
+
+	ErrorLogEntries int
+
+	// Security answers Security Send/Receive, e.g. a tcg_sim.TPer
+	Security SecurityHandler
+}
+
+type SecurityHandler interface {
+	SecurityCommand(rw bool, dma bool, protocol uint8, comId uint16, buffer []byte, timeoutSecs int) error
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Model:         "GO-DPARM NVME SIMULATOR",
+		Serial:        "SIMNVME000000001",
+		Firmware:      "1.0",
+		VendorId:      0x1d79,
+		Namespaces:    1,
+		NamespaceSize: 0x200000,
+		LbaFormats: []LbaFormat{
+			{DataSize: 9},
+			{DataSize: 12},
+		},
+		FirmwareSlots:         3,
+		FirmwareSlot1ReadOnly: true,
+		Sanicap:               0x7,
+		ErrorLogEntries:       16,
+	}
+}
+
+// Handler scripts the response of one admin opcode.
+// Returning false falls through to the built-in behaviour.
+type Handler func(cmd *nvme.NvmeAdminCmd) (bool, error)
+
+type namespace struct {
+	size  uint64
+	flbas uint8
+	inUse uint64
+}
+
+// Controller implements common.NvmeDriverHandle
+type Controller struct {
+	mu     sync.Mutex
+	config Config
+	closed bool
+
+	handlers map[nvme.AdminOpCode]Handler
+	cmdId    uint16
+
+	namespaces []*namespace
+
+	features features
+	smart    nvme.SmartLogPage
+	errorLog []errorEntry
+	errorCnt uint64
+
+	firmware firmwareState
+	sanitize sanitizeState
+}
+
+func NewController(config Config) *Controller {
+	c := &Controller{
+		config:   config,
+		handlers: make(map[nvme.AdminOpCode]Handler),
+	}
+	for i := 0; i < config.Namespaces; i++ {
+		c.namespaces = append(c.namespaces, &namespace{
+			size:  config.NamespaceSize,
+			inUse: config.NamespaceSize,
+		})
+	}
+	c.features.init()
+	c.firmware.init(&config)
+	c.initSmart()
+	return c
+}
+
+// On replaces the built-in handling of an opcode, a nil handler restores it.
+func (c *Controller) On(op nvme.AdminOpCode, handler Handler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if handler == nil {
+		delete(c.handlers, op)
+	} else {
+		c.handlers[op] = handler
+	}
+}
+
+// PowerCycle simulates a controller level reset after a power loss
+func (c *Controller) PowerCycle() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.features.reset()
+	c.firmware.reset()
+	addCounter(&c.smart.PowerCycles, 1)
+}
+
+func (c *Controller) GetDriverName() string {
+	return "NvmeSimulator"
+}
+
+func (c *Controller) GetDrivingType() common.DrivingType {
+	return common.DrivingNvme
+}
+
+func (c *Controller) ReopenWritable() error {
+	return nil
+}
+
+func (c *Controller) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+}
+
+// SecurityCommand fails like the OS drivers do, DriveHandleImpl then falls back to Security Send/Receive
+func (c *Controller) SecurityCommand(rw bool, dma bool, protocol uint8, comId uint16, buffer []byte, timeoutSecs int) error {
+	return ErrNotSupported
+}
+
+func (c *Controller) GetIdentity() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.identifyController()
+}
+
+func (c *Controller) NvmeGetLogPage(nsid uint32, logId uint32, rae bool, size int) ([]byte, error) {
+	return common_nvme.NvmeGetLogPageByAdminPassthru(c, nsid, logId, rae, size)
+}
+
+// DoNvmeAdminPassthru returns the completion status as nvme.StatusCode when it is not successful
+func (c *Controller) DoNvmeAdminPassthru(cmd *nvme.NvmeAdminCmd) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return ErrClosed
+	}
+
+	op := nvme.AdminOpCode(cmd.Opcode)
+	c.cmdId++
+
+	if handler, ok := c.handlers[op]; ok {
+		handled, err := handler(cmd)
+		if handled || err != nil {
+			return err
+		}
+	}
+
+	status := c.execute(op, cmd)
+	if status != nvme.NVME_SC_SUCCESS {
+		c.logError(cmd, status)
+		return status
+	}
+	return nil
+}
+
+func (c *Controller) execute(op nvme.AdminOpCode, cmd *nvme.NvmeAdminCmd) nvme.StatusCode {
+	if len(cmd.DataBuffer) < int(cmd.DataLen) {
+		return nvme.NVME_SC_DATA_XFER_ERROR
+	}
+	data := cmd.DataBuffer[:cmd.DataLen]
+
+	switch op {
+	case nvme.NVME_ADMIN_OP_IDENTIFY:
+		return c.identify(cmd, data)
+	case nvme.NVME_ADMIN_OP_GET_LOG_PAGE:
+		return c.getLogPage(cmd, data)
+	case nvme.NVME_ADMIN_OP_GET_FEATURES:
+		return c.getFeatures(cmd)
+	case nvme.NVME_ADMIN_OP_SET_FEATURES:
+		return c.setFeatures(cmd)
+	case nvme.NVME_ADMIN_OP_FORMAT_NVM:
+		return c.formatNvm(cmd)
+	case nvme.NVME_ADMIN_OP_SANITIZE_NVM:
+		return c.sanitizeNvm(cmd)
+	case nvme.NVME_ADMIN_OP_DOWNLOAD_FW:
+		return c.downloadFirmware(cmd, data)
+	case nvme.NVME_ADMIN_OP_ACTIVATE_FW:
+		return c.commitFirmware(cmd)
+	case nvme.NVME_ADMIN_OP_SECURITY_SEND, nvme.NVME_ADMIN_OP_SECURITY_RECV:
+		return c.securityCommand(op, cmd, data)
+	}
+
+	return nvme.NVME_SC_INVALID_OPCODE
+}
+
+// namespaceOf returns the namespace addressed by nsid, nil for an invalid nsid
+func (c *Controller) namespaceOf(nsid uint32) *namespace {
+	if nsid == 0 || int(nsid) > len(c.namespaces) {
+		return nil
+	}
+	return c.namespaces[nsid-1]
+}
+
+// securityCommand implements Security Send/Receive, NVMe 1.3 5.22 and 5.23
+func (c *Controller) securityCommand(op nvme.AdminOpCode, cmd *nvme.NvmeAdminCmd, data []byte) nvme.StatusCode {
+	if c.config.Security == nil {
+		return nvme.NVME_SC_INVALID_OPCODE
+	}
+
+	protocol := uint8(cmd.Cdw10 >> 24)
+	comId := uint16(cmd.Cdw10 >> 8)
+	length := int(cmd.Cdw11)
+	if length > len(data) {
+		return nvme.NVME_SC_INVALID_FIELD
+	}
+
+	if err := c.config.Security.SecurityCommand(op == nvme.NVME_ADMIN_OP_SECURITY_SEND, false, protocol, comId, data[:length], 0); err != nil {
+		return nvme.NVME_SC_INVALID_FIELD
+	}
+	return nvme.NVME_SC_SUCCESS
+}
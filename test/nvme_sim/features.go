@@ -0,0 +1,170 @@
+package nvme_sim
+
+import (
+	"github.com/jc-lab/go-dparm/nvme"
+)
+
+// Feature identifiers, NVMe 1.3 Figure 129
+const (
+	featureArbitration          = 0x01
+	featurePowerManagement      = 0x02
+	featureTemperatureThreshold = 0x04
+	featureErrorRecovery        = 0x05
+	featureVolatileWriteCache   = 0x06
+	featureNumberOfQueues       = 0x07
+	featureInterruptCoalescing  = 0x08
+	featureWriteAtomicity       = 0x0a
+	featureAsyncEventConfig     = 0x0b
+)
+
+// Get Features select field, NVMe 1.3 Figure 127
+const (
+	selectCurrent      = 0
+	selectDefault      = 1
+	selectSaved        = 2
+	selectCapabilities = 3
+)
+
+type featureDef struct {
+	saveable bool
+	value    uint32
+}
+
+var featureDefs = map[uint8]featureDef{
+	featureArbitration:          {saveable: true},
+	featurePowerManagement:      {saveable: true},
+	featureTemperatureThreshold: {saveable: true, value: 343},
+	featureErrorRecovery:        {saveable: true},
+	featureVolatileWriteCache:   {saveable: true, value: 1},
+	featureNumberOfQueues:       {value: 0x003f003f},
+	featureInterruptCoalescing:  {saveable: true},
+	featureWriteAtomicity:       {saveable: true},
+	featureAsyncEventConfig:     {saveable: true},
+}
+
+// featureKey identifies a feature value, the temperature threshold has one per sensor and type
+type featureKey struct {
+	fid    uint8
+	subsel uint32
+}
+
+type features struct {
+	current   map[featureKey]uint32
+	saved     map[featureKey]uint32
+	queuesSet bool
+}
+
+func (f *features) init() {
+	f.current = make(map[featureKey]uint32)
+	f.saved = make(map[featureKey]uint32)
+}
+
+func (f *features) reset() {
+	f.current = make(map[featureKey]uint32)
+	for k, v := range f.saved {
+		f.current[k] = v
+	}
+	f.queuesSet = false
+}
+
+func keyOf(fid uint8, cdw11 uint32) featureKey {
+	key := featureKey{fid: fid}
+	if fid == featureTemperatureThreshold {
+		// TMPSEL and THSEL
+		key.subsel = cdw11 & 0x003f0000
+	}
+	return key
+}
+
+func defaultValue(key featureKey) uint32 {
+	if key.fid == featureTemperatureThreshold && key.subsel&0x00300000 != 0 {
+		// under temperature threshold
+		return 0
+	}
+	return featureDefs[key.fid].value
+}
+
+func (f *features) get(key featureKey, sel uint32) uint32 {
+	switch sel {
+	case selectSaved:
+		if v, ok := f.saved[key]; ok {
+			return v
+		}
+	case selectCurrent:
+		if v, ok := f.current[key]; ok {
+			return v
+		}
+	}
+	return defaultValue(key)
+}
+
+// getFeatures returns the attribute in completion queue entry dword 0
+func (c *Controller) getFeatures(cmd *nvme.NvmeAdminCmd) nvme.StatusCode {
+	fid := uint8(cmd.Cdw10)
+	sel := (cmd.Cdw10 >> 8) & 0x7
+	def, ok := featureDefs[fid]
+	if !ok {
+		return nvme.NVME_SC_INVALID_FIELD
+	}
+
+	if sel == selectCapabilities {
+		// changeable, and saveable when supported
+		cmd.Result = 0x4
+		if def.saveable {
+			cmd.Result |= 0x1
+		}
+		return nvme.NVME_SC_SUCCESS
+	}
+	if sel > selectCapabilities {
+		return nvme.NVME_SC_INVALID_FIELD
+	}
+
+	cmd.Result = c.features.get(keyOf(fid, cmd.Cdw11), sel)
+	return nvme.NVME_SC_SUCCESS
+}
+
+func (c *Controller) setFeatures(cmd *nvme.NvmeAdminCmd) nvme.StatusCode {
+	fid := uint8(cmd.Cdw10)
+	save := cmd.Cdw10&0x80000000 != 0
+	def, ok := featureDefs[fid]
+	if !ok {
+		return nvme.NVME_SC_INVALID_FIELD
+	}
+	if save && !def.saveable {
+		return nvme.NVME_SC_FEATURE_NOT_SAVEABLE
+	}
+
+	key := keyOf(fid, cmd.Cdw11)
+	value := cmd.Cdw11
+	switch fid {
+	case featureTemperatureThreshold:
+		value &= 0xffff
+	case featureVolatileWriteCache:
+		value &= 0x1
+	case featureNumberOfQueues:
+		// may only be set once after a reset, 0xffff is an invalid request
+		if c.features.queuesSet {
+			return nvme.NVME_SC_CMD_SEQ_ERROR
+		}
+		if value&0xffff == 0xffff || value>>16 == 0xffff {
+			return nvme.NVME_SC_INVALID_FIELD
+		}
+		c.features.queuesSet = true
+		// the controller allocates what it supports at most
+		nsqa, ncqa := value&0xffff, value>>16
+		if nsqa > 0x3f {
+			nsqa = 0x3f
+		}
+		if ncqa > 0x3f {
+			ncqa = 0x3f
+		}
+		value = ncqa<<16 | nsqa
+	}
+
+	c.features.current[key] = value
+	if save {
+		c.features.saved[key] = value
+	}
+	cmd.Result = value
+	return nvme.NVME_SC_SUCCESS
+}
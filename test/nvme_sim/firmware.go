@@ -0,0 +1,131 @@
+package nvme_sim
+
+import (
+	"strings"
+
+	"github.com/jc-lab/go-dparm/nvme"
+)
+
+// Firmware Commit actions, NVMe 1.3 Figure 73
+const (
+	commitReplace             = 0
+	commitReplaceAndActivate  = 1
+	commitActivate            = 2
+	commitReplaceAndActivateN = 3
+)
+
+// firmwareRevisionLength is the size of a revision in the Firmware Slot log
+const firmwareRevisionLength = 8
+
+// firmwareState keeps the firmware slots, an image's revision is taken from its first 8 bytes
+type firmwareState struct {
+	slots      []string
+	active     int
+	next       int
+	download   []byte
+	slot1RO    bool
+	activateNR bool
+}
+
+func (f *firmwareState) init(config *Config) {
+	f.slots = make([]string, config.FirmwareSlots)
+	f.slots[0] = config.Firmware
+	f.active = 1
+	f.slot1RO = config.FirmwareSlot1ReadOnly
+	f.activateNR = config.FirmwareActivateWithoutReset
+}
+
+func (f *firmwareState) reset() {
+	if f.next != 0 {
+		f.active = f.next
+		f.next = 0
+	}
+	f.download = nil
+}
+
+func (f *firmwareState) activeRevision() string {
+	return f.slots[f.active-1]
+}
+
+func (c *Controller) downloadFirmware(cmd *nvme.NvmeAdminCmd, data []byte) nvme.StatusCode {
+	length := int(cmd.Cdw10+1) * 4
+	offset := int(cmd.Cdw11) * 4
+	if length > len(data) {
+		return nvme.NVME_SC_INVALID_FIELD
+	}
+	if offset != len(c.firmware.download) {
+		// pieces have to be downloaded in order without gaps or overlaps
+		return nvme.NVME_SC_OVERLAPPING_RANGE
+	}
+	c.firmware.download = append(c.firmware.download, data[:length]...)
+	return nvme.NVME_SC_SUCCESS
+}
+
+// commitFirmware implements Firmware Commit, NVMe 1.3 5.11
+func (c *Controller) commitFirmware(cmd *nvme.NvmeAdminCmd) nvme.StatusCode {
+	if c.sanitize.status == sanitizeInProgress {
+		return nvme.NVME_SC_SANITIZE_IN_PROGRESS
+	}
+
+	f := &c.firmware
+	slot := int(cmd.Cdw10 & 0x7)
+	action := (cmd.Cdw10 >> 3) & 0x7
+
+	if slot > len(f.slots) {
+		return nvme.NVME_SC_FIRMWARE_SLOT
+	}
+
+	if action == commitReplace || action == commitReplaceAndActivate || action == commitReplaceAndActivateN {
+		if slot == 0 {
+			// the controller picks the first writable slot
+			slot = 1
+			if f.slot1RO {
+				slot = 2
+			}
+		}
+		if slot > len(f.slots) || (slot == 1 && f.slot1RO) {
+			return nvme.NVME_SC_FIRMWARE_SLOT
+		}
+		if len(f.download) < firmwareRevisionLength {
+			return nvme.NVME_SC_FIRMWARE_IMAGE
+		}
+		f.slots[slot-1] = strings.TrimRight(string(f.download[:firmwareRevisionLength]), " \x00")
+		f.download = nil
+	} else if action == commitActivate {
+		if slot == 0 {
+			slot = f.active
+		}
+		if f.slots[slot-1] == "" {
+			return nvme.NVME_SC_FIRMWARE_IMAGE
+		}
+	} else {
+		return nvme.NVME_SC_INVALID_FIELD
+	}
+
+	switch action {
+	case commitReplaceAndActivate, commitActivate:
+		f.next = slot
+	case commitReplaceAndActivateN:
+		if !f.activateNR {
+			f.next = slot
+			return nvme.NVME_SC_FW_NEEDS_CONV_RESET
+		}
+		f.active = slot
+		f.next = 0
+	}
+	return nvme.NVME_SC_SUCCESS
+}
+
+// slotLogPage builds the Firmware Slot Information log, NVMe 1.3 Figure 96
+func (f *firmwareState) slotLogPage() []byte {
+	page := make([]byte, logPageSize)
+	page[0] = uint8(f.active) | uint8(f.next)<<4
+	for i, revision := range f.slots {
+		if revision == "" {
+			continue
+		}
+		rev := page[8+8*i : 16+8*i]
+		nvmeString(rev, revision)
+	}
+	return page
+}
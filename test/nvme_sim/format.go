@@ -0,0 +1,127 @@
+package nvme_sim
+
+import (
+	"encoding/binary"
+
+	"github.com/jc-lab/go-dparm/nvme"
+)
+
+// Sanitize Status log SSTAT values, NVMe 1.3 Figure 101
+const (
+	sanitizeSucceeded  = uint16(nvme.NVME_SANITIZE_LOG_COMPLETED_SUCCESS)
+	sanitizeInProgress = uint16(nvme.NVME_SANITIZE_LOG_IN_PROGESS)
+)
+
+type sanitizeState struct {
+	status    uint16
+	cdw10     uint32
+	remaining int
+	polls     int
+}
+
+// formatNvm implements Format NVM, NVMe 1.3 5.23
+func (c *Controller) formatNvm(cmd *nvme.NvmeAdminCmd) nvme.StatusCode {
+	if c.sanitize.status == sanitizeInProgress {
+		return nvme.NVME_SC_SANITIZE_IN_PROGRESS
+	}
+
+	lbaf := int(cmd.Cdw10 & 0xf)
+	ses := (cmd.Cdw10 >> 9) & 0x7
+	if lbaf >= len(c.config.LbaFormats) {
+		return nvme.NVME_SC_INVALID_FORMAT
+	}
+	if ses > 2 {
+		return nvme.NVME_SC_INVALID_FIELD
+	}
+
+	targets := c.namespaces
+	if cmd.Nsid != 0xffffffff {
+		ns := c.namespaceOf(cmd.Nsid)
+		if ns == nil {
+			return nvme.NVME_SC_INVALID_NS
+		}
+		targets = []*namespace{ns}
+	}
+
+	bytesPerNs := c.config.NamespaceSize << c.config.LbaFormats[0].DataSize
+	for _, ns := range targets {
+		ns.flbas = uint8(lbaf)
+		ns.size = bytesPerNs >> c.config.LbaFormats[lbaf].DataSize
+		ns.inUse = 0
+	}
+	return nvme.NVME_SC_SUCCESS
+}
+
+// sanitizeNvm implements Sanitize, NVMe 1.3 5.24
+func (c *Controller) sanitizeNvm(cmd *nvme.NvmeAdminCmd) nvme.StatusCode {
+	if c.sanitize.status == sanitizeInProgress {
+		return nvme.NVME_SC_SANITIZE_IN_PROGRESS
+	}
+
+	action := nvme.SanitizeCommand(cmd.Cdw10 & 0x7)
+	switch action {
+	case nvme.NVME_SANITIZE_ACT_EXIT:
+		return nvme.NVME_SC_SUCCESS
+	case nvme.NVME_SANITIZE_ACT_BLOCK_ERASE:
+		if c.config.Sanicap&0x2 == 0 {
+			return nvme.NVME_SC_INVALID_FIELD
+		}
+	case nvme.NVME_SANITIZE_ACT_OVERWRITE:
+		if c.config.Sanicap&0x4 == 0 {
+			return nvme.NVME_SC_INVALID_FIELD
+		}
+	case nvme.NVME_SANITIZE_ACT_CRYPTO_ERASE:
+		if c.config.Sanicap&0x1 == 0 {
+			return nvme.NVME_SC_INVALID_FIELD
+		}
+	default:
+		return nvme.NVME_SC_INVALID_FIELD
+	}
+
+	c.sanitize.cdw10 = cmd.Cdw10
+	c.sanitize.polls = c.config.SanitizePolls
+	c.sanitize.remaining = c.config.SanitizePolls
+	if c.sanitize.remaining > 0 {
+		c.sanitize.status = sanitizeInProgress
+	} else {
+		c.completeSanitize()
+	}
+	return nvme.NVME_SC_SUCCESS
+}
+
+func (c *Controller) completeSanitize() {
+	c.sanitize.status = sanitizeSucceeded
+	for _, ns := range c.namespaces {
+		ns.inUse = 0
+	}
+}
+
+// sanitizeLogPage advances an in progress sanitize operation on every read
+func (c *Controller) sanitizeLogPage() []byte {
+	s := &c.sanitize
+
+	progress := uint16(0xffff)
+	if s.status == sanitizeInProgress {
+		s.remaining--
+		if s.remaining <= 0 {
+			c.completeSanitize()
+		} else {
+			progress = uint16(65536 * (s.polls - s.remaining) / s.polls)
+		}
+	}
+
+	page := make([]byte, logPageSize)
+	status := s.status
+	if status == sanitizeSucceeded {
+		// one completed pass, global data erased
+		status |= 1<<3 | uint16(nvme.NVME_SANITIZE_LOG_GLOBAL_DATA_ERASED)
+	}
+	binary.LittleEndian.PutUint16(page[0:], progress)
+	binary.LittleEndian.PutUint16(page[2:], status)
+	binary.LittleEndian.PutUint32(page[4:], s.cdw10)
+	for i := 8; i < 32; i += 4 {
+		// estimated times are not reported
+		binary.LittleEndian.PutUint32(page[i:], 0xffffffff)
+	}
+	return page
+}
@@ -0,0 +1,127 @@
+package nvme_sim
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/jc-lab/go-dparm/internal"
+	"github.com/jc-lab/go-dparm/nvme"
+	"github.com/lunixbochs/struc"
+)
+
+const identifySize = 4096
+
+// Identify CNS values, NVMe 1.3 Figure 106
+const (
+	cnsNamespace           = 0x00
+	cnsController          = 0x01
+	cnsActiveNamespaceList = 0x02
+)
+
+func nvmeString(dst []byte, text string) {
+	for i := range dst {
+		dst[i] = ' '
+	}
+	copy(dst, text)
+}
+
+func (c *Controller) identify(cmd *nvme.NvmeAdminCmd, data []byte) nvme.StatusCode {
+	if len(data) < identifySize {
+		return nvme.NVME_SC_INVALID_FIELD
+	}
+
+	switch cmd.Cdw10 & 0xff {
+	case cnsNamespace:
+		ns := c.namespaceOf(cmd.Nsid)
+		if ns == nil {
+			return nvme.NVME_SC_INVALID_NS
+		}
+		copy(data, c.identifyNamespace(ns))
+	case cnsController:
+		copy(data, c.identifyController())
+	case cnsActiveNamespaceList:
+		for i := range data[:identifySize] {
+			data[i] = 0
+		}
+		offset := 0
+		for nsid := cmd.Nsid + 1; int(nsid) <= len(c.namespaces); nsid++ {
+			binary.LittleEndian.PutUint32(data[offset:], nsid)
+			offset += 4
+		}
+	default:
+		return nvme.NVME_SC_INVALID_FIELD
+	}
+
+	return nvme.NVME_SC_SUCCESS
+}
+
+func (c *Controller) identifyController() []byte {
+	id := &nvme.IdentifyController{}
+
+	id.Vid = c.config.VendorId
+	id.Ssvid = c.config.VendorId
+	nvmeString(id.Sn[:], c.config.Serial)
+	nvmeString(id.Mn[:], c.config.Model)
+	nvmeString(id.Fr[:], c.firmware.activeRevision())
+	id.Mdts = 5
+	id.Cntlid = 1
+	id.MajorVersion = 1
+	id.MinorVersion = 3
+
+	// Security Send/Receive, Format NVM, Firmware Download/Commit
+	id.Oacs = 0x0002 | 0x0004
+	if c.config.Security != nil {
+		id.Oacs |= 0x0001
+	}
+	id.Frmw = uint8(c.config.FirmwareSlots) << 1
+	if c.config.FirmwareSlot1ReadOnly {
+		id.Frmw |= 0x01
+	}
+	if c.config.FirmwareActivateWithoutReset {
+		id.Frmw |= 0x10
+	}
+	// SMART log per namespace is not supported
+	id.Lpa = 0x02
+	id.Elpe = uint8(c.config.ErrorLogEntries - 1)
+	id.Wctemp = 343
+	id.Cctemp = 353
+
+	var capacity uint64
+	for _, ns := range c.namespaces {
+		capacity += ns.size << c.config.LbaFormats[0].DataSize
+	}
+	binary.LittleEndian.PutUint64(id.Tnvmcap[:], capacity)
+	id.Sanicap = c.config.Sanicap
+
+	id.Sqes = 0x66
+	id.Cqes = 0x44
+	id.Nn = uint32(len(c.namespaces))
+	id.Vwc = 0x01
+	// format applies to all namespaces, crypto erase supported
+	id.Fna = 0x01 | 0x04
+	copy(id.Subnqn[:], "nqn.2014-08.org.nvmexpress:uuid:go-dparm-nvme-simulator")
+
+	var buf bytes.Buffer
+	if err := struc.PackWithOptions(&buf, id, internal.GetStrucOptions()); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+// identifyNamespace builds the Identify Namespace data structure, NVMe 1.3 Figure 114
+func (c *Controller) identifyNamespace(ns *namespace) []byte {
+	data := make([]byte, identifySize)
+
+	binary.LittleEndian.PutUint64(data[0:], ns.size)
+	binary.LittleEndian.PutUint64(data[8:], ns.size)
+	binary.LittleEndian.PutUint64(data[16:], ns.inUse)
+	data[25] = uint8(len(c.config.LbaFormats) - 1)
+	data[26] = ns.flbas
+
+	for i, format := range c.config.LbaFormats {
+		binary.LittleEndian.PutUint16(data[128+4*i:], format.MetadataSize)
+		data[128+4*i+2] = format.DataSize
+	}
+
+	return data
+}
@@ -0,0 +1,125 @@
+package nvme_sim
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/jc-lab/go-dparm/internal"
+	"github.com/jc-lab/go-dparm/nvme"
+	"github.com/lunixbochs/struc"
+)
+
+const (
+	errorEntrySize = 64
+	logPageSize    = 512
+)
+
+// errorEntry is one Error Information log entry, NVMe 1.3 Figure 93
+type errorEntry struct {
+	count  uint64
+	cmdId  uint16
+	status nvme.StatusCode
+	nsid   uint32
+}
+
+func (c *Controller) initSmart() {
+	c.smart.CompositeTemperature = 308
+	c.smart.AvailableSpare = 100
+	c.smart.AvailableSpareThreshold = 10
+	c.smart.PercentageUsed = 1
+	addCounter(&c.smart.PowerCycles, 1)
+	addCounter(&c.smart.PowerOnHours, 1)
+}
+
+// UpdateSmartLog lets a test change the SMART / Health Information log
+func (c *Controller) UpdateSmartLog(update func(log *nvme.SmartLogPage)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	update(&c.smart)
+}
+
+// addCounter adds to a 128-bit little endian SMART counter
+func addCounter(counter *[16]uint8, value uint64) {
+	low := binary.LittleEndian.Uint64(counter[0:])
+	sum := low + value
+	binary.LittleEndian.PutUint64(counter[0:], sum)
+	if sum < low {
+		binary.LittleEndian.PutUint64(counter[8:], binary.LittleEndian.Uint64(counter[8:])+1)
+	}
+}
+
+func (c *Controller) logError(cmd *nvme.NvmeAdminCmd, status nvme.StatusCode) {
+	c.errorCnt++
+	entry := errorEntry{
+		count:  c.errorCnt,
+		cmdId:  c.cmdId,
+		status: status,
+		nsid:   cmd.Nsid,
+	}
+	c.errorLog = append([]errorEntry{entry}, c.errorLog...)
+	if len(c.errorLog) > c.config.ErrorLogEntries {
+		c.errorLog = c.errorLog[:c.config.ErrorLogEntries]
+	}
+	addCounter(&c.smart.NumberOfErrorInformationLogEntries, 1)
+}
+
+// getLogPage implements Get Log Page, NVMe 1.3 5.14
+func (c *Controller) getLogPage(cmd *nvme.NvmeAdminCmd, data []byte) nvme.StatusCode {
+	lid := nvme.GetLogPageIdentifier(cmd.Cdw10 & 0xff)
+	numd := (cmd.Cdw10>>16 | (cmd.Cdw11&0xffff)<<16) + 1
+	offset := uint64(cmd.Cdw12) | uint64(cmd.Cdw13)<<32
+	length := uint64(numd) * 4
+	if length > uint64(len(data)) {
+		return nvme.NVME_SC_INVALID_FIELD
+	}
+
+	var page []byte
+	switch lid {
+	case nvme.NVME_GET_LOG_PAGE_ERROR_INFO:
+		page = c.errorLogPage()
+	case nvme.NVME_GET_LOG_PAGE_SMART:
+		if cmd.Nsid != 0 && cmd.Nsid != 0xffffffff {
+			return nvme.NVME_SC_INVALID_FIELD
+		}
+		page = c.smartLogPage()
+	case nvme.NVME_GET_LOG_PAGE_FIRMWARE_SLOT_INFO:
+		page = c.firmware.slotLogPage()
+	case nvme.NVME_GET_LOG_PAGE_SANITIZE_STATUS:
+		page = c.sanitizeLogPage()
+	default:
+		return nvme.NVME_SC_INVALID_LOG_PAGE
+	}
+
+	if offset&3 != 0 || offset > uint64(len(page)) {
+		return nvme.NVME_SC_INVALID_FIELD
+	}
+	out := data[:length]
+	for i := range out {
+		out[i] = 0
+	}
+	copy(out, page[offset:])
+
+	return nvme.NVME_SC_SUCCESS
+}
+
+func (c *Controller) errorLogPage() []byte {
+	page := make([]byte, errorEntrySize*c.config.ErrorLogEntries)
+	for i, entry := range c.errorLog {
+		e := page[i*errorEntrySize:]
+		binary.LittleEndian.PutUint64(e[0:], entry.count)
+		binary.LittleEndian.PutUint16(e[10:], entry.cmdId)
+		// status field, bit 0 is the phase tag
+		binary.LittleEndian.PutUint16(e[12:], uint16(entry.status)<<1)
+		binary.LittleEndian.PutUint16(e[14:], 0xffff)
+		binary.LittleEndian.PutUint32(e[24:], entry.nsid)
+	}
+	return page
+}
+
+func (c *Controller) smartLogPage() []byte {
+	var buf bytes.Buffer
+	if err := struc.PackWithOptions(&buf, &c.smart, internal.GetStrucOptions()); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,45 @@
+package tcg_sim
+
+import (
+	"bytes"
+
+	"github.com/jc-lab/go-dparm/tcg"
+)
+
+// blockSIDComId receives the Block SID command, TCG Storage Feature Set: Block SID Authentication 3.1
+const blockSIDComId uint16 = 0x0005
+
+// blockSID blocks authentication as SID until the next power cycle, the Hardware Reset bit of the Clear Events
+// field also lifts the block on a hardware reset
+func (t *TPer) blockSID(buffer []byte) {
+	t.sidBlocked = true
+	t.sidBlockedHardwareReset = len(buffer) > 0 && buffer[0]&0x01 != 0
+}
+
+// HardwareReset drops every session and applies the LockOnReset/DoneOnReset settings like a power cycle, a Block
+// SID is only lifted when the Block SID command asked for it.
+func (t *TPer) HardwareReset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.sidBlockedHardwareReset {
+		t.sidBlocked = false
+	}
+	t.reset()
+}
+
+// blockSIDFeature is the Block SID Authentication feature descriptor
+func (t *TPer) blockSIDFeature() []byte {
+	feature := featureHeader(tcg.FcBlockSID, 1, 12)
+	msid := t.adminSP.object(tcg.C_PIN_MSID).bytes(colCPinPIN)
+	if !bytes.Equal(t.adminSP.object(tcg.C_PIN_SID).bytes(colCPinPIN), msid) {
+		feature[4] |= 0x01
+	}
+	if t.sidBlocked {
+		feature[4] |= 0x02
+		if t.sidBlockedHardwareReset {
+			feature[5] |= 0x01
+		}
+	}
+	return feature
+}
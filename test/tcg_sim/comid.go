@@ -0,0 +1,110 @@
+package tcg_sim
+
+import (
+	"encoding/binary"
+)
+
+// ComID management, TCG Storage Architecture Core Specification 3.3.4.3 and 3.3.4.7
+const (
+	comIdRequestVerifyComIdValid uint32 = 0x00000001
+	comIdRequestStackReset       uint32 = 0x00000002
+
+	// ComID states of VERIFY_COMID_VALID
+	comIdStateInvalid    uint32 = 0
+	comIdStateIssued     uint32 = 2
+	comIdStateAssociated uint32 = 3
+
+	firstDynamicComId uint16 = 0x8000
+)
+
+func (t *TPer) isStaticComId(comId uint16) bool {
+	return comId >= t.config.BaseComId && int(comId) < int(t.config.BaseComId)+int(t.config.NumComIds)
+}
+
+func (t *TPer) isValidComId(comId uint16) bool {
+	return t.isStaticComId(comId) || t.dynamicComIds[comId]
+}
+
+// comIdSend accepts a VERIFY_COMID_VALID or STACK_RESET request, the response is kept for the next comIdRecv
+func (t *TPer) comIdSend(comId uint16, buffer []byte) error {
+	if len(buffer) < 8 || binary.BigEndian.Uint16(buffer[0:]) != comId {
+		return ErrInvalidComId
+	}
+	requestCode := binary.BigEndian.Uint32(buffer[4:])
+
+	var data []byte
+	switch requestCode {
+	case comIdRequestVerifyComIdValid:
+		state := comIdStateInvalid
+		if t.isValidComId(comId) {
+			state = comIdStateIssued
+			for _, sess := range t.sessions {
+				if sess.comId == comId {
+					state = comIdStateAssociated
+				}
+			}
+		}
+		// the state is followed by the allocation, expiry and current time, which the simulator leaves zero
+		data = make([]byte, 4+3*10)
+		binary.BigEndian.PutUint32(data, state)
+	case comIdRequestStackReset:
+		if !t.isValidComId(comId) {
+			return ErrInvalidComId
+		}
+		t.stackReset(comId)
+		data = make([]byte, 4)
+	default:
+		return ErrUnsupportedProtocol
+	}
+
+	resp := make([]byte, 12+len(data))
+	binary.BigEndian.PutUint16(resp[0:], comId)
+	binary.BigEndian.PutUint32(resp[4:], requestCode)
+	binary.BigEndian.PutUint16(resp[10:], uint16(len(data)))
+	copy(resp[12:], data)
+	t.comIdPending[comId] = resp
+	return nil
+}
+
+// comIdRecv returns the response of the last request on comId, ComID 0 issues a dynamic ComID with GET_COMID
+func (t *TPer) comIdRecv(comId uint16, buffer []byte) error {
+	for i := range buffer {
+		buffer[i] = 0
+	}
+	if len(buffer) < 4 {
+		return ErrInvalidComId
+	}
+
+	if comId == 0x0000 {
+		if !t.config.ComIdManagement {
+			return ErrUnsupportedProtocol
+		}
+		issued := t.nextComId
+		t.nextComId++
+		t.dynamicComIds[issued] = true
+		binary.BigEndian.PutUint16(buffer[0:], issued)
+		return nil
+	}
+
+	resp, ok := t.comIdPending[comId]
+	if !ok {
+		// no request, the response carries the ComID without data
+		binary.BigEndian.PutUint16(buffer[0:], comId)
+		return nil
+	}
+	copy(buffer, resp)
+	delete(t.comIdPending, comId)
+	return nil
+}
+
+// stackReset closes every session on comId aborting its transaction, drops its pending response and returns it to the default properties
+func (t *TPer) stackReset(comId uint16) {
+	for tsn, sess := range t.sessions {
+		if sess.comId == comId {
+			sess.abortTransaction()
+			delete(t.sessions, tsn)
+		}
+	}
+	delete(t.pending, comId)
+	t.hostProperties = nil
+}
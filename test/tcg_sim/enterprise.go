@@ -0,0 +1,246 @@
+package tcg_sim
+
+import (
+	"github.com/jc-lab/go-dparm/tcg"
+)
+
+// ACEs of the Enterprise SSC simulation, one per authority
+var (
+	aceEnterpriseEraseMaster = makeUID(tableACE, 0x00008401)
+)
+
+func aceEnterpriseBandMaster(index int) tcg.OpalUID {
+	return makeUID(tableACE, 0x00008001+uint32(index))
+}
+
+func bandUID(index int) tcg.OpalUID {
+	return makeUID(tableLocking, 0x00000001+uint32(index))
+}
+
+func bandMasterUID(index int) tcg.OpalUID {
+	return makeUID(tableAuthority, 0x00008001+uint32(index))
+}
+
+func bandKeyUID(index int) tcg.OpalUID {
+	return makeUID(tableKAES256, 0x00000001+uint32(index))
+}
+
+// enterpriseColumnNames lists the column names of the tables the Enterprise SSC addresses by name, TCG Storage Enterprise SSC 6.3
+var enterpriseColumnNames = map[uint32][]string{
+	tableCPIN:        {"UID", "Name", "CommonName", "PIN", "CharSet", "TryLimit", "Tries", "Persistence"},
+	tableLockingInfo: {"UID", "Name", "Version", "EncryptSupport", "MaxRanges"},
+	tableLocking: {"UID", "Name", "CommonName", "RangeStart", "RangeLength",
+		"ReadLockEnabled", "WriteLockEnabled", "ReadLocked", "WriteLocked", "LockOnReset", "ActiveKey"},
+}
+
+func enterpriseColumn(table uint32, name []byte) (uint64, bool) {
+	for i, column := range enterpriseColumnNames[table] {
+		if column == string(name) {
+			return uint64(i), true
+		}
+	}
+	return 0, false
+}
+
+func enterpriseColumnName(table uint32, col uint64) (string, bool) {
+	names := enterpriseColumnNames[table]
+	if col >= uint64(len(names)) {
+		return "", false
+	}
+	return names[col], true
+}
+
+func (t *TPer) buildEnterpriseAdminSP() *sp {
+	s := newSP(tcg.ADMINSP_UID)
+	s.addTable(newObjectTable(tableACE, "ACE"))
+
+	authorities := s.addTable(newObjectTable(tableAuthority, "Authority"))
+	authorities.add(anybodyUID, newAuthority(tcg.OpalUID{}, true, nil))
+	authorities.add(makersClassUID, newClassAuthority())
+	sidCred, psidCred := tcg.C_PIN_SID, cpinPSIDUID
+	authorities.add(tcg.SID_UID, newAuthority(tcg.OpalUID{}, true, &sidCred))
+	authorities.add(tcg.PSID_UID, newAuthority(tcg.OpalUID{}, true, &psidCred))
+
+	cpins := s.addTable(newObjectTable(tableCPIN, "C_PIN"))
+	cpins.add(tcg.C_PIN_SID, t.newCPin([]byte(t.config.MSID)))
+	cpins.add(tcg.C_PIN_MSID, map[uint64]any{
+		colCPinPIN:         []byte(t.config.MSID),
+		colCPinTryLimit:    uint64(0),
+		colCPinTries:       uint64(0),
+		colCPinPersistence: uint64(0),
+	})
+	cpins.add(psidCred, t.newCPin([]byte(t.config.PSID)))
+
+	s.addACE(aceAnybody, booleanExpr{anybodyUID}, nil)
+	s.addACE(aceCPinMSIDGetPIN, booleanExpr{anybodyUID}, []uint64{0, colCPinPIN})
+	s.addACE(aceCPinSIDSetPIN, booleanExpr{tcg.SID_UID}, []uint64{colCPinPIN})
+	s.addACE(aceSPSID, booleanExpr{tcg.SID_UID}, nil)
+	s.addACE(aceSPPSID, booleanExpr{tcg.PSID_UID}, nil)
+
+	s.grant(aceCPinMSIDGetPIN, tcg.EGET, tcg.C_PIN_MSID)
+	s.grant(aceAnybody, tcg.RANDOM, tcg.THISSP_UID)
+	s.grant(aceCPinSIDSetPIN, tcg.ESET, tcg.C_PIN_SID)
+	s.grant(aceSPSID, tcg.REVERT, tcg.ADMINSP_UID)
+	s.grant(aceSPPSID, tcg.REVERT, tcg.ADMINSP_UID)
+
+	return s
+}
+
+// buildEnterpriseLockingSP creates the Locking SP with BandMaster0..MaxRanges and the EraseMaster, every PIN is the MSID
+func (t *TPer) buildEnterpriseLockingSP() *sp {
+	s := newSP(tcg.ENTERPRISE_LOCKINGSP_UID)
+	s.addTable(newObjectTable(tableACE, "ACE"))
+
+	authorities := s.addTable(newObjectTable(tableAuthority, "Authority"))
+	cpins := s.addTable(newObjectTable(tableCPIN, "C_PIN"))
+	authorities.add(anybodyUID, newAuthority(tcg.OpalUID{}, true, nil))
+	eraseMasterCred := tcg.C_PIN_ENTERPRISE_ERASEMASTER
+	authorities.add(tcg.ENTERPRISE_ERASEMASTER_UID, newAuthority(tcg.OpalUID{}, true, &eraseMasterCred))
+	cpins.add(eraseMasterCred, t.newCPin([]byte(t.config.MSID)))
+
+	lockingInfo := s.addTable(newObjectTable(tableLockingInfo, "LockingInfo"))
+	lockingInfo.add(tcg.ENTERPRISE_LOCKING_INFO_TABLE, map[uint64]any{
+		uint64(tcg.LOCKINGINFO_ENCRYPT_SUPPORT): uint64(1),
+		uint64(tcg.LOCKINGINFO_MAXRANGES):       uint64(t.config.MaxRanges),
+	})
+
+	s.addACE(aceAnybody, booleanExpr{anybodyUID}, nil)
+	s.addACE(aceEnterpriseEraseMaster, booleanExpr{tcg.ENTERPRISE_ERASEMASTER_UID}, nil)
+	s.addACE(aceCPinAdminsSetPIN, booleanExpr{tcg.ENTERPRISE_ERASEMASTER_UID}, []uint64{colCPinPIN})
+	s.grant(aceAnybody, tcg.EGET, tcg.ENTERPRISE_LOCKING_INFO_TABLE)
+	s.grant(aceCPinAdminsSetPIN, tcg.ESET, eraseMasterCred)
+
+	locking := s.addTable(newObjectTable(tableLocking, "Locking"))
+	keys := s.addTable(newObjectTable(tableKAES256, "K_AES_256"))
+	for i := 0; i <= t.config.MaxRanges; i++ {
+		bandMaster := bandMasterUID(i)
+		cred := cpinOf(bandMaster)
+		authorities.add(bandMaster, newAuthority(tcg.OpalUID{}, true, &cred))
+		cpins.add(cred, t.newCPin([]byte(t.config.MSID)))
+
+		locking.add(bandUID(i), map[uint64]any{
+			uint64(tcg.LOCKING_RANGE_START):        uint64(0),
+			uint64(tcg.LOCKING_RANGE_LENGTH):       uint64(0),
+			uint64(tcg.LOCKING_READ_LOCK_ENABLED):  uint64(0),
+			uint64(tcg.LOCKING_WRITE_LOCK_ENABLED): uint64(0),
+			uint64(tcg.LOCKING_READ_LOCKED):        uint64(0),
+			uint64(tcg.LOCKING_WRITE_LOCKED):       uint64(0),
+			uint64(tcg.LOCKING_LOCK_ON_RESET):      []uint64{0},
+			uint64(tcg.LOCKING_ACTIVE_KEY):         bandKeyUID(i),
+		})
+		keys.add(bandKeyUID(i), map[uint64]any{
+			3: t.newKey(),
+			4: uint64(23), // AES-256 XTS
+		})
+
+		ace := aceEnterpriseBandMaster(i)
+		s.addACE(ace, booleanExpr{bandMaster}, nil)
+		cpinACE := makeUID(tableACE, 0x00008c01+uint32(i))
+		s.addACE(cpinACE, booleanExpr{bandMaster}, []uint64{colCPinPIN})
+		s.grant(ace, tcg.EGET, bandUID(i))
+		s.grant(ace, tcg.ESET, bandUID(i))
+		s.grant(cpinACE, tcg.ESET, cred)
+		s.grant(aceEnterpriseEraseMaster, tcg.ERASE, bandUID(i))
+	}
+
+	return s
+}
+
+// methodEGet runs Get with the column names of the Cellblock translated, the result is named by column name
+func (t *TPer) methodEGet(sess *session, call *methodCall) (list, tcg.MethodStatus) {
+	table := tableOf(call.Invoking)
+
+	var args list
+	if len(call.Args) > 0 {
+		args, _ = call.Args[0].(list)
+	}
+	cells := list{}
+	for _, item := range args {
+		if n, ok := item.(named); ok {
+			if name, ok := n.Value.([]byte); ok {
+				col, ok := enterpriseColumn(table, name)
+				if !ok {
+					return nil, tcg.INVALID_PARAMETER
+				}
+				item = named{Name: n.Name, Value: col}
+			}
+		}
+		cells = append(cells, item)
+	}
+
+	result, status := t.methodGet(sess, &methodCall{Invoking: call.Invoking, Method: call.Method, Args: list{cells}})
+	if status != tcg.SUCCESS {
+		return nil, status
+	}
+
+	values := list{}
+	for _, item := range result[0].(list) {
+		n := item.(named)
+		name, ok := enterpriseColumnName(table, n.Name.(uint64))
+		if !ok {
+			continue
+		}
+		values = append(values, named{Name: name, Value: n.Value})
+	}
+	return list{values}, tcg.SUCCESS
+}
+
+// methodESet runs Set[Where, Values] on an object, the values are named by column name
+func (t *TPer) methodESet(sess *session, call *methodCall) (list, tcg.MethodStatus) {
+	if len(call.Args) != 2 {
+		return nil, tcg.INVALID_PARAMETER
+	}
+	if where, ok := call.Args[0].(list); !ok || len(where) != 0 {
+		return nil, tcg.INVALID_PARAMETER
+	}
+	values, ok := call.Args[1].(list)
+	if !ok {
+		return nil, tcg.INVALID_PARAMETER
+	}
+
+	cells := list{}
+	for _, item := range values {
+		n, ok := item.(named)
+		if !ok {
+			return nil, tcg.INVALID_PARAMETER
+		}
+		name, ok := n.Name.([]byte)
+		if !ok {
+			return nil, tcg.INVALID_PARAMETER
+		}
+		col, ok := enterpriseColumn(tableOf(call.Invoking), name)
+		if !ok {
+			return nil, tcg.INVALID_PARAMETER
+		}
+		cells = append(cells, named{Name: col, Value: n.Value})
+	}
+
+	return t.methodSet(sess, &methodCall{Invoking: call.Invoking, Method: call.Method, Args: list{named{Name: uint64(1), Value: cells}}})
+}
+
+// methodErase replaces the key of a band, unlocks it, resets LockOnReset to power cycle and the PIN of its BandMaster
+// to the MSID, TCG Storage Enterprise SSC 6.3.4.1
+func (t *TPer) methodErase(sess *session, call *methodCall) (list, tcg.MethodStatus) {
+	if _, ok := sess.checkACL(call.Invoking, call.Method); !ok {
+		return nil, tcg.NOT_AUTHORIZED
+	}
+	band := sess.sp.object(call.Invoking)
+	if band == nil || tableOf(call.Invoking) != tableLocking {
+		return nil, tcg.INVALID_PARAMETER
+	}
+
+	if keyUID, ok := band.cols[uint64(tcg.LOCKING_ACTIVE_KEY)].(tcg.OpalUID); ok {
+		if key := sess.sp.object(keyUID); key != nil {
+			key.cols[3] = t.newKey()
+		}
+	}
+	for _, col := range []tcg.OpalToken{tcg.LOCKING_READ_LOCK_ENABLED, tcg.LOCKING_WRITE_LOCK_ENABLED, tcg.LOCKING_READ_LOCKED, tcg.LOCKING_WRITE_LOCKED} {
+		band.cols[uint64(col)] = uint64(0)
+	}
+	band.cols[uint64(tcg.LOCKING_LOCK_ON_RESET)] = []uint64{0}
+	if cpin := sess.sp.object(cpinOf(bandMasterUID(int(rowOf(call.Invoking)) - 1))); cpin != nil {
+		cpin.cols[colCPinPIN] = []byte(t.config.MSID)
+	}
+
+	return list{}, tcg.SUCCESS
+}
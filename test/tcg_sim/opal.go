@@ -0,0 +1,387 @@
+package tcg_sim
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+
+	"github.com/jc-lab/go-dparm/tcg"
+)
+
+// Authorities, TCG Storage Opal SSC 4.2.1.7 and 4.3.1.8
+var (
+	adminsClassUID = makeUID(tableAuthority, 0x00000002)
+	makersClassUID = makeUID(tableAuthority, 0x00000003)
+	usersClassUID  = makeUID(tableAuthority, 0x00030000)
+	adminSPAdmin1  = makeUID(tableAuthority, 0x00000201)
+
+	cpinPSIDUID = makeUID(tableCPIN, 0x0001ff01)
+)
+
+// ACEs, TCG Storage Opal SSC 4.2.1.6 and 4.3.1.7
+var (
+	aceAnybody               = makeUID(tableACE, 0x00000001)
+	aceAdmin                 = makeUID(tableACE, 0x00000002)
+	aceSPSID                 = makeUID(tableACE, 0x00030002)
+	aceSPPSID                = makeUID(tableACE, 0x0001ff01)
+	aceCPinSIDGetNoPIN       = makeUID(tableACE, 0x00008c02)
+	aceCPinSIDSetPIN         = makeUID(tableACE, 0x00008c03)
+	aceCPinMSIDGetPIN        = makeUID(tableACE, 0x00008c04)
+	aceCPinAdminsGetAllNoPIN = makeUID(tableACE, 0x0003a000)
+	aceCPinAdminsSetPIN      = makeUID(tableACE, 0x0003a001)
+	aceACEGetAll             = makeUID(tableACE, 0x00038000)
+	aceACESetBooleanExpr     = makeUID(tableACE, 0x00038001)
+	aceAuthoritySetEnabled   = makeUID(tableACE, 0x00039001)
+	aceLockingGlobalAdmins   = makeUID(tableACE, 0x0003f000)
+	aceLockingAdminsRange    = makeUID(tableACE, 0x0003f001)
+	aceMBRControlAdminsSet   = makeUID(tableACE, 0x0003f800)
+	aceMBRControlSetDone     = makeUID(tableACE, 0x0003f801)
+	aceDataRemovalSetSID     = makeUID(tableACE, 0x00050001)
+	// aceCPinAnybodyGetTries is a vendor unique ACE of Config.PublicTryLimits
+	aceCPinAnybodyGetTries = makeUID(tableACE, 0x00050002)
+)
+
+func lockingRangeUID(index int) tcg.OpalUID {
+	if index == 0 {
+		return makeUID(tableLocking, 0x00000001)
+	}
+	return makeUID(tableLocking, 0x00030000+uint32(index))
+}
+
+func keyUID(index int) tcg.OpalUID {
+	if index == 0 {
+		return makeUID(tableKAES256, 0x00000001)
+	}
+	return makeUID(tableKAES256, 0x00030000+uint32(index))
+}
+
+func lockingAdminUID(index int) tcg.OpalUID {
+	return makeUID(tableAuthority, 0x00010000+uint32(index))
+}
+
+func lockingUserUID(index int) tcg.OpalUID {
+	return makeUID(tableAuthority, 0x00030000+uint32(index))
+}
+
+func cpinOf(authority tcg.OpalUID) tcg.OpalUID {
+	return makeUID(tableCPIN, rowOf(authority))
+}
+
+func (t *TPer) newCPin(pin []byte) map[uint64]any {
+	return map[uint64]any{
+		colCPinPIN:         append([]byte{}, pin...),
+		colCPinTryLimit:    t.config.TryLimit,
+		colCPinTries:       uint64(0),
+		colCPinPersistence: uint64(0),
+	}
+}
+
+func newAuthority(class tcg.OpalUID, enabled bool, credential *tcg.OpalUID) map[uint64]any {
+	cols := map[uint64]any{
+		colAuthorityIsClass: uint64(0),
+		colAuthorityClass:   class,
+		colAuthorityEnabled: boolValue(enabled),
+	}
+	if credential != nil {
+		cols[colAuthorityCredential] = *credential
+	}
+	return cols
+}
+
+func newClassAuthority() map[uint64]any {
+	return map[uint64]any{
+		colAuthorityIsClass: uint64(1),
+		colAuthorityEnabled: uint64(1),
+	}
+}
+
+func boolValue(v bool) uint64 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+func (t *TPer) factoryReset() {
+	if t.config.SSC == SSCEnterprise {
+		t.adminSP = t.buildEnterpriseAdminSP()
+		t.lockingSP = t.buildEnterpriseLockingSP()
+		return
+	}
+	t.adminSP = t.buildAdminSP()
+	t.lockingSP = t.buildLockingSP()
+	t.singleUser = nil
+}
+
+func (t *TPer) isLockingSPActive() bool {
+	if t.config.SSC == SSCEnterprise {
+		// the Enterprise Locking SP is always Manufactured
+		return true
+	}
+	r := t.adminSP.object(tcg.LOCKINGSP_UID)
+	return r != nil && r.uint(colSPLifeCycle) == lifeCycleManufactured
+}
+
+func (t *TPer) activateLockingSP() {
+	t.lockingSP = t.buildLockingSP()
+	t.adminSP.object(tcg.LOCKINGSP_UID).cols[colSPLifeCycle] = lifeCycleManufactured
+
+	sidPin := t.adminSP.object(tcg.C_PIN_SID).bytes(colCPinPIN)
+	t.lockingSP.object(cpinOf(lockingAdminUID(1))).cols[colCPinPIN] = append([]byte{}, sidPin...)
+}
+
+func (t *TPer) revertLockingSP() {
+	t.lockingSP = t.buildLockingSP()
+	t.singleUser = nil
+	t.adminSP.object(tcg.LOCKINGSP_UID).cols[colSPLifeCycle] = lifeCycleManufacturedInactive
+}
+
+func (t *TPer) buildAdminSP() *sp {
+	s := newSP(tcg.ADMINSP_UID)
+	s.addTable(newObjectTable(tableACE, "ACE"))
+
+	authorities := s.addTable(newObjectTable(tableAuthority, "Authority"))
+	authorities.add(anybodyUID, newAuthority(tcg.OpalUID{}, true, nil))
+	authorities.add(adminsClassUID, newClassAuthority())
+	authorities.add(makersClassUID, newClassAuthority())
+	sidCred, admin1Cred, psidCred := tcg.C_PIN_SID, cpinOf(adminSPAdmin1), cpinPSIDUID
+	authorities.add(tcg.SID_UID, newAuthority(tcg.OpalUID{}, true, &sidCred))
+	authorities.add(adminSPAdmin1, newAuthority(adminsClassUID, false, &admin1Cred))
+	authorities.add(tcg.PSID_UID, newAuthority(tcg.OpalUID{}, true, &psidCred))
+
+	cpins := s.addTable(newObjectTable(tableCPIN, "C_PIN"))
+	sidPin := t.config.SID
+	if sidPin == "" {
+		sidPin = t.config.MSID
+	}
+	cpins.add(tcg.C_PIN_SID, t.newCPin([]byte(sidPin)))
+	cpins.add(tcg.C_PIN_MSID, map[uint64]any{
+		colCPinPIN:         []byte(t.config.MSID),
+		colCPinTryLimit:    uint64(0),
+		colCPinTries:       uint64(0),
+		colCPinPersistence: uint64(0),
+	})
+	cpins.add(admin1Cred, t.newCPin(nil))
+	cpins.add(psidCred, t.newCPin([]byte(t.config.PSID)))
+
+	sps := s.addTable(newObjectTable(tableSP, "SP"))
+	sps.add(tcg.ADMINSP_UID, map[uint64]any{
+		1:              []byte("Admin"),
+		colSPLifeCycle: lifeCycleManufactured,
+	})
+	sps.add(tcg.LOCKINGSP_UID, map[uint64]any{
+		1:              []byte("Locking"),
+		colSPLifeCycle: lifeCycleManufacturedInactive,
+	})
+
+	if t.config.SSC == SSCPyrite2 {
+		dataRemoval := s.addTable(newObjectTable(tableDataRemovalMechanism, "DataRemovalMechanism"))
+		dataRemoval.add(tcg.DATA_REMOVAL_MECHANISM, map[uint64]any{
+			uint64(tcg.ACTIVE_DATA_REMOVAL_MECHANISM): uint64(tcg.DataRemovalOverwrite),
+		})
+	}
+	s.addTableTable()
+
+	s.addACE(aceAnybody, booleanExpr{anybodyUID}, nil)
+	s.addACE(aceAdmin, booleanExpr{adminsClassUID}, nil)
+	s.addACE(aceCPinMSIDGetPIN, booleanExpr{anybodyUID}, []uint64{0, colCPinPIN})
+	s.addACE(aceCPinSIDGetNoPIN, booleanExpr{adminsClassUID, tcg.SID_UID}, []uint64{0, 1, 2, 4, 5, 6, 7})
+	s.addACE(aceCPinSIDSetPIN, booleanExpr{tcg.SID_UID}, []uint64{colCPinPIN})
+	s.addACE(aceSPSID, booleanExpr{tcg.SID_UID}, nil)
+	s.addACE(aceSPPSID, booleanExpr{tcg.PSID_UID}, nil)
+
+	s.grant(aceAnybody, tcg.AUTHENTICATE, tcg.THISSP_UID)
+	s.grant(aceAnybody, tcg.GET, makeUID(tableSP, 0), makeUID(tableTable, 0))
+	s.grant(aceAnybody, tcg.NEXT, makeUID(tableSP, 0))
+	s.grant(aceAnybody, tcg.GETACL, makeUID(tableAccessControl, 0))
+	s.grant(aceAnybody, tcg.RANDOM, tcg.THISSP_UID)
+	s.grant(aceAdmin, tcg.GET, makeUID(tableAuthority, 0))
+	s.grant(aceCPinMSIDGetPIN, tcg.GET, tcg.C_PIN_MSID)
+	s.grant(aceCPinSIDGetNoPIN, tcg.GET, tcg.C_PIN_SID)
+	s.grant(aceCPinSIDSetPIN, tcg.SET, tcg.C_PIN_SID)
+	s.grant(aceSPSID, tcg.REVERT, tcg.ADMINSP_UID, tcg.LOCKINGSP_UID)
+	s.grant(aceSPSID, tcg.ACTIVATE, tcg.LOCKINGSP_UID)
+	s.grant(aceSPPSID, tcg.REVERT, tcg.ADMINSP_UID)
+	if t.config.PublicTryLimits {
+		s.addACE(aceCPinAnybodyGetTries, booleanExpr{anybodyUID}, []uint64{colCPinTryLimit, colCPinTries, colCPinPersistence})
+		s.grant(aceCPinAnybodyGetTries, tcg.GET, tcg.C_PIN_SID)
+	}
+
+	if t.config.SSC == SSCPyrite2 {
+		s.addACE(aceDataRemovalSetSID, booleanExpr{tcg.SID_UID}, []uint64{uint64(tcg.ACTIVE_DATA_REMOVAL_MECHANISM)})
+		s.grant(aceAnybody, tcg.GET, tcg.DATA_REMOVAL_MECHANISM)
+		s.grant(aceDataRemovalSetSID, tcg.SET, tcg.DATA_REMOVAL_MECHANISM)
+	}
+
+	return s
+}
+
+func (t *TPer) buildLockingSP() *sp {
+	s := newSP(tcg.LOCKINGSP_UID)
+	s.addTable(newObjectTable(tableACE, "ACE"))
+
+	authorities := s.addTable(newObjectTable(tableAuthority, "Authority"))
+	cpins := s.addTable(newObjectTable(tableCPIN, "C_PIN"))
+	authorities.add(anybodyUID, newAuthority(tcg.OpalUID{}, true, nil))
+	authorities.add(adminsClassUID, newClassAuthority())
+	authorities.add(usersClassUID, newClassAuthority())
+	for i := 1; i <= t.config.NumLockingAdmins; i++ {
+		cred := cpinOf(lockingAdminUID(i))
+		authorities.add(lockingAdminUID(i), newAuthority(adminsClassUID, i == 1, &cred))
+		cpins.add(cred, t.newCPin(nil))
+	}
+	for i := 1; i <= t.config.NumLockingUsers; i++ {
+		cred := cpinOf(lockingUserUID(i))
+		authorities.add(lockingUserUID(i), newAuthority(usersClassUID, false, &cred))
+		cpins.add(cred, t.newCPin(nil))
+	}
+
+	lockingInfo := s.addTable(newObjectTable(tableLockingInfo, "LockingInfo"))
+	lockingInfo.add(tcg.LOCKING_INFO_TABLE, map[uint64]any{
+		uint64(tcg.LOCKINGINFO_ENCRYPT_SUPPORT): boolValue(t.config.SSC != SSCPyrite2),
+		uint64(tcg.LOCKINGINFO_MAXRANGES):       uint64(t.config.MaxRanges),
+	})
+
+	locking := s.addTable(newObjectTable(tableLocking, "Locking"))
+	keys := s.addTable(newObjectTable(tableKAES256, "K_AES_256"))
+	for i := 0; i <= t.config.MaxRanges; i++ {
+		lockOnReset := []uint64{}
+		if i == 0 {
+			lockOnReset = []uint64{0}
+		}
+		locking.add(lockingRangeUID(i), map[uint64]any{
+			uint64(tcg.LOCKING_RANGE_START):        uint64(0),
+			uint64(tcg.LOCKING_RANGE_LENGTH):       uint64(0),
+			uint64(tcg.LOCKING_READ_LOCK_ENABLED):  uint64(0),
+			uint64(tcg.LOCKING_WRITE_LOCK_ENABLED): uint64(0),
+			uint64(tcg.LOCKING_READ_LOCKED):        uint64(0),
+			uint64(tcg.LOCKING_WRITE_LOCKED):       uint64(0),
+			uint64(tcg.LOCKING_LOCK_ON_RESET):      lockOnReset,
+			uint64(tcg.LOCKING_ACTIVE_KEY):         keyUID(i),
+		})
+		keys.add(keyUID(i), map[uint64]any{
+			3: t.newKey(),
+			4: uint64(23), // AES-256 XTS
+		})
+	}
+
+	mbrControl := s.addTable(newObjectTable(tableMBRControl, "MBRControl"))
+	mbrControl.add(tcg.MBRCONTROL, map[uint64]any{
+		colMBRControlEnable: uint64(0),
+		colMBRControlDone:   uint64(0),
+		3:                   []uint64{0},
+	})
+	s.addTable(newByteTable(tableMBR, "MBR", t.config.MBRSize))
+	for i := 0; i < t.config.DataStoreTables; i++ {
+		s.addTable(newByteTable(tableDataStore+uint32(i), "DataStore", t.config.DataStoreSize))
+	}
+	s.addTableTable()
+
+	admins := booleanExpr{adminsClassUID}
+	s.addACE(aceAnybody, booleanExpr{anybodyUID}, nil)
+	s.addACE(aceAdmin, admins, nil)
+	s.addACE(aceCPinAdminsGetAllNoPIN, admins, []uint64{0, 1, 2, 4, 5, 6, 7})
+	s.addACE(aceCPinAdminsSetPIN, admins, []uint64{colCPinPIN})
+	s.addACE(aceLockingGlobalAdmins, admins, []uint64{5, 6, 7, 8, 9})
+	s.addACE(aceLockingAdminsRange, admins, []uint64{3, 4, 5, 6, 7, 8, 9})
+	s.addACE(aceMBRControlAdminsSet, admins, []uint64{1, 2, 3})
+	s.addACE(aceMBRControlSetDone, admins, []uint64{2, 3})
+	s.addACE(aceACEGetAll, admins, nil)
+	s.addACE(aceACESetBooleanExpr, admins, []uint64{colACEBooleanExpr})
+	s.addACE(aceAuthoritySetEnabled, admins, []uint64{colAuthorityEnabled})
+
+	s.grant(aceAnybody, tcg.AUTHENTICATE, tcg.THISSP_UID)
+	s.grant(aceAnybody, tcg.GETACL, makeUID(tableAccessControl, 0))
+	s.grant(aceAnybody, tcg.RANDOM, tcg.THISSP_UID)
+	s.grant(aceAnybody, tcg.GET, tcg.LOCKING_INFO_TABLE, tcg.MBRCONTROL, makeUID(tableMBR, 0), makeUID(tableTable, 0))
+	for i := 0; i < t.config.DataStoreTables; i++ {
+		s.grant(aceAdmin, tcg.GET, makeUID(tableDataStore+uint32(i), 0))
+		s.grant(aceAdmin, tcg.SET, makeUID(tableDataStore+uint32(i), 0))
+	}
+	s.grant(aceAdmin, tcg.REVERTSP, tcg.THISSP_UID)
+	if t.config.SingleUser {
+		s.grant(aceAdmin, tcg.REACTIVATE, tcg.THISSP_UID)
+	}
+	s.grant(aceAdmin, tcg.SET, makeUID(tableMBR, 0))
+	s.grant(aceAdmin, tcg.GET, makeUID(tableAuthority, 0), makeUID(tableLocking, 0))
+	s.grant(aceAdmin, tcg.NEXT, makeUID(tableAuthority, 0), makeUID(tableCPIN, 0), makeUID(tableLocking, 0), makeUID(tableACE, 0))
+	s.grant(aceACEGetAll, tcg.GET, makeUID(tableACE, 0))
+	for i := 2; i <= t.config.NumLockingAdmins; i++ {
+		s.grant(aceAuthoritySetEnabled, tcg.SET, lockingAdminUID(i))
+	}
+	for i := 1; i <= t.config.NumLockingUsers; i++ {
+		s.grant(aceAuthoritySetEnabled, tcg.SET, lockingUserUID(i))
+	}
+	s.grant(aceCPinAdminsGetAllNoPIN, tcg.GET, makeUID(tableCPIN, 0))
+	s.grant(aceCPinAdminsSetPIN, tcg.SET, makeUID(tableCPIN, 0))
+	if t.config.PublicTryLimits {
+		s.addACE(aceCPinAnybodyGetTries, booleanExpr{anybodyUID}, []uint64{colCPinTryLimit, colCPinTries, colCPinPersistence})
+		s.grant(aceCPinAnybodyGetTries, tcg.GET, makeUID(tableCPIN, 0))
+	}
+	s.grant(aceLockingGlobalAdmins, tcg.SET, lockingRangeUID(0))
+	for i := 0; i <= t.config.MaxRanges; i++ {
+		rdLocked := makeUID(tableACE, 0x0003e000+uint32(i))
+		wrLocked := makeUID(tableACE, 0x0003e800+uint32(i))
+		s.addACE(rdLocked, admins, []uint64{uint64(tcg.LOCKING_READ_LOCKED)})
+		s.addACE(wrLocked, admins, []uint64{uint64(tcg.LOCKING_WRITE_LOCKED)})
+		s.grant(rdLocked, tcg.SET, lockingRangeUID(i))
+		s.grant(wrLocked, tcg.SET, lockingRangeUID(i))
+		s.grant(aceACESetBooleanExpr, tcg.SET, rdLocked, wrLocked)
+		genKey := makeUID(tableACE, 0x0003b800+uint32(i))
+		s.addACE(genKey, admins, nil)
+		s.grant(genKey, tcg.GENKEY, keyUID(i))
+		if i > 0 {
+			s.grant(aceLockingAdminsRange, tcg.SET, lockingRangeUID(i))
+		}
+	}
+	s.grant(aceMBRControlAdminsSet, tcg.SET, tcg.MBRCONTROL)
+	s.grant(aceMBRControlSetDone, tcg.SET, tcg.MBRCONTROL)
+
+	return s
+}
+
+// methodGenKey replaces the key of a K_AES object, the locking state of its range is kept
+func (t *TPer) methodGenKey(sess *session, call *methodCall) (list, tcg.MethodStatus) {
+	if !sess.write {
+		return nil, tcg.NOT_AUTHORIZED
+	}
+	if _, ok := sess.checkACL(call.Invoking, call.Method); !ok {
+		return nil, tcg.NOT_AUTHORIZED
+	}
+	key := sess.sp.object(call.Invoking)
+	if key == nil || tableOf(call.Invoking) != tableKAES256 {
+		return nil, tcg.INVALID_PARAMETER
+	}
+
+	key.cols[3] = t.newKey()
+	return list{}, tcg.SUCCESS
+}
+
+// maxRandomCount is the largest Count of Random, TCG Storage Opal SSC 5.1.4 requires 32
+const maxRandomCount = 32
+
+// methodRandom returns Count bytes of the random number generator, up to maxRandomCount bytes per call
+func (t *TPer) methodRandom(sess *session, call *methodCall) (list, tcg.MethodStatus) {
+	if call.Invoking != tcg.THISSP_UID || len(call.Args) < 1 {
+		return nil, tcg.INVALID_PARAMETER
+	}
+	if _, ok := sess.checkACL(call.Invoking, call.Method); !ok {
+		return nil, tcg.NOT_AUTHORIZED
+	}
+	count, ok := asUint(call.Args[0])
+	if !ok || count > maxRandomCount {
+		return nil, tcg.INVALID_PARAMETER
+	}
+
+	data := make([]byte, count)
+	if _, err := rand.Read(data); err != nil {
+		return nil, tcg.TPER_MALFUNCTION
+	}
+	return list{data}, tcg.SUCCESS
+}
+
+func (t *TPer) newKey() []byte {
+	t.keySeq++
+	key := make([]byte, 32)
+	binary.BigEndian.PutUint64(key, t.keySeq)
+	return key
+}
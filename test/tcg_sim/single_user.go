@@ -0,0 +1,180 @@
+package tcg_sim
+
+import (
+	"encoding/binary"
+
+	"github.com/jc-lab/go-dparm/tcg"
+)
+
+// singleUserMode is the Single User Mode state of the Locking SP, TCG Storage Opal Feature Set: Single User Mode
+type singleUserMode struct {
+	ranges map[int]bool
+	all    bool
+	// policy is the RangeStartRangeLengthPolicy, 1 gives RangeStart and RangeLength to the Admins
+	policy uint64
+}
+
+// ACEs of the ranges in Single User Mode, the simulator numbers them by range
+func aceSingleUserRange(index int) tcg.OpalUID {
+	return makeUID(tableACE, 0x0003f100+uint32(index))
+}
+
+func aceSingleUserAdminsBounds(index int) tcg.OpalUID {
+	return makeUID(tableACE, 0x0003f200+uint32(index))
+}
+
+func aceSingleUserCPin(index int) tcg.OpalUID {
+	return makeUID(tableACE, 0x0003a100+uint32(index))
+}
+
+func (t *TPer) rangeIndexOf(uid tcg.OpalUID) (int, bool) {
+	for i := 0; i <= t.config.MaxRanges; i++ {
+		if lockingRangeUID(i) == uid {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// parseSingleUserMode reads the SingleUserSelectionList and RangeStartRangeLengthPolicy of Activate or Reactivate,
+// a nil mode means no range is in Single User Mode.
+func (t *TPer) parseSingleUserMode(args list) (*singleUserMode, tcg.MethodStatus) {
+	v, ok := args.namedArg(tcg.SUM_SELECTION_LIST, "")
+	if !ok {
+		return nil, tcg.SUCCESS
+	}
+	if !t.config.SingleUser {
+		return nil, tcg.INVALID_PARAMETER
+	}
+
+	mode := &singleUserMode{ranges: make(map[int]bool)}
+	if uid, ok := asUID(v); ok {
+		// the Locking table selects every range
+		if uid != makeUID(tableLocking, 0) {
+			return nil, tcg.INVALID_PARAMETER
+		}
+		mode.all = true
+		for i := 0; i <= t.config.MaxRanges; i++ {
+			mode.ranges[i] = true
+		}
+	} else if items, ok := v.(list); ok {
+		for _, item := range items {
+			uid, ok := asUID(item)
+			if !ok {
+				return nil, tcg.INVALID_PARAMETER
+			}
+			index, ok := t.rangeIndexOf(uid)
+			if !ok {
+				return nil, tcg.INVALID_PARAMETER
+			}
+			mode.ranges[index] = true
+		}
+	} else {
+		return nil, tcg.INVALID_PARAMETER
+	}
+
+	for index := range mode.ranges {
+		if index+1 > t.config.NumLockingUsers {
+			return nil, tcg.INVALID_PARAMETER
+		}
+	}
+
+	if v, ok := args.namedArg(tcg.SUM_RANGE_START_LENGTH_POLICY, ""); ok {
+		if mode.policy, ok = asUint(v); !ok || mode.policy > 1 {
+			return nil, tcg.INVALID_PARAMETER
+		}
+	}
+	return mode, tcg.SUCCESS
+}
+
+// applySingleUserMode gives every range in Single User Mode to its owner, UserN+1 owns range N, and sets the PIN of
+// the owners. The Admins lose the locks of the ranges.
+func (t *TPer) applySingleUserMode(mode *singleUserMode, pin []byte) {
+	t.singleUser = mode
+	if mode == nil {
+		return
+	}
+
+	s := t.lockingSP
+	for index := range mode.ranges {
+		user := lockingUserUID(index + 1)
+		owner := booleanExpr{user}
+		s.object(user).cols[colAuthorityEnabled] = uint64(1)
+		s.object(cpinOf(user)).cols[colCPinPIN] = append([]byte{}, pin...)
+
+		s.object(makeUID(tableACE, 0x0003e000+uint32(index))).cols[colACEBooleanExpr] = owner
+		s.object(makeUID(tableACE, 0x0003e800+uint32(index))).cols[colACEBooleanExpr] = owner
+		s.object(makeUID(tableACE, 0x0003b800+uint32(index))).cols[colACEBooleanExpr] = owner
+
+		rangeUID := lockingRangeUID(index)
+		columns := []uint64{
+			uint64(tcg.LOCKING_READ_LOCK_ENABLED), uint64(tcg.LOCKING_WRITE_LOCK_ENABLED), uint64(tcg.LOCKING_LOCK_ON_RESET),
+		}
+		if index == 0 {
+			s.revoke(aceLockingGlobalAdmins, tcg.SET, rangeUID)
+		} else {
+			s.revoke(aceLockingAdminsRange, tcg.SET, rangeUID)
+			bounds := []uint64{uint64(tcg.LOCKING_RANGE_START), uint64(tcg.LOCKING_RANGE_LENGTH)}
+			if mode.policy == 0 {
+				columns = append(columns, bounds...)
+			} else {
+				s.addACE(aceSingleUserAdminsBounds(index), booleanExpr{adminsClassUID}, bounds)
+				s.grant(aceSingleUserAdminsBounds(index), tcg.SET, rangeUID)
+			}
+		}
+		s.addACE(aceSingleUserRange(index), owner, columns)
+		s.grant(aceSingleUserRange(index), tcg.SET, rangeUID)
+
+		s.addACE(aceSingleUserCPin(index), owner, []uint64{colCPinPIN})
+		s.grant(aceSingleUserCPin(index), tcg.SET, cpinOf(user))
+	}
+}
+
+// methodReactivate rebuilds the Locking SP with new ranges in Single User Mode, the PIN of Admin1 is kept unless
+// Admin1PIN is given and the owners of the ranges get the PIN of Admin1.
+func (t *TPer) methodReactivate(sess *session, call *methodCall) (list, tcg.MethodStatus) {
+	if call.Invoking != tcg.THISSP_UID || sess.sp != t.lockingSP || !t.config.SingleUser {
+		return nil, tcg.INVALID_PARAMETER
+	}
+	if _, ok := sess.checkACL(call.Invoking, call.Method); !ok {
+		return nil, tcg.NOT_AUTHORIZED
+	}
+
+	mode, status := t.parseSingleUserMode(call.Args)
+	if status != tcg.SUCCESS {
+		return nil, status
+	}
+	pin := t.lockingSP.object(cpinOf(lockingAdminUID(1))).bytes(colCPinPIN)
+	if v, ok := call.Args.namedArg(tcg.SUM_REACTIVATE_ADMIN1_PIN, ""); ok {
+		if pin, ok = v.([]byte); !ok {
+			return nil, tcg.INVALID_PARAMETER
+		}
+	}
+
+	t.lockingSP = t.buildLockingSP()
+	t.lockingSP.object(cpinOf(lockingAdminUID(1))).cols[colCPinPIN] = append([]byte{}, pin...)
+	t.applySingleUserMode(mode, pin)
+
+	// the session is aborted once the response has been sent
+	delete(t.sessions, sess.tsn)
+
+	return list{}, tcg.SUCCESS
+}
+
+// singleUserFeature is the Single User Mode feature descriptor
+func (t *TPer) singleUserFeature() []byte {
+	feature := featureHeader(tcg.FcSingleUser, 1, 12)
+	if mode := t.singleUser; mode != nil {
+		binary.BigEndian.PutUint32(feature[4:], uint32(len(mode.ranges)))
+		if len(mode.ranges) > 0 {
+			feature[8] |= 0x01
+		}
+		if mode.all {
+			feature[8] |= 0x02
+		}
+		if mode.policy == 1 {
+			feature[8] |= 0x04
+		}
+	}
+	return feature
+}
@@ -0,0 +1,849 @@
+package tcg_sim
+
+import (
+	"bytes"
+
+	"github.com/jc-lab/go-dparm/tcg"
+)
+
+var (
+	methodSyncSession = tcg.OpalMethod{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff, 0x03}
+
+	anybodyUID = makeUID(tableAuthority, 0x00000001)
+)
+
+// Cellblock names, TCG Storage Architecture Core Spec 5.1.4.2.3
+const (
+	cellTable       uint64 = 0x00
+	cellStartRow    uint64 = 0x01
+	cellEndRow      uint64 = 0x02
+	cellStartColumn uint64 = 0x03
+	cellEndColumn   uint64 = 0x04
+)
+
+// booleanExpr is the BooleanExpr column of an ACE, authorities are OR'ed.
+type booleanExpr []tcg.OpalUID
+
+// postfixExpr is a BooleanExpr with AND or NOT operators, the terms are tcg.OpalUID authorities and uint64 boolean_ACE
+// operators in postfix order.
+type postfixExpr []any
+
+// Values of the boolean_ACE terms
+const (
+	booleanAnd uint64 = 0
+	booleanOr  uint64 = 1
+	booleanNot uint64 = 2
+)
+
+type sp struct {
+	uid    tcg.OpalUID
+	tables map[uint32]*table
+	acl    map[aclKey][]tcg.OpalUID
+}
+
+func newSP(uid tcg.OpalUID) *sp {
+	return &sp{
+		uid:    uid,
+		tables: make(map[uint32]*table),
+		acl:    make(map[aclKey][]tcg.OpalUID),
+	}
+}
+
+func (s *sp) addTable(t *table) *table {
+	s.tables[t.uid] = t
+	return t
+}
+
+func (s *sp) object(uid tcg.OpalUID) *row {
+	t, ok := s.tables[tableOf(uid)]
+	if !ok || t.isBytes {
+		return nil
+	}
+	return t.rows[uid]
+}
+
+// addTableTable describes the tables of the SP in its Table table and the columns of the Table table in its Column
+// table, the Rows of a byte table are its size.
+func (s *sp) addTableTable() {
+	columns := s.addTable(newObjectTable(tableColumn, "Column"))
+	tables := s.addTable(newObjectTable(tableTable, "Table"))
+	for uid, t := range s.tables {
+		cols := map[uint64]any{
+			1:            []byte(t.name),
+			colTableKind: uint64(1), // object table
+		}
+		if t.isBytes {
+			cols[colTableKind] = uint64(2) // byte table
+			cols[colTableRows] = uint64(len(t.data))
+		}
+		tables.add(makeUID(tableTable, uid), cols)
+	}
+
+	// the simulator only describes the columns of the Table table it fills
+	for col, name := range map[uint64]string{0: "UID", 1: "Name", colTableKind: "Kind", colTableRows: "Rows"} {
+		columns.add(makeUID(tableColumn, 0x00010000+uint32(col)), map[uint64]any{
+			1: []byte(name),
+		})
+	}
+
+	s.grant(aceAnybody, tcg.NEXT, makeUID(tableTable, 0), makeUID(tableColumn, 0))
+	s.grant(aceAnybody, tcg.GET, makeUID(tableColumn, 0))
+}
+
+// addACE creates an ACE row, a nil columns list grants every column.
+func (s *sp) addACE(ace tcg.OpalUID, authorities booleanExpr, columns []uint64) {
+	cols := map[uint64]any{
+		colACEBooleanExpr: authorities,
+	}
+	if columns != nil {
+		cols[colACEColumns] = columns
+	}
+	s.tables[tableACE].add(ace, cols)
+}
+
+// revoke removes the ACE from the AccessControl entry of every (invoking, method) pair.
+func (s *sp) revoke(ace tcg.OpalUID, method tcg.OpalMethod, invoking ...tcg.OpalUID) {
+	for _, uid := range invoking {
+		key := aclKey{invoking: uid, method: method}
+		aces := s.acl[key][:0]
+		for _, granted := range s.acl[key] {
+			if granted != ace {
+				aces = append(aces, granted)
+			}
+		}
+		s.acl[key] = aces
+	}
+}
+
+// grant adds the ACE to the AccessControl entry of every (invoking, method) pair.
+func (s *sp) grant(ace tcg.OpalUID, method tcg.OpalMethod, invoking ...tcg.OpalUID) {
+	for _, uid := range invoking {
+		key := aclKey{invoking: uid, method: method}
+		s.acl[key] = append(s.acl[key], ace)
+	}
+}
+
+type session struct {
+	comId       uint16
+	tsn, hsn    uint32
+	sp          *sp
+	write       bool
+	authorities map[tcg.OpalUID]bool
+	// transaction is the SP at the start of the transaction of the session, nil outside of a transaction
+	transaction *sp
+}
+
+func (s *session) isAuthorized(authority tcg.OpalUID) bool {
+	if authority == anybodyUID || s.authorities[authority] {
+		return true
+	}
+
+	auth := s.sp.object(authority)
+	if auth == nil || !auth.bool(colAuthorityIsClass) {
+		return false
+	}
+	for uid := range s.authorities {
+		member := s.sp.object(uid)
+		if member == nil {
+			continue
+		}
+		if class, ok := member.cols[colAuthorityClass].(tcg.OpalUID); ok && class == authority {
+			return true
+		}
+	}
+	return false
+}
+
+// checkACL returns the columns granted to the session, nil with ok=true means every column.
+// aces returns the ACEs of the AccessControl entry of an object followed by the ACEs granted on its whole table.
+func (s *sp) aces(invoking tcg.OpalUID, method tcg.OpalMethod) []tcg.OpalUID {
+	aces := append([]tcg.OpalUID{}, s.acl[aclKey{invoking: invoking, method: method}]...)
+	if rowOf(invoking) != 0 {
+		aces = append(aces, s.acl[aclKey{invoking: makeUID(tableOf(invoking), 0), method: method}]...)
+	}
+	return aces
+}
+
+// evalBooleanExpr returns whether the authorities of the session satisfy the BooleanExpr of an ACE
+func (s *session) evalBooleanExpr(expr any) bool {
+	switch x := expr.(type) {
+	case booleanExpr:
+		for _, authority := range x {
+			if s.isAuthorized(authority) {
+				return true
+			}
+		}
+	case postfixExpr:
+		var stack []bool
+		for _, term := range x {
+			switch v := term.(type) {
+			case tcg.OpalUID:
+				stack = append(stack, s.isAuthorized(v))
+			case uint64:
+				if v == booleanNot {
+					stack[len(stack)-1] = !stack[len(stack)-1]
+					continue
+				}
+				left, right := stack[len(stack)-2], stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				if v == booleanAnd {
+					stack[len(stack)-1] = left && right
+				} else {
+					stack[len(stack)-1] = left || right
+				}
+			}
+		}
+		return len(stack) == 1 && stack[0]
+	}
+	return false
+}
+
+func (s *session) checkACL(invoking tcg.OpalUID, method tcg.OpalMethod) (columns map[uint64]bool, ok bool) {
+	for _, aceUID := range s.sp.aces(invoking, method) {
+		ace := s.sp.object(aceUID)
+		if ace == nil {
+			continue
+		}
+		if !s.evalBooleanExpr(ace.cols[colACEBooleanExpr]) {
+			continue
+		}
+
+		cols, restricted := ace.cols[colACEColumns].([]uint64)
+		if !restricted {
+			return nil, true
+		}
+		if columns == nil {
+			columns = make(map[uint64]bool)
+		}
+		for _, col := range cols {
+			columns[col] = true
+		}
+		ok = true
+	}
+
+	return columns, ok
+}
+
+func (t *TPer) sp(uid tcg.OpalUID) *sp {
+	switch uid {
+	case tcg.ADMINSP_UID:
+		return t.adminSP
+	case tcg.LOCKINGSP_UID:
+		if t.config.SSC != SSCEnterprise {
+			return t.lockingSP
+		}
+	case tcg.ENTERPRISE_LOCKINGSP_UID:
+		if t.config.SSC == SSCEnterprise {
+			return t.lockingSP
+		}
+	}
+	return nil
+}
+
+func (t *TPer) sessionManagerCall(comId uint16, call *methodCall) []byte {
+	switch call.Method {
+	case tcg.STARTSESSION:
+		return t.startSession(comId, call)
+	case tcg.PROPERTIES:
+		return t.properties(call)
+	}
+	return failure(tcg.INVALID_PARAMETER)
+}
+
+// tperProperties are the communication properties of the TPer, TCG Storage Architecture Core Specification 5.2.2.1
+func (t *TPer) tperProperties() []named {
+	maxComPacketSize := uint64(t.config.MaxComPacketSize)
+	return []named{
+		{[]byte("MaxComPacketSize"), maxComPacketSize},
+		{[]byte("MaxResponseComPacketSize"), maxComPacketSize},
+		{[]byte("MaxPacketSize"), maxComPacketSize - comPacketHeaderSize},
+		{[]byte("MaxIndTokenSize"), maxComPacketSize - headerSize},
+		{[]byte("MaxPackets"), uint64(1)},
+		{[]byte("MaxSubpackets"), uint64(1)},
+		{[]byte("MaxMethods"), uint64(1)},
+		{[]byte("MaxSessions"), uint64(t.config.MaxSessions)},
+		{[]byte("MaxAuthentications"), uint64(2)},
+		{[]byte("MaxTransactionLimit"), uint64(1)},
+	}
+}
+
+// properties records the host properties and answers with the TPer properties and the accepted host properties
+func (t *TPer) properties(call *methodCall) []byte {
+	t.hostProperties = make(map[string]uint64)
+	if v, ok := call.Args.namedArg(0, "HostProperties"); ok {
+		hostList, ok := v.(list)
+		if !ok {
+			return failure(tcg.INVALID_PARAMETER)
+		}
+		for _, item := range hostList {
+			n, ok := item.(named)
+			if !ok {
+				return failure(tcg.INVALID_PARAMETER)
+			}
+			name, ok := n.Name.([]byte)
+			value, isUint := asUint(n.Value)
+			if !ok || !isUint {
+				return failure(tcg.INVALID_PARAMETER)
+			}
+			t.hostProperties[string(name)] = value
+		}
+	}
+
+	tper, accepted := list{}, list{}
+	for _, property := range t.tperProperties() {
+		tper = append(tper, property)
+
+		limit := property.Value.(uint64)
+		if value, ok := t.hostProperties[string(property.Name.([]byte))]; ok {
+			if value > limit {
+				value = limit
+			}
+			accepted = append(accepted, named{property.Name, value})
+		}
+	}
+
+	enc := &encoder{}
+	enc.token(tcg.CALL)
+	enc.value(tcg.SMUID_UID)
+	enc.value(tcg.PROPERTIES)
+	enc.value(list{tper, named{uint64(0), accepted}})
+	enc.status(tcg.SUCCESS)
+
+	return enc.buf
+}
+
+func (t *TPer) startSession(comId uint16, call *methodCall) []byte {
+	if len(call.Args) < 3 {
+		return failure(tcg.INVALID_PARAMETER)
+	}
+	hsn, ok := asUint(call.Args[0])
+	if !ok {
+		return failure(tcg.INVALID_PARAMETER)
+	}
+	spUID, ok := asUID(call.Args[1])
+	if !ok {
+		return failure(tcg.INVALID_PARAMETER)
+	}
+	write, ok := asUint(call.Args[2])
+	if !ok {
+		return failure(tcg.INVALID_PARAMETER)
+	}
+
+	target := t.sp(spUID)
+	if target == nil {
+		return failure(tcg.INVALID_PARAMETER)
+	}
+	if spUID == tcg.LOCKINGSP_UID && !t.isLockingSPActive() {
+		return failure(tcg.INVALID_PARAMETER)
+	}
+	if len(t.sessions) >= t.config.MaxSessions {
+		return failure(tcg.SP_BUSY)
+	}
+
+	sess := &session{
+		comId:       comId,
+		hsn:         uint32(hsn),
+		sp:          target,
+		write:       write != 0,
+		authorities: make(map[tcg.OpalUID]bool),
+	}
+
+	if v, ok := call.Args[3:].namedArg(3, "HostSigningAuthority"); ok {
+		authority, ok := asUID(v)
+		if !ok {
+			return failure(tcg.INVALID_PARAMETER)
+		}
+		challenge, _ := call.Args[3:].namedArg(0, "HostChallenge")
+		proof, _ := challenge.([]byte)
+		if status := t.authenticate(sess, authority, proof); status != tcg.SUCCESS {
+			return failure(status)
+		}
+		sess.authorities[authority] = true
+	}
+
+	t.nextTsn++
+	sess.tsn = t.nextTsn
+	t.sessions[sess.tsn] = sess
+
+	enc := &encoder{}
+	enc.token(tcg.CALL)
+	enc.value(tcg.SMUID_UID)
+	enc.value(methodSyncSession)
+	enc.value(list{uint64(sess.hsn), uint64(sess.tsn)})
+	enc.status(tcg.SUCCESS)
+
+	return enc.buf
+}
+
+// authenticate verifies proof against the authority's credential and maintains the C_PIN Tries counter.
+func (t *TPer) authenticate(sess *session, authority tcg.OpalUID, proof []byte) tcg.MethodStatus {
+	if t.sidBlocked && authority == tcg.SID_UID {
+		return tcg.NOT_AUTHORIZED
+	}
+
+	auth := sess.sp.object(authority)
+	if auth == nil || auth.bool(colAuthorityIsClass) || !auth.bool(colAuthorityEnabled) {
+		return tcg.NOT_AUTHORIZED
+	}
+
+	credUID, ok := auth.cols[colAuthorityCredential].(tcg.OpalUID)
+	if !ok {
+		// authorities without credential, like Anybody
+		return tcg.SUCCESS
+	}
+	cred := sess.sp.object(credUID)
+	if cred == nil {
+		return tcg.NOT_AUTHORIZED
+	}
+
+	limit := cred.uint(colCPinTryLimit)
+	tries := cred.uint(colCPinTries)
+	if limit != 0 && tries >= limit {
+		return tcg.AUTHORITY_LOCKED_OUT
+	}
+
+	if !bytes.Equal(cred.bytes(colCPinPIN), proof) {
+		cred.cols[colCPinTries] = tries + 1
+		return tcg.NOT_AUTHORIZED
+	}
+
+	cred.cols[colCPinTries] = uint64(0)
+	return tcg.SUCCESS
+}
+
+func (t *TPer) sessionCall(sess *session, call *methodCall) (list, tcg.MethodStatus) {
+	invoking := call.Invoking
+
+	switch call.Method {
+	case tcg.AUTHENTICATE, tcg.EAUTHENTICATE:
+		return t.methodAuthenticate(sess, call)
+	case tcg.GET:
+		return t.methodGet(sess, call)
+	case tcg.SET:
+		return t.methodSet(sess, call)
+	case tcg.NEXT:
+		return t.methodNext(sess, call)
+	case tcg.GETACL:
+		return t.methodGetACL(sess, call)
+	case tcg.EGET:
+		return t.methodEGet(sess, call)
+	case tcg.ESET:
+		return t.methodESet(sess, call)
+	case tcg.ERASE:
+		return t.methodErase(sess, call)
+	case tcg.GENKEY:
+		return t.methodGenKey(sess, call)
+	case tcg.RANDOM:
+		return t.methodRandom(sess, call)
+	case tcg.REVERT:
+		if _, ok := sess.checkACL(invoking, call.Method); !ok {
+			return nil, tcg.NOT_AUTHORIZED
+		}
+		return t.methodRevert(sess, invoking)
+	case tcg.ACTIVATE:
+		if invoking != tcg.LOCKINGSP_UID || sess.sp != t.adminSP {
+			return nil, tcg.INVALID_PARAMETER
+		}
+		if _, ok := sess.checkACL(invoking, call.Method); !ok {
+			return nil, tcg.NOT_AUTHORIZED
+		}
+		mode, status := t.parseSingleUserMode(call.Args)
+		if status != tcg.SUCCESS {
+			return nil, status
+		}
+		// activating an SP that is already Manufactured has no effect
+		if !t.isLockingSPActive() {
+			t.activateLockingSP()
+			t.applySingleUserMode(mode, t.adminSP.object(tcg.C_PIN_SID).bytes(colCPinPIN))
+		}
+		return list{}, tcg.SUCCESS
+	case tcg.REACTIVATE:
+		return t.methodReactivate(sess, call)
+	case tcg.REVERTSP:
+		if invoking != tcg.THISSP_UID || sess.sp != t.lockingSP {
+			return nil, tcg.INVALID_PARAMETER
+		}
+		if _, ok := sess.checkACL(invoking, call.Method); !ok {
+			return nil, tcg.NOT_AUTHORIZED
+		}
+		return t.methodRevert(sess, tcg.LOCKINGSP_UID)
+	}
+
+	return nil, tcg.NOT_AUTHORIZED
+}
+
+func (t *TPer) methodAuthenticate(sess *session, call *methodCall) (list, tcg.MethodStatus) {
+	if call.Invoking != tcg.THISSP_UID || len(call.Args) < 1 {
+		return nil, tcg.INVALID_PARAMETER
+	}
+	authority, ok := asUID(call.Args[0])
+	if !ok {
+		return nil, tcg.INVALID_PARAMETER
+	}
+
+	v, _ := call.Args[1:].namedArg(0, "Challenge")
+	proof, _ := v.([]byte)
+
+	switch t.authenticate(sess, authority, proof) {
+	case tcg.SUCCESS:
+		sess.authorities[authority] = true
+		return list{uint64(1)}, tcg.SUCCESS
+	case tcg.AUTHORITY_LOCKED_OUT:
+		return nil, tcg.AUTHORITY_LOCKED_OUT
+	}
+
+	return list{uint64(0)}, tcg.SUCCESS
+}
+
+// cellBlock reads the optional Cellblock of a Get, or the Where parameter of a Set on a byte table.
+func cellBlock(args list) (startRow, endRow, startCol, endCol uint64, hasRows bool) {
+	startCol, endCol = 0, ^uint64(0)
+	startRow, endRow = 0, ^uint64(0)
+
+	if v, ok := args.namedArg(cellStartRow, "startRow"); ok {
+		startRow, _ = asUint(v)
+		hasRows = true
+	}
+	if v, ok := args.namedArg(cellEndRow, "endRow"); ok {
+		endRow, _ = asUint(v)
+		hasRows = true
+	}
+	if v, ok := args.namedArg(cellStartColumn, "startColumn"); ok {
+		startCol, _ = asUint(v)
+	}
+	if v, ok := args.namedArg(cellEndColumn, "endColumn"); ok {
+		endCol, _ = asUint(v)
+	}
+	return
+}
+
+func (t *TPer) methodGet(sess *session, call *methodCall) (list, tcg.MethodStatus) {
+	var args list
+	if len(call.Args) > 0 {
+		args, _ = call.Args[0].(list)
+	}
+	startRow, endRow, startCol, endCol, _ := cellBlock(args)
+
+	allowed, ok := sess.checkACL(call.Invoking, call.Method)
+	if !ok {
+		return nil, tcg.NOT_AUTHORIZED
+	}
+
+	if tbl, ok := sess.sp.tables[tableOf(call.Invoking)]; ok && tbl.isBytes && rowOf(call.Invoking) == 0 {
+		if endRow == ^uint64(0) {
+			endRow = uint64(len(tbl.data)) - 1
+		}
+		if startRow > endRow || endRow >= uint64(len(tbl.data)) {
+			return nil, tcg.INVALID_PARAMETER
+		}
+		return list{append([]byte{}, tbl.data[startRow:endRow+1]...)}, tcg.SUCCESS
+	}
+
+	obj := sess.sp.object(call.Invoking)
+	if obj == nil {
+		return nil, tcg.INVALID_PARAMETER
+	}
+
+	cols := make([]uint64, 0, len(obj.cols))
+	for col := range obj.cols {
+		cols = append(cols, col)
+	}
+	sortUint64(cols)
+
+	values := list{}
+	for _, col := range cols {
+		if col < startCol || col > endCol {
+			continue
+		}
+		if allowed != nil && !allowed[col] {
+			continue
+		}
+		values = append(values, named{Name: col, Value: cellValue(obj.cols[col])})
+	}
+
+	return list{values}, tcg.SUCCESS
+}
+
+// cellValue converts a stored cell into its wire representation.
+func cellValue(v any) any {
+	switch x := v.(type) {
+	case []uint64:
+		out := list{}
+		for _, item := range x {
+			out = append(out, item)
+		}
+		return out
+	case booleanExpr:
+		out := list{}
+		for i, authority := range x {
+			out = append(out, named{Name: tcg.HALF_UID_AUTHORITY_OBJ_REF[:4], Value: authority})
+			if i > 0 {
+				out = append(out, named{Name: tcg.HALF_UID_BOOLEAN_ACE[:4], Value: booleanOr})
+			}
+		}
+		return out
+	case postfixExpr:
+		out := list{}
+		for _, term := range x {
+			if authority, ok := term.(tcg.OpalUID); ok {
+				out = append(out, named{Name: tcg.HALF_UID_AUTHORITY_OBJ_REF[:4], Value: authority})
+			} else {
+				out = append(out, named{Name: tcg.HALF_UID_BOOLEAN_ACE[:4], Value: term})
+			}
+		}
+		return out
+	}
+	return v
+}
+
+func sortUint64(v []uint64) {
+	for i := 1; i < len(v); i++ {
+		for j := i; j > 0 && v[j] < v[j-1]; j-- {
+			v[j], v[j-1] = v[j-1], v[j]
+		}
+	}
+}
+
+// methodNext returns the rows of an object table following Where, in UID order.
+func (t *TPer) methodNext(sess *session, call *methodCall) (list, tcg.MethodStatus) {
+	if _, ok := sess.checkACL(call.Invoking, call.Method); !ok {
+		return nil, tcg.NOT_AUTHORIZED
+	}
+
+	tbl, ok := sess.sp.tables[tableOf(call.Invoking)]
+	if !ok || tbl.isBytes || rowOf(call.Invoking) != 0 {
+		return nil, tcg.INVALID_PARAMETER
+	}
+
+	var args list
+	if len(call.Args) > 0 {
+		args, _ = call.Args[0].(list)
+	}
+	var where tcg.OpalUID
+	if v, ok := args.namedArg(0, "Where"); ok {
+		if where, ok = asUID(v); !ok {
+			return nil, tcg.INVALID_PARAMETER
+		}
+	}
+	count := ^uint64(0)
+	if v, ok := args.namedArg(1, "Count"); ok {
+		if count, ok = asUint(v); !ok {
+			return nil, tcg.INVALID_PARAMETER
+		}
+	}
+
+	uids := make([]tcg.OpalUID, 0, len(tbl.rows))
+	for uid := range tbl.rows {
+		if bytes.Compare(uid[:], where[:]) > 0 {
+			uids = append(uids, uid)
+		}
+	}
+	for i := 1; i < len(uids); i++ {
+		for j := i; j > 0 && bytes.Compare(uids[j][:], uids[j-1][:]) < 0; j-- {
+			uids[j], uids[j-1] = uids[j-1], uids[j]
+		}
+	}
+
+	rows := list{}
+	for _, uid := range uids {
+		if uint64(len(rows)) >= count {
+			break
+		}
+		rows = append(rows, uid)
+	}
+	return list{rows}, tcg.SUCCESS
+}
+
+// methodGetACL returns the ACEs of the AccessControl entry of the InvokingID and MethodID arguments.
+func (t *TPer) methodGetACL(sess *session, call *methodCall) (list, tcg.MethodStatus) {
+	if call.Invoking != makeUID(tableAccessControl, 0) || len(call.Args) < 2 {
+		return nil, tcg.INVALID_PARAMETER
+	}
+	if _, ok := sess.checkACL(call.Invoking, call.Method); !ok {
+		return nil, tcg.NOT_AUTHORIZED
+	}
+
+	invoking, ok := asUID(call.Args[0])
+	if !ok {
+		return nil, tcg.INVALID_PARAMETER
+	}
+	method, ok := asUID(call.Args[1])
+	if !ok {
+		return nil, tcg.INVALID_PARAMETER
+	}
+
+	aces := list{}
+	for _, ace := range sess.sp.aces(invoking, tcg.OpalMethod(method)) {
+		aces = append(aces, ace)
+	}
+	return list{aces}, tcg.SUCCESS
+}
+
+func (t *TPer) methodSet(sess *session, call *methodCall) (list, tcg.MethodStatus) {
+	if !sess.write {
+		return nil, tcg.NOT_AUTHORIZED
+	}
+
+	allowed, ok := sess.checkACL(call.Invoking, call.Method)
+	if !ok {
+		return nil, tcg.NOT_AUTHORIZED
+	}
+
+	values, ok := call.Args.namedArg(1, "Values")
+	if !ok {
+		return nil, tcg.INVALID_PARAMETER
+	}
+
+	if tbl, ok := sess.sp.tables[tableOf(call.Invoking)]; ok && tbl.isBytes && rowOf(call.Invoking) == 0 {
+		data, ok := values.([]byte)
+		if !ok {
+			return nil, tcg.INVALID_PARAMETER
+		}
+		var where uint64
+		if v, ok := call.Args.namedArg(0, "Where"); ok {
+			if where, ok = asUint(v); !ok {
+				return nil, tcg.INVALID_PARAMETER
+			}
+		}
+		if where+uint64(len(data)) > uint64(len(tbl.data)) {
+			return nil, tcg.INVALID_PARAMETER
+		}
+		copy(tbl.data[where:], data)
+		return list{}, tcg.SUCCESS
+	}
+
+	obj := sess.sp.object(call.Invoking)
+	if obj == nil {
+		return nil, tcg.INVALID_PARAMETER
+	}
+
+	cells, ok := values.(list)
+	if !ok {
+		return nil, tcg.INVALID_PARAMETER
+	}
+
+	updates := make(map[uint64]any)
+	for _, item := range cells {
+		cell, ok := item.(named)
+		if !ok {
+			return nil, tcg.INVALID_PARAMETER
+		}
+		col, ok := asUint(cell.Name)
+		if !ok {
+			return nil, tcg.INVALID_PARAMETER
+		}
+		if _, exists := obj.cols[col]; !exists {
+			return nil, tcg.INVALID_PARAMETER
+		}
+		if allowed != nil && !allowed[col] {
+			return nil, tcg.NOT_AUTHORIZED
+		}
+		value, ok := storeValue(obj.cols[col], cell.Value)
+		if !ok {
+			return nil, tcg.INVALID_PARAMETER
+		}
+		updates[col] = value
+	}
+
+	for col, value := range updates {
+		obj.cols[col] = value
+	}
+	return list{}, tcg.SUCCESS
+}
+
+// storeValue converts a wire value into the representation of the existing cell.
+func storeValue(current any, v any) (any, bool) {
+	switch current.(type) {
+	case uint64:
+		return asUint(v)
+	case []byte:
+		b, ok := v.([]byte)
+		return append([]byte{}, b...), ok
+	case tcg.OpalUID:
+		return asUID(v)
+	case []uint64:
+		items, ok := v.(list)
+		if !ok {
+			return nil, false
+		}
+		out := []uint64{}
+		for _, item := range items {
+			n, ok := asUint(item)
+			if !ok {
+				return nil, false
+			}
+			out = append(out, n)
+		}
+		return out, true
+	case booleanExpr, postfixExpr:
+		return asBooleanExpr(v)
+	}
+	return nil, false
+}
+
+// asBooleanExpr decodes a postfix BooleanExpr, a booleanExpr when it only ORs authorities and a postfixExpr otherwise.
+func asBooleanExpr(v any) (any, bool) {
+	items, ok := v.(list)
+	if !ok {
+		return nil, false
+	}
+	expr := booleanExpr{}
+	postfix := postfixExpr{}
+	anyOf := true
+	depth := 0
+	for _, item := range items {
+		term, ok := item.(named)
+		if !ok {
+			return nil, false
+		}
+		name, _ := term.Name.([]byte)
+		switch {
+		case bytes.Equal(name, tcg.HALF_UID_AUTHORITY_OBJ_REF[:4]):
+			authority, ok := asUID(term.Value)
+			if !ok {
+				return nil, false
+			}
+			expr = append(expr, authority)
+			postfix = append(postfix, authority)
+			depth++
+		case bytes.Equal(name, tcg.HALF_UID_BOOLEAN_ACE[:4]):
+			op, ok := asUint(term.Value)
+			if !ok || op > booleanNot || (op == booleanNot && depth < 1) || (op != booleanNot && depth < 2) {
+				return nil, false
+			}
+			if op != booleanNot {
+				depth--
+			}
+			anyOf = anyOf && op == booleanOr
+			postfix = append(postfix, op)
+		default:
+			return nil, false
+		}
+	}
+	if depth > 1 {
+		return nil, false
+	}
+	if anyOf {
+		return expr, true
+	}
+	return postfix, true
+}
+
+func (t *TPer) methodRevert(sess *session, target tcg.OpalUID) (list, tcg.MethodStatus) {
+	switch target {
+	case tcg.ADMINSP_UID:
+		t.factoryReset()
+	case tcg.LOCKINGSP_UID:
+		if !t.isLockingSPActive() {
+			return nil, tcg.INVALID_PARAMETER
+		}
+		t.revertLockingSP()
+	default:
+		return nil, tcg.INVALID_PARAMETER
+	}
+
+	// the session is aborted once the response has been sent
+	delete(t.sessions, sess.tsn)
+
+	return list{}, tcg.SUCCESS
+}
@@ -0,0 +1,132 @@
+package tcg_sim
+
+import (
+	"encoding/binary"
+
+	"github.com/jc-lab/go-dparm/tcg"
+)
+
+func makeUID(table, row uint32) tcg.OpalUID {
+	var uid tcg.OpalUID
+	binary.BigEndian.PutUint32(uid[0:], table)
+	binary.BigEndian.PutUint32(uid[4:], row)
+	return uid
+}
+
+func tableOf(uid tcg.OpalUID) uint32 {
+	return binary.BigEndian.Uint32(uid[0:])
+}
+
+func rowOf(uid tcg.OpalUID) uint32 {
+	return binary.BigEndian.Uint32(uid[4:])
+}
+
+// Table half UIDs, TCG Storage Architecture Core Spec 5.1.4
+const (
+	tableTable         uint32 = 0x00000001
+	tableSPInfo        uint32 = 0x00000002
+	tableColumn        uint32 = 0x00000004
+	tableMethodID      uint32 = 0x00000006
+	tableAccessControl uint32 = 0x00000007
+	tableACE           uint32 = 0x00000008
+	tableAuthority     uint32 = 0x00000009
+	tableCPIN          uint32 = 0x0000000B
+	tableTPerInfo      uint32 = 0x00000201
+	tableSP            uint32 = 0x00000205
+	tableLockingInfo   uint32 = 0x00000801
+	tableLocking       uint32 = 0x00000802
+	tableMBRControl    uint32 = 0x00000803
+	tableMBR           uint32 = 0x00000804
+	tableKAES128       uint32 = 0x00000805
+	tableKAES256       uint32 = 0x00000806
+
+	tableDataStore            uint32 = 0x00001001
+	tableDataRemovalMechanism uint32 = 0x00001101
+)
+
+// Column numbers used by the simulator
+const (
+	colAuthorityIsClass    uint64 = 3
+	colAuthorityClass      uint64 = 4
+	colAuthorityEnabled    uint64 = 5
+	colAuthorityCredential uint64 = 10
+
+	colCPinPIN         = uint64(tcg.CREDENTIAL_PIN)
+	colCPinTryLimit    = uint64(tcg.CREDENTIAL_TRY_LIMIT)
+	colCPinTries       = uint64(tcg.CREDENTIAL_TRIES)
+	colCPinPersistence = uint64(tcg.CREDENTIAL_PERSISTENCE)
+
+	colSPLifeCycle uint64 = 6
+
+	colTableKind uint64 = 4
+	colTableRows uint64 = 7
+
+	colACEBooleanExpr uint64 = 3
+	colACEColumns     uint64 = 4
+
+	colMBRControlEnable uint64 = uint64(tcg.MBRENABLE)
+	colMBRControlDone   uint64 = uint64(tcg.MBRDONE)
+)
+
+// SP life cycle states, TCG Storage Opal SSC 5.2.2
+const (
+	lifeCycleManufacturedInactive uint64 = 0x08
+	lifeCycleManufactured         uint64 = 0x09
+)
+
+// row is one object of an object table, cells are keyed by column number.
+type row struct {
+	uid  tcg.OpalUID
+	cols map[uint64]any
+}
+
+func (r *row) uint(col uint64) uint64 {
+	v, _ := asUint(r.cols[col])
+	return v
+}
+
+func (r *row) bool(col uint64) bool {
+	return r.uint(col) != 0
+}
+
+func (r *row) bytes(col uint64) []byte {
+	v, _ := r.cols[col].([]byte)
+	return v
+}
+
+// table is either an object table (rows) or a byte table (data).
+type table struct {
+	uid     uint32
+	name    string
+	rows    map[tcg.OpalUID]*row
+	isBytes bool
+	data    []byte
+}
+
+func newObjectTable(uid uint32, name string) *table {
+	return &table{
+		uid:  uid,
+		name: name,
+		rows: make(map[tcg.OpalUID]*row),
+	}
+}
+
+func newByteTable(uid uint32, name string, size int) *table {
+	return &table{
+		uid:     uid,
+		name:    name,
+		isBytes: true,
+		data:    make([]byte, size),
+	}
+}
+
+func (t *table) add(uid tcg.OpalUID, cols map[uint64]any) *row {
+	r := &row{uid: uid, cols: cols}
+	t.rows[uid] = r
+	return r
+}
+
+type aclKey struct {
+	invoking tcg.OpalUID
+	method   tcg.OpalMethod
+}
@@ -0,0 +1,329 @@
+package tcg_sim
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/jc-lab/go-dparm/tcg"
+)
+
+var (
+	errTruncated    = errors.New("truncated token stream")
+	errUnexpected   = errors.New("unexpected token")
+	errUnbalanced   = errors.New("unbalanced list or name")
+	errNotCallToken = errors.New("method call expected")
+)
+
+// list is a decoded STARTLIST ... ENDLIST sequence.
+type list []any
+
+// named is a decoded STARTNAME name value ENDNAME sequence.
+type named struct {
+	Name  any
+	Value any
+}
+
+// control is a decoded control token such as CALL or ENDOFDATA.
+type control tcg.OpalToken
+
+// decodeTokens splits a subpacket payload into a flat list of values.
+// Atoms are returned as uint64, int64 or []byte, everything else as control.
+func decodeTokens(buf []byte) ([]any, error) {
+	var out []any
+
+	for len(buf) > 0 {
+		head := buf[0]
+
+		var hdrLen, dataLen int
+		var isBytes, isSigned bool
+
+		switch {
+		case head&0x80 == 0:
+			// tiny atom
+			if head&0x40 != 0 {
+				v := int64(head & 0x3f)
+				if v&0x20 != 0 {
+					v -= 0x40
+				}
+				out = append(out, v)
+			} else {
+				out = append(out, uint64(head&0x3f))
+			}
+			buf = buf[1:]
+			continue
+		case head&0x40 == 0:
+			// short atom
+			hdrLen, dataLen = 1, int(head&0x0f)
+			isBytes, isSigned = head&0x20 != 0, head&0x10 != 0
+		case head&0x20 == 0:
+			// medium atom
+			if len(buf) < 2 {
+				return nil, errTruncated
+			}
+			hdrLen, dataLen = 2, int(head&0x07)<<8|int(buf[1])
+			isBytes, isSigned = head&0x10 != 0, head&0x08 != 0
+		case head&0x10 == 0:
+			// long atom
+			if len(buf) < 4 {
+				return nil, errTruncated
+			}
+			hdrLen, dataLen = 4, int(buf[1])<<16|int(buf[2])<<8|int(buf[3])
+			isBytes, isSigned = head&0x02 != 0, head&0x01 != 0
+		default:
+			if tcg.OpalToken(head) != tcg.EMPTYATOM {
+				out = append(out, control(head))
+			}
+			buf = buf[1:]
+			continue
+		}
+
+		if len(buf) < hdrLen+dataLen {
+			return nil, errTruncated
+		}
+		data := buf[hdrLen : hdrLen+dataLen]
+		buf = buf[hdrLen+dataLen:]
+
+		switch {
+		case isBytes:
+			out = append(out, append([]byte{}, data...))
+		case isSigned:
+			var v int64
+			for i, b := range data {
+				if i == 0 && b&0x80 != 0 {
+					v = -1
+				}
+				v = v<<8 | int64(b)
+			}
+			out = append(out, v)
+		default:
+			var v uint64
+			for _, b := range data {
+				v = v<<8 | uint64(b)
+			}
+			out = append(out, v)
+		}
+	}
+
+	return out, nil
+}
+
+// buildTree folds STARTLIST/STARTNAME sequences of a flat token list into list and named values.
+func buildTree(tokens []any) ([]any, error) {
+	out, rest, err := buildUntil(tokens, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errUnbalanced
+	}
+	return out, nil
+}
+
+func buildUntil(tokens []any, end tcg.OpalToken) ([]any, []any, error) {
+	var out []any
+
+	for len(tokens) > 0 {
+		tok := tokens[0]
+		tokens = tokens[1:]
+
+		c, ok := tok.(control)
+		if !ok {
+			out = append(out, tok)
+			continue
+		}
+
+		switch tcg.OpalToken(c) {
+		case tcg.STARTLIST:
+			items, rest, err := buildUntil(tokens, tcg.ENDLIST)
+			if err != nil {
+				return nil, nil, err
+			}
+			out = append(out, list(items))
+			tokens = rest
+		case tcg.STARTNAME:
+			items, rest, err := buildUntil(tokens, tcg.ENDNAME)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(items) != 2 {
+				return nil, nil, errUnbalanced
+			}
+			out = append(out, named{Name: items[0], Value: items[1]})
+			tokens = rest
+		case tcg.ENDLIST, tcg.ENDNAME:
+			if end != tcg.OpalToken(c) {
+				return nil, nil, errUnbalanced
+			}
+			return out, tokens, nil
+		default:
+			out = append(out, c)
+		}
+	}
+
+	if end != 0 {
+		return nil, nil, errUnbalanced
+	}
+	return out, nil, nil
+}
+
+// methodCall is a decoded CALL invokingUID methodUID [ args ] ENDOFDATA [ status ].
+type methodCall struct {
+	Invoking tcg.OpalUID
+	Method   tcg.OpalMethod
+	Args     list
+}
+
+func parseMethodCall(items []any) (*methodCall, error) {
+	if len(items) < 4 {
+		return nil, errNotCallToken
+	}
+	if c, ok := items[0].(control); !ok || tcg.OpalToken(c) != tcg.CALL {
+		return nil, errNotCallToken
+	}
+
+	invoking, ok := items[1].([]byte)
+	if !ok || len(invoking) != 8 {
+		return nil, errUnexpected
+	}
+	method, ok := items[2].([]byte)
+	if !ok || len(method) != 8 {
+		return nil, errUnexpected
+	}
+	args, ok := items[3].(list)
+	if !ok {
+		return nil, errUnexpected
+	}
+
+	call := &methodCall{Args: args}
+	copy(call.Invoking[:], invoking)
+	copy(call.Method[:], method)
+
+	return call, nil
+}
+
+// namedArg finds an optional parameter by its numeric or string name.
+func (l list) namedArg(id uint64, text string) (any, bool) {
+	for _, item := range l {
+		n, ok := item.(named)
+		if !ok {
+			continue
+		}
+		switch name := n.Name.(type) {
+		case uint64:
+			if name == id {
+				return n.Value, true
+			}
+		case []byte:
+			if text != "" && string(name) == text {
+				return n.Value, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// encoder writes tokens of a method response.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) token(t tcg.OpalToken) {
+	e.buf = append(e.buf, uint8(t))
+}
+
+func (e *encoder) uint(v uint64) {
+	if v < 64 {
+		e.buf = append(e.buf, uint8(v))
+		return
+	}
+
+	var tmp [8]byte
+	binary.BigEndian.PutUint64(tmp[:], v)
+	n := 0
+	for n < 7 && tmp[n] == 0 {
+		n++
+	}
+	e.buf = append(e.buf, 0x80|uint8(8-n))
+	e.buf = append(e.buf, tmp[n:]...)
+}
+
+func (e *encoder) bytes(b []byte) {
+	switch {
+	case len(b) < 16:
+		e.buf = append(e.buf, 0xa0|uint8(len(b)))
+	case len(b) < 2048:
+		e.buf = append(e.buf, 0xd0|uint8(len(b)>>8), uint8(len(b)))
+	default:
+		e.buf = append(e.buf, 0xe2, uint8(len(b)>>16), uint8(len(b)>>8), uint8(len(b)))
+	}
+	e.buf = append(e.buf, b...)
+}
+
+// value encodes uint64, int, bool, []byte, string, tcg.OpalUID, list and named values.
+func (e *encoder) value(v any) {
+	switch x := v.(type) {
+	case uint64:
+		e.uint(x)
+	case int:
+		e.uint(uint64(x))
+	case bool:
+		if x {
+			e.uint(1)
+		} else {
+			e.uint(0)
+		}
+	case []byte:
+		e.bytes(x)
+	case string:
+		e.bytes([]byte(x))
+	case tcg.OpalUID:
+		e.bytes(x[:])
+	case tcg.OpalMethod:
+		e.bytes(x[:])
+	case list:
+		e.token(tcg.STARTLIST)
+		for _, item := range x {
+			e.value(item)
+		}
+		e.token(tcg.ENDLIST)
+	case named:
+		e.token(tcg.STARTNAME)
+		e.value(x.Name)
+		e.value(x.Value)
+		e.token(tcg.ENDNAME)
+	case control:
+		e.token(tcg.OpalToken(x))
+	}
+}
+
+// status appends ENDOFDATA and the method status list.
+func (e *encoder) status(status tcg.MethodStatus) {
+	e.token(tcg.ENDOFDATA)
+	e.token(tcg.STARTLIST)
+	e.uint(uint64(status))
+	e.uint(0)
+	e.uint(0)
+	e.token(tcg.ENDLIST)
+}
+
+func asUint(v any) (uint64, bool) {
+	switch x := v.(type) {
+	case uint64:
+		return x, true
+	case int64:
+		if x >= 0 {
+			return uint64(x), true
+		}
+	}
+	return 0, false
+}
+
+func asUID(v any) (tcg.OpalUID, bool) {
+	var uid tcg.OpalUID
+	b, ok := v.([]byte)
+	if !ok || len(b) != 8 {
+		return uid, false
+	}
+	copy(uid[:], b)
+	return uid, true
+}
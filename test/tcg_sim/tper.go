@@ -0,0 +1,594 @@
+// Package tcg_sim is an in-memory TCG Storage TPer that answers IF-SEND/IF-RECV
+// like a self-encrypting drive, so the tcg package can be exercised without hardware.
+package tcg_sim
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	"github.com/jc-lab/go-dparm/tcg"
+)
+
+var (
+	ErrUnsupportedProtocol = errors.New("unsupported security protocol")
+	ErrInvalidComId        = errors.New("invalid comid")
+)
+
+const (
+	comPacketHeaderSize = 20
+	packetHeaderSize    = 24
+	subPacketHeaderSize = 12
+	headerSize          = comPacketHeaderSize + packetHeaderSize + subPacketHeaderSize
+)
+
+type SSC int
+
+const (
+	SSCOpal2 SSC = 0 + iota
+	SSCOpal1
+	SSCEnterprise
+	// SSCPyrite2 has the global range only, no media encryption and a DataRemovalMechanism table
+	SSCPyrite2
+	SSCRuby
+)
+
+type Config struct {
+	SSC    SSC
+	Serial string
+	MSID   string
+	PSID   string
+	// SID is a vendor unique initial SID PIN, empty means the SID PIN is the MSID
+	SID string
+	// VendorRevertSID reports a vendor unique C_PIN_SID after a revert, it is only reported and the revert still
+	// restores SID
+	VendorRevertSID bool
+
+	BaseComId   uint16
+	NumComIds   uint16
+	MaxSessions int
+	// MaxComPacketSize is the largest ComPacket the TPer accepts, larger ones are discarded
+	MaxComPacketSize int
+
+	NumLockingAdmins int
+	NumLockingUsers  int
+	// MaxRanges is the number of ranges besides the global range, the number of Enterprise bands besides band 0.
+	// It is ignored by Pyrite.
+	MaxRanges int
+	MBRSize   int
+	// DataStoreSize is the size of every DataStore table, DataStoreTables more than one advertises the Additional
+	// DataStore Tables feature
+	DataStoreSize   int
+	DataStoreTables int
+
+	// TryLimit is applied to every C_PIN except MSID, zero means unlimited
+	TryLimit uint64
+	// PublicTryLimits grants Anybody Get of TryLimit, Tries and Persistence of every C_PIN, as some drives do
+	PublicTryLimits bool
+
+	// LockingSPActive starts the drive with an activated Locking SP whose Admin1 PIN is the MSID
+	LockingSPActive bool
+
+	// SingleUser advertises the Single User Mode feature set, Activate and Reactivate accept the Single User Mode
+	// parameters
+	SingleUser bool
+
+	// ComIdManagement advertises ComID management in the TPer feature and issues dynamic ComIDs with GET_COMID
+	ComIdManagement bool
+
+	// BlockSID advertises the Block SID Authentication feature and accepts the Block SID command
+	BlockSID bool
+}
+
+func DefaultConfig() Config {
+	return Config{
+		SSC:              SSCOpal2,
+		Serial:           "SIMTPER000000001",
+		MSID:             "MSIDMSIDMSIDMSIDMSIDMSIDMSIDMSID",
+		PSID:             "PSIDPSIDPSIDPSIDPSIDPSIDPSIDPSID",
+		BaseComId:        0x07fe,
+		NumComIds:        1,
+		MaxSessions:      1,
+		MaxComPacketSize: 2048,
+		NumLockingAdmins: 4,
+		NumLockingUsers:  8,
+		MaxRanges:        8,
+		MBRSize:          0x400000,
+		DataStoreSize:    0x20000,
+		DataStoreTables:  1,
+	}
+}
+
+// TPer implements tcg.DriveCommandHandler
+type TPer struct {
+	mu     sync.Mutex
+	config Config
+
+	adminSP   *sp
+	lockingSP *sp
+
+	sessions map[uint32]*session
+	nextTsn  uint32
+	keySeq   uint64
+
+	pending map[uint16][]byte
+
+	hostProperties map[string]uint64
+
+	singleUser *singleUserMode
+
+	dynamicComIds map[uint16]bool
+	nextComId     uint16
+	comIdPending  map[uint16][]byte
+
+	// sidBlocked fails every authentication as SID, a hardware reset lifts it when sidBlockedHardwareReset is set
+	sidBlocked              bool
+	sidBlockedHardwareReset bool
+}
+
+func NewTPer(config Config) *TPer {
+	if config.SSC == SSCPyrite2 {
+		config.MaxRanges = 0
+	}
+
+	t := &TPer{
+		config:   config,
+		sessions: make(map[uint32]*session),
+		nextTsn:  0x1000,
+		pending:  make(map[uint16][]byte),
+
+		dynamicComIds: make(map[uint16]bool),
+		nextComId:     firstDynamicComId,
+		comIdPending:  make(map[uint16][]byte),
+	}
+	t.factoryReset()
+
+	if config.LockingSPActive && config.SSC != SSCEnterprise {
+		t.activateLockingSP()
+	}
+
+	return t
+}
+
+func (t *TPer) GetConfig() Config {
+	return t.config
+}
+
+// PowerCycle drops every session, lifts a Block SID and applies the LockOnReset/DoneOnReset settings.
+func (t *TPer) PowerCycle() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.sidBlocked = false
+	t.reset()
+}
+
+// reset drops every session and applies the LockOnReset/DoneOnReset settings.
+func (t *TPer) reset() {
+	// a power cycle aborts the transactions of the sessions
+	for _, sess := range t.sessions {
+		sess.abortTransaction()
+	}
+	t.sessions = make(map[uint32]*session)
+	t.pending = make(map[uint16][]byte)
+	t.hostProperties = nil
+	t.dynamicComIds = make(map[uint16]bool)
+	t.comIdPending = make(map[uint16][]byte)
+
+	for _, s := range []*sp{t.adminSP, t.lockingSP} {
+		if cpins, ok := s.tables[tableCPIN]; ok {
+			for _, r := range cpins.rows {
+				if !r.bool(colCPinPersistence) {
+					r.cols[colCPinTries] = uint64(0)
+				}
+			}
+		}
+	}
+
+	if !t.isLockingSPActive() {
+		return
+	}
+
+	for _, r := range t.lockingSP.tables[tableLocking].rows {
+		if resetContains(r.cols[uint64(tcg.LOCKING_LOCK_ON_RESET)], 0) {
+			if r.bool(uint64(tcg.LOCKING_READ_LOCK_ENABLED)) {
+				r.cols[uint64(tcg.LOCKING_READ_LOCKED)] = uint64(1)
+			}
+			if r.bool(uint64(tcg.LOCKING_WRITE_LOCK_ENABLED)) {
+				r.cols[uint64(tcg.LOCKING_WRITE_LOCKED)] = uint64(1)
+			}
+		}
+	}
+
+	if mbrControl, ok := t.lockingSP.tables[tableMBRControl]; ok {
+		for _, r := range mbrControl.rows {
+			if resetContains(r.cols[3], 0) {
+				r.cols[colMBRControlDone] = uint64(0)
+			}
+		}
+	}
+}
+
+func resetContains(v any, resetType uint64) bool {
+	types, _ := v.([]uint64)
+	for _, item := range types {
+		if item == resetType {
+			return true
+		}
+	}
+	return false
+}
+
+// HostProperties returns the host properties of the last Properties call, nil before the first one.
+func (t *TPer) HostProperties() map[string]uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.hostProperties
+}
+
+// RangeKey returns the media encryption key of a locking range or an Enterprise band, nil when there is none.
+func (t *TPer) RangeKey(index int) []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	uid := lockingRangeUID(index)
+	if t.config.SSC == SSCEnterprise {
+		uid = bandUID(index)
+	}
+	r := t.lockingSP.object(uid)
+	if r == nil {
+		return nil
+	}
+	keyUID, ok := r.cols[uint64(tcg.LOCKING_ACTIVE_KEY)].(tcg.OpalUID)
+	if !ok {
+		return nil
+	}
+	key := t.lockingSP.object(keyUID)
+	if key == nil {
+		return nil
+	}
+	return append([]byte{}, key.bytes(3)...)
+}
+
+// PIN returns the PIN of a C_PIN object of the Admin SP or the Locking SP, nil when there is none.
+func (t *TPer) PIN(cpin tcg.OpalUID) []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, s := range []*sp{t.adminSP, t.lockingSP} {
+		if s == nil {
+			continue
+		}
+		if obj := s.object(cpin); obj != nil {
+			return append([]byte{}, obj.bytes(colCPinPIN)...)
+		}
+	}
+	return nil
+}
+
+// OpenSessions returns the number of sessions currently open on the TPer.
+func (t *TPer) OpenSessions() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.sessions)
+}
+
+func (t *TPer) GetTcgLevel0InfoAndSerial() (tcg.TcgLevel0Info, string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	buffer := make([]byte, tcg.MIN_BUFFER_LENGTH)
+	t.discovery0(buffer)
+	discovery, err := tcg.ParseLevel0Discovery(buffer)
+	if err != nil {
+		return tcg.TcgLevel0Info{}, t.config.Serial
+	}
+
+	return discovery.Level0Info(), t.config.Serial
+}
+
+func (t *TPer) SecurityCommand(rw bool, dma bool, protocol uint8, comId uint16, buffer []byte, timeoutSecs int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch protocol {
+	case 0x01:
+		if comId == 0x0001 {
+			if rw {
+				return ErrInvalidComId
+			}
+			t.discovery0(buffer)
+			return nil
+		}
+		if !t.isValidComId(comId) {
+			return ErrInvalidComId
+		}
+		if rw {
+			t.ifSend(comId, buffer)
+		} else {
+			t.ifRecv(comId, buffer)
+		}
+		return nil
+	case 0x02:
+		if comId == blockSIDComId && t.config.BlockSID {
+			if !rw {
+				return ErrInvalidComId
+			}
+			t.blockSID(buffer)
+			return nil
+		}
+		if rw {
+			return t.comIdSend(comId, buffer)
+		}
+		return t.comIdRecv(comId, buffer)
+	}
+
+	return ErrUnsupportedProtocol
+}
+
+func (t *TPer) discovery0(buffer []byte) {
+	for i := range buffer {
+		buffer[i] = 0
+	}
+
+	out := make([]byte, 48)
+	for _, feature := range t.features() {
+		out = append(out, feature...)
+	}
+	binary.BigEndian.PutUint32(out[0:], uint32(len(out)-4))
+	binary.BigEndian.PutUint32(out[4:], 1)
+
+	copy(buffer, out)
+}
+
+func featureHeader(code tcg.FeatureCode, version uint8, length int) []byte {
+	out := make([]byte, 4+length)
+	binary.BigEndian.PutUint16(out[0:], uint16(code))
+	out[2] = version << 4
+	out[3] = uint8(length)
+	return out
+}
+
+func (t *TPer) features() [][]byte {
+	var out [][]byte
+
+	tper := featureHeader(tcg.FcTPer, 1, 12)
+	tper[4] = 0x11 // Sync, Streaming
+	if t.config.ComIdManagement {
+		tper[4] |= 0x40
+	}
+	out = append(out, tper)
+
+	locking := featureHeader(tcg.FcLocking, 1, 12)
+	locking[4] = 0x01 // LockingSupported
+	if t.config.SSC != SSCPyrite2 {
+		locking[4] |= 0x08 // MediaEncryption
+	}
+	if t.isLockingSPActive() {
+		locking[4] |= 0x02
+		if t.anyRangeLocked() {
+			locking[4] |= 0x04
+		}
+		if mbrControl := t.lockingSP.object(makeUID(tableMBRControl, 1)); mbrControl != nil {
+			if mbrControl.bool(colMBRControlEnable) {
+				locking[4] |= 0x10
+			}
+			if mbrControl.bool(colMBRControlDone) {
+				locking[4] |= 0x20
+			}
+		}
+	}
+	out = append(out, locking)
+
+	geometry := featureHeader(tcg.FcGeometryReporting, 1, 28)
+	geometry[4] = 0x01
+	binary.BigEndian.PutUint32(geometry[12:], 512)
+	binary.BigEndian.PutUint64(geometry[16:], 8)
+	out = append(out, geometry)
+
+	switch t.config.SSC {
+	case SSCOpal1:
+		opal := featureHeader(tcg.FcOpalSscV100, 1, 12)
+		binary.BigEndian.PutUint16(opal[4:], t.config.BaseComId)
+		binary.BigEndian.PutUint16(opal[6:], t.config.NumComIds)
+		out = append(out, opal)
+	case SSCOpal2:
+		opal := featureHeader(tcg.FcOpalSscV200, 1, 16)
+		binary.BigEndian.PutUint16(opal[4:], t.config.BaseComId)
+		binary.BigEndian.PutUint16(opal[6:], t.config.NumComIds)
+		binary.BigEndian.PutUint16(opal[9:], uint16(t.config.NumLockingAdmins))
+		binary.BigEndian.PutUint16(opal[11:], uint16(t.config.NumLockingUsers))
+		t.putCPinSIDIndicators(opal)
+		out = append(out, opal)
+	case SSCEnterprise:
+		enterprise := featureHeader(tcg.FcEnterprise, 1, 16)
+		binary.BigEndian.PutUint16(enterprise[4:], t.config.BaseComId)
+		binary.BigEndian.PutUint16(enterprise[6:], t.config.NumComIds)
+		out = append(out, enterprise)
+	case SSCPyrite2:
+		pyrite := featureHeader(tcg.FcPyriteV200, 1, 16)
+		binary.BigEndian.PutUint16(pyrite[4:], t.config.BaseComId)
+		binary.BigEndian.PutUint16(pyrite[6:], t.config.NumComIds)
+		t.putCPinSIDIndicators(pyrite)
+		out = append(out, pyrite)
+
+		dataRemoval := featureHeader(tcg.FcDataRemoval, 1, 32)
+		dataRemoval[6] = 1<<tcg.DataRemovalOverwrite | 1<<tcg.DataRemovalBlockErase
+		dataRemoval[7] = 1 << tcg.DataRemovalOverwrite
+		binary.BigEndian.PutUint16(dataRemoval[8:], 90)
+		binary.BigEndian.PutUint16(dataRemoval[10:], 15)
+		out = append(out, dataRemoval)
+	case SSCRuby:
+		ruby := featureHeader(tcg.FcRuby, 1, 16)
+		binary.BigEndian.PutUint16(ruby[4:], t.config.BaseComId)
+		binary.BigEndian.PutUint16(ruby[6:], t.config.NumComIds)
+		binary.BigEndian.PutUint16(ruby[9:], uint16(t.config.NumLockingAdmins))
+		binary.BigEndian.PutUint16(ruby[11:], uint16(t.config.NumLockingUsers))
+		t.putCPinSIDIndicators(ruby)
+		out = append(out, ruby)
+	}
+
+	if t.config.SingleUser {
+		out = append(out, t.singleUserFeature())
+	}
+	if t.config.BlockSID {
+		out = append(out, t.blockSIDFeature())
+	}
+	if t.config.DataStoreTables > 1 {
+		dataStore := featureHeader(tcg.FcDataStore, 1, 16)
+		binary.BigEndian.PutUint16(dataStore[6:], uint16(t.config.DataStoreTables))
+		binary.BigEndian.PutUint32(dataStore[8:], uint32(t.config.DataStoreTables*t.config.DataStoreSize))
+		binary.BigEndian.PutUint32(dataStore[12:], 1)
+		out = append(out, dataStore)
+	}
+
+	return out
+}
+
+// putCPinSIDIndicators reports a vendor unique initial C_PIN_SID, which a revert restores
+func (t *TPer) putCPinSIDIndicators(descriptor []byte) {
+	if t.config.SID != "" {
+		descriptor[13] = 0xff
+		descriptor[14] = 0xff
+	}
+	if t.config.VendorRevertSID {
+		descriptor[14] = 0xff
+	}
+}
+
+func (t *TPer) anyRangeLocked() bool {
+	for _, r := range t.lockingSP.tables[tableLocking].rows {
+		if r.bool(uint64(tcg.LOCKING_READ_LOCK_ENABLED)) && r.bool(uint64(tcg.LOCKING_READ_LOCKED)) {
+			return true
+		}
+		if r.bool(uint64(tcg.LOCKING_WRITE_LOCK_ENABLED)) && r.bool(uint64(tcg.LOCKING_WRITE_LOCKED)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *TPer) ifSend(comId uint16, buffer []byte) {
+	delete(t.pending, comId)
+
+	if len(buffer) < headerSize {
+		return
+	}
+
+	comPacketLen := comPacketHeaderSize + int(binary.BigEndian.Uint32(buffer[16:]))
+	if t.config.MaxComPacketSize != 0 && comPacketLen > t.config.MaxComPacketSize {
+		return
+	}
+
+	tsn := binary.BigEndian.Uint32(buffer[20:])
+	hsn := binary.BigEndian.Uint32(buffer[24:])
+	payloadLen := int(binary.BigEndian.Uint32(buffer[52:]))
+	if headerSize+payloadLen > len(buffer) {
+		return
+	}
+
+	payload := t.handlePayload(comId, tsn, hsn, buffer[headerSize:headerSize+payloadLen])
+	if payload == nil {
+		return
+	}
+
+	padded := (len(payload) + 3) &^ 3
+	resp := make([]byte, headerSize+padded)
+	binary.BigEndian.PutUint16(resp[4:], comId)
+	binary.BigEndian.PutUint32(resp[16:], uint32(len(resp)-comPacketHeaderSize))
+	binary.BigEndian.PutUint32(resp[20:], tsn)
+	binary.BigEndian.PutUint32(resp[24:], hsn)
+	binary.BigEndian.PutUint32(resp[40:], uint32(subPacketHeaderSize+padded))
+	binary.BigEndian.PutUint32(resp[52:], uint32(len(payload)))
+	copy(resp[headerSize:], payload)
+
+	t.pending[comId] = resp
+}
+
+func (t *TPer) ifRecv(comId uint16, buffer []byte) {
+	for i := range buffer {
+		buffer[i] = 0
+	}
+	if len(buffer) < comPacketHeaderSize {
+		return
+	}
+	binary.BigEndian.PutUint16(buffer[4:], comId)
+
+	resp, ok := t.pending[comId]
+	if !ok {
+		return
+	}
+	if len(resp) > len(buffer) {
+		// the host has to retry with a buffer of at least MinTransfer bytes
+		binary.BigEndian.PutUint32(buffer[12:], uint32(len(resp)))
+		return
+	}
+
+	copy(buffer, resp)
+	delete(t.pending, comId)
+}
+
+func (t *TPer) handlePayload(comId uint16, tsn, hsn uint32, payload []byte) []byte {
+	tokens, err := decodeTokens(payload)
+	if err != nil || len(tokens) == 0 {
+		return nil
+	}
+
+	if c, ok := tokens[0].(control); ok && tcg.OpalToken(c) == tcg.ENDOFSESSION {
+		sess, ok := t.sessions[tsn]
+		if !ok || sess.hsn != hsn {
+			return nil
+		}
+		// ending the session aborts its transaction
+		sess.abortTransaction()
+		delete(t.sessions, tsn)
+		return []byte{uint8(tcg.ENDOFSESSION)}
+	}
+	if c, ok := tokens[0].(control); ok && (tcg.OpalToken(c) == tcg.STARTTRANSACTON || tcg.OpalToken(c) == tcg.ENDTRANSACTON) {
+		sess, ok := t.sessions[tsn]
+		if !ok || sess.hsn != hsn {
+			return nil
+		}
+		return t.transactionControl(sess, tcg.OpalToken(c), tokens[1:])
+	}
+
+	items, err := buildTree(tokens)
+	if err != nil {
+		return failure(tcg.INVALID_PARAMETER)
+	}
+	call, err := parseMethodCall(items)
+	if err != nil {
+		return failure(tcg.INVALID_PARAMETER)
+	}
+
+	if tsn == 0 && hsn == 0 {
+		if call.Invoking != tcg.SMUID_UID {
+			return failure(tcg.INVALID_PARAMETER)
+		}
+		return t.sessionManagerCall(comId, call)
+	}
+
+	sess, ok := t.sessions[tsn]
+	if !ok || sess.hsn != hsn {
+		return nil
+	}
+
+	result, status := t.sessionCall(sess, call)
+
+	enc := &encoder{}
+	if status == tcg.SUCCESS {
+		enc.value(result)
+	} else {
+		enc.value(list{})
+	}
+	enc.status(status)
+
+	return enc.buf
+}
+
+func failure(status tcg.MethodStatus) []byte {
+	enc := &encoder{}
+	enc.value(list{})
+	enc.status(status)
+	return enc.buf
+}
@@ -0,0 +1,89 @@
+package tcg_sim
+
+import (
+	"github.com/jc-lab/go-dparm/tcg"
+)
+
+// Status codes of the transaction tokens, TCG Storage Architecture Core Spec 3.2.4.4
+const (
+	transactionOk     uint64 = 0x00
+	transactionFailed uint64 = 0x01
+)
+
+// transactionControl handles a StartTransaction or EndTransaction token and answers with the token and the status of
+// the transaction. A session has a single transaction, its MaxTransactionLimit is 1.
+func (t *TPer) transactionControl(sess *session, token tcg.OpalToken, args []any) []byte {
+	result := transactionOk
+	status, ok := uint64(0), len(args) > 0
+	if ok {
+		status, ok = asUint(args[0])
+	}
+
+	switch {
+	case !ok:
+		result = transactionFailed
+	case token == tcg.STARTTRANSACTON:
+		if sess.transaction != nil {
+			result = transactionFailed
+			break
+		}
+		sess.transaction = sess.sp.clone()
+	case sess.transaction == nil:
+		result = transactionFailed
+	case status == transactionOk:
+		sess.transaction = nil
+	default:
+		sess.abortTransaction()
+		result = transactionFailed
+	}
+
+	enc := &encoder{}
+	enc.token(token)
+	enc.uint(result)
+	return enc.buf
+}
+
+// abortTransaction restores the SP of the session to the start of the transaction. The failed tries of the C_PINs
+// are kept, an aborted transaction must not reset the TryLimit.
+func (s *session) abortTransaction() {
+	snapshot := s.transaction
+	if snapshot == nil {
+		return
+	}
+	s.transaction = nil
+
+	if cpins, ok := s.sp.tables[tableCPIN]; ok {
+		for uid, r := range cpins.rows {
+			tries, ok := r.cols[colCPinTries]
+			if saved := snapshot.object(uid); ok && saved != nil {
+				saved.cols[colCPinTries] = tries
+			}
+		}
+	}
+	*s.sp = *snapshot
+}
+
+// clone copies the tables and the ACL of the SP, cells are replaced rather than modified so they are shared
+func (s *sp) clone() *sp {
+	c := newSP(s.uid)
+	for uid, t := range s.tables {
+		ct := &table{uid: t.uid, name: t.name, isBytes: t.isBytes}
+		if t.isBytes {
+			ct.data = append([]byte{}, t.data...)
+		} else {
+			ct.rows = make(map[tcg.OpalUID]*row, len(t.rows))
+			for rowUID, r := range t.rows {
+				cols := make(map[uint64]any, len(r.cols))
+				for col, v := range r.cols {
+					cols[col] = v
+				}
+				ct.rows[rowUID] = &row{uid: r.uid, cols: cols}
+			}
+		}
+		c.tables[uid] = ct
+	}
+	for key, aces := range s.acl {
+		c.acl[key] = append([]tcg.OpalUID{}, aces...)
+	}
+	return c
+}